@@ -0,0 +1,314 @@
+// Package avalanche — анализ лавинного эффекта блочных шифров. Для каждой
+// выборки (случайные ключ и блок) по очереди инвертируется каждый бит
+// входа — открытого текста или ключа — и сравниваются состояния двух
+// шифрований после каждого раунда и шифртексты. Собираются:
+//
+//   - распределение расстояния Хэмминга между состояниями по раундам;
+//   - матрица SAC (строгий лавинный критерий): доля выборок, в которых
+//     инверсия входного бита i меняет выходной бит j, в идеале 0,5;
+//   - BIC (независимость выходных бит): коэффициент корреляции изменений
+//     выходных бит j и k при инверсии бита i, в идеале 0.
+//
+// Шифр подключается через интерфейс Cipher; отчет печатается и
+// сохраняется в CSV и JSON.
+package avalanche
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"strconv"
+)
+
+// Cipher шифр для анализа
+type Cipher interface {
+	Name() string
+	// BlockBits размер блока в битах
+	BlockBits() int
+	// KeyBits число анализируемых бит ключа, KeyBytes — длина ключа
+	KeyBits() int
+	KeyBytes() int
+	// FlipKeyBit инвертирует i-й анализируемый бит ключа
+	FlipKeyBit(key []byte, i int)
+	// EncryptTrace шифрует блок и возвращает шифртекст и состояния после
+	// каждого раунда (0 — до первого)
+	EncryptTrace(plain, key []byte) (out []byte, states [][]byte)
+}
+
+// RoundStats распределение расстояния Хэмминга после одного раунда
+type RoundStats struct {
+	Round     int     `json:"round"`
+	Mean      float64 `json:"mean"`
+	StdDev    float64 `json:"std_dev"`
+	Min       int     `json:"min"`
+	Max       int     `json:"max"`
+	Histogram []int   `json:"histogram"` // Histogram[d] — число пар на расстоянии d
+}
+
+// Stats результаты для одного вида входа (открытый текст или ключ)
+type Stats struct {
+	Input  string       `json:"input"`
+	Rounds []RoundStats `json:"rounds"`
+	// SAC[i][j] — вероятность изменения бита j шифртекста при инверсии бита i
+	SAC             [][]float64 `json:"sac"`
+	SACMean         float64     `json:"sac_mean"`
+	SACMaxDeviation float64     `json:"sac_max_deviation"`
+	// Модули коэффициентов корреляции изменений пар выходных бит
+	BICMaxCorrelation  float64 `json:"bic_max_correlation"`
+	BICMeanCorrelation float64 `json:"bic_mean_correlation"`
+}
+
+// Report отчет анализа одного шифра
+type Report struct {
+	Cipher    string `json:"cipher"`
+	BlockBits int    `json:"block_bits"`
+	Samples   int    `json:"samples"`
+	Plaintext Stats  `json:"plaintext"`
+	Key       Stats  `json:"key"`
+}
+
+// Run собирает статистику по samples случайным ключам и блокам
+func Run(c Cipher, samples int) (*Report, error) {
+	if samples < 2 {
+		return nil, fmt.Errorf("число выборок %d меньше 2", samples)
+	}
+	report := &Report{Cipher: c.Name(), BlockBits: c.BlockBits(), Samples: samples}
+	flipPlain := func(block []byte, i int) { block[i/8] ^= 0x80 >> (i % 8) }
+	var err error
+	if report.Plaintext, err = collect(c, samples, "plaintext", c.BlockBits(), flipPlain); err != nil {
+		return nil, err
+	}
+	if report.Key, err = collect(c, samples, "key", c.KeyBits(), c.FlipKeyBit); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// collect статистика для инверсий nIn бит входа input функцией flip
+func collect(c Cipher, samples int, input string, nIn int, flip func([]byte, int)) (Stats, error) {
+	nOut := c.BlockBits()
+	var hist [][]int // hist[раунд][расстояние]
+	sac := make([][]int, nIn)
+	joint := make([][]int32, nIn) // joint[i][j*nOut+k], j < k — одновременные изменения
+	for i := range sac {
+		sac[i] = make([]int, nOut)
+		joint[i] = make([]int32, nOut*nOut)
+	}
+
+	plain := make([]byte, nOut/8)
+	key := make([]byte, c.KeyBytes())
+	changed := make([]int, 0, nOut)
+	for s := 0; s < samples; s++ {
+		if _, err := rand.Read(plain); err != nil {
+			return Stats{}, err
+		}
+		if _, err := rand.Read(key); err != nil {
+			return Stats{}, err
+		}
+		out, states := c.EncryptTrace(plain, key)
+		if hist == nil {
+			hist = make([][]int, len(states))
+			for r := range hist {
+				hist[r] = make([]int, nOut+1)
+			}
+		}
+
+		for i := 0; i < nIn; i++ {
+			var out2 []byte
+			var states2 [][]byte
+			if input == "key" {
+				flip(key, i)
+				out2, states2 = c.EncryptTrace(plain, key)
+				flip(key, i)
+			} else {
+				flip(plain, i)
+				out2, states2 = c.EncryptTrace(plain, key)
+				flip(plain, i)
+			}
+			for r := range states {
+				hist[r][HammingDistance(states[r], states2[r])]++
+			}
+
+			changed = changed[:0]
+			for j := 0; j < nOut; j++ {
+				if (out[j/8]^out2[j/8])&(0x80>>(j%8)) != 0 {
+					changed = append(changed, j)
+					sac[i][j]++
+				}
+			}
+			for a, j := range changed {
+				row := joint[i][j*nOut:]
+				for _, k := range changed[a+1:] {
+					row[k]++
+				}
+			}
+		}
+	}
+
+	stats := Stats{Input: input}
+	for r, h := range hist {
+		stats.Rounds = append(stats.Rounds, histogramStats(r, h))
+	}
+
+	n := float64(samples)
+	stats.SAC = make([][]float64, nIn)
+	var sum, corrSum float64
+	var pairs int
+	for i := range sac {
+		stats.SAC[i] = make([]float64, nOut)
+		for j, count := range sac[i] {
+			p := float64(count) / n
+			stats.SAC[i][j] = p
+			sum += p
+			stats.SACMaxDeviation = max(stats.SACMaxDeviation, math.Abs(p-0.5))
+		}
+		for j := 0; j < nOut; j++ {
+			pj := stats.SAC[i][j]
+			for k := j + 1; k < nOut; k++ {
+				pk := stats.SAC[i][k]
+				v := pj * (1 - pj) * pk * (1 - pk)
+				if v == 0 {
+					continue // бит меняется всегда или никогда
+				}
+				corr := math.Abs(float64(joint[i][j*nOut+k])/n-pj*pk) / math.Sqrt(v)
+				stats.BICMaxCorrelation = max(stats.BICMaxCorrelation, corr)
+				corrSum += corr
+				pairs++
+			}
+		}
+	}
+	stats.SACMean = sum / float64(nIn*nOut)
+	if pairs > 0 {
+		stats.BICMeanCorrelation = corrSum / float64(pairs)
+	}
+	return stats, nil
+}
+
+// histogramStats среднее, стандартное отклонение и диапазон гистограммы
+func histogramStats(round int, h []int) RoundStats {
+	st := RoundStats{Round: round, Min: -1, Histogram: h}
+	var n, sum, sq float64
+	for d, count := range h {
+		if count == 0 {
+			continue
+		}
+		if st.Min < 0 {
+			st.Min = d
+		}
+		st.Max = d
+		n += float64(count)
+		sum += float64(d * count)
+		sq += float64(d * d * count)
+	}
+	st.Mean = sum / n
+	st.StdDev = math.Sqrt(max(sq/n-st.Mean*st.Mean, 0))
+	return st
+}
+
+// HammingDistance число различающихся бит a и b
+func HammingDistance(a, b []byte) int {
+	n := 0
+	for i := range a {
+		n += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return n
+}
+
+// Print выводит распределение по раундам и сводку SAC и BIC
+func Print(w io.Writer, r *Report) {
+	fmt.Fprintf(w, "%s, выборок: %d (идеал — %d из %d бит, SAC 0.5, корреляция 0)\n", r.Cipher, r.Samples, r.BlockBits/2, r.BlockBits)
+	for _, st := range []Stats{r.Plaintext, r.Key} {
+		name := "открытого текста"
+		if st.Input == "key" {
+			name = "ключа"
+		}
+		fmt.Fprintf(w, "\nИнверсия бита %s:\n", name)
+		fmt.Fprintf(w, "  %5s %8s %8s %5s %5s\n", "раунд", "среднее", "ст.откл", "мин", "макс")
+		for _, rs := range st.Rounds {
+			fmt.Fprintf(w, "  %5d %8.2f %8.2f %5d %5d\n", rs.Round, rs.Mean, rs.StdDev, rs.Min, rs.Max)
+		}
+		fmt.Fprintf(w, "  SAC: среднее %.4f, наибольшее отклонение от 0.5: %.4f\n", st.SACMean, st.SACMaxDeviation)
+		fmt.Fprintf(w, "  BIC: средняя |корреляция| %.4f, наибольшая %.4f\n", st.BICMeanCorrelation, st.BICMaxCorrelation)
+	}
+}
+
+// WriteJSON сохраняет отчет целиком
+func WriteJSON(path string, r *Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// WriteCSV сохраняет три таблицы: <prefix>_rounds.csv
+// (input, round, distance, count) и матрицы SAC <prefix>_sac_plaintext.csv
+// и <prefix>_sac_key.csv (строка — входной бит, столбец — выходной)
+func WriteCSV(prefix string, r *Report) error {
+	rows := [][]string{{"input", "round", "distance", "count"}}
+	for _, st := range []Stats{r.Plaintext, r.Key} {
+		for _, rs := range st.Rounds {
+			for d, count := range rs.Histogram {
+				rows = append(rows, []string{st.Input, strconv.Itoa(rs.Round), strconv.Itoa(d), strconv.Itoa(count)})
+			}
+		}
+	}
+	if err := writeCSV(prefix+"_rounds.csv", rows); err != nil {
+		return err
+	}
+	for _, st := range []Stats{r.Plaintext, r.Key} {
+		rows = rows[:0]
+		for _, line := range st.SAC {
+			row := make([]string, len(line))
+			for j, p := range line {
+				row[j] = strconv.FormatFloat(p, 'f', 4, 64)
+			}
+			rows = append(rows, row)
+		}
+		if err := writeCSV(prefix+"_sac_"+st.Input+".csv", rows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSV(path string, rows [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Analyze выполняет команду "analyze avalanche": собирает статистику,
+// печатает ее в w и, если заданы csvPrefix и jsonPath, сохраняет отчет
+func Analyze(w io.Writer, c Cipher, samples int, csvPrefix, jsonPath string) error {
+	report, err := Run(c, samples)
+	if err != nil {
+		return err
+	}
+	Print(w, report)
+	if csvPrefix != "" {
+		if err := WriteCSV(csvPrefix, report); err != nil {
+			return fmt.Errorf("ошибка записи CSV: %w", err)
+		}
+		fmt.Fprintf(w, "\nCSV: %s_rounds.csv, %s_sac_plaintext.csv, %s_sac_key.csv\n", csvPrefix, csvPrefix, csvPrefix)
+	}
+	if jsonPath != "" {
+		if err := WriteJSON(jsonPath, report); err != nil {
+			return fmt.Errorf("ошибка записи JSON: %w", err)
+		}
+		fmt.Fprintf(w, "JSON: %s\n", jsonPath)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package avalanche
+
+import (
+	"crypto/aes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// identityCipher не шифрует: шифртекст равен открытому тексту, ключ ни на
+// что не влияет. Состояния — блок до и после "раунда".
+type identityCipher struct{}
+
+func (identityCipher) Name() string   { return "identity" }
+func (identityCipher) BlockBits() int { return 16 }
+func (identityCipher) KeyBits() int   { return 8 }
+func (identityCipher) KeyBytes() int  { return 1 }
+
+func (identityCipher) FlipKeyBit(key []byte, i int) { key[0] ^= 0x80 >> i }
+
+func (identityCipher) EncryptTrace(plain, key []byte) ([]byte, [][]byte) {
+	out := append([]byte(nil), plain...)
+	return out, [][]byte{out, out}
+}
+
+// aesCipher AES-128 из crypto/aes без промежуточных раундов
+type aesCipher struct{}
+
+func (aesCipher) Name() string                 { return "AES-128" }
+func (aesCipher) BlockBits() int               { return 128 }
+func (aesCipher) KeyBits() int                 { return 128 }
+func (aesCipher) KeyBytes() int                { return 16 }
+func (aesCipher) FlipKeyBit(key []byte, i int) { key[i/8] ^= 0x80 >> (i % 8) }
+
+func (aesCipher) EncryptTrace(plain, key []byte) ([]byte, [][]byte) {
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	out := make([]byte, aes.BlockSize)
+	b.Encrypt(out, plain)
+	return out, [][]byte{append([]byte(nil), plain...), out}
+}
+
+// TestIdentity: у тождественного шифра инверсия бита i меняет только
+// выходной бит i, а инверсия бита ключа — ничего.
+func TestIdentity(t *testing.T) {
+	r, err := Run(identityCipher{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Cipher != "identity" || r.BlockBits != 16 || r.Samples != 10 {
+		t.Fatalf("заголовок отчета: %+v", r)
+	}
+	for _, rs := range r.Plaintext.Rounds {
+		if rs.Mean != 1 || rs.StdDev != 0 || rs.Min != 1 || rs.Max != 1 || rs.Histogram[1] != 10*16 {
+			t.Fatalf("раунд %d: %+v", rs.Round, rs)
+		}
+	}
+	for i, row := range r.Plaintext.SAC {
+		for j, p := range row {
+			if want := map[bool]float64{true: 1, false: 0}[i == j]; p != want {
+				t.Fatalf("SAC[%d][%d] = %f, ожидается %f", i, j, p, want)
+			}
+		}
+	}
+	if r.Plaintext.SACMean != 1.0/16 || r.Plaintext.SACMaxDeviation != 0.5 {
+		t.Fatalf("SAC: среднее %f, отклонение %f", r.Plaintext.SACMean, r.Plaintext.SACMaxDeviation)
+	}
+	if r.Plaintext.BICMaxCorrelation != 0 || r.Plaintext.BICMeanCorrelation != 0 {
+		t.Fatalf("BIC для битов, меняющихся всегда или никогда: %+v", r.Plaintext)
+	}
+	if len(r.Key.SAC) != 8 || r.Key.SACMean != 0 || r.Key.Rounds[1].Max != 0 {
+		t.Fatalf("ключ не влияет на шифрование, получено %+v", r.Key)
+	}
+}
+
+// TestAES: у полного AES меняется около половины бит, SAC близок к 0,5.
+func TestAES(t *testing.T) {
+	r, err := Run(aesCipher{}, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []Stats{r.Plaintext, r.Key} {
+		if len(st.Rounds) != 2 {
+			t.Fatalf("%s: %d состояний в отчете", st.Input, len(st.Rounds))
+		}
+		if mean := st.Rounds[1].Mean; math.Abs(mean-64) > 1 {
+			t.Fatalf("%s: меняется %.2f бит", st.Input, mean)
+		}
+		if math.Abs(st.SACMean-0.5) > 0.01 {
+			t.Fatalf("%s: среднее SAC %.4f", st.Input, st.SACMean)
+		}
+		if st.BICMeanCorrelation > 0.15 {
+			t.Fatalf("%s: средняя корреляция %.4f", st.Input, st.BICMeanCorrelation)
+		}
+	}
+}
+
+func TestRunSamples(t *testing.T) {
+	for _, samples := range []int{-1, 0, 1} {
+		if _, err := Run(identityCipher{}, samples); err == nil {
+			t.Errorf("%d выборок приняты", samples)
+		}
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	if d := HammingDistance([]byte{0xFF, 0x00}, []byte{0x0F, 0x01}); d != 5 {
+		t.Fatalf("расстояние %d, ожидается 5", d)
+	}
+}
+
+// TestExport: CSV содержит гистограммы и матрицы SAC, JSON читается
+// обратно.
+func TestExport(t *testing.T) {
+	r, err := Run(identityCipher{}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "avalanche")
+	var out strings.Builder
+	if err := Analyze(&out, identityCipher{}, 4, prefix, prefix+".json"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "identity, выборок: 4") {
+		t.Fatalf("вывод: %q", out.String())
+	}
+
+	// Заголовок и по 2 раунда × 17 расстояний для текста и ключа
+	if rows := readCSV(t, prefix+"_rounds.csv"); len(rows) != 1+2*2*17 {
+		t.Fatalf("_rounds.csv: %d строк", len(rows))
+	}
+	if rows := readCSV(t, prefix+"_sac_plaintext.csv"); len(rows) != 16 || len(rows[0]) != 16 {
+		t.Fatalf("_sac_plaintext.csv: %dx%d", len(rows), len(rows[0]))
+	}
+	if rows := readCSV(t, prefix+"_sac_key.csv"); len(rows) != 8 || rows[0][0] != "0.0000" {
+		t.Fatalf("_sac_key.csv: %v", rows)
+	}
+
+	data, err := os.ReadFile(prefix + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Report
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Cipher != r.Cipher || len(back.Key.SAC) != 8 || len(back.Plaintext.SAC[0]) != 16 {
+		t.Fatalf("JSON прочитан неверно: %+v", back)
+	}
+}
+
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
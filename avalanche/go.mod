@@ -0,0 +1,3 @@
+module avalanche
+
+go 1.25.1
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"is_3/src/attacks"
+	"is_3/src/rsa_alg"
+	"math/big"
+	"os"
+)
+
+const demoMessage = "Attack at dawn"
+
+func printAttackUsage() {
+	fmt.Println("Атаки на RSA без дополнения:")
+	fmt.Println("  attack factor -keys открытый.json [-in шифртекст -out файл] | -demo")
+	fmt.Println("  attack wiener -keys открытый.json [-in шифртекст -out файл] | -demo")
+	fmt.Println("  attack hastad -keys a.json -keys b.json -keys c.json -c <число> -c <число> -c <число> | -demo")
+	fmt.Println("  attack common-modulus -keys a.json -keys b.json -c <число> -c <число> | -demo")
+	fmt.Println("  attack dictionary -keys открытый.json -in шифртекст -out файл | -demo")
+}
+
+// attackCommand выполняет "attack <имя>". С флагом -demo атака запускается
+// на специально сгенерированных уязвимых ключах и проверяет, что открытый
+// текст восстановлен.
+func attackCommand(list []string) error {
+	if len(list) == 0 {
+		printAttackUsage()
+		return errors.New("не указана атака")
+	}
+	name := list[0]
+	a := parseArgs(list[1:], "demo")
+
+	switch name {
+	case "factor":
+		if a.has("demo") {
+			return demoFactor()
+		}
+		return recoverKeyCommand(a, func(pub *rsa_alg.PublicKey) (*rsa_alg.PrivateKey, error) {
+			priv, res, err := attacks.RecoverByFactoring(pub)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Printf("Метод: %s\np = %s\nq = %s\n", res.Method, res.P, res.Q)
+			return priv, nil
+		})
+
+	case "wiener":
+		if a.has("demo") {
+			return demoWiener()
+		}
+		return recoverKeyCommand(a, attacks.Wiener)
+
+	case "hastad":
+		if a.has("demo") {
+			return demoHastad()
+		}
+		pubs, cts, err := loadKeysAndCiphertexts(a)
+		if err != nil {
+			return err
+		}
+		m, err := attacks.Hastad(pubs, cts)
+		if err != nil {
+			return err
+		}
+		printRecovered(m)
+
+	case "common-modulus":
+		if a.has("demo") {
+			return demoCommonModulus()
+		}
+		pubs, cts, err := loadKeysAndCiphertexts(a)
+		if err != nil {
+			return err
+		}
+		if len(pubs) != 2 {
+			return errors.New("нужно ровно два ключа и два шифртекста")
+		}
+		m, err := attacks.CommonModulus(pubs[0], pubs[1], cts[0], cts[1])
+		if err != nil {
+			return err
+		}
+		printRecovered(m)
+
+	case "dictionary":
+		if a.has("demo") {
+			return demoDictionary()
+		}
+		pub, _, err := rsa_alg.ReadKeysFile(a.get("keys", keysFileName))
+		if err != nil {
+			return err
+		}
+		return dictionaryFile(pub, a.get("in", ""), a.get("out", ""))
+
+	default:
+		printAttackUsage()
+		return fmt.Errorf("неизвестная атака: %s", name)
+	}
+	return nil
+}
+
+// recoverKeyCommand восстанавливает закрытый ключ по открытому и, если
+// заданы -in и -out, расшифровывает им файл.
+func recoverKeyCommand(a *args, recover func(*rsa_alg.PublicKey) (*rsa_alg.PrivateKey, error)) error {
+	pub, _, err := rsa_alg.ReadKeysFile(a.get("keys", keysFileName))
+	if err != nil {
+		return err
+	}
+	priv, err := recover(pub)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Закрытый ключ восстановлен: d = %s\n", priv.D)
+
+	in, out := a.get("in", ""), a.get("out", "")
+	if in == "" || out == "" {
+		return nil
+	}
+	if err := rsa_alg.NewRSAFromKeys(pub, priv).DecryptFile(in, out); err != nil {
+		return err
+	}
+	fmt.Printf("Файл %s расшифрован в %s\n", in, out)
+	return nil
+}
+
+func loadKeysAndCiphertexts(a *args) ([]*rsa_alg.PublicKey, []*big.Int, error) {
+	var pubs []*rsa_alg.PublicKey
+	for _, path := range a.flags["keys"] {
+		pub, _, err := rsa_alg.ReadKeysFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		pubs = append(pubs, pub)
+	}
+	var cts []*big.Int
+	for _, s := range a.flags["c"] {
+		c, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, nil, fmt.Errorf("неверный шифртекст: %s", s)
+		}
+		cts = append(cts, c)
+	}
+	if len(pubs) != len(cts) {
+		return nil, nil, errors.New("число -keys и -c должно совпадать")
+	}
+	return pubs, cts, nil
+}
+
+func dictionaryFile(pub *rsa_alg.PublicKey, in, out string) error {
+	if in == "" || out == "" {
+		return errors.New("нужны -in и -out")
+	}
+	input, err := os.Open(in)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	output, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	n, err := attacks.ByteDictionary(pub, input, output)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Восстановлено %d байт без закрытого ключа: %s\n", n, out)
+	return nil
+}
+
+func printRecovered(m *big.Int) {
+	fmt.Printf("m = %s\n", m)
+	fmt.Printf("Как текст: %q\n", m.Bytes())
+}
+
+// checkRecovered сравнивает восстановленный текст с исходным.
+func checkRecovered(attack string, want, got []byte) error {
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("%s: восстановлено %q, ожидалось %q", attack, got, want)
+	}
+	fmt.Printf("✓ %s: открытый текст восстановлен: %q\n", attack, got)
+	return nil
+}
+
+func demoFactor() error {
+	e := big.NewInt(65537)
+	cases := []struct {
+		title string
+		gen   func() (*rsa_alg.PublicKey, error)
+	}{
+		{"малый модуль 40 бит", func() (*rsa_alg.PublicKey, error) {
+			pub, _, err := rsa_alg.GenerateKey(40, e)
+			return pub, err
+		}},
+		{"модуль 80 бит", func() (*rsa_alg.PublicKey, error) {
+			pub, _, err := rsa_alg.GenerateKey(80, e)
+			return pub, err
+		}},
+		{"близкие p и q, 1024 бит", func() (*rsa_alg.PublicKey, error) {
+			pub, _, err := attacks.GenerateClosePrimesKey(1024, e)
+			return pub, err
+		}},
+	}
+	for _, c := range cases {
+		pub, err := c.gen()
+		if err != nil {
+			return err
+		}
+		msg := []byte(demoMessage)[:min(len(demoMessage), pub.Size()-1)]
+		ct := pub.EncryptInt(new(big.Int).SetBytes(msg))
+
+		priv, res, err := attacks.RecoverByFactoring(pub)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.title, err)
+		}
+		fmt.Printf("%s: %s\n", c.title, res.Method)
+		if err := checkRecovered("factor", msg, priv.DecryptInt(ct).Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func demoWiener() error {
+	pub, _, err := attacks.GenerateWienerKey(1024)
+	if err != nil {
+		return err
+	}
+	ct := pub.EncryptInt(new(big.Int).SetBytes([]byte(demoMessage)))
+	priv, err := attacks.Wiener(pub)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("e = %s\nнайдено d = %s (%d бит)\n", pub.E, priv.D, priv.D.BitLen())
+	return checkRecovered("wiener", []byte(demoMessage), priv.DecryptInt(ct).Bytes())
+}
+
+func demoHastad() error {
+	m := new(big.Int).SetBytes([]byte(demoMessage))
+	var pubs []*rsa_alg.PublicKey
+	var cts []*big.Int
+	for i := 0; i < 3; i++ {
+		pub, _, err := rsa_alg.GenerateKey(512, big.NewInt(3))
+		if err != nil {
+			return err
+		}
+		pubs = append(pubs, pub)
+		cts = append(cts, pub.EncryptInt(m))
+	}
+	got, err := attacks.Hastad(pubs, cts)
+	if err != nil {
+		return err
+	}
+	return checkRecovered("hastad", []byte(demoMessage), got.Bytes())
+}
+
+func demoCommonModulus() error {
+	pub1, pub2, err := attacks.GenerateCommonModulusKeys(512)
+	if err != nil {
+		return err
+	}
+	m := new(big.Int).SetBytes([]byte(demoMessage))
+	got, err := attacks.CommonModulus(pub1, pub2, pub1.EncryptInt(m), pub2.EncryptInt(m))
+	if err != nil {
+		return err
+	}
+	return checkRecovered("common-modulus", []byte(demoMessage), got.Bytes())
+}
+
+func demoDictionary() error {
+	dir, err := os.MkdirTemp("", "rsa-dictionary")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	plain := dir + "/plain.txt"
+	encrypted := dir + "/plain.enc"
+	recovered := dir + "/recovered.txt"
+	msg := []byte(demoMessage + "\x00\xff")
+	if err := os.WriteFile(plain, msg, 0644); err != nil {
+		return err
+	}
+
+	rsa, err := rsa_alg.NewRSABits(false, dir+"/keys.json", 512)
+	if err != nil {
+		return err
+	}
+	if err := rsa.EncryptFile(plain, encrypted); err != nil {
+		return err
+	}
+	if err := dictionaryFile(rsa.PublicKey(), encrypted, recovered); err != nil {
+		return err
+	}
+	got, err := os.ReadFile(recovered)
+	if err != nil {
+		return err
+	}
+	return checkRecovered("dictionary", msg, got)
+}
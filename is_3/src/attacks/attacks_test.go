@@ -0,0 +1,205 @@
+package attacks
+
+import (
+	"bytes"
+	"errors"
+	"is_3/src/rsa_alg"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testMessage = "Attack at dawn"
+
+// encryptMessage шифрует testMessage (усеченное под модуль) без дополнения.
+func encryptMessage(pub *rsa_alg.PublicKey) ([]byte, *big.Int) {
+	msg := []byte(testMessage)[:min(len(testMessage), pub.Size()-1)]
+	return msg, pub.EncryptInt(new(big.Int).SetBytes(msg))
+}
+
+func TestFactorMethods(t *testing.T) {
+	// n = 1000003 · 1000033: каждый метод должен найти один из множителей
+	n := new(big.Int).Mul(big.NewInt(1000003), big.NewInt(1000033))
+	for name, factor := range map[string]func(*big.Int) (*big.Int, error){
+		"TrialDivision": func(n *big.Int) (*big.Int, error) { return TrialDivision(n, 2_000_000) },
+		"Fermat":        func(n *big.Int) (*big.Int, error) { return Fermat(n, 1000) },
+		"PollardRho":    func(n *big.Int) (*big.Int, error) { return PollardRho(n, 1_000_000) },
+	} {
+		p, err := factor(n)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if p.Cmp(big.NewInt(1000003)) != 0 && p.Cmp(big.NewInt(1000033)) != 0 {
+			t.Errorf("%s: найден делитель %s", name, p)
+		}
+	}
+
+	if _, err := TrialDivision(n, 1000); !errors.Is(err, ErrNotFound) {
+		t.Errorf("TrialDivision с малой границей: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func TestRecoverByFactoring(t *testing.T) {
+	e := big.NewInt(65537)
+	cases := []struct {
+		name   string
+		method string
+		gen    func() (*rsa_alg.PublicKey, *rsa_alg.PrivateKey, error)
+	}{
+		{"40 бит", "", func() (*rsa_alg.PublicKey, *rsa_alg.PrivateKey, error) { return rsa_alg.GenerateKey(40, e) }},
+		{"80 бит", "", func() (*rsa_alg.PublicKey, *rsa_alg.PrivateKey, error) { return rsa_alg.GenerateKey(80, e) }},
+		{"близкие p и q, 1024 бит", "Ферма", func() (*rsa_alg.PublicKey, *rsa_alg.PrivateKey, error) { return GenerateClosePrimesKey(1024, e) }},
+	}
+	for _, c := range cases {
+		pub, want, err := c.gen()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, ct := encryptMessage(pub)
+
+		priv, res, err := RecoverByFactoring(pub)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if c.method != "" && res.Method != c.method {
+			t.Errorf("%s: метод %q, ожидается %q", c.name, res.Method, c.method)
+		}
+		p, q := want.P, want.Q
+		if p.Cmp(q) > 0 {
+			p, q = q, p
+		}
+		if res.P.Cmp(p) != 0 || res.Q.Cmp(q) != 0 {
+			t.Errorf("%s: множители %s·%s, ожидаются %s·%s", c.name, res.P, res.Q, p, q)
+		}
+		if got := priv.DecryptInt(ct).Bytes(); !bytes.Equal(got, msg) {
+			t.Errorf("%s: восстановлено %q, ожидается %q", c.name, got, msg)
+		}
+	}
+}
+
+func TestWiener(t *testing.T) {
+	pub, want, err := GenerateWienerKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := Wiener(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if priv.D.Cmp(want.D) != 0 {
+		t.Fatalf("найдено d = %s, ожидается %s", priv.D, want.D)
+	}
+	msg, ct := encryptMessage(pub)
+	if got := priv.DecryptInt(ct).Bytes(); !bytes.Equal(got, msg) {
+		t.Errorf("восстановлено %q, ожидается %q", got, msg)
+	}
+
+	// Обычный ключ с e = 65537 и большим d атаке не поддается
+	pub, _, err = rsa_alg.GenerateKey(1024, big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Wiener(pub); !errors.Is(err, ErrNotFound) {
+		t.Errorf("обычный ключ: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func TestHastad(t *testing.T) {
+	m := new(big.Int).SetBytes([]byte(testMessage))
+	var pubs []*rsa_alg.PublicKey
+	var cts []*big.Int
+	for i := 0; i < 3; i++ {
+		pub, _, err := rsa_alg.GenerateKey(512, big.NewInt(3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+		cts = append(cts, pub.EncryptInt(m))
+	}
+	got, err := Hastad(pubs, cts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(m) != 0 {
+		t.Fatalf("восстановлено %q, ожидается %q", got.Bytes(), testMessage)
+	}
+
+	// Двух шифртекстов для e = 3 недостаточно
+	if _, err := Hastad(pubs[:2], cts[:2]); err == nil {
+		t.Error("Hastad с двумя шифртекстами не вернул ошибку")
+	}
+}
+
+func TestIntRoot(t *testing.T) {
+	x := new(big.Int).Exp(big.NewInt(123456789), big.NewInt(3), nil)
+	if r, exact := IntRoot(x, 3); !exact || r.Int64() != 123456789 {
+		t.Errorf("IntRoot(x, 3) = %s, %v", r, exact)
+	}
+	x.Add(x, big.NewInt(1))
+	if r, exact := IntRoot(x, 3); exact || r.Int64() != 123456789 {
+		t.Errorf("IntRoot(x+1, 3) = %s, %v", r, exact)
+	}
+}
+
+func TestCommonModulus(t *testing.T) {
+	pub1, pub2, err := GenerateCommonModulusKeys(512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := new(big.Int).SetBytes([]byte(testMessage))
+	got, err := CommonModulus(pub1, pub2, pub1.EncryptInt(m), pub2.EncryptInt(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(m) != 0 {
+		t.Fatalf("восстановлено %q, ожидается %q", got.Bytes(), testMessage)
+	}
+
+	other, _, err := rsa_alg.GenerateKey(512, big.NewInt(257))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CommonModulus(pub1, other, pub1.EncryptInt(m), other.EncryptInt(m)); err == nil {
+		t.Error("CommonModulus с разными модулями не вернул ошибку")
+	}
+}
+
+func TestByteDictionary(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.txt")
+	encrypted := filepath.Join(dir, "plain.enc")
+	msg := []byte(testMessage + "\x00\xff")
+	if err := os.WriteFile(plain, msg, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := rsa_alg.NewRSABits(false, filepath.Join(dir, "keys.json"), 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.EncryptFile(plain, encrypted); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	n, err := ByteDictionary(r.PublicKey(), bytes.NewReader(data), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(msg) || !bytes.Equal(got.Bytes(), msg) {
+		t.Fatalf("восстановлено %q (%d байт), ожидается %q", got.Bytes(), n, msg)
+	}
+
+	// Словарь чужого открытого ключа не подходит
+	other, _, err := rsa_alg.GenerateKey(512, big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ByteDictionary(other, bytes.NewReader(data), &bytes.Buffer{}); err == nil {
+		t.Error("словарь чужого ключа не вернул ошибку")
+	}
+}
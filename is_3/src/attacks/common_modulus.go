@@ -0,0 +1,59 @@
+package attacks
+
+import (
+	"errors"
+	"is_3/src/rsa_alg"
+	"math/big"
+)
+
+// CommonModulus восстанавливает m, зашифрованное двумя ключами с общим
+// модулем n и взаимно простыми экспонентами e1, e2. По расширенному
+// алгоритму Евклида a·e1 + b·e2 = 1, откуда c1^a · c2^b = m mod n.
+func CommonModulus(pub1, pub2 *rsa_alg.PublicKey, c1, c2 *big.Int) (*big.Int, error) {
+	if pub1.N.Cmp(pub2.N) != 0 {
+		return nil, errors.New("CommonModulus: модули ключей различаются")
+	}
+	n := pub1.N
+	a := new(big.Int)
+	b := new(big.Int)
+	gcd := new(big.Int).GCD(a, b, pub1.E, pub2.E)
+	if gcd.Cmp(one) != 0 {
+		return nil, errors.New("CommonModulus: экспоненты не взаимно просты")
+	}
+
+	x := powSigned(c1, a, n)
+	y := powSigned(c2, b, n)
+	if x == nil || y == nil {
+		return nil, errors.New("CommonModulus: шифртекст не обратим по модулю n")
+	}
+	m := x.Mul(x, y)
+	return m.Mod(m, n), nil
+}
+
+// powSigned вычисляет c^k mod n для k любого знака.
+func powSigned(c, k, n *big.Int) *big.Int {
+	if k.Sign() >= 0 {
+		return new(big.Int).Exp(c, k, n)
+	}
+	inv := new(big.Int).ModInverse(c, n)
+	if inv == nil {
+		return nil
+	}
+	return inv.Exp(inv, new(big.Int).Neg(k), n)
+}
+
+// GenerateCommonModulusKeys генерирует два ключа с общим модулем и
+// экспонентами 65537 и 257, как при ошибочной раздаче одного n нескольким
+// пользователям.
+func GenerateCommonModulusKeys(bits int) (*rsa_alg.PublicKey, *rsa_alg.PublicKey, error) {
+	for {
+		pub1, priv1, err := rsa_alg.GenerateKey(bits, big.NewInt(65537))
+		if err != nil {
+			return nil, nil, err
+		}
+		pub2 := &rsa_alg.PublicKey{E: big.NewInt(257), N: pub1.N}
+		if _, err := rsa_alg.PrivateKeyFromFactors(pub2, priv1.P, priv1.Q); err == nil {
+			return pub1, pub2, nil
+		}
+	}
+}
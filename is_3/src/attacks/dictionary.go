@@ -0,0 +1,47 @@
+package attacks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"is_3/src/rsa_alg"
+	"math/big"
+)
+
+// ByteDictionary расшифровывает побайтовый формат rsa_alg.EncryptFile,
+// зная только открытый ключ. RSA без дополнения детерминировано, поэтому
+// достаточно зашифровать все 256 значений байта и искать шифртексты в
+// таблице.
+func ByteDictionary(pub *rsa_alg.PublicKey, r io.Reader, w io.Writer) (int, error) {
+	dictionary := make(map[string]byte, 256)
+	for b := 0; b < 256; b++ {
+		c := pub.EncryptInt(big.NewInt(int64(b)))
+		dictionary[c.String()] = byte(b)
+	}
+
+	reader := bufio.NewReader(r)
+	writer := bufio.NewWriter(w)
+	// Заголовок, если он есть, только пропускается: ключ проверяет словарь
+	if _, err := rsa_alg.ReadByteHeader(reader); err != nil {
+		return 0, fmt.Errorf("ByteDictionary: %w", err)
+	}
+	count := 0
+	for {
+		c, err := rsa_alg.ReadByteCiphertext(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("ByteDictionary: %w", err)
+		}
+		b, ok := dictionary[c.String()]
+		if !ok {
+			return count, fmt.Errorf("ByteDictionary: значение %d не найдено в словаре: файл зашифрован другим ключом", count)
+		}
+		if err := writer.WriteByte(b); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, writer.Flush()
+}
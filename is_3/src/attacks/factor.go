@@ -0,0 +1,161 @@
+// Пакет attacks демонстрирует атаки на RSA без дополнения ("textbook RSA")
+// над ключами и форматом шифртекста пакета rsa_alg.
+package attacks
+
+import (
+	"errors"
+	"fmt"
+	"is_3/src/rsa_alg"
+	"math/big"
+)
+
+// ErrNotFound атака не дала результата
+var ErrNotFound = errors.New("attacks: множитель не найден")
+
+var (
+	one = big.NewInt(1)
+	two = big.NewInt(2)
+)
+
+// TrialDivision ищет делитель n перебором нечетных чисел до limit.
+func TrialDivision(n *big.Int, limit uint64) (*big.Int, error) {
+	if n.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+	d := big.NewInt(3)
+	r := new(big.Int)
+	sq := new(big.Int)
+	for i := uint64(3); i <= limit; i += 2 {
+		if sq.Mul(d, d).Cmp(n) > 0 {
+			break
+		}
+		if r.Mod(n, d).Sign() == 0 {
+			return d, nil
+		}
+		d.Add(d, two)
+	}
+	return nil, ErrNotFound
+}
+
+// PollardRho ищет делитель n ро-методом Полларда с функцией x² + c.
+// Ожидаемое число итераций порядка √p для наименьшего делителя p.
+func PollardRho(n *big.Int, maxIter int) (*big.Int, error) {
+	if n.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+	for c := int64(1); c < 20; c++ {
+		cc := big.NewInt(c)
+		x := big.NewInt(2)
+		y := big.NewInt(2)
+		d := big.NewInt(1)
+		diff := new(big.Int)
+
+		f := func(v *big.Int) {
+			v.Mul(v, v)
+			v.Add(v, cc)
+			v.Mod(v, n)
+		}
+		for i := 0; i < maxIter && d.Cmp(one) == 0; i++ {
+			f(x)
+			f(y)
+			f(y)
+			diff.Sub(x, y)
+			diff.Abs(diff)
+			d.GCD(nil, nil, diff, n)
+		}
+		if d.Cmp(one) != 0 && d.Cmp(n) != 0 {
+			return d, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Fermat раскладывает n = a² - b² = (a-b)(a+b). Быстро работает, когда
+// p и q близки друг к другу: a начинается с ⌈√n⌉.
+func Fermat(n *big.Int, maxIter int) (*big.Int, error) {
+	if n.Bit(0) == 0 {
+		return big.NewInt(2), nil
+	}
+	a := new(big.Int).Sqrt(n)
+	if new(big.Int).Mul(a, a).Cmp(n) < 0 {
+		a.Add(a, one)
+	}
+	b2 := new(big.Int)
+	b := new(big.Int)
+	for i := 0; i < maxIter; i++ {
+		b2.Mul(a, a)
+		b2.Sub(b2, n)
+		b.Sqrt(b2)
+		if new(big.Int).Mul(b, b).Cmp(b2) == 0 {
+			p := new(big.Int).Sub(a, b)
+			if p.Cmp(one) > 0 {
+				return p, nil
+			}
+		}
+		a.Add(a, one)
+	}
+	return nil, ErrNotFound
+}
+
+// FactorResult результат факторизации модуля
+type FactorResult struct {
+	Method string
+	P, Q   *big.Int
+}
+
+// Factor пробует методы по очереди: перебор делителей, Ферма, ро-Полларда.
+func Factor(n *big.Int) (*FactorResult, error) {
+	methods := []struct {
+		name string
+		run  func() (*big.Int, error)
+	}{
+		{"перебор делителей", func() (*big.Int, error) { return TrialDivision(n, 1_000_000) }},
+		{"Ферма", func() (*big.Int, error) { return Fermat(n, 100_000) }},
+		{"ро-метод Полларда", func() (*big.Int, error) { return PollardRho(n, 50_000_000) }},
+	}
+	for _, m := range methods {
+		p, err := m.run()
+		if err != nil {
+			continue
+		}
+		q := new(big.Int).Div(n, p)
+		if p.Cmp(q) > 0 {
+			p, q = q, p
+		}
+		return &FactorResult{Method: m.name, P: p, Q: q}, nil
+	}
+	return nil, fmt.Errorf("Factor: %w", ErrNotFound)
+}
+
+// RecoverByFactoring раскладывает модуль открытого ключа и восстанавливает
+// закрытый ключ.
+func RecoverByFactoring(pub *rsa_alg.PublicKey) (*rsa_alg.PrivateKey, *FactorResult, error) {
+	res, err := Factor(pub.N)
+	if err != nil {
+		return nil, nil, err
+	}
+	priv, err := rsa_alg.PrivateKeyFromFactors(pub, res.P, res.Q)
+	if err != nil {
+		return nil, nil, err
+	}
+	return priv, res, nil
+}
+
+// GenerateClosePrimesKey генерирует ключ с близкими p и q: q — следующее
+// простое после p. Такой модуль раскладывается методом Ферма за одну итерацию.
+func GenerateClosePrimesKey(bits int, e *big.Int) (*rsa_alg.PublicKey, *rsa_alg.PrivateKey, error) {
+	for {
+		_, base, err := rsa_alg.GenerateKey(bits, e)
+		if err != nil {
+			return nil, nil, err
+		}
+		q := new(big.Int).Add(base.P, two)
+		for !q.ProbablyPrime(20) {
+			q.Add(q, two)
+		}
+		pub := &rsa_alg.PublicKey{E: new(big.Int).Set(e), N: new(big.Int).Mul(base.P, q)}
+		if priv, err := rsa_alg.PrivateKeyFromFactors(pub, base.P, q); err == nil {
+			return pub, priv, nil
+		}
+	}
+}
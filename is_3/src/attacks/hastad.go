@@ -0,0 +1,88 @@
+package attacks
+
+import (
+	"errors"
+	"fmt"
+	"is_3/src/rsa_alg"
+	"math/big"
+)
+
+// Hastad восстанавливает сообщение m, отправленное без дополнения e
+// получателям с одинаковой открытой экспонентой e (широковещательная атака
+// Хостада). По китайской теореме об остатках из c_i = m^e mod n_i
+// получается m^e mod n_1·…·n_e, а так как m < n_i, это m^e без приведения,
+// и m — целый корень степени e.
+func Hastad(pubs []*rsa_alg.PublicKey, ciphertexts []*big.Int) (*big.Int, error) {
+	if len(pubs) == 0 || len(pubs) != len(ciphertexts) {
+		return nil, errors.New("Hastad: число ключей и шифртекстов должно совпадать")
+	}
+	e := pubs[0].E
+	if !e.IsInt64() || e.Int64() > int64(len(pubs)) {
+		return nil, fmt.Errorf("Hastad: нужно не меньше e = %s шифртекстов, получено %d", e, len(pubs))
+	}
+	for _, pub := range pubs[1:] {
+		if pub.E.Cmp(e) != 0 {
+			return nil, errors.New("Hastad: открытые экспоненты различаются")
+		}
+	}
+
+	moduli := make([]*big.Int, len(pubs))
+	for i, pub := range pubs {
+		moduli[i] = pub.N
+	}
+	me, err := CRT(ciphertexts, moduli)
+	if err != nil {
+		return nil, fmt.Errorf("Hastad: %w", err)
+	}
+
+	m, exact := IntRoot(me, int(e.Int64()))
+	if !exact {
+		return nil, fmt.Errorf("Hastad: %w", ErrNotFound)
+	}
+	return m, nil
+}
+
+// CRT решает систему x ≡ a_i (mod n_i) для попарно взаимно простых n_i.
+func CRT(remainders, moduli []*big.Int) (*big.Int, error) {
+	product := big.NewInt(1)
+	for _, n := range moduli {
+		product.Mul(product, n)
+	}
+
+	x := new(big.Int)
+	for i, n := range moduli {
+		mi := new(big.Int).Div(product, n)
+		inv := new(big.Int).ModInverse(mi, n)
+		if inv == nil {
+			return nil, errors.New("CRT: модули не взаимно просты")
+		}
+		term := new(big.Int).Mul(remainders[i], mi)
+		term.Mul(term, inv)
+		x.Add(x, term)
+	}
+	return x.Mod(x, product), nil
+}
+
+// IntRoot вычисляет ⌊x^(1/k)⌋ методом Ньютона и сообщает, точный ли корень.
+func IntRoot(x *big.Int, k int) (*big.Int, bool) {
+	if x.Sign() == 0 {
+		return new(big.Int), true
+	}
+	bk := big.NewInt(int64(k))
+	km1 := big.NewInt(int64(k - 1))
+
+	// Начальное приближение сверху: 2^⌈bits/k⌉
+	r := new(big.Int).Lsh(one, uint(x.BitLen()/k+1))
+	for {
+		// r' = ((k-1)·r + x / r^(k-1)) / k
+		next := new(big.Int).Exp(r, km1, nil)
+		next.Div(x, next)
+		next.Add(next, new(big.Int).Mul(km1, r))
+		next.Div(next, bk)
+		if next.Cmp(r) >= 0 {
+			break
+		}
+		r = next
+	}
+	return r, new(big.Int).Exp(r, bk, nil).Cmp(x) == 0
+}
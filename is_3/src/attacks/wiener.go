@@ -0,0 +1,108 @@
+package attacks
+
+import (
+	"crypto/rand"
+	"fmt"
+	"is_3/src/rsa_alg"
+	"math/big"
+)
+
+// Wiener восстанавливает малую закрытую экспоненту d < n^(1/4)/3.
+// Из e·d = 1 + k·φ(n) следует, что k/d — подходящая дробь цепной дроби
+// e/n. Для каждой подходящей дроби вычисляется кандидат φ и проверяется,
+// что x² - (n - φ + 1)x + n = 0 имеет целые корни p и q.
+func Wiener(pub *rsa_alg.PublicKey) (*rsa_alg.PrivateKey, error) {
+	// Разложение e/n в цепную дробь и подходящие дроби h/k
+	num := new(big.Int).Set(pub.E)
+	den := new(big.Int).Set(pub.N)
+	hPrev, h := big.NewInt(0), big.NewInt(1) // h_{-2}, h_{-1}
+	kPrev, k := big.NewInt(1), big.NewInt(0) // k_{-2}, k_{-1}
+	a := new(big.Int)
+	rem := new(big.Int)
+
+	for den.Sign() != 0 {
+		a.QuoRem(num, den, rem)
+		num, den = den, new(big.Int).Set(rem)
+
+		hNext := new(big.Int).Add(new(big.Int).Mul(a, h), hPrev)
+		kNext := new(big.Int).Add(new(big.Int).Mul(a, k), kPrev)
+		hPrev, h = h, hNext
+		kPrev, k = k, kNext
+
+		// h/k ≈ e/n, значит h — это k (кратность φ), а k — кандидат d
+		if priv := wienerCandidate(pub, h, k); priv != nil {
+			return priv, nil
+		}
+	}
+	return nil, fmt.Errorf("Wiener: %w", ErrNotFound)
+}
+
+func wienerCandidate(pub *rsa_alg.PublicKey, kk, d *big.Int) *rsa_alg.PrivateKey {
+	if kk.Sign() == 0 || d.Bit(0) == 0 {
+		return nil
+	}
+	// φ = (e·d - 1) / k должно делиться нацело
+	ed1 := new(big.Int).Mul(pub.E, d)
+	ed1.Sub(ed1, one)
+	phi, r := new(big.Int).QuoRem(ed1, kk, new(big.Int))
+	if r.Sign() != 0 {
+		return nil
+	}
+
+	// p + q = n - φ + 1, дискриминант (p+q)² - 4n = (p-q)²
+	s := new(big.Int).Sub(pub.N, phi)
+	s.Add(s, one)
+	disc := new(big.Int).Mul(s, s)
+	disc.Sub(disc, new(big.Int).Lsh(pub.N, 2))
+	if disc.Sign() < 0 {
+		return nil
+	}
+	t := new(big.Int).Sqrt(disc)
+	if new(big.Int).Mul(t, t).Cmp(disc) != 0 {
+		return nil
+	}
+	p := new(big.Int).Add(s, t)
+	p.Rsh(p, 1)
+	q := new(big.Int).Sub(s, t)
+	q.Rsh(q, 1)
+	if new(big.Int).Mul(p, q).Cmp(pub.N) != 0 {
+		return nil
+	}
+	return &rsa_alg.PrivateKey{D: new(big.Int).Set(d), N: new(big.Int).Set(pub.N), P: p, Q: q}
+}
+
+// GenerateWienerKey генерирует ключ, уязвимый к атаке Винера: сначала
+// выбирается малое d < n^(1/4)/3, затем e = d⁻¹ mod φ(n).
+func GenerateWienerKey(bits int) (*rsa_alg.PublicKey, *rsa_alg.PrivateKey, error) {
+	for {
+		_, base, err := rsa_alg.GenerateKey(bits, big.NewInt(65537))
+		if err != nil {
+			return nil, nil, err
+		}
+		n := new(big.Int).Mul(base.P, base.Q)
+		phi := new(big.Int).Mul(
+			new(big.Int).Sub(base.P, one),
+			new(big.Int).Sub(base.Q, one),
+		)
+
+		// Граница n^(1/4)/3
+		bound := new(big.Int).Sqrt(new(big.Int).Sqrt(n))
+		bound.Div(bound, big.NewInt(3))
+		if bound.Cmp(big.NewInt(3)) <= 0 {
+			return nil, nil, fmt.Errorf("GenerateWienerKey: модуль %d бит слишком мал", bits)
+		}
+
+		d, err := rand.Int(rand.Reader, bound)
+		if err != nil {
+			return nil, nil, err
+		}
+		d.SetBit(d, 0, 1)
+		e := new(big.Int).ModInverse(d, phi)
+		if e == nil || d.Cmp(one) <= 0 {
+			continue
+		}
+		pub := &rsa_alg.PublicKey{E: e, N: n}
+		priv := &rsa_alg.PrivateKey{D: d, N: new(big.Int).Set(n), P: base.P, Q: base.Q}
+		return pub, priv, nil
+	}
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"is_3/src/rsa_alg"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// args разобранные аргументы командной строки: позиционные и флаги вида
+// "-name value" / "--name value". Флаг может повторяться.
+type args struct {
+	positional []string
+	flags      map[string][]string
+}
+
+// parseArgs разбирает аргументы. Флаги из boolFlags не принимают значения.
+func parseArgs(list []string, boolFlags ...string) *args {
+	a := &args{flags: make(map[string][]string)}
+	for i := 0; i < len(list); i++ {
+		arg := list[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			a.positional = append(a.positional, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if key, value, ok := strings.Cut(name, "="); ok {
+			a.flags[key] = append(a.flags[key], value)
+			continue
+		}
+		if slices.Contains(boolFlags, name) || i+1 >= len(list) {
+			a.flags[name] = append(a.flags[name], "")
+			continue
+		}
+		a.flags[name] = append(a.flags[name], list[i+1])
+		i++
+	}
+	return a
+}
+
+func (a *args) has(name string) bool {
+	_, ok := a.flags[name]
+	return ok
+}
+
+// get возвращает последнее значение флага или def.
+func (a *args) get(name, def string) string {
+	values := a.flags[name]
+	if len(values) == 0 {
+		return def
+	}
+	return values[len(values)-1]
+}
+
+func (a *args) getInt(name string, def int) (int, error) {
+	value := a.get(name, "")
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("неверное значение -%s: %s", name, value)
+	}
+	return n, nil
+}
+
+func printUsage() {
+	fmt.Println("Использование:")
+	fmt.Println("  go run ./src                     интерактивное меню")
+	fmt.Println("  genkey [-keys rsa_keys.json] [-bits N] [-primes 2..5]")
+	fmt.Println("  encrypt <входной_файл> <выходной_файл> [-keys файл] [-mode byte|block|hybrid] [-padding pkcs1|oaep]")
+	fmt.Println("          [--recipient открытый.json]...   получатели гибридного режима (по умолчанию свой ключ)")
+	fmt.Println("  decrypt <входной_файл> <выходной_файл> [-keys файл] [-ladder] [-blinding]")
+	fmt.Println("  pubkey <выходной_файл> [-keys файл]   сохранить только открытый ключ")
+	fmt.Println("  sign <файл> [-keys файл] [-scheme pss|pkcs1] [-out файл.sig]")
+	fmt.Println("  verify <файл> <файл.sig> [-keys файл] [-scheme pss|pkcs1]")
+	fmt.Println("  timing [-bits 1024] [-n 2000] [-fixed 2]  тест утечки по времени (dudect)")
+	fmt.Println("  bench [-bits 2048] [-n 50] [-count 3] генерация и расшифровка для 2..5 простых")
+	fmt.Println("  rekey <каталог|файл> [-keys файл]      перешифровать файлы текущим ключом")
+	fmt.Println("        [-legacy файл]...   побайтовые файлы без заголовка (ключ подбирается)")
+	fmt.Println("  inspect [-keys файл] [-dir каталог_открытых_ключей]")
+	fmt.Println("  attack <factor|wiener|hastad|common-modulus|dictionary> ... (см. attack help)")
+	fmt.Println("  elgamal <genkey|encrypt|decrypt|demo> ...  схема Эль-Гамаля (см. elgamal help)")
+	fmt.Println("  paillier <genkey|encrypt|decrypt|demo> ... схема Пэйе (см. paillier help)")
+}
+
+// runCommand выполняет команду из аргументов командной строки.
+func runCommand(list []string) error {
+	command := list[0]
+	switch command {
+	case "attack":
+		return attackCommand(list[1:])
+	case "elgamal":
+		return elgamalCommand(list[1:])
+	case "paillier":
+		return paillierCommand(list[1:])
+	}
+	a := parseArgs(list[1:], "ladder", "blinding")
+	keysFile := a.get("keys", keysFileName)
+
+	switch command {
+	case "genkey":
+		bits, err := a.getInt("bits", 0)
+		if err != nil {
+			return err
+		}
+		primes, err := a.getInt("primes", 2)
+		if err != nil {
+			return err
+		}
+		if _, err := rsa_alg.NewRSAPrimes(false, keysFile, bits, primes); err != nil {
+			return err
+		}
+		fmt.Printf("Новые ключи сгенерированы и сохранены в %s\n", keysFile)
+
+	case "encrypt":
+		if len(a.positional) != 2 {
+			return fmt.Errorf("использование: encrypt <входной_файл> <выходной_файл>")
+		}
+		if a.get("mode", "") == "hybrid" {
+			return encryptHybridCommand(keysFile, a)
+		}
+		rsa, err := rsa_alg.NewRSA(true, keysFile)
+		if err != nil {
+			return err
+		}
+		switch a.get("mode", "byte") {
+		case "byte":
+			err = rsa.EncryptFile(a.positional[0], a.positional[1])
+		case "block":
+			padding, perr := rsa_alg.ParsePadding(a.get("padding", "pkcs1"))
+			if perr != nil {
+				return perr
+			}
+			err = rsa.EncryptFileBlocks(a.positional[0], a.positional[1], padding)
+		default:
+			return fmt.Errorf("неизвестный режим: %s", a.get("mode", ""))
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка шифрования: %w", err)
+		}
+		fmt.Printf("Файл зашифрован и сохранен как %s\n", a.positional[1])
+
+	case "decrypt":
+		if len(a.positional) != 2 {
+			return fmt.Errorf("использование: decrypt <входной_файл> <выходной_файл>")
+		}
+		rsa, err := rsa_alg.NewRSA(true, keysFile)
+		if err != nil {
+			return err
+		}
+		rsa.SetHardening(a.has("ladder"), a.has("blinding"))
+		if err := rsa.DecryptFile(a.positional[0], a.positional[1]); err != nil {
+			return fmt.Errorf("ошибка расшифровки: %w", err)
+		}
+		fmt.Printf("Файл расшифрован и сохранен как %s\n", a.positional[1])
+
+	case "pubkey":
+		if len(a.positional) != 1 {
+			return fmt.Errorf("использование: pubkey <выходной_файл>")
+		}
+		pub, _, err := rsa_alg.ReadKeysFile(keysFile)
+		if err != nil {
+			return err
+		}
+		if err := rsa_alg.WriteKeysFile(a.positional[0], pub, nil); err != nil {
+			return err
+		}
+		fmt.Printf("Открытый ключ сохранен в %s\n", a.positional[0])
+
+	case "sign":
+		if len(a.positional) != 1 {
+			return fmt.Errorf("использование: sign <файл>")
+		}
+		return signCommand(keysFile, a.positional[0], a.get("out", a.positional[0]+".sig"), a.get("scheme", "pss"))
+
+	case "verify":
+		if len(a.positional) != 2 {
+			return fmt.Errorf("использование: verify <файл> <файл.sig>")
+		}
+		return verifyCommand(keysFile, a.positional[0], a.positional[1], a.get("scheme", "pss"))
+
+	case "timing":
+		return timingCommand(a)
+
+	case "bench":
+		return benchCommand(a)
+
+	case "rekey":
+		if len(a.positional) != 1 {
+			return fmt.Errorf("использование: rekey <каталог|файл>")
+		}
+		return rekeyCommand(keysFile, a.positional[0], a.flags["legacy"])
+
+	case "inspect":
+		return inspectCommand(keysFile, a.get("dir", ""))
+
+	case "help", "-h", "--help":
+		printUsage()
+
+	default:
+		printUsage()
+		return fmt.Errorf("неизвестная команда: %s", command)
+	}
+	return nil
+}
+
+func runCLI() {
+	if err := runCommand(os.Args[1:]); err != nil {
+		fmt.Printf("Ошибка: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// encryptHybridCommand шифрует файл для получателей из --recipient или,
+// если их нет, для владельца ключа.
+func encryptHybridCommand(keysFile string, a *args) error {
+	if len(a.positional) != 2 {
+		return fmt.Errorf("использование: encrypt <входной_файл> <выходной_файл> -mode hybrid")
+	}
+	var recipients []*rsa_alg.PublicKey
+	for _, path := range a.flags["recipient"] {
+		pub, _, err := rsa_alg.ReadKeysFile(path)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, pub)
+	}
+	if len(recipients) == 0 {
+		pub, _, err := rsa_alg.ReadKeysFile(keysFile)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, pub)
+	}
+
+	if err := rsa_alg.EncryptFileHybrid(a.positional[0], a.positional[1], recipients); err != nil {
+		return fmt.Errorf("ошибка шифрования: %w", err)
+	}
+	fmt.Printf("Файл зашифрован для %d получателей и сохранен как %s\n", len(recipients), a.positional[1])
+	return nil
+}
+
+// signCommand подписывает SHA-256 файла и сохраняет подпись в sigPath.
+func signCommand(keysFile, path, sigPath, schemeName string) error {
+	scheme, err := rsa_alg.ParseSignatureScheme(schemeName)
+	if err != nil {
+		return err
+	}
+	_, priv, err := rsa_alg.ReadKeysFile(keysFile)
+	if err != nil {
+		return err
+	}
+	if priv == nil {
+		return fmt.Errorf("в файле %s нет закрытого ключа", keysFile)
+	}
+	sig, err := priv.SignFile(path, scheme)
+	if err != nil {
+		return fmt.Errorf("ошибка подписи: %w", err)
+	}
+	if err := os.WriteFile(sigPath, sig, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Подпись %s сохранена в %s\n", scheme, sigPath)
+	return nil
+}
+
+// verifyCommand проверяет подпись файла. Достаточно файла с открытым ключом.
+func verifyCommand(keysFile, path, sigPath, schemeName string) error {
+	scheme, err := rsa_alg.ParseSignatureScheme(schemeName)
+	if err != nil {
+		return err
+	}
+	pub, _, err := rsa_alg.ReadKeysFile(keysFile)
+	if err != nil {
+		return err
+	}
+	sig, err := os.ReadFile(sigPath)
+	if err != nil {
+		return err
+	}
+	if err := pub.VerifyFile(path, scheme, sig); err != nil {
+		return err
+	}
+	fmt.Printf("Подпись %s верна\n", scheme)
+	return nil
+}
+
+// timingCommand сравнивает время закрытой операции на фиксированном и
+// случайных шифртекстах без защиты, с лестницей Монтгомери, с ослеплением
+// и с обоими.
+func timingCommand(a *args) error {
+	bits, err := a.getInt("bits", 1024)
+	if err != nil {
+		return err
+	}
+	samples, err := a.getInt("n", 2000)
+	if err != nil {
+		return err
+	}
+	fixed, ok := new(big.Int).SetString(a.get("fixed", "2"), 10)
+	if !ok {
+		return fmt.Errorf("неверное значение -fixed: %s", a.get("fixed", ""))
+	}
+	pub, priv, err := rsa_alg.GenerateKey(bits, big.NewInt(65537))
+	if err != nil {
+		return err
+	}
+	if fixed.Cmp(pub.N) >= 0 {
+		return fmt.Errorf("-fixed должно быть меньше модуля")
+	}
+
+	fmt.Printf("Ключ %d бит, %d измерений на класс, фиксированный вход c = %s, порог |t| > %.1f\n",
+		bits, samples, fixed, rsa_alg.TimingThreshold)
+	configs := []struct {
+		name      string
+		hardening rsa_alg.Hardening
+	}{
+		{"big.Int.Exp", rsa_alg.Hardening{}},
+		{"лестница", rsa_alg.Hardening{Ladder: true}},
+		{"ослепление", rsa_alg.Hardening{Blinding: pub.E}},
+		{"лестница+ослепление", rsa_alg.Hardening{Ladder: true, Blinding: pub.E}},
+	}
+	for _, c := range configs {
+		priv.Hardening = c.hardening
+		r := rsa_alg.TimingTest(priv, fixed, samples)
+		verdict := "различий не обнаружено"
+		if r.Leak() {
+			verdict = "УТЕЧКА"
+		}
+		fmt.Printf("  %-22s фикс. %9.0f нс  случ. %9.0f нс  |t| = %7.2f  %s\n",
+			c.name, r.MeanFixed, r.MeanRandom, r.T, verdict)
+	}
+	return nil
+}
+
+// benchCommand сравнивает многопростые ключи: среднее время генерации и
+// расшифровки по КТО, а также расшифровки без КТО (c^d mod n).
+func benchCommand(a *args) error {
+	bits, err := a.getInt("bits", 2048)
+	if err != nil {
+		return err
+	}
+	rounds, err := a.getInt("n", 50)
+	if err != nil {
+		return err
+	}
+	keys, err := a.getInt("count", 3)
+	if err != nil {
+		return err
+	}
+	if rounds < 1 || keys < 1 {
+		return fmt.Errorf("-n и -count должны быть положительными")
+	}
+
+	fmt.Printf("Модуль %d бит, %d ключей на строку, %d расшифровок на ключ\n", bits, keys, rounds)
+	fmt.Printf("  %-7s %14s %14s %14s %9s\n", "простых", "генерация", "КТО", "без КТО", "ускорение")
+	for primes := 2; primes <= rsa_alg.MaxPrimes; primes++ {
+		var genTime, crtTime, plainTime time.Duration
+		for k := 0; k < keys; k++ {
+			start := time.Now()
+			pub, priv, err := rsa_alg.GenerateMultiPrimeKey(bits, primes, big.NewInt(65537))
+			if err != nil {
+				return err
+			}
+			genTime += time.Since(start)
+
+			c, err := rand.Int(rand.Reader, pub.N)
+			if err != nil {
+				return err
+			}
+			start = time.Now()
+			for i := 0; i < rounds; i++ {
+				priv.DecryptInt(c)
+			}
+			crtTime += time.Since(start)
+
+			start = time.Now()
+			for i := 0; i < rounds; i++ {
+				new(big.Int).Exp(c, priv.D, priv.N)
+			}
+			plainTime += time.Since(start)
+		}
+		perOp := time.Duration(keys * rounds)
+		fmt.Printf("  %-7d %14v %14v %14v %8.2fx\n", primes,
+			(genTime / time.Duration(keys)).Round(time.Microsecond),
+			(crtTime / perOp).Round(time.Microsecond),
+			(plainTime / perOp).Round(time.Microsecond),
+			float64(plainTime)/float64(crtTime))
+	}
+	return nil
+}
+
+// rekeyCommand перешифровывает текущим ключом файлы, зашифрованные
+// ключами, выведенными из обращения. Побайтовые файлы без заголовка
+// перешифровываются, только если перечислены в legacy.
+func rekeyCommand(keysFile, root string, legacy []string) error {
+	rsa, err := rsa_alg.NewRSA(true, keysFile)
+	if err != nil {
+		return err
+	}
+	counts := make(map[rsa_alg.RekeyStatus]int)
+	failed, err := rsa.RekeyTree(root, legacy, func(path string, status rsa_alg.RekeyStatus, err error) {
+		if err != nil {
+			fmt.Printf("  [FAIL] %v\n", err)
+			return
+		}
+		counts[status]++
+		switch status {
+		case rsa_alg.RekeyDone:
+			fmt.Printf("  [OK] %s\n", path)
+		case rsa_alg.RekeyUnknown:
+			fmt.Printf("  [??] %s: блочный файл версии 1 без идентификатора ключа\n", path)
+		case rsa_alg.RekeyUnsupported:
+			fmt.Printf("  [--] %s: нет заголовка RSAB, RSAE или RSAH, пропущен\n", path)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Перешифровано: %d, уже текущим ключом: %d, ключ не указан: %d, пропущено: %d, ошибок: %d\n",
+		counts[rsa_alg.RekeyDone], counts[rsa_alg.RekeyCurrent], counts[rsa_alg.RekeyUnknown], counts[rsa_alg.RekeyUnsupported], failed)
+	if failed > 0 {
+		return fmt.Errorf("не удалось перешифровать файлов: %d", failed)
+	}
+	return nil
+}
+
+// inspectCommand печатает параметры ключа, отпечаток и результаты проверок.
+func inspectCommand(keysFile, dir string) error {
+	pub, priv, err := rsa_alg.ReadKeysFile(keysFile)
+	if err != nil {
+		return err
+	}
+	fp, err := pub.Fingerprint()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Файл:        %s\n", keysFile)
+	fmt.Printf("Модуль:      %d бит\n", pub.N.BitLen())
+	fmt.Printf("Экспонента:  %s\n", pub.E)
+	fmt.Printf("Закрытый:    %t\n", priv != nil)
+	if priv != nil && len(priv.Primes()) > 0 {
+		fmt.Printf("Простых:     %d\n", len(priv.Primes()))
+	}
+	fmt.Printf("Отпечаток:   %s\n", rsa_alg.FingerprintString(fp))
+	fmt.Print(rsa_alg.Randomart(fp, fmt.Sprintf("RSA %d", pub.N.BitLen())))
+
+	if keyring, err := rsa_alg.ReadKeyring(keysFile); err == nil && len(keyring.Retired) > 0 {
+		fmt.Println("Выведенные из обращения ключи:")
+		for _, pair := range keyring.Retired {
+			fmt.Printf("  %s  %d бит  %s\n", pair.ID, pair.Public.N.BitLen(), pair.RetiredAt.Format(time.RFC3339))
+		}
+	}
+
+	fmt.Println("Проверки:")
+	for _, f := range rsa_alg.CheckKey(pub, priv) {
+		fmt.Printf("  [%s] %s\n", f.Severity, f.Message)
+	}
+
+	if dir == "" {
+		return nil
+	}
+	keys, err := rsa_alg.LoadPublicKeys(dir)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(keys, func(k rsa_alg.KeyFile) bool { return sameFile(k.Path, keysFile) }) {
+		keys = append(keys, rsa_alg.KeyFile{Path: keysFile, Key: pub})
+	}
+	shared := rsa_alg.FindSharedFactors(keys)
+	fmt.Printf("Общие множители среди %d ключей каталога %s:\n", len(keys), dir)
+	if len(shared) == 0 {
+		fmt.Println("  [OK] не найдены")
+	}
+	for _, s := range shared {
+		if s.Same {
+			fmt.Printf("  [FAIL] %s и %s: одинаковый модуль\n", s.A, s.B)
+			continue
+		}
+		fmt.Printf("  [FAIL] %s и %s: НОД = %s\n", s.A, s.B, s.Factor)
+	}
+	return nil
+}
+
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
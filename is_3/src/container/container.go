@@ -0,0 +1,189 @@
+package container
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Заголовок блочного файла, общий для rsa_alg, elgamal и paillier:
+//
+//	magic   [4]byte  сигнатура алгоритма, например "RSAB"
+//	version uint8    Version
+//	padding uint8    схема дополнения, смысл зависит от алгоритма
+//	modLen  uint16   длина модуля в байтах
+//	keyID   [8]byte  идентификатор ключа (с версии 2)
+//	blocks  uint64   число блоков
+//
+// Далее идут blocks блоков одинаковой длины, которую определяет алгоритм.
+// Файлы версии 1 не содержат keyID.
+const (
+	Version   = 2
+	KeyIDSize = 8
+	MagicSize = 4
+)
+
+// ErrTrailingData после последнего блока есть данные
+var ErrTrailingData = errors.New("container: лишние данные после последнего блока")
+
+// Header заголовок блочного файла
+type Header struct {
+	Magic   string
+	Version byte
+	Padding byte
+	ModLen  uint16
+	KeyID   []byte
+	Blocks  uint64
+}
+
+// Size длина заголовка в байтах.
+func (h *Header) Size() int {
+	if h.Version == 1 {
+		return MagicSize + 1 + 1 + 2 + 8
+	}
+	return MagicSize + 1 + 1 + 2 + KeyIDSize + 8
+}
+
+func (h *Header) Marshal() []byte {
+	buf := make([]byte, 0, h.Size())
+	buf = append(buf, h.Magic...)
+	buf = append(buf, h.Version, h.Padding)
+	buf = binary.BigEndian.AppendUint16(buf, h.ModLen)
+	if h.Version >= 2 {
+		buf = append(buf, h.KeyID...)
+	}
+	return binary.BigEndian.AppendUint64(buf, h.Blocks)
+}
+
+// ReadHeader читает заголовок и проверяет сигнатуру magic.
+func ReadHeader(r io.Reader, magic string) (*Header, error) {
+	fixed := make([]byte, MagicSize+1+1+2)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("ReadHeader: %w", err)
+	}
+	if string(fixed[:MagicSize]) != magic {
+		return nil, fmt.Errorf("ReadHeader: неверная сигнатура %q, ожидается %q", fixed[:MagicSize], magic)
+	}
+	h := &Header{
+		Magic:   magic,
+		Version: fixed[4],
+		Padding: fixed[5],
+		ModLen:  binary.BigEndian.Uint16(fixed[6:]),
+	}
+	if h.Version != 1 && h.Version != Version {
+		return nil, fmt.Errorf("ReadHeader: неподдерживаемая версия %d", h.Version)
+	}
+
+	rest := make([]byte, h.Size()-len(fixed))
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, fmt.Errorf("ReadHeader: %w", err)
+	}
+	if h.Version >= 2 {
+		h.KeyID = rest[:KeyIDSize]
+		rest = rest[KeyIDSize:]
+	}
+	h.Blocks = binary.BigEndian.Uint64(rest)
+	return h, nil
+}
+
+// PeekMagic возвращает первые 4 байта потока, не извлекая их. Если поток
+// короче, возвращается пустая строка.
+func PeekMagic(r *bufio.Reader) string {
+	magic, err := r.Peek(MagicSize)
+	if err != nil {
+		return ""
+	}
+	return string(magic)
+}
+
+// WriteBlocks пишет заголовок и шифрует поток фрагментами по chunkSize
+// байт. encrypt шифрует фрагмент в блок ровно blockSize байт. Число блоков
+// заранее неизвестно, поэтому оно дописывается в заголовок по окончании.
+func WriteBlocks(input io.Reader, output io.WriterAt, header *Header, chunkSize, blockSize int, encrypt func(chunk, block []byte) error) error {
+	reader := bufio.NewReader(input)
+	writer := bufio.NewWriter(io.NewOffsetWriter(output, 0))
+	header.Blocks = 0
+	if _, err := writer.Write(header.Marshal()); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, chunkSize)
+	block := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("WriteBlocks: блок %d: %w", header.Blocks, err)
+		}
+		if eerr := encrypt(chunk[:n], block); eerr != nil {
+			return fmt.Errorf("WriteBlocks: блок %d: %w", header.Blocks, eerr)
+		}
+		if _, err := writer.Write(block); err != nil {
+			return err
+		}
+		header.Blocks++
+
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	// Число блоков записывается в конец заголовка
+	_, err := output.WriteAt(binary.BigEndian.AppendUint64(nil, header.Blocks), int64(header.Size()-8))
+	return err
+}
+
+// ReadBlocks читает header.Blocks блоков по blockSize байт, следующих за
+// заголовком, и пишет результат decrypt. После последнего блока данных
+// быть не должно.
+func ReadBlocks(reader io.Reader, writer io.Writer, header *Header, blockSize int, decrypt func(block []byte) ([]byte, error)) error {
+	block := make([]byte, blockSize)
+	for i := uint64(0); i < header.Blocks; i++ {
+		if _, err := io.ReadFull(reader, block); err != nil {
+			return fmt.Errorf("ReadBlocks: блок %d: %w", i, err)
+		}
+		msg, err := decrypt(block)
+		if err != nil {
+			return fmt.Errorf("ReadBlocks: блок %d: %w", i, err)
+		}
+		if _, err := writer.Write(msg); err != nil {
+			return err
+		}
+	}
+	if n, _ := reader.Read(block[:1]); n != 0 {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+// EmbedChunk превращает фрагмент в число 0x01 || chunk. Ведущий байт 0x01
+// сохраняет ведущие нули фрагмента и гарантирует m ≠ 0. Для модуля длиной
+// k байт фрагмент не должен быть длиннее ChunkSize(k).
+func EmbedChunk(chunk []byte) *big.Int {
+	m := make([]byte, 0, len(chunk)+1)
+	m = append(m, 1)
+	return new(big.Int).SetBytes(append(m, chunk...))
+}
+
+// ExtractChunk обращает EmbedChunk.
+func ExtractChunk(m *big.Int) ([]byte, error) {
+	b := m.Bytes()
+	if len(b) == 0 || b[0] != 1 {
+		return nil, errors.New("ExtractChunk: неверное кодирование блока")
+	}
+	return b[1:], nil
+}
+
+// ChunkSize наибольшая длина фрагмента для EmbedChunk, при которой число
+// меньше любого модуля длиной k байт.
+func ChunkSize(k int) int {
+	return k - 2
+}
@@ -0,0 +1,97 @@
+package container
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Файлы ключей elgamal и paillier хранятся в JSON (числа — десятичные
+// строки) или в PEM, если имя файла оканчивается на ".pem". PEM-блоки
+// содержат DER-последовательность чисел ключа. Стандартных форматов для
+// этих схем нет, поэтому типы блоков свои ("ELGAMAL PUBLIC KEY" и т.п.)
+// и openssl такие файлы не читает.
+
+// IsPEMPath сообщает, что ключи по пути path хранятся в PEM.
+func IsPEMPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pem")
+}
+
+// WriteKeysFile сохраняет ключи: в PEM-файл пишутся blocks, в остальные
+// файлы — keys в JSON.
+func WriteKeysFile(path string, keys any, blocks []*pem.Block) error {
+	if IsPEMPath(path) {
+		var buf bytes.Buffer
+		for _, block := range blocks {
+			if err := pem.Encode(&buf, block); err != nil {
+				return err
+			}
+		}
+		return os.WriteFile(path, buf.Bytes(), 0644)
+	}
+
+	jsonData, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, jsonData, 0644)
+}
+
+// ReadKeysFile читает файл ключей. Для PEM-файла возвращаются его блоки,
+// иначе JSON разбирается в keys и блоков нет.
+func ReadKeysFile(path string, keys any) ([]*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !IsPEMPath(path) {
+		if err := json.Unmarshal(data, keys); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return nil, nil
+	}
+
+	var blocks []*pem.Block
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("%s: PEM-блоки не найдены", path)
+	}
+	return blocks, nil
+}
+
+// FindPEMBlock возвращает первый блок типа typ или nil.
+func FindPEMBlock(blocks []*pem.Block, typ string) *pem.Block {
+	for _, block := range blocks {
+		if block.Type == typ {
+			return block
+		}
+	}
+	return nil
+}
+
+// ParseInt разбирает десятичное число из файла ключей.
+func ParseInt(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("неверное число %q", s)
+	}
+	return n, nil
+}
+
+// FileExists сообщает, что path существует и это не каталог.
+func FileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
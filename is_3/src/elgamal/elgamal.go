@@ -0,0 +1,381 @@
+package elgamal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"is_3/src/container"
+	"math/big"
+)
+
+// minGroupBits минимальный размер простого p для NewElGamal
+const minGroupBits = 64
+
+// group14P простое MODP-группы 14 (2048 бит), RFC 3526 раздел 3.
+// p = 2^2048 - 2^1984 - 1 + 2^64·(⌊2^1918·π⌋ + 124476), безопасное:
+// (p-1)/2 тоже простое. Генератор g = 2.
+const group14P = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+	"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+	"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+	"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+	"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+	"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+	"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+	"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF"
+
+var one = big.NewInt(1)
+
+// ElGamal схема Эль-Гамаля над Z_p^*, p = 2q + 1 — безопасное простое.
+// Устроена как rsa_alg.RSA: ключи хранятся в JSON- или PEM-файле, файлы
+// шифруются блоками в контейнере с общим заголовком (см. EncryptFile).
+type ElGamal struct {
+	publicKey  *PublicKey
+	privateKey *PrivateKey
+	keysFile   string
+	bits       int
+}
+
+// PublicKey открытый ключ: группа (p, g) и y = g^x mod p
+type PublicKey struct {
+	P *big.Int
+	G *big.Int
+	Y *big.Int
+}
+
+// PrivateKey закрытый ключ: группа (p, g) и показатель x
+type PrivateKey struct {
+	P *big.Int
+	G *big.Int
+	X *big.Int
+}
+
+// Ciphertext шифртекст (c1, c2) = (g^k, m·y^k)
+type Ciphertext struct {
+	C1 *big.Int
+	C2 *big.Int
+}
+
+// NewElGamal загружает ключи из файла или генерирует новые. bits == 0
+// означает 2048-битную группу RFC 3526, иначе генерируется безопасное
+// простое длиной bits бит (для больших bits это долго).
+func NewElGamal(loadKeys bool, keysFileName string, bits int) (*ElGamal, error) {
+	if bits != 0 && bits < minGroupBits {
+		return nil, fmt.Errorf("NewElGamal: размер группы %d бит меньше минимального %d", bits, minGroupBits)
+	}
+	e := &ElGamal{
+		keysFile: keysFileName,
+		bits:     bits,
+	}
+
+	if loadKeys && container.FileExists(e.keysFile) {
+		pub, priv, err := ReadKeysFile(e.keysFile)
+		if err != nil {
+			return nil, fmt.Errorf("NewElGamal: %w", err)
+		}
+		if priv == nil {
+			return nil, fmt.Errorf("NewElGamal: в файле %s нет закрытого ключа", e.keysFile)
+		}
+		e.publicKey, e.privateKey = pub, priv
+		return e, nil
+	}
+
+	p, g := Group14()
+	if bits != 0 {
+		var err error
+		if p, g, err = GenerateGroup(bits); err != nil {
+			return nil, fmt.Errorf("NewElGamal: %w", err)
+		}
+	}
+	pub, priv, err := GenerateKey(p, g)
+	if err != nil {
+		return nil, fmt.Errorf("NewElGamal: %w", err)
+	}
+	e.publicKey, e.privateKey = pub, priv
+	if err := WriteKeysFile(e.keysFile, pub, priv); err != nil {
+		return nil, fmt.Errorf("NewElGamal: %w", err)
+	}
+	return e, nil
+}
+
+// NewElGamalFromKeys создает ElGamal из готовой пары ключей без файла.
+// priv может быть nil, тогда доступно только шифрование.
+func NewElGamalFromKeys(pub *PublicKey, priv *PrivateKey) *ElGamal {
+	return &ElGamal{publicKey: pub, privateKey: priv}
+}
+
+// PublicKey возвращает открытый ключ.
+func (e *ElGamal) PublicKey() *PublicKey {
+	return e.publicKey
+}
+
+// PrivateKey возвращает закрытый ключ.
+func (e *ElGamal) PrivateKey() *PrivateKey {
+	return e.privateKey
+}
+
+// Group14 возвращает 2048-битную группу RFC 3526: p и g = 2.
+func Group14() (p, g *big.Int) {
+	p, _ = new(big.Int).SetString(group14P, 16)
+	return p, big.NewInt(2)
+}
+
+// GenerateGroup генерирует безопасное простое p = 2q + 1 длиной bits бит
+// и генератор g подгруппы порядка q (квадратичных вычетов).
+func GenerateGroup(bits int) (p, g *big.Int, err error) {
+	for {
+		q, err := rand.Prime(rand.Reader, bits-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		p = new(big.Int).Lsh(q, 1)
+		p.Add(p, one)
+		if p.BitLen() == bits && p.ProbablyPrime(20) {
+			break
+		}
+	}
+	for {
+		h, err := rand.Int(rand.Reader, p)
+		if err != nil {
+			return nil, nil, err
+		}
+		g = h.Exp(h, big.NewInt(2), p)
+		if g.Cmp(one) > 0 {
+			return p, g, nil
+		}
+	}
+}
+
+// GenerateKey выбирает случайный x из [1, q-1] и вычисляет y = g^x mod p.
+func GenerateKey(p, g *big.Int) (*PublicKey, *PrivateKey, error) {
+	x, err := randExponent(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	pub := &PublicKey{
+		P: new(big.Int).Set(p),
+		G: new(big.Int).Set(g),
+		Y: new(big.Int).Exp(g, x, p),
+	}
+	priv := &PrivateKey{P: pub.P, G: pub.G, X: x}
+	return pub, priv, nil
+}
+
+// randExponent случайное число из [1, q-1], q = (p-1)/2.
+func randExponent(p *big.Int) (*big.Int, error) {
+	q := new(big.Int).Rsh(p, 1)
+	k, err := rand.Int(rand.Reader, new(big.Int).Sub(q, one))
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, one), nil
+}
+
+// Size возвращает длину p в байтах.
+func (pub *PublicKey) Size() int {
+	return (pub.P.BitLen() + 7) / 8
+}
+
+// Encrypt шифрует число 0 < m < p со случайным k:
+// c1 = g^k mod p, c2 = m·y^k mod p.
+//
+// Сообщение не отображается в подгруппу квадратичных вычетов, поэтому
+// шифртекст выдает символ Лежандра m. Для учебной схемы это допустимо.
+func (pub *PublicKey) Encrypt(m *big.Int) (*Ciphertext, error) {
+	if m.Sign() <= 0 || m.Cmp(pub.P) >= 0 {
+		return nil, errors.New("Encrypt: сообщение вне диапазона (0, p)")
+	}
+	k, err := randExponent(pub.P)
+	if err != nil {
+		return nil, err
+	}
+	c2 := new(big.Int).Exp(pub.Y, k, pub.P)
+	c2.Mul(c2, m).Mod(c2, pub.P)
+	return &Ciphertext{C1: new(big.Int).Exp(pub.G, k, pub.P), C2: c2}, nil
+}
+
+// Decrypt вычисляет m = c2·(c1^x)⁻¹ mod p.
+func (priv *PrivateKey) Decrypt(c *Ciphertext) (*big.Int, error) {
+	if c.C1.Sign() <= 0 || c.C1.Cmp(priv.P) >= 0 || c.C2.Sign() <= 0 || c.C2.Cmp(priv.P) >= 0 {
+		return nil, errors.New("Decrypt: шифртекст вне диапазона (0, p)")
+	}
+	s := new(big.Int).Exp(c.C1, priv.X, priv.P)
+	if s.ModInverse(s, priv.P) == nil {
+		return nil, errors.New("Decrypt: c1 необратимо по модулю p")
+	}
+	return s.Mul(s, c.C2).Mod(s, priv.P), nil
+}
+
+// Mul перемножает шифртексты: Decrypt(Mul(E(a), E(b))) = a·b mod p.
+// Мультипликативный гомоморфизм схемы Эль-Гамаля.
+func (pub *PublicKey) Mul(a, b *Ciphertext) *Ciphertext {
+	return &Ciphertext{
+		C1: new(big.Int).Mod(new(big.Int).Mul(a.C1, b.C1), pub.P),
+		C2: new(big.Int).Mod(new(big.Int).Mul(a.C2, b.C2), pub.P),
+	}
+}
+
+// Rerandomize умножает шифртекст на E(1): открытый текст не меняется,
+// а шифртекст становится неотличим от свежего.
+func (pub *PublicKey) Rerandomize(c *Ciphertext) (*Ciphertext, error) {
+	unit, err := pub.Encrypt(one)
+	if err != nil {
+		return nil, err
+	}
+	return pub.Mul(c, unit), nil
+}
+
+// KeyID короткий идентификатор ключа: первые 8 байт SHA-256 от DER (p, g, y).
+func (pub *PublicKey) KeyID() (string, error) {
+	der, err := asn1.Marshal(publicKeyASN1{pub.P, pub.G, pub.Y})
+	if err != nil {
+		return "", fmt.Errorf("KeyID: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// Формат файла ключей (elgamal_keys.json), числа — десятичные строки.
+// В PEM-файле (elgamal_keys.pem) те же числа лежат DER-последовательностями
+// в блоках pemPublicType и pemPrivateType, см. container.WriteKeysFile.
+type keysJSON struct {
+	PrivateKey *privateKeyJSON `json:"private_key,omitempty"`
+	PublicKey  *publicKeyJSON  `json:"public_key"`
+}
+
+type publicKeyJSON struct {
+	P string `json:"p"`
+	G string `json:"g"`
+	Y string `json:"y"`
+}
+
+type privateKeyJSON struct {
+	P string `json:"p"`
+	G string `json:"g"`
+	X string `json:"x"`
+}
+
+const (
+	pemPublicType  = "ELGAMAL PUBLIC KEY"
+	pemPrivateType = "ELGAMAL PRIVATE KEY"
+)
+
+type publicKeyASN1 struct{ P, G, Y *big.Int }
+
+type privateKeyASN1 struct{ P, G, X *big.Int }
+
+// WriteKeysFile сохраняет ключи в JSON или, если имя оканчивается на
+// ".pem", в PEM. priv может быть nil, тогда записывается только открытый
+// ключ.
+func WriteKeysFile(path string, pub *PublicKey, priv *PrivateKey) error {
+	keys := keysJSON{
+		PublicKey: &publicKeyJSON{P: pub.P.String(), G: pub.G.String(), Y: pub.Y.String()},
+	}
+	der, err := asn1.Marshal(publicKeyASN1{pub.P, pub.G, pub.Y})
+	if err != nil {
+		return err
+	}
+	blocks := []*pem.Block{{Type: pemPublicType, Bytes: der}}
+	if priv != nil {
+		keys.PrivateKey = &privateKeyJSON{P: priv.P.String(), G: priv.G.String(), X: priv.X.String()}
+		if der, err = asn1.Marshal(privateKeyASN1{priv.P, priv.G, priv.X}); err != nil {
+			return err
+		}
+		blocks = append(blocks, &pem.Block{Type: pemPrivateType, Bytes: der})
+	}
+	return container.WriteKeysFile(path, keys, blocks)
+}
+
+// ReadKeysFile читает ключи из JSON или PEM. Если в файле только открытый
+// ключ, priv равен nil.
+func ReadKeysFile(path string) (pub *PublicKey, priv *PrivateKey, err error) {
+	var keys keysJSON
+	blocks, err := container.ReadKeysFile(path, &keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	if blocks != nil {
+		pub, priv, err = parsePEM(blocks)
+	} else {
+		pub, priv, err = keys.parse()
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if priv == nil {
+		return pub, nil, nil
+	}
+	if priv.P.Cmp(pub.P) != 0 || priv.G.Cmp(pub.G) != 0 || new(big.Int).Exp(priv.G, priv.X, priv.P).Cmp(pub.Y) != 0 {
+		return nil, nil, fmt.Errorf("%s: открытый и закрытый ключи не соответствуют друг другу", path)
+	}
+	return pub, priv, nil
+}
+
+func parsePEM(blocks []*pem.Block) (*PublicKey, *PrivateKey, error) {
+	block := container.FindPEMBlock(blocks, pemPublicType)
+	if block == nil {
+		return nil, nil, errors.New("нет открытого ключа")
+	}
+	var p publicKeyASN1
+	if _, err := asn1.Unmarshal(block.Bytes, &p); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", pemPublicType, err)
+	}
+	pub := &PublicKey{P: p.P, G: p.G, Y: p.Y}
+
+	block = container.FindPEMBlock(blocks, pemPrivateType)
+	if block == nil {
+		return pub, nil, nil
+	}
+	var x privateKeyASN1
+	if _, err := asn1.Unmarshal(block.Bytes, &x); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", pemPrivateType, err)
+	}
+	return pub, &PrivateKey{P: x.P, G: x.G, X: x.X}, nil
+}
+
+func (keys *keysJSON) parse() (pub *PublicKey, priv *PrivateKey, err error) {
+	if keys.PublicKey == nil {
+		return nil, nil, errors.New("нет открытого ключа")
+	}
+	if pub, err = keys.PublicKey.parse(); err != nil {
+		return nil, nil, err
+	}
+	if keys.PrivateKey == nil {
+		return pub, nil, nil
+	}
+	if priv, err = keys.PrivateKey.parse(); err != nil {
+		return nil, nil, err
+	}
+	return pub, priv, nil
+}
+
+func (j *publicKeyJSON) parse() (pub *PublicKey, err error) {
+	pub = &PublicKey{}
+	if pub.P, err = container.ParseInt(j.P); err != nil {
+		return nil, fmt.Errorf("p: %w", err)
+	}
+	if pub.G, err = container.ParseInt(j.G); err != nil {
+		return nil, fmt.Errorf("g: %w", err)
+	}
+	if pub.Y, err = container.ParseInt(j.Y); err != nil {
+		return nil, fmt.Errorf("y: %w", err)
+	}
+	return pub, nil
+}
+
+func (j *privateKeyJSON) parse() (priv *PrivateKey, err error) {
+	priv = &PrivateKey{}
+	if priv.P, err = container.ParseInt(j.P); err != nil {
+		return nil, fmt.Errorf("p: %w", err)
+	}
+	if priv.G, err = container.ParseInt(j.G); err != nil {
+		return nil, fmt.Errorf("g: %w", err)
+	}
+	if priv.X, err = container.ParseInt(j.X); err != nil {
+		return nil, fmt.Errorf("x: %w", err)
+	}
+	return priv, nil
+}
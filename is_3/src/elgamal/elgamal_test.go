@@ -0,0 +1,106 @@
+package elgamal
+
+import (
+	"crypto/rand"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestKeysFile сохраняет ключи в JSON и PEM и читает их обратно.
+func TestKeysFile(t *testing.T) {
+	p, g := Group14()
+	pub, priv, err := GenerateKey(p, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"keys.json", "keys.pem"} {
+		path := filepath.Join(dir, name)
+		if err := WriteKeysFile(path, pub, priv); err != nil {
+			t.Fatal(err)
+		}
+		gotPub, gotPriv, err := ReadKeysFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if gotPub.P.Cmp(p) != 0 || gotPub.G.Cmp(g) != 0 || gotPub.Y.Cmp(pub.Y) != 0 || gotPriv.X.Cmp(priv.X) != 0 {
+			t.Fatalf("%s: прочитаны другие ключи", name)
+		}
+
+		// Только открытый ключ
+		if err := WriteKeysFile(path, pub, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, gotPriv, err := ReadKeysFile(path); err != nil || gotPriv != nil {
+			t.Fatalf("%s без закрытого ключа: %v, %v", name, gotPriv, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "keys.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "-----BEGIN "+pemPublicType+"-----") {
+		t.Fatalf("PEM-файл начинается с %q", data[:min(len(data), 40)])
+	}
+
+	// Закрытый ключ от другой пары не принимается
+	_, other, err := GenerateKey(p, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"mixed.json", "mixed.pem"} {
+		path := filepath.Join(dir, name)
+		if err := WriteKeysFile(path, pub, other); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := ReadKeysFile(path); err == nil {
+			t.Errorf("%s: ключи разных пар не отклонены", name)
+		}
+	}
+}
+
+// TestHomomorphic: D(E(a)·E(b)) = a·b mod p, в том числе после
+// перерандомизации.
+func TestHomomorphic(t *testing.T) {
+	p, g := Group14()
+	pub, priv, err := GenerateKey(p, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		a, err := rand.Int(rand.Reader, new(big.Int).Sub(p, one))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := rand.Int(rand.Reader, new(big.Int).Sub(p, one))
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.Add(a, one)
+		b.Add(b, one)
+		ca, err := pub.Encrypt(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cb, err := pub.Encrypt(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rerandomized, err := pub.Rerandomize(pub.Mul(ca, cb))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := priv.Decrypt(rerandomized)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := new(big.Int).Mul(a, b)
+		if got.Cmp(want.Mod(want, p)) != 0 {
+			t.Fatal("D(E(a)·E(b)) ≠ a·b mod p")
+		}
+	}
+}
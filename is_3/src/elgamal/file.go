@@ -0,0 +1,121 @@
+package elgamal
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"is_3/src/container"
+	"math/big"
+	"os"
+)
+
+// Формат файла: заголовок container.Header с сигнатурой "EGAB" и
+// modLen = длина p в байтах. Каждый блок — c1 || c2, по modLen байт,
+// и содержит до container.ChunkSize(modLen) байт открытого текста,
+// закодированных container.EmbedChunk.
+const blockMagic = "EGAB"
+
+// ErrWrongKey файл зашифрован другим ключом
+var ErrWrongKey = errors.New("elgamal: файл зашифрован другим ключом")
+
+// EncryptFile шифрует файл открытым ключом.
+func (e *ElGamal) EncryptFile(inputPath, outputPath string) error {
+	inputFile, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	return e.encryptStream(inputFile, outputFile)
+}
+
+func (e *ElGamal) encryptStream(input io.Reader, output io.WriterAt) error {
+	pub := e.publicKey
+	k := pub.Size()
+	if container.ChunkSize(k) < 1 {
+		return fmt.Errorf("EncryptFile: группа %d бит слишком мала", pub.P.BitLen())
+	}
+	id, err := pub.KeyID()
+	if err != nil {
+		return err
+	}
+	keyID, _ := hex.DecodeString(id)
+
+	header := &container.Header{
+		Magic:   blockMagic,
+		Version: container.Version,
+		ModLen:  uint16(k),
+		KeyID:   keyID,
+	}
+	return container.WriteBlocks(input, output, header, container.ChunkSize(k), 2*k, func(chunk, block []byte) error {
+		c, err := pub.Encrypt(container.EmbedChunk(chunk))
+		if err != nil {
+			return err
+		}
+		c.C1.FillBytes(block[:k])
+		c.C2.FillBytes(block[k:])
+		return nil
+	})
+}
+
+// DecryptFile расшифровывает файл, записанный EncryptFile.
+func (e *ElGamal) DecryptFile(inputPath, outputPath string) error {
+	inputFile, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	writer := bufio.NewWriter(outputFile)
+	if err := e.decryptStream(bufio.NewReader(inputFile), writer); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func (e *ElGamal) decryptStream(reader io.Reader, writer io.Writer) error {
+	header, err := container.ReadHeader(reader, blockMagic)
+	if err != nil {
+		return fmt.Errorf("DecryptFile: %w", err)
+	}
+	id, err := e.publicKey.KeyID()
+	if err != nil {
+		return err
+	}
+	if header.KeyID != nil && hex.EncodeToString(header.KeyID) != id {
+		return fmt.Errorf("DecryptFile: %w: %x", ErrWrongKey, header.KeyID)
+	}
+	k := e.publicKey.Size()
+	if int(header.ModLen) != k {
+		return fmt.Errorf("DecryptFile: %w: модуль %d байт", ErrWrongKey, header.ModLen)
+	}
+
+	err = container.ReadBlocks(reader, writer, header, 2*k, func(block []byte) ([]byte, error) {
+		m, err := e.privateKey.Decrypt(&Ciphertext{
+			C1: new(big.Int).SetBytes(block[:k]),
+			C2: new(big.Int).SetBytes(block[k:]),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return container.ExtractChunk(m)
+	})
+	if err != nil {
+		return fmt.Errorf("DecryptFile: %w", err)
+	}
+	return nil
+}
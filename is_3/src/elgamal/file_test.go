@@ -0,0 +1,83 @@
+package elgamal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"is_3/src/container"
+	"is_3/src/paillier"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFile шифрует файлы разной длины, в том числе пустой и кратный
+// размеру блока, проверяет расшифровку, отказ чужому ключу и отказ
+// расшифровать файл Пэйе.
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	// Небольшая группа, чтобы генерация безопасного простого была быстрой
+	keysFile := filepath.Join(dir, "owner.pem")
+	owner, err := NewElGamal(false, keysFile, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewElGamal(true, keysFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.PublicKey().Y.Cmp(owner.PublicKey().Y) != 0 {
+		t.Fatal("из файла прочитан другой ключ")
+	}
+	p, g := Group14()
+	pub, priv, err := GenerateKey(p, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger := NewElGamalFromKeys(pub, priv)
+	other, err := paillier.NewPaillier(false, filepath.Join(dir, "paillier.json"), 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		owner, stranger *ElGamal
+	}{
+		{loaded, stranger},
+		{stranger, loaded},
+	} {
+		chunk := container.ChunkSize(c.owner.PublicKey().Size())
+		for _, size := range []int{0, 1, chunk, 3*chunk + 7} {
+			plain := filepath.Join(dir, fmt.Sprintf("plain%d.bin", size))
+			encrypted := plain + ".enc"
+			decrypted := plain + ".dec"
+			want := make([]byte, size)
+			if _, err := rand.Read(want); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(plain, want, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.owner.EncryptFile(plain, encrypted); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.owner.DecryptFile(encrypted, decrypted); err != nil {
+				t.Fatalf("%d байт: %v", size, err)
+			}
+			got, err := os.ReadFile(decrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%d байт: расшифрованный текст не совпадает с исходным", size)
+			}
+			if err := c.stranger.DecryptFile(encrypted, decrypted); !errors.Is(err, ErrWrongKey) {
+				t.Fatalf("чужой ключ: ожидалась ошибка %v, получено %v", ErrWrongKey, err)
+			}
+			if err := other.DecryptFile(encrypted, decrypted); err == nil {
+				t.Fatal("файл расшифрован схемой Пэйе")
+			}
+		}
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"is_3/src/container"
 	"is_3/src/rsa_alg"
+	"os"
 )
 
 const (
@@ -32,6 +34,11 @@ func main2() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		runCLI()
+		return
+	}
+
 	fmt.Println("\nВыберите действие:")
 	fmt.Println("1. Сгенерировать новые ключи")
 	fmt.Println("2. Зашифровать файл")
@@ -67,7 +74,7 @@ func main() {
 			fmt.Print("Введите путь к файлу для шифрования: ")
 			fmt.Scan(&inputFile)
 
-			if !rsa_alg.FileExists(inputFile) {
+			if !container.FileExists(inputFile) {
 				fmt.Println("Файл не найден!")
 				continue
 			}
@@ -87,7 +94,7 @@ func main() {
 			fmt.Print("Введите путь к зашифрованному файлу: ")
 			fmt.Scan(&inputFile)
 
-			if !rsa_alg.FileExists(inputFile) {
+			if !container.FileExists(inputFile) {
 				fmt.Println("Файл не найден!")
 				continue
 			}
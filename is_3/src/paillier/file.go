@@ -0,0 +1,117 @@
+package paillier
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"is_3/src/container"
+	"math/big"
+	"os"
+)
+
+// Формат файла: заголовок container.Header с сигнатурой "PAIB" и
+// modLen = длина n в байтах. Каждый блок — шифртекст по модулю n² длиной
+// 2·modLen байт и содержит до container.ChunkSize(modLen) байт открытого
+// текста, закодированных container.EmbedChunk.
+const blockMagic = "PAIB"
+
+// ErrWrongKey файл зашифрован другим ключом
+var ErrWrongKey = errors.New("paillier: файл зашифрован другим ключом")
+
+// EncryptFile шифрует файл открытым ключом.
+func (p *Paillier) EncryptFile(inputPath, outputPath string) error {
+	inputFile, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	return p.encryptStream(inputFile, outputFile)
+}
+
+func (p *Paillier) encryptStream(input io.Reader, output io.WriterAt) error {
+	pub := p.publicKey
+	k := pub.Size()
+	if container.ChunkSize(k) < 1 {
+		return fmt.Errorf("EncryptFile: модуль %d бит слишком мал", pub.N.BitLen())
+	}
+	id, err := pub.KeyID()
+	if err != nil {
+		return err
+	}
+	keyID, _ := hex.DecodeString(id)
+
+	header := &container.Header{
+		Magic:   blockMagic,
+		Version: container.Version,
+		ModLen:  uint16(k),
+		KeyID:   keyID,
+	}
+	return container.WriteBlocks(input, output, header, container.ChunkSize(k), 2*k, func(chunk, block []byte) error {
+		c, err := pub.Encrypt(container.EmbedChunk(chunk))
+		if err != nil {
+			return err
+		}
+		c.FillBytes(block)
+		return nil
+	})
+}
+
+// DecryptFile расшифровывает файл, записанный EncryptFile.
+func (p *Paillier) DecryptFile(inputPath, outputPath string) error {
+	inputFile, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	writer := bufio.NewWriter(outputFile)
+	if err := p.decryptStream(bufio.NewReader(inputFile), writer); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func (p *Paillier) decryptStream(reader io.Reader, writer io.Writer) error {
+	header, err := container.ReadHeader(reader, blockMagic)
+	if err != nil {
+		return fmt.Errorf("DecryptFile: %w", err)
+	}
+	id, err := p.publicKey.KeyID()
+	if err != nil {
+		return err
+	}
+	if header.KeyID != nil && hex.EncodeToString(header.KeyID) != id {
+		return fmt.Errorf("DecryptFile: %w: %x", ErrWrongKey, header.KeyID)
+	}
+	k := p.publicKey.Size()
+	if int(header.ModLen) != k {
+		return fmt.Errorf("DecryptFile: %w: модуль %d байт", ErrWrongKey, header.ModLen)
+	}
+
+	err = container.ReadBlocks(reader, writer, header, 2*k, func(block []byte) ([]byte, error) {
+		m, err := p.privateKey.Decrypt(new(big.Int).SetBytes(block))
+		if err != nil {
+			return nil, err
+		}
+		return container.ExtractChunk(m)
+	})
+	if err != nil {
+		return fmt.Errorf("DecryptFile: %w", err)
+	}
+	return nil
+}
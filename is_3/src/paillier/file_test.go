@@ -0,0 +1,82 @@
+package paillier
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"is_3/src/container"
+	"is_3/src/elgamal"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFile шифрует файлы разной длины, в том числе пустой и кратный
+// размеру блока, проверяет расшифровку, отказ чужому ключу и отказ
+// расшифровать файл Эль-Гамаля.
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	keysFile := filepath.Join(dir, "owner.pem")
+	owner, err := NewPaillier(false, keysFile, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewPaillier(true, keysFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.PublicKey().N.Cmp(owner.PublicKey().N) != 0 {
+		t.Fatal("из файла прочитан другой ключ")
+	}
+	pub, priv, err := GenerateKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger := NewPaillierFromKeys(pub, priv)
+	// Небольшая группа, чтобы генерация безопасного простого была быстрой
+	other, err := elgamal.NewElGamal(false, filepath.Join(dir, "elgamal.json"), 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		owner, stranger *Paillier
+	}{
+		{loaded, stranger},
+		{stranger, loaded},
+	} {
+		chunk := container.ChunkSize(c.owner.PublicKey().Size())
+		for _, size := range []int{0, 1, chunk, 3*chunk + 7} {
+			plain := filepath.Join(dir, fmt.Sprintf("plain%d.bin", size))
+			encrypted := plain + ".enc"
+			decrypted := plain + ".dec"
+			want := make([]byte, size)
+			if _, err := rand.Read(want); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(plain, want, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.owner.EncryptFile(plain, encrypted); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.owner.DecryptFile(encrypted, decrypted); err != nil {
+				t.Fatalf("%d байт: %v", size, err)
+			}
+			got, err := os.ReadFile(decrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%d байт: расшифрованный текст не совпадает с исходным", size)
+			}
+			if err := c.stranger.DecryptFile(encrypted, decrypted); !errors.Is(err, ErrWrongKey) {
+				t.Fatalf("чужой ключ: ожидалась ошибка %v, получено %v", ErrWrongKey, err)
+			}
+			if err := other.DecryptFile(encrypted, decrypted); err == nil {
+				t.Fatal("файл расшифрован схемой Эль-Гамаля")
+			}
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"is_3/src/container"
+	"math/big"
+)
+
+// minKeyBits минимальный размер модуля для NewPaillier
+const minKeyBits = 64
+
+// defaultKeyBits размер модуля при bits == 0
+const defaultKeyBits = 2048
+
+var one = big.NewInt(1)
+
+// Paillier криптосистема Пэйе с g = n + 1. Устроена как rsa_alg.RSA:
+// ключи хранятся в JSON- или PEM-файле, файлы шифруются блоками в
+// контейнере с общим заголовком (см. EncryptFile).
+type Paillier struct {
+	publicKey  *PublicKey
+	privateKey *PrivateKey
+	keysFile   string
+	bits       int
+}
+
+// PublicKey открытый ключ: модуль n, генератор g = n + 1 подразумевается
+type PublicKey struct {
+	N *big.Int
+}
+
+// PrivateKey закрытый ключ: λ = НОК(p-1, q-1) и μ = λ⁻¹ mod n
+type PrivateKey struct {
+	N      *big.Int
+	Lambda *big.Int
+	Mu     *big.Int
+	P      *big.Int
+	Q      *big.Int
+}
+
+// NewPaillier загружает ключи из файла или генерирует новые с модулем
+// bits бит (0 — 2048).
+func NewPaillier(loadKeys bool, keysFileName string, bits int) (*Paillier, error) {
+	if bits == 0 {
+		bits = defaultKeyBits
+	}
+	if bits < minKeyBits {
+		return nil, fmt.Errorf("NewPaillier: размер ключа %d бит меньше минимального %d", bits, minKeyBits)
+	}
+	p := &Paillier{
+		keysFile: keysFileName,
+		bits:     bits,
+	}
+
+	if loadKeys && container.FileExists(p.keysFile) {
+		pub, priv, err := ReadKeysFile(p.keysFile)
+		if err != nil {
+			return nil, fmt.Errorf("NewPaillier: %w", err)
+		}
+		if priv == nil {
+			return nil, fmt.Errorf("NewPaillier: в файле %s нет закрытого ключа", p.keysFile)
+		}
+		p.publicKey, p.privateKey = pub, priv
+		return p, nil
+	}
+
+	pub, priv, err := GenerateKey(bits)
+	if err != nil {
+		return nil, fmt.Errorf("NewPaillier: %w", err)
+	}
+	p.publicKey, p.privateKey = pub, priv
+	if err := WriteKeysFile(p.keysFile, pub, priv); err != nil {
+		return nil, fmt.Errorf("NewPaillier: %w", err)
+	}
+	return p, nil
+}
+
+// NewPaillierFromKeys создает Paillier из готовой пары ключей без файла.
+// priv может быть nil, тогда доступно только шифрование.
+func NewPaillierFromKeys(pub *PublicKey, priv *PrivateKey) *Paillier {
+	return &Paillier{publicKey: pub, privateKey: priv}
+}
+
+// PublicKey возвращает открытый ключ.
+func (p *Paillier) PublicKey() *PublicKey {
+	return p.publicKey
+}
+
+// PrivateKey возвращает закрытый ключ.
+func (p *Paillier) PrivateKey() *PrivateKey {
+	return p.privateKey
+}
+
+// GenerateKey генерирует p и q длиной bits/2 бит, n = p·q ровно bits бит.
+func GenerateKey(bits int) (*PublicKey, *PrivateKey, error) {
+	if bits < minKeyBits {
+		return nil, nil, fmt.Errorf("GenerateKey: размер ключа %d бит меньше минимального %d", bits, minKeyBits)
+	}
+	for {
+		p, err := rand.Prime(rand.Reader, (bits+1)/2)
+		if err != nil {
+			return nil, nil, err
+		}
+		q, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			return nil, nil, err
+		}
+		n := new(big.Int).Mul(p, q)
+		if p.Cmp(q) == 0 || n.BitLen() != bits {
+			continue
+		}
+		priv, err := PrivateKeyFromFactors(p, q)
+		if err != nil {
+			continue
+		}
+		return &PublicKey{N: n}, priv, nil
+	}
+}
+
+// PrivateKeyFromFactors вычисляет λ и μ по p и q.
+func PrivateKeyFromFactors(p, q *big.Int) (*PrivateKey, error) {
+	n := new(big.Int).Mul(p, q)
+	pm1 := new(big.Int).Sub(p, one)
+	qm1 := new(big.Int).Sub(q, one)
+	// При g = n + 1 нужно НОД(n, φ(n)) = 1
+	if new(big.Int).GCD(nil, nil, n, new(big.Int).Mul(pm1, qm1)).Cmp(one) != 0 {
+		return nil, errors.New("PrivateKeyFromFactors: НОД(n, φ(n)) ≠ 1")
+	}
+	gcd := new(big.Int).GCD(nil, nil, pm1, qm1)
+	lambda := new(big.Int).Mul(pm1, qm1)
+	lambda.Div(lambda, gcd)
+	mu := new(big.Int).ModInverse(lambda, n)
+	if mu == nil {
+		return nil, errors.New("PrivateKeyFromFactors: λ необратимо по модулю n")
+	}
+	return &PrivateKey{
+		N:      n,
+		Lambda: lambda,
+		Mu:     mu,
+		P:      new(big.Int).Set(p),
+		Q:      new(big.Int).Set(q),
+	}, nil
+}
+
+// Size возвращает длину n в байтах. Шифртекст занимает вдвое больше.
+func (pub *PublicKey) Size() int {
+	return (pub.N.BitLen() + 7) / 8
+}
+
+// NSquare возвращает n².
+func (pub *PublicKey) NSquare() *big.Int {
+	return new(big.Int).Mul(pub.N, pub.N)
+}
+
+// Encrypt шифрует 0 ≤ m < n со случайным r ∈ Z_n^*:
+// c = g^m·r^n mod n² = (1 + m·n)·r^n mod n².
+func (pub *PublicKey) Encrypt(m *big.Int) (*big.Int, error) {
+	if m.Sign() < 0 || m.Cmp(pub.N) >= 0 {
+		return nil, errors.New("Encrypt: сообщение вне диапазона [0, n)")
+	}
+	r, err := pub.randUnit()
+	if err != nil {
+		return nil, err
+	}
+	n2 := pub.NSquare()
+	c := new(big.Int).Mul(m, pub.N)
+	c.Add(c, one)
+	return c.Mul(c, r.Exp(r, pub.N, n2)).Mod(c, n2), nil
+}
+
+// randUnit случайный элемент Z_n^*.
+func (pub *PublicKey) randUnit() (*big.Int, error) {
+	for {
+		r, err := rand.Int(rand.Reader, pub.N)
+		if err != nil {
+			return nil, err
+		}
+		if r.Sign() > 0 && new(big.Int).GCD(nil, nil, r, pub.N).Cmp(one) == 0 {
+			return r, nil
+		}
+	}
+}
+
+// Decrypt вычисляет m = L(c^λ mod n²)·μ mod n, L(x) = (x - 1)/n.
+func (priv *PrivateKey) Decrypt(c *big.Int) (*big.Int, error) {
+	n2 := new(big.Int).Mul(priv.N, priv.N)
+	if c.Sign() <= 0 || c.Cmp(n2) >= 0 {
+		return nil, errors.New("Decrypt: шифртекст вне диапазона (0, n²)")
+	}
+	x := new(big.Int).Exp(c, priv.Lambda, n2)
+	x.Sub(x, one).Div(x, priv.N)
+	return x.Mul(x, priv.Mu).Mod(x, priv.N), nil
+}
+
+// Add складывает зашифрованные числа: Decrypt(Add(E(a), E(b))) = a + b mod n.
+// Аддитивный гомоморфизм схемы Пэйе.
+func (pub *PublicKey) Add(a, b *big.Int) *big.Int {
+	c := new(big.Int).Mul(a, b)
+	return c.Mod(c, pub.NSquare())
+}
+
+// AddPlain прибавляет к зашифрованному числу открытое k:
+// E(a)·g^k = E(a + k mod n).
+func (pub *PublicKey) AddPlain(c, k *big.Int) *big.Int {
+	n2 := pub.NSquare()
+	gk := new(big.Int).Mod(k, pub.N)
+	gk.Mul(gk, pub.N).Add(gk, one)
+	return gk.Mul(gk, c).Mod(gk, n2)
+}
+
+// MulPlain умножает зашифрованное число на открытое k: E(a)^k = E(a·k mod n).
+func (pub *PublicKey) MulPlain(c, k *big.Int) *big.Int {
+	return new(big.Int).Exp(c, new(big.Int).Mod(k, pub.N), pub.NSquare())
+}
+
+// KeyID короткий идентификатор ключа: первые 8 байт SHA-256 от DER n.
+func (pub *PublicKey) KeyID() (string, error) {
+	der, err := asn1.Marshal(pub.N)
+	if err != nil {
+		return "", fmt.Errorf("KeyID: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// Формат файла ключей (paillier_keys.json), числа — десятичные строки.
+// В PEM-файле (paillier_keys.pem) те же числа лежат DER-последовательностями
+// в блоках pemPublicType и pemPrivateType, см. container.WriteKeysFile.
+type keysJSON struct {
+	PrivateKey *privateKeyJSON `json:"private_key,omitempty"`
+	PublicKey  *publicKeyJSON  `json:"public_key"`
+}
+
+type publicKeyJSON struct {
+	N string `json:"n"`
+}
+
+type privateKeyJSON struct {
+	P string `json:"p"`
+	Q string `json:"q"`
+}
+
+const (
+	pemPublicType  = "PAILLIER PUBLIC KEY"
+	pemPrivateType = "PAILLIER PRIVATE KEY"
+)
+
+type publicKeyASN1 struct{ N *big.Int }
+
+type privateKeyASN1 struct{ P, Q *big.Int }
+
+// WriteKeysFile сохраняет ключи в JSON или, если имя оканчивается на
+// ".pem", в PEM. Закрытый ключ хранится как p и q, λ и μ вычисляются при
+// чтении. priv может быть nil.
+func WriteKeysFile(path string, pub *PublicKey, priv *PrivateKey) error {
+	keys := keysJSON{
+		PublicKey: &publicKeyJSON{N: pub.N.String()},
+	}
+	der, err := asn1.Marshal(publicKeyASN1{pub.N})
+	if err != nil {
+		return err
+	}
+	blocks := []*pem.Block{{Type: pemPublicType, Bytes: der}}
+	if priv != nil {
+		keys.PrivateKey = &privateKeyJSON{P: priv.P.String(), Q: priv.Q.String()}
+		if der, err = asn1.Marshal(privateKeyASN1{priv.P, priv.Q}); err != nil {
+			return err
+		}
+		blocks = append(blocks, &pem.Block{Type: pemPrivateType, Bytes: der})
+	}
+	return container.WriteKeysFile(path, keys, blocks)
+}
+
+// ReadKeysFile читает ключи из JSON или PEM. Если в файле только открытый
+// ключ, priv равен nil.
+func ReadKeysFile(path string) (pub *PublicKey, priv *PrivateKey, err error) {
+	var keys keysJSON
+	blocks, err := container.ReadKeysFile(path, &keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	var n, p, q *big.Int
+	if blocks != nil {
+		n, p, q, err = parsePEM(blocks)
+	} else {
+		n, p, q, err = keys.parse()
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	pub = &PublicKey{N: n}
+	if p == nil {
+		return pub, nil, nil
+	}
+	if new(big.Int).Mul(p, q).Cmp(n) != 0 {
+		return nil, nil, fmt.Errorf("%s: p·q ≠ n", path)
+	}
+	if priv, err = PrivateKeyFromFactors(p, q); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pub, priv, nil
+}
+
+// parsePEM возвращает n и, если есть закрытый ключ, p и q.
+func parsePEM(blocks []*pem.Block) (n, p, q *big.Int, err error) {
+	block := container.FindPEMBlock(blocks, pemPublicType)
+	if block == nil {
+		return nil, nil, nil, errors.New("нет открытого ключа")
+	}
+	var pub publicKeyASN1
+	if _, err := asn1.Unmarshal(block.Bytes, &pub); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", pemPublicType, err)
+	}
+
+	block = container.FindPEMBlock(blocks, pemPrivateType)
+	if block == nil {
+		return pub.N, nil, nil, nil
+	}
+	var priv privateKeyASN1
+	if _, err := asn1.Unmarshal(block.Bytes, &priv); err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", pemPrivateType, err)
+	}
+	return pub.N, priv.P, priv.Q, nil
+}
+
+// parse возвращает n и, если есть закрытый ключ, p и q.
+func (keys *keysJSON) parse() (n, p, q *big.Int, err error) {
+	if keys.PublicKey == nil {
+		return nil, nil, nil, errors.New("нет открытого ключа")
+	}
+	if n, err = container.ParseInt(keys.PublicKey.N); err != nil {
+		return nil, nil, nil, fmt.Errorf("n: %w", err)
+	}
+	if keys.PrivateKey == nil {
+		return n, nil, nil, nil
+	}
+	if p, err = container.ParseInt(keys.PrivateKey.P); err != nil {
+		return nil, nil, nil, fmt.Errorf("p: %w", err)
+	}
+	if q, err = container.ParseInt(keys.PrivateKey.Q); err != nil {
+		return nil, nil, nil, fmt.Errorf("q: %w", err)
+	}
+	return n, p, q, nil
+}
@@ -0,0 +1,113 @@
+package paillier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestKeysFile сохраняет ключи в JSON и PEM и читает их обратно.
+func TestKeysFile(t *testing.T) {
+	pub, priv, err := GenerateKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"keys.json", "keys.pem"} {
+		path := filepath.Join(dir, name)
+		if err := WriteKeysFile(path, pub, priv); err != nil {
+			t.Fatal(err)
+		}
+		gotPub, gotPriv, err := ReadKeysFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if gotPub.N.Cmp(pub.N) != 0 || gotPriv.Lambda.Cmp(priv.Lambda) != 0 || gotPriv.Mu.Cmp(priv.Mu) != 0 {
+			t.Fatalf("%s: прочитаны другие ключи", name)
+		}
+
+		// Только открытый ключ
+		if err := WriteKeysFile(path, pub, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, gotPriv, err := ReadKeysFile(path); err != nil || gotPriv != nil {
+			t.Fatalf("%s без закрытого ключа: %v, %v", name, gotPriv, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "keys.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "-----BEGIN "+pemPublicType+"-----") {
+		t.Fatalf("PEM-файл начинается с %q", data[:min(len(data), 40)])
+	}
+
+	// p и q от другого модуля не принимаются
+	_, other, err := GenerateKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"mixed.json", "mixed.pem"} {
+		path := filepath.Join(dir, name)
+		if err := WriteKeysFile(path, pub, other); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := ReadKeysFile(path); err == nil {
+			t.Errorf("%s: ключи разных пар не отклонены", name)
+		}
+	}
+}
+
+// TestHomomorphic: D(E(a)·E(b)) = a + b, D(E(a)·g^k) = a + k и
+// D(E(a)^k) = a·k по модулю n.
+func TestHomomorphic(t *testing.T) {
+	pub, priv, err := GenerateKey(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		a, err := rand.Int(rand.Reader, pub.N)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := rand.Int(rand.Reader, pub.N)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := rand.Int(rand.Reader, pub.N)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ca, err := pub.Encrypt(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cb, err := pub.Encrypt(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cases := []struct {
+			name string
+			c    *big.Int
+			want *big.Int
+		}{
+			{"D(E(a)·E(b)) = a + b", pub.Add(ca, cb), new(big.Int).Add(a, b)},
+			{"D(E(a)·g^k) = a + k", pub.AddPlain(ca, k), new(big.Int).Add(a, k)},
+			{"D(E(a)^k) = a·k", pub.MulPlain(ca, k), new(big.Int).Mul(a, k)},
+		}
+		for _, c := range cases {
+			got, err := priv.Decrypt(c.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Cmp(c.want.Mod(c.want, pub.N)) != 0 {
+				t.Fatalf("%s: не выполняется", c.name)
+			}
+		}
+	}
+}
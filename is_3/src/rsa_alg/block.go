@@ -0,0 +1,101 @@
+package rsa_alg
+
+import (
+	"fmt"
+	"io"
+	"is_3/src/container"
+	"math/big"
+	"os"
+)
+
+// Блочный формат файла: заголовок container.Header с сигнатурой "RSAB",
+// в поле padding — схема дополнения (Padding). Далее идут блоки ровно по
+// modLen байт, каждый содержит до padding.MaxMessageLen(modLen) байт
+// открытого текста. Файлы версии 1 не содержат keyID и расшифровываются
+// текущим ключом.
+const blockMagic = "RSAB"
+
+// EncryptFileBlocks шифрует файл блоками размером с модуль. В каждый блок
+// упаковывается до k-11 (PKCS#1 v1.5) или k-66 (OAEP) байт открытого текста.
+func (r *RSA) EncryptFileBlocks(inputPath, outputPath string, padding Padding) error {
+	inputFile, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	return r.encryptBlockStream(inputFile, outputFile, padding)
+}
+
+// encryptBlockStream шифрует поток текущим ключом. Число блоков заранее
+// неизвестно, поэтому оно дописывается в заголовок по окончании.
+func (r *RSA) encryptBlockStream(input io.Reader, output io.WriterAt, padding Padding) error {
+	k := r.publicKey.Size()
+	chunkSize := padding.MaxMessageLen(k)
+	if chunkSize < 1 {
+		return fmt.Errorf("encryptBlockStream: модуль %d бит слишком мал для %s", r.publicKey.N.BitLen(), padding)
+	}
+	keyID, err := r.publicKey.keyIDBytes()
+	if err != nil {
+		return err
+	}
+
+	header := &container.Header{
+		Magic:   blockMagic,
+		Version: container.Version,
+		Padding: byte(padding),
+		ModLen:  uint16(k),
+		KeyID:   keyID,
+	}
+	return container.WriteBlocks(input, output, header, chunkSize, k, func(chunk, block []byte) error {
+		em, err := padding.pad(chunk, k)
+		if err != nil {
+			return err
+		}
+		r.publicKey.EncryptInt(new(big.Int).SetBytes(em)).FillBytes(block)
+		return nil
+	})
+}
+
+// decryptBlocks расшифровывает поток в блочном формате ключом, указанным
+// в заголовке.
+func (r *RSA) decryptBlocks(reader io.Reader, writer io.Writer) error {
+	header, err := container.ReadHeader(reader, blockMagic)
+	if err != nil {
+		return err
+	}
+	priv := r.privateKey
+	if header.KeyID != nil {
+		if priv, err = r.privateKeyByID(header.KeyID); err != nil {
+			return fmt.Errorf("decryptBlocks: %w", err)
+		}
+	}
+	return decryptBlockBody(header, priv, reader, writer)
+}
+
+// decryptBlockBody расшифровывает блоки, следующие за заголовком.
+func decryptBlockBody(header *container.Header, priv *PrivateKey, reader io.Reader, writer io.Writer) error {
+	k := (priv.N.BitLen() + 7) / 8
+	if int(header.ModLen) != k {
+		return fmt.Errorf("decryptBlocks: файл зашифрован ключом с модулем %d байт, текущий ключ %d байт", header.ModLen, k)
+	}
+
+	em := make([]byte, k)
+	err := container.ReadBlocks(reader, writer, header, k, func(block []byte) ([]byte, error) {
+		c := new(big.Int).SetBytes(block)
+		if c.Cmp(priv.N) >= 0 {
+			return nil, ErrDecryption
+		}
+		return Padding(header.Padding).unpad(priv.DecryptInt(c).FillBytes(em))
+	})
+	if err != nil {
+		return fmt.Errorf("decryptBlocks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package rsa_alg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"is_3/src/container"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestBlockFileSizes шифрует файлы блоками и расшифровывает их. Размеры
+// выбраны на границах блока: k-11 помещается в один блок PKCS#1 v1.5,
+// k-11+1 уже нет; для OAEP граница k-66.
+func TestBlockFileSizes(t *testing.T) {
+	for _, bits := range []int{2048, 4096} {
+		r, err := NewRSABits(false, filepath.Join(t.TempDir(), "keys.json"), bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		k := r.PublicKey().Size()
+		for _, padding := range []Padding{PaddingPKCS1v15, PaddingOAEP} {
+			max := padding.MaxMessageLen(k)
+			sizes := []int{0, 1, k - 11, k - 11 + 1, max - 1, max, max + 1, 2*max + 1, 2 << 20}
+			for _, size := range sizes {
+				t.Run(fmt.Sprintf("%d/%s/%d", bits, padding, size), func(t *testing.T) {
+					if size > 1<<20 && testing.Short() {
+						t.Skip("большой файл пропускается в режиме -short")
+					}
+					checkBlockRoundTrip(t, r, padding, size)
+				})
+			}
+		}
+	}
+}
+
+// checkBlockRoundTrip шифрует size случайных байт и проверяет заголовок,
+// длину шифртекста и расшифровку.
+func checkBlockRoundTrip(t *testing.T, r *RSA, padding Padding, size int) {
+	t.Helper()
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.bin")
+	encrypted := filepath.Join(dir, "plain.blk")
+	want := writeRandomFile(t, plain, size)
+	if err := r.EncryptFileBlocks(plain, encrypted, padding); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := container.ReadHeader(bytes.NewReader(data), blockMagic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := r.PublicKey().Size()
+	max := padding.MaxMessageLen(k)
+	blocks := (size + max - 1) / max
+	if header.Blocks != uint64(blocks) || Padding(header.Padding) != padding || int(header.ModLen) != k {
+		t.Fatalf("заголовок: блоков %d, схема %d, модуль %d; ожидается %d, %d, %d",
+			header.Blocks, header.Padding, header.ModLen, blocks, padding, k)
+	}
+	if len(data) != header.Size()+blocks*k {
+		t.Fatalf("длина шифртекста %d, ожидается %d", len(data), header.Size()+blocks*k)
+	}
+	checkDecrypted(t, r, encrypted, want)
+}
+
+// TestBlockFileCorrupted: обрезанный файл, лишние данные и испорченный
+// блок не расшифровываются.
+func TestBlockFileCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRSABits(false, filepath.Join(dir, "keys.json"), 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "plain.bin")
+	writeRandomFile(t, plain, 1000)
+	encrypted := filepath.Join(dir, "plain.blk")
+	if err := r.EncryptFileBlocks(plain, encrypted, PaddingOAEP); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := r.PublicKey().Size()
+
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)-k/2] ^= 1
+	cases := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"без последнего байта", data[:len(data)-1], nil},
+		{"без последнего блока", data[:len(data)-k], nil},
+		{"обрезанный заголовок", data[:10], nil},
+		{"лишние данные", append(bytes.Clone(data), 0), container.ErrTrailingData},
+		{"испорченный блок", flipped, ErrDecryption},
+	}
+	for _, c := range cases {
+		path := filepath.Join(dir, "corrupted.blk")
+		if err := os.WriteFile(path, c.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		err := r.DecryptFile(path, filepath.Join(dir, "corrupted.dec"))
+		if err == nil {
+			t.Errorf("%s: расшифровка не вернула ошибку", c.name)
+			continue
+		}
+		if c.want != nil && !errors.Is(err, c.want) {
+			t.Errorf("%s: ошибка %v, ожидается %v", c.name, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package rsa_alg
+
+import (
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// oidRSAEncryption идентификатор rsaEncryption, RFC 8017 A.1
+var oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+
+type pkcs1PublicKey struct {
+	N *big.Int
+	E *big.Int
+}
+
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// MarshalPKIX кодирует открытый ключ в DER-структуру SubjectPublicKeyInfo.
+// Кодирование своё, а не x509.MarshalPKIXPublicKey, чтобы поддержать
+// экспоненту произвольного размера.
+func (pub *PublicKey) MarshalPKIX() ([]byte, error) {
+	keyBytes, err := asn1.Marshal(pkcs1PublicKey{N: pub.N, E: pub.E})
+	if err != nil {
+		return nil, fmt.Errorf("MarshalPKIX: %w", err)
+	}
+	spki := subjectPublicKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidRSAEncryption,
+			Parameters: asn1.NullRawValue,
+		},
+		PublicKey: asn1.BitString{Bytes: keyBytes, BitLength: 8 * len(keyBytes)},
+	}
+	der, err := asn1.Marshal(spki)
+	if err != nil {
+		return nil, fmt.Errorf("MarshalPKIX: %w", err)
+	}
+	return der, nil
+}
+
+// Fingerprint возвращает SHA-256 от SubjectPublicKeyInfo ключа.
+func (pub *PublicKey) Fingerprint() ([]byte, error) {
+	der, err := pub.MarshalPKIX()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+	return sum[:], nil
+}
+
+// FingerprintString форматирует отпечаток как в OpenSSH: "SHA256:<base64>".
+func FingerprintString(fp []byte) string {
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(fp)
+}
+
+// KeyID короткий идентификатор ключа: первые 8 байт отпечатка в hex.
+func (pub *PublicKey) KeyID() (string, error) {
+	fp, err := pub.Fingerprint()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(fp[:8]), nil
+}
+
+// Размер поля randomart как в OpenSSH
+const (
+	randomartWidth  = 17
+	randomartHeight = 9
+)
+
+// Randomart рисует отпечаток алгоритмом "drunken bishop" (OpenSSH).
+// Слон стартует в центре поля, каждая пара бит отпечатка (от младших
+// к старшим) сдвигает его по диагонали; чем чаще посещена клетка, тем
+// "плотнее" символ.
+func Randomart(fp []byte, title string) string {
+	const symbols = " .o+=*BOX@%&#/^SE"
+	maxCount := len(symbols) - 3
+
+	var field [randomartWidth][randomartHeight]int
+	x, y := randomartWidth/2, randomartHeight/2
+	for _, b := range fp {
+		for i := 0; i < 4; i++ {
+			if b&1 != 0 {
+				x++
+			} else {
+				x--
+			}
+			if b&2 != 0 {
+				y++
+			} else {
+				y--
+			}
+			x = max(0, min(x, randomartWidth-1))
+			y = max(0, min(y, randomartHeight-1))
+			if field[x][y] < maxCount {
+				field[x][y]++
+			}
+			b >>= 2
+		}
+	}
+	field[randomartWidth/2][randomartHeight/2] = len(symbols) - 2
+	field[x][y] = len(symbols) - 1
+
+	var sb strings.Builder
+	sb.WriteString(randomartBorder(title))
+	for row := 0; row < randomartHeight; row++ {
+		sb.WriteByte('|')
+		for col := 0; col < randomartWidth; col++ {
+			sb.WriteByte(symbols[field[col][row]])
+		}
+		sb.WriteString("|\n")
+	}
+	sb.WriteString(randomartBorder("SHA256"))
+	return sb.String()
+}
+
+func randomartBorder(title string) string {
+	if title != "" {
+		title = "[" + title + "]"
+	}
+	if len(title) > randomartWidth {
+		title = title[:randomartWidth]
+	}
+	left := (randomartWidth - len(title)) / 2
+	right := randomartWidth - len(title) - left
+	return "+" + strings.Repeat("-", left) + title + strings.Repeat("-", right) + "+\n"
+}
@@ -0,0 +1,88 @@
+package rsa_alg
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// TestFingerprint: отпечаток — SHA-256 от SubjectPublicKeyInfo, тот же,
+// что дает x509.MarshalPKIXPublicKey, KeyID — первые 8 байт отпечатка.
+func TestFingerprint(t *testing.T) {
+	for _, bits := range []int{512, 1024, 2048} {
+		pub, _, err := GenerateKey(bits, big.NewInt(65537))
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(&rsa.PublicKey{N: pub.N, E: int(pub.E.Int64())})
+		if err != nil {
+			t.Fatal(err)
+		}
+		own, err := pub.MarshalPKIX()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(own, der) {
+			t.Fatalf("%d бит: MarshalPKIX отличается от x509.MarshalPKIXPublicKey", bits)
+		}
+
+		want := sha256.Sum256(der)
+		fp, err := pub.Fingerprint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(fp, want[:]) {
+			t.Fatalf("%d бит: отпечаток %x, ожидается %x", bits, fp, want)
+		}
+		id, err := pub.KeyID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != hex.EncodeToString(want[:8]) {
+			t.Fatalf("%d бит: KeyID %s, ожидается %x", bits, id, want[:8])
+		}
+		if s := FingerprintString(fp); !strings.HasPrefix(s, "SHA256:") || strings.HasSuffix(s, "=") {
+			t.Errorf("FingerprintString: %q", s)
+		}
+	}
+}
+
+// TestFingerprintLargeExponent: экспонента больше int, которую не
+// поддерживает crypto/rsa, кодируется и разбирается обратно.
+func TestFingerprintLargeExponent(t *testing.T) {
+	pub, _, err := GenerateKey(512, big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _ := new(big.Int).SetString("100000000000000000000000000000000000000001", 16)
+	large := &PublicKey{E: e, N: pub.N}
+	a, err := large.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := pub.Fingerprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a, b) {
+		t.Fatal("отпечаток не зависит от экспоненты")
+	}
+}
+
+func TestRandomart(t *testing.T) {
+	art := Randomart(bytes.Repeat([]byte{0x5a}, 32), "RSA 2048")
+	lines := strings.Split(strings.TrimSuffix(art, "\n"), "\n")
+	if len(lines) != randomartHeight+2 {
+		t.Fatalf("строк %d, ожидается %d:\n%s", len(lines), randomartHeight+2, art)
+	}
+	for _, line := range lines[1 : len(lines)-1] {
+		if n := len([]rune(line)); n != randomartWidth+2 {
+			t.Fatalf("ширина строки %d, ожидается %d:\n%s", n, randomartWidth+2, art)
+		}
+	}
+}
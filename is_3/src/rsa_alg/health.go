@@ -0,0 +1,207 @@
+package rsa_alg
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// recommendedKeyBits размер модуля, меньше которого выдается предупреждение;
+// модуль меньше insecureKeyBits считается ошибкой: его можно разложить
+const (
+	recommendedKeyBits = 2048
+	insecureKeyBits    = 1024
+)
+
+// Severity важность результата проверки
+type Severity int
+
+const (
+	SeverityOK Severity = iota
+	SeverityInfo
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityOK:
+		return "OK"
+	case SeverityInfo:
+		return "INFO"
+	case SeverityWarning:
+		return "WARN"
+	case SeverityError:
+		return "FAIL"
+	default:
+		return "?"
+	}
+}
+
+// Finding результат одной проверки ключа
+type Finding struct {
+	Severity Severity
+	Message  string
+}
+
+// CheckKey проверяет согласованность ключа. priv может быть nil.
+func CheckKey(pub *PublicKey, priv *PrivateKey) []Finding {
+	var findings []Finding
+	add := func(s Severity, format string, a ...any) {
+		findings = append(findings, Finding{Severity: s, Message: fmt.Sprintf(format, a...)})
+	}
+
+	one := big.NewInt(1)
+	bits := pub.N.BitLen()
+	if bits < insecureKeyBits {
+		add(SeverityError, "модуль %d бит меньше %d и раскладывается на множители", bits, insecureKeyBits)
+	} else if bits < recommendedKeyBits {
+		add(SeverityWarning, "модуль %d бит меньше рекомендуемых %d", bits, recommendedKeyBits)
+	} else {
+		add(SeverityOK, "размер модуля %d бит", bits)
+	}
+	if pub.E.Cmp(big.NewInt(3)) < 0 || pub.E.Bit(0) == 0 {
+		add(SeverityError, "открытая экспонента %s должна быть нечетной и не меньше 3", pub.E)
+	}
+
+	if priv == nil {
+		add(SeverityInfo, "закрытого ключа нет, проверки P, Q и D пропущены")
+		return findings
+	}
+	if priv.N.Cmp(pub.N) != 0 {
+		add(SeverityError, "модули открытого и закрытого ключей различаются")
+		return findings
+	}
+	if priv.P == nil || priv.Q == nil {
+		add(SeverityInfo, "P и Q в файле нет, проверка E·D ≡ 1 mod λ(N) невозможна")
+		return findings
+	}
+
+	primes := priv.Primes()
+	if len(primes) > 2 {
+		add(SeverityInfo, "многопростой ключ: %d простых множителей", len(primes))
+	}
+	product := big.NewInt(1)
+	for i, prime := range primes {
+		name := primeName(i)
+		if prime.ProbablyPrime(20) {
+			add(SeverityOK, "%s простое", name)
+		} else {
+			add(SeverityError, "%s составное", name)
+		}
+		for j := range i {
+			if primes[j].Cmp(prime) == 0 {
+				add(SeverityError, "%s совпадает с %s", name, primeName(j))
+				return findings
+			}
+		}
+		product.Mul(product, prime)
+	}
+	if product.Cmp(pub.N) != 0 {
+		add(SeverityError, "произведение простых ≠ N")
+		return findings
+	}
+
+	// Сохраненные d_i и t_i должны совпадать с вычисленными по ключу
+	for i, other := range priv.computeOthers() {
+		if other.Coeff == nil {
+			add(SeverityError, "%s повторяет один из предыдущих множителей", primeName(i+2))
+			return findings
+		}
+		stored := priv.Others[i]
+		if stored.Exp == nil || stored.Coeff == nil {
+			continue
+		}
+		if stored.Exp.Cmp(other.Exp) != 0 || stored.Coeff.Cmp(other.Coeff) != 0 {
+			add(SeverityError, "d и t для %s не соответствуют ключу", primeName(i+2))
+		}
+	}
+
+	// λ(N) = НОК(r_i - 1)
+	lambda := big.NewInt(1)
+	for _, prime := range primes {
+		pm1 := new(big.Int).Sub(prime, one)
+		gcd := new(big.Int).GCD(nil, nil, lambda, pm1)
+		lambda.Mul(lambda, pm1).Div(lambda, gcd)
+	}
+
+	ed := new(big.Int).Mul(pub.E, priv.D)
+	if ed.Mod(ed, lambda).Cmp(one) == 0 {
+		add(SeverityOK, "E·D ≡ 1 mod λ(N)")
+	} else {
+		add(SeverityError, "E·D ≢ 1 mod λ(N)")
+	}
+	return findings
+}
+
+// primeName имя i-го простого множителя: P, Q, R3, R4, …
+func primeName(i int) string {
+	switch i {
+	case 0:
+		return "P"
+	case 1:
+		return "Q"
+	default:
+		return fmt.Sprintf("R%d", i+1)
+	}
+}
+
+// KeyFile открытый ключ, прочитанный из файла
+type KeyFile struct {
+	Path string
+	Key  *PublicKey
+}
+
+// SharedFactor пара ключей с общим простым множителем. Если модули
+// совпадают целиком, Same равно true.
+type SharedFactor struct {
+	A, B   string
+	Factor *big.Int
+	Same   bool
+}
+
+// LoadPublicKeys читает открытые ключи из всех *.json файлов каталога.
+// Файлы, не являющиеся файлами ключей, пропускаются.
+func LoadPublicKeys(dir string) ([]KeyFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var keys []KeyFile
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		pub, _, err := ReadKeysFile(path)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, KeyFile{Path: path, Key: pub})
+	}
+	return keys, nil
+}
+
+// FindSharedFactors попарно вычисляет НОД модулей. Общий множитель
+// у двух разных модулей означает, что оба ключа факторизуются.
+func FindSharedFactors(keys []KeyFile) []SharedFactor {
+	var shared []SharedFactor
+	one := big.NewInt(1)
+	for i := 0; i < len(keys); i++ {
+		for j := i + 1; j < len(keys); j++ {
+			gcd := new(big.Int).GCD(nil, nil, keys[i].Key.N, keys[j].Key.N)
+			if gcd.Cmp(one) != 0 {
+				shared = append(shared, SharedFactor{
+					A:      keys[i].Path,
+					B:      keys[j].Path,
+					Factor: gcd,
+					Same:   gcd.Cmp(keys[i].Key.N) == 0 && gcd.Cmp(keys[j].Key.N) == 0,
+				})
+			}
+		}
+	}
+	return shared
+}
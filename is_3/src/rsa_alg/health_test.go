@@ -0,0 +1,137 @@
+package rsa_alg
+
+import (
+	"crypto/rand"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// hasError сообщает, есть ли среди результатов CheckKey ошибка.
+func hasError(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckKey(t *testing.T) {
+	pub, priv, err := GenerateKey(2048, big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if findings := CheckKey(pub, priv); hasError(findings) {
+		t.Fatalf("исправный ключ отклонен: %v", findings)
+	}
+	if findings := CheckKey(pub, nil); hasError(findings) {
+		t.Fatalf("исправный открытый ключ отклонен: %v", findings)
+	}
+
+	// p == q: N = p², d = e⁻¹ mod p(p-1)
+	p := priv.P
+	square := &PublicKey{E: pub.E, N: new(big.Int).Mul(p, p)}
+	phi := new(big.Int).Mul(p, new(big.Int).Sub(p, bigOne))
+	samePrimes := &PrivateKey{
+		D: new(big.Int).ModInverse(pub.E, phi),
+		N: square.N,
+		P: p,
+		Q: new(big.Int).Set(p),
+	}
+
+	badD := *priv
+	badD.D = new(big.Int).Add(priv.D, bigOne)
+
+	short, shortPriv, err := GenerateKey(512, big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		pub  *PublicKey
+		priv *PrivateKey
+	}{
+		{"e = 1", &PublicKey{E: big.NewInt(1), N: pub.N}, nil},
+		{"четная e", &PublicKey{E: big.NewInt(65536), N: pub.N}, nil},
+		{"p = q", square, samePrimes},
+		{"неверное d", pub, &badD},
+		{"модуль 512 бит", short, shortPriv},
+		{"чужой модуль", short, priv},
+	}
+	for _, c := range cases {
+		if findings := CheckKey(c.pub, c.priv); !hasError(findings) {
+			t.Errorf("%s: ключ не отклонен: %v", c.name, findings)
+		}
+	}
+}
+
+func TestFindSharedFactors(t *testing.T) {
+	primes := make([]*big.Int, 5)
+	for i := range primes {
+		p, err := rand.Prime(rand.Reader, 512)
+		if err != nil {
+			t.Fatal(err)
+		}
+		primes[i] = p
+	}
+	e := big.NewInt(65537)
+	modulus := func(p, q *big.Int) *PublicKey {
+		return &PublicKey{E: e, N: new(big.Int).Mul(p, q)}
+	}
+
+	// a и b делят простое primes[0], c и d — один и тот же модуль
+	dir := t.TempDir()
+	keys := map[string]*PublicKey{
+		"a.json": modulus(primes[0], primes[1]),
+		"b.json": modulus(primes[0], primes[2]),
+		"c.json": modulus(primes[3], primes[4]),
+		"d.json": modulus(primes[3], primes[4]),
+		"e.json": modulus(primes[1], primes[4]),
+	}
+	for name, pub := range keys {
+		if err := WriteKeysFile(filepath.Join(dir, name), pub, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.json"), []byte(`{"x": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadPublicKeys(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(keys) {
+		t.Fatalf("прочитано ключей %d, ожидается %d", len(loaded), len(keys))
+	}
+
+	type pair struct{ a, b string }
+	want := map[pair]*big.Int{
+		{"a.json", "b.json"}: primes[0],
+		{"a.json", "e.json"}: primes[1],
+		{"c.json", "d.json"}: keys["c.json"].N,
+		{"c.json", "e.json"}: primes[4],
+		{"d.json", "e.json"}: primes[4],
+	}
+	shared := FindSharedFactors(loaded)
+	if len(shared) != len(want) {
+		t.Fatalf("найдено пар %d, ожидается %d: %v", len(shared), len(want), shared)
+	}
+	for _, s := range shared {
+		key := pair{filepath.Base(s.A), filepath.Base(s.B)}
+		factor, ok := want[key]
+		if !ok {
+			t.Errorf("лишняя пара %s, %s", key.a, key.b)
+			continue
+		}
+		if s.Factor.Cmp(factor) != 0 {
+			t.Errorf("%s, %s: НОД %s, ожидается %s", key.a, key.b, s.Factor, factor)
+		}
+		if s.Same != (key == pair{"c.json", "d.json"}) {
+			t.Errorf("%s, %s: Same = %t", key.a, key.b, s.Same)
+		}
+	}
+}
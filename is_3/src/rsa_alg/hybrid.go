@@ -0,0 +1,339 @@
+package rsa_alg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"is_3/src/container"
+	"math/big"
+	"os"
+)
+
+// Гибридный формат файла. Данные шифруются случайным сеансовым ключом
+// AES-256-GCM, а сеансовый ключ — открытым ключом каждого получателя (OAEP).
+//
+//	magic      [4]byte  "RSAH"
+//	version    uint8    hybridVersion
+//	recipients uint16   число записей в таблице получателей
+//	таблица, для каждого получателя:
+//	  keyID    [8]byte  первые 8 байт отпечатка открытого ключа
+//	  length   uint16   длина обернутого ключа
+//	  wrapped  [length]byte
+//	nonce      [12]byte базовый nonce
+//
+// Далее идут фрагменты: hybridChunkSize байт открытого текста + 16 байт тега.
+// Последний фрагмент короче hybridChunkSize (возможно, пустой) и помечен
+// флагом в дополнительных данных, поэтому усечение файла обнаруживается.
+// Весь заголовок входит в дополнительные данные каждого фрагмента.
+const (
+	hybridMagic         = "RSAH"
+	hybridVersion       = 1
+	hybridChunkSize     = 64 * 1024
+	sessionKeySize      = 32
+	keyIDSize           = container.KeyIDSize
+	maxHybridRecipients = 1<<16 - 1
+)
+
+// ErrNoRecipient в таблице получателей нет записи для данного ключа
+var ErrNoRecipient = errors.New("rsa_alg: файл зашифрован не для этого ключа")
+
+// Recipient запись таблицы получателей
+type Recipient struct {
+	KeyID   []byte
+	Wrapped []byte
+}
+
+type hybridHeader struct {
+	Recipients []Recipient
+	Nonce      []byte
+	raw        []byte // заголовок как записан в файле, дополнительные данные GCM
+}
+
+func (h *hybridHeader) marshal() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(hybridMagic)
+	buf.WriteByte(hybridVersion)
+	binary.Write(&buf, binary.BigEndian, uint16(len(h.Recipients)))
+	for _, rcpt := range h.Recipients {
+		buf.Write(rcpt.KeyID)
+		binary.Write(&buf, binary.BigEndian, uint16(len(rcpt.Wrapped)))
+		buf.Write(rcpt.Wrapped)
+	}
+	buf.Write(h.Nonce)
+	return buf.Bytes()
+}
+
+func readHybridHeader(r io.Reader) (*hybridHeader, error) {
+	var raw bytes.Buffer
+	tee := io.TeeReader(r, &raw)
+
+	fixed := make([]byte, 4+1+2)
+	if _, err := io.ReadFull(tee, fixed); err != nil {
+		return nil, fmt.Errorf("readHybridHeader: %w", err)
+	}
+	if string(fixed[:4]) != hybridMagic {
+		return nil, errors.New("readHybridHeader: неверная сигнатура")
+	}
+	if fixed[4] != hybridVersion {
+		return nil, fmt.Errorf("readHybridHeader: неподдерживаемая версия %d", fixed[4])
+	}
+	count := int(binary.BigEndian.Uint16(fixed[5:]))
+
+	h := &hybridHeader{}
+	for i := 0; i < count; i++ {
+		entry := make([]byte, keyIDSize+2)
+		if _, err := io.ReadFull(tee, entry); err != nil {
+			return nil, fmt.Errorf("readHybridHeader: получатель %d: %w", i, err)
+		}
+		wrapped := make([]byte, binary.BigEndian.Uint16(entry[keyIDSize:]))
+		if _, err := io.ReadFull(tee, wrapped); err != nil {
+			return nil, fmt.Errorf("readHybridHeader: получатель %d: %w", i, err)
+		}
+		h.Recipients = append(h.Recipients, Recipient{KeyID: entry[:keyIDSize], Wrapped: wrapped})
+	}
+
+	h.Nonce = make([]byte, 12)
+	if _, err := io.ReadFull(tee, h.Nonce); err != nil {
+		return nil, fmt.Errorf("readHybridHeader: %w", err)
+	}
+	h.raw = raw.Bytes()
+	return h, nil
+}
+
+// keyIDBytes возвращает идентификатор ключа в двоичном виде.
+func (pub *PublicKey) keyIDBytes() ([]byte, error) {
+	id, err := pub.KeyID()
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(id)
+}
+
+// wrapKey шифрует сеансовый ключ открытым ключом получателя.
+func (pub *PublicKey) wrapKey(sessionKey []byte) ([]byte, error) {
+	k := pub.Size()
+	em, err := PaddingOAEP.pad(sessionKey, k)
+	if err != nil {
+		return nil, fmt.Errorf("модуль %d бит слишком мал для гибридного режима: %w", pub.N.BitLen(), err)
+	}
+	return pub.EncryptInt(new(big.Int).SetBytes(em)).FillBytes(make([]byte, k)), nil
+}
+
+// unwrapKey расшифровывает сеансовый ключ.
+func (priv *PrivateKey) unwrapKey(wrapped []byte) ([]byte, error) {
+	k := (priv.N.BitLen() + 7) / 8
+	c := new(big.Int).SetBytes(wrapped)
+	if len(wrapped) != k || c.Cmp(priv.N) >= 0 {
+		return nil, ErrDecryption
+	}
+	key, err := PaddingOAEP.unpad(priv.DecryptInt(c).FillBytes(make([]byte, k)))
+	if err != nil || len(key) != sessionKeySize {
+		return nil, ErrDecryption
+	}
+	return key, nil
+}
+
+// chunkNonce nonce i-го фрагмента: базовый nonce, младшие 8 байт которого
+// сложены по XOR с номером фрагмента.
+func chunkNonce(base []byte, index uint64) []byte {
+	nonce := bytes.Clone(base)
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], index)
+	for i := range counter {
+		nonce[4+i] ^= counter[i]
+	}
+	return nonce
+}
+
+func chunkAD(header []byte, final bool) []byte {
+	ad := bytes.Clone(header)
+	if final {
+		return append(ad, 1)
+	}
+	return append(ad, 0)
+}
+
+// EncryptFileHybrid шифрует файл для одного или нескольких получателей.
+// Сеансовый ключ оборачивается открытым ключом каждого получателя.
+func EncryptFileHybrid(inputPath, outputPath string, recipients []*PublicKey) error {
+	if len(recipients) == 0 {
+		return errors.New("EncryptFileHybrid: не задан ни один получатель")
+	}
+	if len(recipients) > maxHybridRecipients {
+		return fmt.Errorf("EncryptFileHybrid: слишком много получателей: %d", len(recipients))
+	}
+
+	sessionKey := make([]byte, sessionKeySize)
+	if _, err := rand.Read(sessionKey); err != nil {
+		return err
+	}
+	defer clear(sessionKey)
+
+	header := &hybridHeader{Nonce: make([]byte, 12)}
+	if _, err := rand.Read(header.Nonce); err != nil {
+		return err
+	}
+	for i, pub := range recipients {
+		id, err := pub.keyIDBytes()
+		if err != nil {
+			return fmt.Errorf("EncryptFileHybrid: получатель %d: %w", i, err)
+		}
+		wrapped, err := pub.wrapKey(sessionKey)
+		if err != nil {
+			return fmt.Errorf("EncryptFileHybrid: получатель %d: %w", i, err)
+		}
+		header.Recipients = append(header.Recipients, Recipient{KeyID: id, Wrapped: wrapped})
+	}
+	rawHeader := header.marshal()
+
+	gcm, err := newChunkAEAD(sessionKey)
+	if err != nil {
+		return err
+	}
+
+	inputFile, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	writer := bufio.NewWriter(outputFile)
+	if _, err := writer.Write(rawHeader); err != nil {
+		return err
+	}
+	if err := sealChunks(gcm, header.Nonce, rawHeader, inputFile, writer); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func newChunkAEAD(sessionKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealChunks шифрует поток фрагментами по hybridChunkSize байт.
+func sealChunks(gcm cipher.AEAD, nonce, rawHeader []byte, input io.Reader, writer io.Writer) error {
+	reader := bufio.NewReader(input)
+	chunk := make([]byte, hybridChunkSize)
+	sealed := make([]byte, 0, hybridChunkSize+gcm.Overhead())
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(reader, chunk)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return err
+		}
+
+		sealed = gcm.Seal(sealed[:0], chunkNonce(nonce, index), chunk[:n], chunkAD(rawHeader, final))
+		if _, err := writer.Write(sealed); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// openChunks расшифровывает и проверяет фрагменты, записанные sealChunks.
+func openChunks(gcm cipher.AEAD, nonce, rawHeader []byte, reader io.Reader, writer io.Writer) error {
+	sealed := make([]byte, hybridChunkSize+gcm.Overhead())
+	plain := make([]byte, 0, hybridChunkSize)
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(reader, sealed)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return err
+		}
+
+		plain, err = gcm.Open(plain[:0], chunkNonce(nonce, index), sealed[:n], chunkAD(rawHeader, final))
+		if err != nil {
+			return fmt.Errorf("фрагмент %d поврежден или файл усечен", index)
+		}
+		if _, err := writer.Write(plain); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// decryptHybrid расшифровывает поток в гибридном формате.
+func (r *RSA) decryptHybrid(reader io.Reader, writer io.Writer) error {
+	header, err := readHybridHeader(reader)
+	if err != nil {
+		return err
+	}
+	sessionKey, _, _, err := r.findSessionKey(header)
+	if err != nil {
+		return fmt.Errorf("decryptHybrid: %w", err)
+	}
+	defer clear(sessionKey)
+
+	gcm, err := newChunkAEAD(sessionKey)
+	if err != nil {
+		return err
+	}
+	if err := openChunks(gcm, header.Nonce, header.raw, reader, writer); err != nil {
+		return fmt.Errorf("decryptHybrid: %w", err)
+	}
+	return nil
+}
+
+// findSessionKey ищет в таблице получателей запись для одного из ключей
+// связки и возвращает сеансовый ключ, номер записи и подошедший ключ.
+// Сначала пробуются записи с совпадающим идентификатором, затем все
+// остальные: идентификатор мог быть не записан или ключ переименован.
+func (r *RSA) findSessionKey(header *hybridHeader) ([]byte, int, *KeyPair, error) {
+	keyring, err := r.keyring()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	var candidates []*KeyPair
+	for _, pair := range append([]*KeyPair{keyring.Current}, keyring.Retired...) {
+		if pair.Private != nil {
+			candidates = append(candidates, pair)
+		}
+	}
+
+	// Текущий ключ проверяется раньше выведенных из обращения
+	tried := make(map[int]bool)
+	for _, pair := range candidates {
+		for i, rcpt := range header.Recipients {
+			if hex.EncodeToString(rcpt.KeyID) != pair.ID {
+				continue
+			}
+			tried[i] = true
+			if key, err := pair.Private.unwrapKey(rcpt.Wrapped); err == nil {
+				return key, i, pair, nil
+			}
+		}
+	}
+	for i, rcpt := range header.Recipients {
+		if tried[i] {
+			continue
+		}
+		for _, pair := range candidates {
+			if key, err := pair.Private.unwrapKey(rcpt.Wrapped); err == nil {
+				return key, i, pair, nil
+			}
+		}
+	}
+	return nil, 0, nil, ErrNoRecipient
+}
@@ -0,0 +1,120 @@
+package rsa_alg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestHybridRecipients шифрует файл для count получателей и проверяет,
+// что каждый из них расшифровывает его своим ключом.
+func TestHybridRecipients(t *testing.T) {
+	for _, count := range []int{1, 2, 50} {
+		dir := t.TempDir()
+		plain := filepath.Join(dir, "plain.bin")
+		encrypted := filepath.Join(dir, "plain.enc")
+		// Размер больше одного фрагмента, чтобы проверить их сцепление
+		want := writeRandomFile(t, plain, 150_000)
+
+		var owners []*RSA
+		var recipients []*PublicKey
+		for i := 0; i < count; i++ {
+			r, err := NewRSABits(false, filepath.Join(dir, fmt.Sprintf("keys%d.json", i)), 1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			owners = append(owners, r)
+			recipients = append(recipients, r.PublicKey())
+		}
+		if err := EncryptFileHybrid(plain, encrypted, recipients); err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range owners {
+			checkDecrypted(t, r, encrypted, want)
+		}
+	}
+}
+
+// TestHybridSizes: пустой файл и размеры на границе фрагмента.
+func TestHybridSizes(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRSABits(false, filepath.Join(dir, "keys.json"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 1, hybridChunkSize - 1, hybridChunkSize, hybridChunkSize + 1, 2 * hybridChunkSize} {
+		plain := filepath.Join(dir, fmt.Sprintf("plain%d.bin", size))
+		encrypted := plain + ".enc"
+		want := writeRandomFile(t, plain, size)
+		if err := EncryptFileHybrid(plain, encrypted, []*PublicKey{r.PublicKey()}); err != nil {
+			t.Fatal(err)
+		}
+		checkDecrypted(t, r, encrypted, want)
+	}
+}
+
+// TestHybridForeignKey: ключ не из таблицы получателей получает
+// ErrNoRecipient.
+func TestHybridForeignKey(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.bin")
+	encrypted := filepath.Join(dir, "plain.enc")
+	writeRandomFile(t, plain, 100)
+	owner, err := NewRSABits(false, filepath.Join(dir, "owner.json"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := NewRSABits(false, filepath.Join(dir, "stranger.json"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptFileHybrid(plain, encrypted, []*PublicKey{owner.PublicKey()}); err != nil {
+		t.Fatal(err)
+	}
+	err = stranger.DecryptFile(encrypted, filepath.Join(dir, "plain.dec"))
+	if !errors.Is(err, ErrNoRecipient) {
+		t.Fatalf("ожидалась ошибка ErrNoRecipient, получено %v", err)
+	}
+}
+
+// TestHybridCorrupted: усечение по границе фрагмента, лишние данные и
+// измененный байт обнаруживаются.
+func TestHybridCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRSABits(false, filepath.Join(dir, "keys.json"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "plain.bin")
+	encrypted := filepath.Join(dir, "plain.enc")
+	writeRandomFile(t, plain, 2*hybridChunkSize+100)
+	if err := EncryptFileHybrid(plain, encrypted, []*PublicKey{r.PublicKey()}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Последний фрагмент: 100 байт текста и 16 байт тега
+	lastChunk := 100 + 16
+	flipped := append([]byte(nil), data...)
+	flipped[len(flipped)-lastChunk-10] ^= 1
+	cases := map[string][]byte{
+		"без последнего фрагмента": data[:len(data)-lastChunk],
+		"без последнего байта":     data[:len(data)-1],
+		"лишние данные":            append(append([]byte(nil), data...), 0),
+		"измененный байт":          flipped,
+	}
+	for name, corrupted := range cases {
+		path := filepath.Join(dir, "corrupted.enc")
+		if err := os.WriteFile(path, corrupted, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.DecryptFile(path, filepath.Join(dir, "corrupted.dec")); err == nil {
+			t.Errorf("%s: расшифровка не вернула ошибку", name)
+		}
+	}
+}
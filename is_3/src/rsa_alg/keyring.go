@@ -0,0 +1,171 @@
+package rsa_alg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrUnknownKey файл зашифрован ключом, которого нет в связке
+var ErrUnknownKey = errors.New("rsa_alg: ключа нет в связке")
+
+// KeyPair пара ключей с идентификатором (см. PublicKey.KeyID). Для
+// выведенных из обращения ключей заполнено RetiredAt.
+type KeyPair struct {
+	ID        string
+	Public    *PublicKey
+	Private   *PrivateKey
+	RetiredAt time.Time
+}
+
+// Keyring связка ключей: текущий ключ и ключи, выведенные из обращения.
+// Старые ключи нужны, чтобы расшифровать файлы, зашифрованные до смены
+// ключа, и перешифровать их текущим (команда rekey).
+type Keyring struct {
+	Current *KeyPair
+	Retired []*KeyPair
+}
+
+type retiredKeyJSON struct {
+	ID         string          `json:"id"`
+	RetiredAt  time.Time       `json:"retired_at"`
+	PrivateKey *privateKeyJSON `json:"private_key,omitempty"`
+	PublicKey  *publicKeyJSON  `json:"public_key"`
+}
+
+// NewKeyPair создает пару ключей и вычисляет ее идентификатор.
+func NewKeyPair(pub *PublicKey, priv *PrivateKey) (*KeyPair, error) {
+	id, err := pub.KeyID()
+	if err != nil {
+		return nil, err
+	}
+	return &KeyPair{ID: id, Public: pub, Private: priv}, nil
+}
+
+// ReadKeyring читает связку ключей из файла ключей.
+func ReadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys keysJSON
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if keys.PublicKey == nil {
+		return nil, fmt.Errorf("%s: нет открытого ключа", path)
+	}
+
+	current, err := parseKeyPair(keys.PublicKey, keys.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	keyring := &Keyring{Current: current}
+	for i, rk := range keys.Retired {
+		if rk.PublicKey == nil {
+			return nil, fmt.Errorf("%s: retired[%d]: нет открытого ключа", path, i)
+		}
+		pair, err := parseKeyPair(rk.PublicKey, rk.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: retired[%d]: %w", path, i, err)
+		}
+		pair.RetiredAt = rk.RetiredAt
+		keyring.Retired = append(keyring.Retired, pair)
+	}
+	return keyring, nil
+}
+
+func parseKeyPair(pubJSON *publicKeyJSON, privJSON *privateKeyJSON) (*KeyPair, error) {
+	pub, err := pubJSON.parse()
+	if err != nil {
+		return nil, err
+	}
+	var priv *PrivateKey
+	if privJSON != nil {
+		if priv, err = privJSON.parse(); err != nil {
+			return nil, err
+		}
+	}
+	return NewKeyPair(pub, priv)
+}
+
+// Write атомарно сохраняет связку ключей.
+func (kr *Keyring) Write(path string) error {
+	keys := keysJSON{
+		PublicKey:  marshalPublicKey(kr.Current.Public),
+		PrivateKey: marshalPrivateKey(kr.Current.Private),
+	}
+	for _, pair := range kr.Retired {
+		keys.Retired = append(keys.Retired, retiredKeyJSON{
+			ID:         pair.ID,
+			RetiredAt:  pair.RetiredAt,
+			PublicKey:  marshalPublicKey(pair.Public),
+			PrivateKey: marshalPrivateKey(pair.Private),
+		})
+	}
+
+	jsonData, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, jsonData, 0644)
+}
+
+// Rotate делает (pub, priv) текущим ключом, а прежний текущий ключ
+// переносит в список выведенных из обращения.
+func (kr *Keyring) Rotate(pub *PublicKey, priv *PrivateKey) error {
+	pair, err := NewKeyPair(pub, priv)
+	if err != nil {
+		return err
+	}
+	if kr.Current != nil && kr.Current.ID != pair.ID {
+		kr.Current.RetiredAt = time.Now().UTC().Truncate(time.Second)
+		kr.Retired = append(kr.Retired, kr.Current)
+	}
+	kr.Current = pair
+	return nil
+}
+
+// Find ищет ключ по идентификатору среди текущего и выведенных из обращения.
+func (kr *Keyring) Find(id string) *KeyPair {
+	if kr.Current != nil && kr.Current.ID == id {
+		return kr.Current
+	}
+	for _, pair := range kr.Retired {
+		if pair.ID == id {
+			return pair
+		}
+	}
+	return nil
+}
+
+// pairs текущий ключ и за ним выведенные из обращения
+func (kr *Keyring) pairs() []*KeyPair {
+	return append([]*KeyPair{kr.Current}, kr.Retired...)
+}
+
+// keyring собирает связку ключей объекта RSA.
+func (r *RSA) keyring() (*Keyring, error) {
+	current, err := NewKeyPair(r.publicKey, r.privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Keyring{Current: current, Retired: r.retired}, nil
+}
+
+// privateKeyByID возвращает закрытый ключ с идентификатором id (8 байт).
+func (r *RSA) privateKeyByID(id []byte) (*PrivateKey, error) {
+	keyring, err := r.keyring()
+	if err != nil {
+		return nil, err
+	}
+	pair := keyring.Find(hex.EncodeToString(id))
+	if pair == nil || pair.Private == nil {
+		return nil, fmt.Errorf("%w: %x", ErrUnknownKey, id)
+	}
+	return pair.Private, nil
+}
@@ -0,0 +1,42 @@
+package rsa_alg
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// NewRSAFromKeys создает RSA из готовой пары ключей без файла ключей.
+// priv может быть nil, тогда доступно только шифрование.
+func NewRSAFromKeys(pub *PublicKey, priv *PrivateKey) *RSA {
+	return &RSA{publicKey: pub, privateKey: priv}
+}
+
+// GenerateKey генерирует пару ключей с модулем bits бит и заданной
+// открытой экспонентой e.
+func GenerateKey(bits int, e *big.Int) (*PublicKey, *PrivateKey, error) {
+	if bits < minKeyBits {
+		return nil, nil, fmt.Errorf("GenerateKey: размер ключа %d бит меньше минимального %d", bits, minKeyBits)
+	}
+	one := big.NewInt(1)
+	for {
+		p, q := generatePrimePair(bits)
+		pub := &PublicKey{E: new(big.Int).Set(e), N: new(big.Int).Mul(p, q)}
+		priv, err := PrivateKeyFromFactors(pub, p, q)
+		if err != nil {
+			// e не взаимно просто с φ(n), пробуем другие p и q
+			continue
+		}
+		if priv.D.Cmp(one) > 0 {
+			return pub, priv, nil
+		}
+	}
+}
+
+// PrivateKeyFromFactors восстанавливает закрытый ключ по разложению n = p·q.
+func PrivateKeyFromFactors(pub *PublicKey, p, q *big.Int) (*PrivateKey, error) {
+	priv, err := PrivateKeyFromPrimes(pub, []*big.Int{p, q})
+	if err != nil {
+		return nil, fmt.Errorf("PrivateKeyFromFactors: %w", err)
+	}
+	return priv, nil
+}
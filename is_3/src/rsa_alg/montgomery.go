@@ -0,0 +1,185 @@
+package rsa_alg
+
+import (
+	"crypto/rand"
+	"math/big"
+	"math/bits"
+)
+
+// Hardening защита закрытой операции от атак по времени. В файл ключей не
+// сохраняется: задается при загрузке, см. RSA.SetHardening.
+type Hardening struct {
+	// Ladder заменяет big.Int.Exp лестницей Монтгомери над словами
+	// фиксированной длины: последовательность умножений не зависит ни от
+	// битов d, ни от значения c. При КТО применяется к каждому простому.
+	Ladder bool
+	// Blinding открытая экспонента e для ослепления: возводится в степень
+	// c·r^e, результат умножается на r⁻¹. nil — без ослепления.
+	Blinding *big.Int
+}
+
+// DecryptInt вычисляет c^d mod n (RSA без дополнения) с учетом
+// priv.Hardening.
+func (priv *PrivateKey) DecryptInt(c *big.Int) *big.Int {
+	h := priv.Hardening
+	if h.Blinding == nil {
+		return priv.exp(c)
+	}
+
+	r, rInv := blindingFactor(priv.N)
+	blinded := new(big.Int).Exp(r, h.Blinding, priv.N)
+	blinded.Mul(blinded, c).Mod(blinded, priv.N)
+
+	m := priv.exp(blinded)
+	return m.Mul(m, rInv).Mod(m, priv.N)
+}
+
+// exp вычисляет c^d mod n, по КТО, если известно разложение n.
+func (priv *PrivateKey) exp(c *big.Int) *big.Int {
+	if priv.P != nil && priv.Q != nil {
+		return priv.decryptCRT(c)
+	}
+	return priv.expMod(c, priv.D, priv.N)
+}
+
+func (priv *PrivateKey) expMod(c, d, n *big.Int) *big.Int {
+	if !priv.Hardening.Ladder || n.Bit(0) == 0 {
+		return new(big.Int).Exp(c, d, n)
+	}
+	return newMontModulus(n).ladderExp(c, d)
+}
+
+// blindingFactor выбирает случайное r, обратимое по модулю n.
+func blindingFactor(n *big.Int) (r, rInv *big.Int) {
+	for {
+		r, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			// crypto/rand не возвращает ошибок начиная с Go 1.24
+			panic(err)
+		}
+		if rInv := new(big.Int).ModInverse(r, n); rInv != nil {
+			return r, rInv
+		}
+	}
+}
+
+// montModulus нечетный модуль в виде слов фиксированной длины (младшие
+// первыми) для умножения Монтгомери с R = 2^(W·len(n)).
+type montModulus struct {
+	n     []uint
+	n0inv uint     // -n⁻¹ mod 2^W
+	rr    []uint   // R² mod n
+	big   *big.Int // модуль
+}
+
+func newMontModulus(n *big.Int) *montModulus {
+	m := &montModulus{big: n}
+	m.n = m.words(n)
+
+	// Обращение n[0] по модулю 2^W методом Ньютона: каждый шаг удваивает
+	// число верных бит
+	inv := uint(1)
+	for i := 0; i < 7; i++ {
+		inv *= 2 - m.n[0]*inv
+	}
+	m.n0inv = -inv
+
+	rr := new(big.Int).Lsh(big.NewInt(1), uint(2*bits.UintSize*len(m.n)))
+	m.rr = m.words(rr.Mod(rr, n))
+	return m
+}
+
+// words раскладывает x < n в len(n) слов.
+func (m *montModulus) words(x *big.Int) []uint {
+	out := make([]uint, (m.big.BitLen()+bits.UintSize-1)/bits.UintSize)
+	for i, w := range x.Bits() {
+		out[i] = uint(w)
+	}
+	return out
+}
+
+func (m *montModulus) toBig(x []uint) *big.Int {
+	ws := make([]big.Word, len(x))
+	for i, w := range x {
+		ws[i] = big.Word(w)
+	}
+	return new(big.Int).SetBits(ws)
+}
+
+// mul вычисляет a·b·R⁻¹ mod n (CIOS). Число операций зависит только от
+// длины модуля, финальное вычитание выполняется по маске.
+func (m *montModulus) mul(a, b []uint) []uint {
+	s := len(m.n)
+	t := make([]uint, s+2)
+	for i := 0; i < s; i++ {
+		var c, cc uint
+		for j := 0; j < s; j++ {
+			hi, lo := bits.Mul(a[j], b[i])
+			lo, cc = bits.Add(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add(lo, c, 0)
+			hi += cc
+			t[j], c = lo, hi
+		}
+		t[s], cc = bits.Add(t[s], c, 0)
+		t[s+1] = cc
+
+		mm := t[0] * m.n0inv
+		hi, lo := bits.Mul(mm, m.n[0])
+		_, cc = bits.Add(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < s; j++ {
+			hi, lo = bits.Mul(mm, m.n[j])
+			lo, cc = bits.Add(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add(lo, c, 0)
+			hi += cc
+			t[j-1], c = lo, hi
+		}
+		t[s-1], cc = bits.Add(t[s], c, 0)
+		t[s] = t[s+1] + cc
+	}
+
+	// t < 2n: вычитаем n, если t[s] = 1 или вычитание прошло без заема
+	d := make([]uint, s)
+	var borrow uint
+	for j := 0; j < s; j++ {
+		d[j], borrow = bits.Sub(t[j], m.n[j], borrow)
+	}
+	mask := -(t[s] | (borrow ^ 1))
+	for j := 0; j < s; j++ {
+		d[j] = d[j]&mask | t[j]&^mask
+	}
+	return d
+}
+
+// cswap меняет a и b местами, если bit = 1, без ветвления.
+func cswap(a, b []uint, bit uint) {
+	mask := -bit
+	for i := range a {
+		x := (a[i] ^ b[i]) & mask
+		a[i] ^= x
+		b[i] ^= x
+	}
+}
+
+// ladderExp вычисляет base^exp mod n лестницей Монтгомери. Цикл проходит
+// по всем битам длины модуля, а не exp, поэтому не выдает и длину d.
+// На каждом шаге выполняются одно умножение и одно возведение в квадрат:
+//
+//	бит 0: R1 = R0·R1, R0 = R0²
+//	бит 1: R0 = R0·R1, R1 = R1²
+func (m *montModulus) ladderExp(base, exp *big.Int) *big.Int {
+	one := make([]uint, len(m.n))
+	one[0] = 1
+	r0 := m.mul(one, m.rr)
+	r1 := m.mul(m.words(new(big.Int).Mod(base, m.big)), m.rr)
+	for i := m.big.BitLen() - 1; i >= 0; i-- {
+		bit := exp.Bit(i)
+		cswap(r0, r1, bit)
+		r1 = m.mul(r0, r1)
+		r0 = m.mul(r0, r0)
+		cswap(r0, r1, bit)
+	}
+	return m.toBig(m.mul(r0, one))
+}
@@ -0,0 +1,153 @@
+package rsa_alg
+
+import (
+	"crypto/rand"
+	"math/big"
+	"path/filepath"
+	"testing"
+)
+
+var hardeningVariants = []Hardening{
+	{},
+	{Ladder: true},
+	{Blinding: big.NewInt(65537)},
+	{Ladder: true, Blinding: big.NewInt(65537)},
+}
+
+// TestLadderExp сравнивает лестницу Монтгомери с big.Int.Exp для
+// нечетных модулей разной длины, в том числе меньше одного слова.
+func TestLadderExp(t *testing.T) {
+	for _, size := range []int{17, 64, 65, 512, 1023} {
+		for i := 0; i < 20; i++ {
+			n, err := rand.Int(rand.Reader, new(big.Int).Lsh(bigOne, uint(size)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			n.SetBit(n, size-1, 1).SetBit(n, 0, 1)
+			base, err := rand.Int(rand.Reader, new(big.Int).Lsh(n, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			exp, err := rand.Int(rand.Reader, n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := newMontModulus(n)
+			for _, e := range []*big.Int{exp, big.NewInt(0), big.NewInt(1)} {
+				want := new(big.Int).Exp(base, e, n)
+				if got := m.ladderExp(base, e); got.Cmp(want) != 0 {
+					t.Fatalf("%s^%s mod %s = %s, ожидается %s", base, e, n, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCswap(t *testing.T) {
+	a, b := []uint{1, 2, 3}, []uint{4, 5, 6}
+	cswap(a, b, 0)
+	if a[0] != 1 || b[0] != 4 {
+		t.Fatalf("cswap с bit = 0 изменил значения: %v, %v", a, b)
+	}
+	cswap(a, b, 1)
+	if a[0] != 4 || a[2] != 6 || b[0] != 1 || b[2] != 3 {
+		t.Fatalf("cswap с bit = 1: %v, %v", a, b)
+	}
+}
+
+// TestHardening сравнивает защищенную закрытую операцию с big.Int.Exp
+// для ключей с разложением (КТО) и без него.
+func TestHardening(t *testing.T) {
+	pub, priv, err := GenerateKey(1024, big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), new(big.Int).Sub(pub.N, bigOne)}
+	for i := 0; i < 20; i++ {
+		c, err := rand.Int(rand.Reader, pub.N)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, c)
+	}
+	noFactors := &PrivateKey{D: priv.D, N: priv.N}
+	for _, key := range []*PrivateKey{priv, noFactors} {
+		for _, h := range hardeningVariants {
+			key.Hardening = h
+			for _, c := range inputs {
+				want := new(big.Int).Exp(c, key.D, key.N)
+				if got := key.DecryptInt(c); got.Cmp(want) != 0 {
+					t.Fatalf("ladder=%t, blinding=%t, КТО=%t: неверный результат для c = %s",
+						h.Ladder, h.Blinding != nil, key.P != nil, c)
+				}
+			}
+		}
+	}
+}
+
+// TestHardeningFile: SetHardening действует и на выведенные из обращения
+// ключи, файлы расшифровываются с защитой.
+func TestHardeningFile(t *testing.T) {
+	dir := t.TempDir()
+	keysFile := filepath.Join(dir, "keys.json")
+	plain := filepath.Join(dir, "plain.bin")
+	want := writeRandomFile(t, plain, 1000)
+
+	old, err := NewRSABits(false, keysFile, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldFile := filepath.Join(dir, "old.blk")
+	if err := old.EncryptFileBlocks(plain, oldFile, PaddingOAEP); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRSABits(false, keysFile, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentFile := filepath.Join(dir, "current.blk")
+	if err := r.EncryptFileBlocks(plain, currentFile, PaddingPKCS1v15); err != nil {
+		t.Fatal(err)
+	}
+
+	r.SetHardening(true, true)
+	for _, pair := range append([]*KeyPair{{Public: r.publicKey, Private: r.privateKey}}, r.retired...) {
+		h := pair.Private.Hardening
+		if !h.Ladder || h.Blinding == nil || h.Blinding.Cmp(pair.Public.E) != 0 {
+			t.Fatalf("ключ %s: защита не включена: %+v", pair.ID, h)
+		}
+	}
+	checkDecrypted(t, r, oldFile, want)
+	checkDecrypted(t, r, currentFile, want)
+}
+
+func TestWelchT(t *testing.T) {
+	a := []float64{10, 11, 9, 10, 12, 8, 10, 11, 9, 10}
+	if got := welchT(a, a); got != 0 {
+		t.Errorf("t для одинаковых выборок %f", got)
+	}
+	shifted := make([]float64, len(a))
+	for i, x := range a {
+		shifted[i] = x + 5
+	}
+	if got := welchT(a, shifted); got > -TimingThreshold {
+		t.Errorf("t для сдвинутых выборок %f, ожидается меньше %f", got, -TimingThreshold)
+	}
+	if got := welchT(a[:1], shifted); got != 0 {
+		t.Errorf("t для выборки из одного значения %f", got)
+	}
+}
+
+// TestTimingTest прогоняет измерение на небольшом числе замеров: само
+// наличие утечки на общей машине не проверяется, оно нестабильно.
+func TestTimingTest(t *testing.T) {
+	_, priv, err := GenerateKey(512, big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv.Hardening = Hardening{Ladder: true}
+	res := TimingTest(priv, big.NewInt(2), 200)
+	if res.Samples != 200 || res.MeanFixed <= 0 || res.MeanRandom <= 0 || res.T < 0 {
+		t.Fatalf("неверный результат: %+v", res)
+	}
+}
@@ -0,0 +1,188 @@
+package rsa_alg
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// MaxPrimes наибольшее число простых множителей модуля. RFC 8017 не
+// ограничивает их число, но при u > 5 множители становятся слишком
+// короткими для модулей практических размеров.
+const MaxPrimes = 5
+
+// OtherPrime дополнительный простой множитель многопростого ключа,
+// OtherPrimeInfo из RFC 8017 A.1.2.
+type OtherPrime struct {
+	R     *big.Int // простое r_i, i ≥ 3
+	Exp   *big.Int // d_i = d mod (r_i - 1)
+	Coeff *big.Int // t_i = (r_1·r_2·…·r_(i-1))⁻¹ mod r_i
+}
+
+// crtValues параметры КТО для p и q, RFC 8017 3.2
+type crtValues struct {
+	dP, dQ, qInv *big.Int
+}
+
+// Primes возвращает все простые множители модуля: p, q и r_3…r_u.
+// Если p и q неизвестны, возвращает nil.
+func (priv *PrivateKey) Primes() []*big.Int {
+	if priv.P == nil || priv.Q == nil {
+		return nil
+	}
+	primes := []*big.Int{priv.P, priv.Q}
+	for _, other := range priv.Others {
+		primes = append(primes, other.R)
+	}
+	return primes
+}
+
+// Precompute вычисляет параметры КТО: dP, dQ, qInv и d_i, t_i
+// дополнительных множителей. Без P и Q ничего не делает.
+func (priv *PrivateKey) Precompute() {
+	if priv.P == nil || priv.Q == nil {
+		return
+	}
+	priv.crt = priv.computeCRT()
+	priv.Others = priv.computeOthers()
+}
+
+func (priv *PrivateKey) computeCRT() *crtValues {
+	return &crtValues{
+		dP:   new(big.Int).Mod(priv.D, new(big.Int).Sub(priv.P, bigOne)),
+		dQ:   new(big.Int).Mod(priv.D, new(big.Int).Sub(priv.Q, bigOne)),
+		qInv: new(big.Int).ModInverse(priv.Q, priv.P),
+	}
+}
+
+func (priv *PrivateKey) computeOthers() []OtherPrime {
+	others := make([]OtherPrime, len(priv.Others))
+	product := new(big.Int).Mul(priv.P, priv.Q)
+	for i, other := range priv.Others {
+		others[i] = OtherPrime{
+			R:     other.R,
+			Exp:   new(big.Int).Mod(priv.D, new(big.Int).Sub(other.R, bigOne)),
+			Coeff: new(big.Int).ModInverse(product, other.R),
+		}
+		product.Mul(product, other.R)
+	}
+	return others
+}
+
+// otherPrimeInfos возвращает Others с заполненными d_i и t_i, не изменяя
+// ключ, если Precompute не вызывался.
+func (priv *PrivateKey) otherPrimeInfos() []OtherPrime {
+	for _, other := range priv.Others {
+		if other.Exp == nil || other.Coeff == nil {
+			return priv.computeOthers()
+		}
+	}
+	return priv.Others
+}
+
+var bigOne = big.NewInt(1)
+
+// decryptCRT вычисляет c^d mod n по КТО, RFC 8017 5.1.2 шаг 2b:
+//
+//	m_1 = c^dP mod p, m_2 = c^dQ mod q
+//	h = (m_1 - m_2)·qInv mod p, m = m_2 + q·h
+//	для i ≥ 3: m_i = c^d_i mod r_i, h = (m_i - m)·t_i mod r_i,
+//	m = m + R·h, R = r_1·…·r_(i-1)
+func (priv *PrivateKey) decryptCRT(c *big.Int) *big.Int {
+	crt := priv.crt
+	if crt == nil {
+		crt = priv.computeCRT()
+	}
+
+	m1 := priv.expMod(c, crt.dP, priv.P)
+	m2 := priv.expMod(c, crt.dQ, priv.Q)
+	h := m1.Sub(m1, m2)
+	h.Mul(h, crt.qInv).Mod(h, priv.P)
+	m := h.Mul(h, priv.Q).Add(h, m2)
+
+	product := new(big.Int).Mul(priv.P, priv.Q)
+	for _, other := range priv.otherPrimeInfos() {
+		mi := priv.expMod(c, other.Exp, other.R)
+		h := mi.Sub(mi, m)
+		h.Mul(h, other.Coeff).Mod(h, other.R)
+		m.Add(m, h.Mul(h, product))
+		product.Mul(product, other.R)
+	}
+	return m
+}
+
+// GenerateMultiPrimeKey генерирует ключ с модулем bits бит из primes
+// различных простых, 2 ≤ primes ≤ MaxPrimes.
+func GenerateMultiPrimeKey(bits, primes int, e *big.Int) (*PublicKey, *PrivateKey, error) {
+	if primes < 2 || primes > MaxPrimes {
+		return nil, nil, fmt.Errorf("GenerateMultiPrimeKey: число простых %d вне диапазона 2..%d", primes, MaxPrimes)
+	}
+	if bits/primes < minKeyBits/2 {
+		return nil, nil, fmt.Errorf("GenerateMultiPrimeKey: модуль %d бит слишком мал для %d простых", bits, primes)
+	}
+	for {
+		factors := make([]*big.Int, primes)
+		n := big.NewInt(1)
+		todo := bits
+		for i := range factors {
+			// Последние множители забирают остаток бит
+			size := todo / (primes - i)
+			p, err := rand.Prime(rand.Reader, size)
+			if err != nil {
+				return nil, nil, err
+			}
+			factors[i] = p
+			n.Mul(n, p)
+			todo -= size
+		}
+		if n.BitLen() != bits {
+			continue
+		}
+		pub := &PublicKey{E: new(big.Int).Set(e), N: n}
+		priv, err := PrivateKeyFromPrimes(pub, factors)
+		if err != nil {
+			// Повтор простых или e не взаимно просто с φ(n)
+			continue
+		}
+		return pub, priv, nil
+	}
+}
+
+// PrivateKeyFromPrimes восстанавливает закрытый ключ по разложению
+// n = r_1·…·r_u на различные простые.
+func PrivateKeyFromPrimes(pub *PublicKey, primes []*big.Int) (*PrivateKey, error) {
+	if len(primes) < 2 {
+		return nil, errors.New("PrivateKeyFromPrimes: нужно хотя бы два простых")
+	}
+	n := big.NewInt(1)
+	phi := big.NewInt(1)
+	for i, p := range primes {
+		for _, q := range primes[:i] {
+			if p.Cmp(q) == 0 {
+				return nil, errors.New("PrivateKeyFromPrimes: простые повторяются")
+			}
+		}
+		n.Mul(n, p)
+		phi.Mul(phi, new(big.Int).Sub(p, bigOne))
+	}
+	if n.Cmp(pub.N) != 0 {
+		return nil, errors.New("PrivateKeyFromPrimes: произведение простых ≠ n")
+	}
+	d := new(big.Int).ModInverse(pub.E, phi)
+	if d == nil {
+		return nil, errors.New("PrivateKeyFromPrimes: e не обратимо по модулю φ(n)")
+	}
+
+	priv := &PrivateKey{
+		D: d,
+		N: new(big.Int).Set(pub.N),
+		P: new(big.Int).Set(primes[0]),
+		Q: new(big.Int).Set(primes[1]),
+	}
+	for _, r := range primes[2:] {
+		priv.Others = append(priv.Others, OtherPrime{R: new(big.Int).Set(r)})
+	}
+	priv.Precompute()
+	return priv, nil
+}
@@ -0,0 +1,119 @@
+package rsa_alg
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+)
+
+// TestMultiPrime генерирует ключи из 3..5 простых, сохраняет и читает их,
+// сравнивает расшифровку по КТО с c^d mod n и расшифровывает файл.
+func TestMultiPrime(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.bin")
+	want := writeRandomFile(t, plain, 5000)
+	for primes := 3; primes <= MaxPrimes; primes++ {
+		keysFile := filepath.Join(dir, fmt.Sprintf("keys%d.json", primes))
+		if _, err := NewRSAPrimes(false, keysFile, 1024, primes); err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewRSA(true, keysFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, priv := r.PublicKey(), r.PrivateKey()
+		if got := len(priv.Primes()); got != primes {
+			t.Fatalf("%s: прочитано %d простых вместо %d", keysFile, got, primes)
+		}
+		if pub.N.BitLen() != 1024 {
+			t.Fatalf("%d простых: модуль %d бит", primes, pub.N.BitLen())
+		}
+		for _, f := range CheckKey(pub, priv) {
+			if f.Severity == SeverityError {
+				t.Fatalf("%s: %s", keysFile, f.Message)
+			}
+		}
+
+		for i := 0; i < 10; i++ {
+			c, err := rand.Int(rand.Reader, pub.N)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := new(big.Int).Exp(c, priv.D, priv.N)
+			for _, h := range hardeningVariants {
+				priv.Hardening = h
+				if got := priv.DecryptInt(c); got.Cmp(want) != 0 {
+					t.Fatalf("%d простых, ladder=%t, blinding=%t: КТО дает неверный результат",
+						primes, h.Ladder, h.Blinding != nil)
+				}
+			}
+		}
+		priv.Hardening = Hardening{}
+
+		encrypted := filepath.Join(dir, fmt.Sprintf("plain%d.blk", primes))
+		if err := r.EncryptFileBlocks(plain, encrypted, PaddingOAEP); err != nil {
+			t.Fatal(err)
+		}
+		checkDecrypted(t, r, encrypted, want)
+	}
+}
+
+// TestMultiPrimeAgainstStdlib: crypto/rsa принимает многопростой ключ и
+// расшифровывает то же, что и собственная реализация.
+func TestMultiPrimeAgainstStdlib(t *testing.T) {
+	pub, priv, err := GenerateMultiPrimeKey(2048, 3, big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: pub.N, E: int(pub.E.Int64())},
+		D:         priv.D,
+		Primes:    priv.Primes(),
+	}
+	if err := std.Validate(); err != nil {
+		t.Fatalf("crypto/rsa отклоняет ключ: %v", err)
+	}
+	msg := []byte("Attack at dawn")
+	ct, err := rsa.EncryptPKCS1v15(rand.Reader, &std.PublicKey, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	em := priv.DecryptInt(new(big.Int).SetBytes(ct)).FillBytes(make([]byte, pub.Size()))
+	got, err := PaddingPKCS1v15.unpad(em)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(msg) {
+		t.Fatalf("расшифровано %q, ожидается %q", got, msg)
+	}
+}
+
+func TestPrivateKeyFromPrimes(t *testing.T) {
+	pub, priv, err := GenerateMultiPrimeKey(1024, 4, big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+	primes := priv.Primes()
+	restored, err := PrivateKeyFromPrimes(pub, primes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := big.NewInt(123456789)
+	if restored.DecryptInt(pub.EncryptInt(c)).Cmp(c) != 0 {
+		t.Fatal("восстановленный ключ расшифровывает неверно")
+	}
+
+	if _, err := PrivateKeyFromPrimes(pub, primes[:3]); err == nil {
+		t.Error("неполное разложение принято")
+	}
+	repeated := append([]*big.Int{primes[0]}, primes[:3]...)
+	if _, err := PrivateKeyFromPrimes(pub, repeated); err == nil {
+		t.Error("повторяющиеся простые приняты")
+	}
+	if _, _, err := GenerateMultiPrimeKey(1024, MaxPrimes+1, big.NewInt(65537)); err == nil {
+		t.Error("число простых больше MaxPrimes принято")
+	}
+}
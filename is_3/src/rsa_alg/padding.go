@@ -0,0 +1,208 @@
+package rsa_alg
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// Padding схема дополнения блока перед возведением в степень
+type Padding byte
+
+const (
+	PaddingPKCS1v15 Padding = 1 // EME-PKCS1-v1_5, RFC 8017 7.2
+	PaddingOAEP     Padding = 2 // EME-OAEP с SHA-256 и пустой меткой, RFC 8017 7.1
+)
+
+// ErrDecryption общая ошибка снятия дополнения. Причина намеренно не
+// уточняется, чтобы не давать оракул для атаки на дополнение.
+var ErrDecryption = errors.New("rsa_alg: ошибка расшифровки")
+
+func (p Padding) String() string {
+	switch p {
+	case PaddingPKCS1v15:
+		return "PKCS#1 v1.5"
+	case PaddingOAEP:
+		return "OAEP-SHA256"
+	default:
+		return fmt.Sprintf("Padding(%d)", byte(p))
+	}
+}
+
+// ParsePadding разбирает имя схемы из командной строки.
+func ParsePadding(name string) (Padding, error) {
+	switch name {
+	case "pkcs1", "pkcs1v15":
+		return PaddingPKCS1v15, nil
+	case "oaep":
+		return PaddingOAEP, nil
+	default:
+		return 0, fmt.Errorf("неизвестная схема дополнения: %s", name)
+	}
+}
+
+// MaxMessageLen возвращает число байт открытого текста, помещающихся
+// в один блок модуля длиной k байт.
+func (p Padding) MaxMessageLen(k int) int {
+	switch p {
+	case PaddingPKCS1v15:
+		return k - 11
+	case PaddingOAEP:
+		return k - 2*sha256.Size - 2
+	default:
+		return 0
+	}
+}
+
+// pad дополняет msg до блока длиной k байт.
+func (p Padding) pad(msg []byte, k int) ([]byte, error) {
+	if len(msg) > p.MaxMessageLen(k) {
+		return nil, fmt.Errorf("pad: сообщение %d байт не помещается в блок %s для модуля %d байт", len(msg), p, k)
+	}
+	switch p {
+	case PaddingPKCS1v15:
+		return padPKCS1v15(msg, k)
+	case PaddingOAEP:
+		return padOAEP(msg, k)
+	default:
+		return nil, fmt.Errorf("pad: неизвестная схема %d", byte(p))
+	}
+}
+
+// unpad снимает дополнение с блока em длиной k байт.
+func (p Padding) unpad(em []byte) ([]byte, error) {
+	switch p {
+	case PaddingPKCS1v15:
+		return unpadPKCS1v15(em)
+	case PaddingOAEP:
+		return unpadOAEP(em)
+	default:
+		return nil, fmt.Errorf("unpad: неизвестная схема %d", byte(p))
+	}
+}
+
+// EM = 0x00 || 0x02 || PS || 0x00 || M, PS - ненулевые случайные байты
+func padPKCS1v15(msg []byte, k int) ([]byte, error) {
+	em := make([]byte, k)
+	em[1] = 2
+	ps := em[2 : k-len(msg)-1]
+	if _, err := rand.Read(ps); err != nil {
+		return nil, err
+	}
+	for i := range ps {
+		for ps[i] == 0 {
+			if _, err := rand.Read(ps[i : i+1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	copy(em[k-len(msg):], msg)
+	return em, nil
+}
+
+func unpadPKCS1v15(em []byte) ([]byte, error) {
+	if len(em) < 11 {
+		return nil, ErrDecryption
+	}
+	good := subtle.ConstantTimeByteEq(em[0], 0) & subtle.ConstantTimeByteEq(em[1], 2)
+
+	// Ищем первый нулевой байт после PS, не прерывая цикл
+	lookingForIndex := 1
+	index := 0
+	for i := 2; i < len(em); i++ {
+		equals0 := subtle.ConstantTimeByteEq(em[i], 0)
+		index = subtle.ConstantTimeSelect(lookingForIndex&equals0, i, index)
+		lookingForIndex = subtle.ConstantTimeSelect(equals0, 0, lookingForIndex)
+	}
+	// PS должен быть не короче 8 байт
+	validPS := subtle.ConstantTimeLessOrEq(2+8, index)
+	good &= ^lookingForIndex & 1 & validPS
+	if good != 1 {
+		return nil, ErrDecryption
+	}
+	return em[index+1:], nil
+}
+
+// EM = 0x00 || maskedSeed || maskedDB, DB = lHash || PS || 0x01 || M
+func padOAEP(msg []byte, k int) ([]byte, error) {
+	h := sha256.New()
+	hLen := h.Size()
+	lHash := sha256.Sum256(nil)
+
+	em := make([]byte, k)
+	seed := em[1 : 1+hLen]
+	db := em[1+hLen:]
+
+	copy(db, lHash[:])
+	db[len(db)-len(msg)-1] = 1
+	copy(db[len(db)-len(msg):], msg)
+
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	mgf1XOR(db, h, seed)
+	mgf1XOR(seed, h, db)
+	return em, nil
+}
+
+func unpadOAEP(em []byte) ([]byte, error) {
+	h := sha256.New()
+	hLen := h.Size()
+	if len(em) < 2*hLen+2 {
+		return nil, ErrDecryption
+	}
+	lHash := sha256.Sum256(nil)
+
+	em = bytes.Clone(em)
+	firstByteIsZero := subtle.ConstantTimeByteEq(em[0], 0)
+	seed := em[1 : 1+hLen]
+	db := em[1+hLen:]
+
+	mgf1XOR(seed, h, db)
+	mgf1XOR(db, h, seed)
+
+	lHash2Good := subtle.ConstantTimeCompare(lHash[:], db[:hLen])
+
+	// После lHash идут нули, затем 0x01 и сообщение
+	lookingForIndex := 1
+	index := 0
+	invalid := 0
+	rest := db[hLen:]
+	for i := 0; i < len(rest); i++ {
+		equals0 := subtle.ConstantTimeByteEq(rest[i], 0)
+		equals1 := subtle.ConstantTimeByteEq(rest[i], 1)
+		index = subtle.ConstantTimeSelect(lookingForIndex&equals1, i, index)
+		lookingForIndex = subtle.ConstantTimeSelect(equals1, 0, lookingForIndex)
+		invalid = subtle.ConstantTimeSelect(lookingForIndex&^equals0, 1, invalid)
+	}
+
+	if firstByteIsZero&lHash2Good&^invalid&^lookingForIndex != 1 {
+		return nil, ErrDecryption
+	}
+	return rest[index+1:], nil
+}
+
+// mgf1XOR накладывает на out маску MGF1(seed), RFC 8017 B.2.1
+func mgf1XOR(out []byte, h hash.Hash, seed []byte) {
+	var counter [4]byte
+	var digest []byte
+
+	done := 0
+	for done < len(out) {
+		h.Reset()
+		h.Write(seed)
+		h.Write(counter[:])
+		digest = h.Sum(digest[:0])
+
+		for i := 0; i < len(digest) && done < len(out); i++ {
+			out[done] ^= digest[i]
+			done++
+		}
+		binary.BigEndian.PutUint32(counter[:], binary.BigEndian.Uint32(counter[:])+1)
+	}
+}
@@ -0,0 +1,305 @@
+package rsa_alg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"is_3/src/container"
+	"os"
+	"path/filepath"
+)
+
+// RekeyStatus результат перешифрования одного файла
+type RekeyStatus int
+
+const (
+	RekeyDone        RekeyStatus = iota // перешифрован текущим ключом
+	RekeyCurrent                        // уже зашифрован текущим ключом
+	RekeyUnsupported                    // файл без заголовка RSAB, RSAE или RSAH
+	RekeyUnknown                        // блочный файл версии 1: ключ не указан
+)
+
+func (s RekeyStatus) String() string {
+	switch s {
+	case RekeyDone:
+		return "перешифрован"
+	case RekeyCurrent:
+		return "текущий ключ"
+	case RekeyUnsupported:
+		return "пропущен"
+	case RekeyUnknown:
+		return "ключ не указан"
+	default:
+		return "?"
+	}
+}
+
+// RekeyFile перешифровывает файл, зашифрованный выведенным из обращения
+// ключом, текущим ключом. Файл заменяется атомарно: результат пишется во
+// временный файл рядом и переименовывается поверх исходного.
+//
+// Перешифровываются только файлы с заголовком RSAB, RSAE или RSAH.
+// Остальные файлы, в том числе побайтовые файлы старого формата без
+// заголовка, не изменяются и получают статус RekeyUnsupported: в старом
+// формате нет ни идентификатора ключа, ни проверки целостности, и короткий
+// незашифрованный файл может случайно «расшифроваться» одним из ключей
+// (см. RekeyLegacyBytes). Блочные файлы версии 1 тоже без идентификатора;
+// они не перешифровываются и получают статус RekeyUnknown.
+func (r *RSA) RekeyFile(path string) (RekeyStatus, error) {
+	inputFile, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer inputFile.Close()
+
+	info, err := inputFile.Stat()
+	if err != nil {
+		return 0, err
+	}
+	currentID, err := r.publicKey.keyIDBytes()
+	if err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReader(inputFile)
+	switch magic := container.PeekMagic(reader); magic {
+	case blockMagic, byteMagic:
+		header, err := container.ReadHeader(reader, magic)
+		if err != nil {
+			return 0, err
+		}
+		if header.KeyID == nil {
+			return RekeyUnknown, nil
+		}
+		if bytes.Equal(header.KeyID, currentID) {
+			return RekeyCurrent, nil
+		}
+		priv, err := r.privateKeyByID(header.KeyID)
+		if err != nil {
+			return 0, err
+		}
+		err = ReplaceFileAtomic(path, info.Mode().Perm(), func(f *os.File) error {
+			return r.rekeyBlocks(header, priv, reader, f)
+		})
+		if err != nil {
+			return 0, err
+		}
+		return RekeyDone, nil
+
+	case hybridMagic:
+		header, err := readHybridHeader(reader)
+		if err != nil {
+			return 0, err
+		}
+		sessionKey, entry, pair, err := r.findSessionKey(header)
+		if err != nil {
+			return 0, err
+		}
+		defer clear(sessionKey)
+		if pair.ID == hex.EncodeToString(currentID) {
+			return RekeyCurrent, nil
+		}
+		err = ReplaceFileAtomic(path, info.Mode().Perm(), func(f *os.File) error {
+			return r.rekeyHybrid(header, sessionKey, entry, reader, f)
+		})
+		if err != nil {
+			return 0, err
+		}
+		return RekeyDone, nil
+
+	default:
+		return RekeyUnsupported, nil
+	}
+}
+
+// RekeyLegacyBytes перешифровывает побайтовый файл EncryptFile без
+// заголовка. Ключ определяется пробной расшифровкой всеми ключами связки,
+// поэтому вызывается только для файлов, явно указанных пользователем.
+// Файл, который не читается ни одним ключом, не изменяется.
+func (r *RSA) RekeyLegacyBytes(path string) (RekeyStatus, error) {
+	inputFile, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer inputFile.Close()
+
+	info, err := inputFile.Stat()
+	if err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(inputFile)
+	switch magic := container.PeekMagic(reader); magic {
+	case blockMagic, byteMagic, hybridMagic:
+		return 0, fmt.Errorf("RekeyLegacyBytes: файл с заголовком %s, а не побайтовый", magic)
+	}
+	if info.Size() == 0 {
+		return 0, errors.New("RekeyLegacyBytes: пустой файл")
+	}
+
+	keyring, err := r.keyring()
+	if err != nil {
+		return 0, err
+	}
+	pair, err := decryptBytesWith(keyring.pairs(), reader, io.Discard)
+	if err != nil {
+		return 0, fmt.Errorf("RekeyLegacyBytes: %w", err)
+	}
+	if pair == keyring.Current {
+		return RekeyCurrent, nil
+	}
+	if _, err := inputFile.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	err = ReplaceFileAtomic(path, info.Mode().Perm(), func(f *os.File) error {
+		return r.rekeyBytes(pair, inputFile, f)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return RekeyDone, nil
+}
+
+// rekeyBytes расшифровывает побайтовый файл без заголовка ключом pair и
+// шифрует его текущим ключом в побайтовом формате с заголовком.
+func (r *RSA) rekeyBytes(pair *KeyPair, input io.Reader, output *os.File) error {
+	pr, pw := io.Pipe()
+	go func() {
+		writer := bufio.NewWriter(pw)
+		_, err := decryptBytesWith([]*KeyPair{pair}, bufio.NewReader(input), writer)
+		if err == nil {
+			err = writer.Flush()
+		}
+		pw.CloseWithError(err)
+	}()
+	err := r.encryptBytes(pr, output)
+	pr.CloseWithError(err)
+	return err
+}
+
+// rekeyBlocks расшифровывает блочный или побайтовый файл старым ключом и
+// сразу шифрует открытый текст текущим в том же формате, не записывая его
+// на диск.
+func (r *RSA) rekeyBlocks(header *container.Header, priv *PrivateKey, reader io.Reader, output *os.File) error {
+	pr, pw := io.Pipe()
+	if header.Magic == byteMagic {
+		go func() {
+			writer := bufio.NewWriter(pw)
+			err := decryptByteBody(header, priv, reader, writer)
+			if err == nil {
+				err = writer.Flush()
+			}
+			pw.CloseWithError(err)
+		}()
+		err := r.encryptBytes(pr, output)
+		pr.CloseWithError(err)
+		return err
+	}
+	go func() {
+		pw.CloseWithError(decryptBlockBody(header, priv, reader, pw))
+	}()
+	err := r.encryptBlockStream(pr, output, Padding(header.Padding))
+	pr.CloseWithError(err)
+	return err
+}
+
+// rekeyHybrid заменяет запись entry таблицы получателей записью для
+// текущего ключа. Сеансовый ключ и записи остальных получателей
+// сохраняются, nonce выбирается заново, фрагменты перешифровываются.
+func (r *RSA) rekeyHybrid(header *hybridHeader, sessionKey []byte, entry int, reader io.Reader, output *os.File) error {
+	id, err := r.publicKey.keyIDBytes()
+	if err != nil {
+		return err
+	}
+	wrapped, err := r.publicKey.wrapKey(sessionKey)
+	if err != nil {
+		return err
+	}
+
+	newHeader := &hybridHeader{
+		Recipients: append([]Recipient(nil), header.Recipients...),
+		Nonce:      make([]byte, 12),
+	}
+	newHeader.Recipients[entry] = Recipient{KeyID: id, Wrapped: wrapped}
+	if _, err := rand.Read(newHeader.Nonce); err != nil {
+		return err
+	}
+	rawHeader := newHeader.marshal()
+
+	gcm, err := newChunkAEAD(sessionKey)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(output)
+	if _, err := writer.Write(rawHeader); err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(openChunks(gcm, header.Nonce, header.raw, reader, pw))
+	}()
+	err = sealChunks(gcm, newHeader.Nonce, rawHeader, pr, writer)
+	pr.CloseWithError(err)
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// RekeyTree обходит каталог root и перешифровывает все файлы побайтового,
+// блочного и гибридного форматов. Файлы из legacy перешифровываются как побайтовые
+// файлы без заголовка (RekeyLegacyBytes); каждый из них должен найтись в
+// root. Для каждого файла вызывается report. Обход не прерывается на
+// ошибке отдельного файла; возвращается число ошибок.
+func (r *RSA) RekeyTree(root string, legacy []string, report func(path string, status RekeyStatus, err error)) (int, error) {
+	absKeys, _ := filepath.Abs(r.keysFile)
+	legacySet := make(map[string]string, len(legacy))
+	for _, path := range legacy {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return 0, err
+		}
+		legacySet[abs] = path
+	}
+	failed := 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		abs, _ := filepath.Abs(path)
+		// Файл ключей не трогаем
+		if abs == absKeys {
+			return nil
+		}
+
+		var status RekeyStatus
+		if _, ok := legacySet[abs]; ok {
+			delete(legacySet, abs)
+			status, err = r.RekeyLegacyBytes(path)
+		} else {
+			status, err = r.RekeyFile(path)
+		}
+		if err != nil {
+			failed++
+			err = fmt.Errorf("%s: %w", path, err)
+		}
+		report(path, status, err)
+		return nil
+	})
+	if err != nil {
+		return failed, err
+	}
+	for _, path := range legacySet {
+		failed++
+		report(path, 0, fmt.Errorf("%s: файл не найден в %s", path, root))
+	}
+	return failed, nil
+}
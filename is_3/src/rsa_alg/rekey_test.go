@@ -0,0 +1,166 @@
+package rsa_alg
+
+import (
+	"bytes"
+	"is_3/src/container"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRekeyTree шифрует файлы во всех форматах, генерирует новый ключ,
+// перешифровывает каталог и проверяет, что файлы читаются одним новым
+// ключом.
+func TestRekeyTree(t *testing.T) {
+	dir := t.TempDir()
+	keysFile := filepath.Join(dir, "keys.json")
+	data := filepath.Join(dir, "data")
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "plain.bin")
+	want := writeRandomFile(t, plain, 70_000)
+
+	old, err := NewRSABits(false, keysFile, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := old.EncryptFileBlocks(plain, filepath.Join(data, "a.blk"), PaddingOAEP); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptFileHybrid(plain, filepath.Join(data, "b.hyb"), []*PublicKey{old.PublicKey()}); err != nil {
+		t.Fatal(err)
+	}
+	smallPlain := filepath.Join(dir, "small.bin")
+	smallWant := writeRandomFile(t, smallPlain, 500)
+	if err := old.EncryptFile(smallPlain, filepath.Join(data, "c.byte")); err != nil {
+		t.Fatal(err)
+	}
+	writeLegacyByteFile(t, filepath.Join(data, "d.old"), old.PublicKey(), smallWant)
+	writeLegacyByteFile(t, filepath.Join(data, "e.old"), old.PublicKey(), smallWant)
+	// Незашифрованный и пустой файлы пропускаются. Файл из нулевых байтов
+	// читается в побайтовом формате любым ключом (длина 0 — значение 0)
+	if err := os.WriteFile(filepath.Join(data, "notes.txt"), []byte("не зашифровано\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	zeros := []byte{0, 0, 0}
+	if err := os.WriteFile(filepath.Join(data, "zeros.bin"), zeros, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(data, "empty"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := NewRSABits(false, keysFile, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]RekeyStatus)
+	// Побайтовый файл без заголовка перешифровывается, только если указан
+	legacy := []string{filepath.Join(data, "d.old")}
+	failed, err := current.RekeyTree(data, legacy, func(path string, status RekeyStatus, err error) {
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		statuses[filepath.Base(path)] = status
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed > 0 {
+		t.Fatalf("rekey: ошибок %d", failed)
+	}
+	for name, want := range map[string]RekeyStatus{
+		"a.blk": RekeyDone, "b.hyb": RekeyDone, "c.byte": RekeyDone,
+		"d.old": RekeyDone, "e.old": RekeyUnsupported, "notes.txt": RekeyUnsupported, "empty": RekeyUnsupported,
+		"zeros.bin": RekeyUnsupported,
+	} {
+		if statuses[name] != want {
+			t.Errorf("%s: статус %v, ожидается %v", name, statuses[name], want)
+		}
+	}
+
+	// Без выведенных из обращения ключей файлы должны читаться
+	only := NewRSAFromKeys(current.PublicKey(), current.PrivateKey())
+	checkDecrypted(t, only, filepath.Join(data, "a.blk"), want)
+	checkDecrypted(t, only, filepath.Join(data, "b.hyb"), want)
+	checkDecrypted(t, only, filepath.Join(data, "c.byte"), smallWant)
+	checkDecrypted(t, only, filepath.Join(data, "d.old"), smallWant)
+	checkDecrypted(t, current, filepath.Join(data, "e.old"), smallWant)
+	if got, err := os.ReadFile(filepath.Join(data, "zeros.bin")); err != nil || !bytes.Equal(got, zeros) {
+		t.Fatalf("zeros.bin изменен: %x, %v", got, err)
+	}
+
+	// Повторный rekey ничего не меняет
+	for _, name := range []string{"a.blk", "b.hyb", "c.byte", "d.old"} {
+		status, err := current.RekeyFile(filepath.Join(data, name))
+		if err != nil || status != RekeyCurrent {
+			t.Errorf("%s: повторный rekey: %v, %v", name, status, err)
+		}
+	}
+	if _, err := current.RekeyLegacyBytes(filepath.Join(data, "d.old")); err == nil {
+		t.Error("d.old: файл с заголовком перешифрован как файл без заголовка")
+	}
+}
+
+// TestRekeyLegacyMissing: файл из -legacy, которого нет в каталоге, —
+// ошибка, а не молчаливый пропуск.
+func TestRekeyLegacyMissing(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRSABits(false, filepath.Join(dir, "keys.json"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := filepath.Join(dir, "data")
+	if err := os.Mkdir(data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "elsewhere.byte")
+	var reported error
+	failed, err := r.RekeyTree(data, []string{missing}, func(path string, status RekeyStatus, err error) {
+		reported = err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 1 || reported == nil {
+		t.Fatalf("ошибок %d (%v), ожидается 1", failed, reported)
+	}
+}
+
+// TestRekeyBlockV1: в блочном файле версии 1 нет идентификатора ключа,
+// такой файл не считается зашифрованным текущим ключом.
+func TestRekeyBlockV1(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRSABits(false, filepath.Join(dir, "keys.json"), 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "plain.bin")
+	want := writeRandomFile(t, plain, 1000)
+	encrypted := filepath.Join(dir, "plain.blk")
+	if err := r.EncryptFileBlocks(plain, encrypted, PaddingPKCS1v15); err != nil {
+		t.Fatal(err)
+	}
+
+	// Заголовок версии 2 заменяется заголовком версии 1 без keyID
+	data, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := container.ReadHeader(bytes.NewReader(data), blockMagic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := data[header.Size():]
+	header.Version = 1
+	if err := os.WriteFile(encrypted, append(header.Marshal(), body...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := r.RekeyFile(encrypted)
+	if err != nil || status != RekeyUnknown {
+		t.Fatalf("rekey файла версии 1: %v, %v, ожидается %v", status, err, RekeyUnknown)
+	}
+	checkDecrypted(t, r, encrypted, want)
+}
@@ -1,18 +1,28 @@
 package rsa_alg
 
 import (
+	"bufio"
 	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"is_3/src/container"
 	"math/big"
 	"os"
 )
 
+// minKeyBits минимальный размер модуля для NewRSABits
+const minKeyBits = 32
+
 type RSA struct {
 	publicKey  *PublicKey
 	privateKey *PrivateKey
 	keysFile   string
+	bits       int
+	primes     int
+	retired    []*KeyPair
 }
 
 type PublicKey struct {
@@ -20,20 +30,57 @@ type PublicKey struct {
 	N *big.Int
 }
 
+// PrivateKey закрытый ключ. P и Q необязательны: в старых файлах ключей
+// их нет. Если они известны, расшифровка идет по КТО. Others содержит
+// множители сверх P и Q многопростого ключа.
 type PrivateKey struct {
-	D *big.Int
-	N *big.Int
+	D      *big.Int
+	N      *big.Int
+	P      *big.Int
+	Q      *big.Int
+	Others []OtherPrime
+
+	Hardening Hardening
+
+	crt *crtValues // см. Precompute
 }
 
 func NewRSA(loadKeys bool, keysFileName string) (*RSA, error) {
+	return NewRSABits(loadKeys, keysFileName, 0)
+}
+
+// NewRSABits работает как NewRSA, но при генерации создает модуль длиной bits бит.
+// bits == 0 означает учебный ключ из простых чисел 100..300.
+func NewRSABits(loadKeys bool, keysFileName string, bits int) (*RSA, error) {
+	return NewRSAPrimes(loadKeys, keysFileName, bits, 2)
+}
+
+// NewRSAPrimes работает как NewRSABits, но модуль нового ключа состоит из
+// primes простых множителей (многопростой RSA, RFC 8017 3.2).
+func NewRSAPrimes(loadKeys bool, keysFileName string, bits, primes int) (*RSA, error) {
+	if bits != 0 && bits < minKeyBits {
+		return nil, fmt.Errorf("NewRSABits: размер ключа %d бит меньше минимального %d", bits, minKeyBits)
+	}
+	if primes < 2 || primes > MaxPrimes {
+		return nil, fmt.Errorf("NewRSAPrimes: число простых %d вне диапазона 2..%d", primes, MaxPrimes)
+	}
+	if primes > 2 && bits == 0 {
+		return nil, errors.New("NewRSAPrimes: для многопростого ключа нужен размер модуля")
+	}
 	rsa := &RSA{
 		keysFile: keysFileName,
+		bits:     bits,
+		primes:   primes,
 	}
 
-	if loadKeys && FileExists(rsa.keysFile) {
-		rsa.loadKeys()
+	if loadKeys && container.FileExists(rsa.keysFile) {
+		if err := rsa.loadKeys(); err != nil {
+			return nil, fmt.Errorf("NewRSA: %w", err)
+		}
 	} else {
-		rsa.generateKeys()
+		if err := rsa.generateKeys(); err != nil {
+			return nil, fmt.Errorf("NewRSA: %w", err)
+		}
 		err := rsa.saveKeys()
 		if err != nil {
 			return nil, fmt.Errorf("NewRSA: %w", err)
@@ -59,12 +106,44 @@ func (r *RSA) generatePrime(min, max int64) *big.Int {
 	}
 }
 
-func (r *RSA) generateKeys() {
+// generatePrimePair возвращает два различных простых числа, произведение
+// которых имеет ровно bits бит.
+func generatePrimePair(bits int) (*big.Int, *big.Int) {
+	for {
+		p, err := rand.Prime(rand.Reader, (bits+1)/2)
+		if err != nil {
+			panic(err)
+		}
+		q, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			panic(err)
+		}
+		if p.Cmp(q) != 0 && new(big.Int).Mul(p, q).BitLen() == bits {
+			return p, q
+		}
+	}
+}
+
+func (r *RSA) generateKeys() error {
+	if r.primes > 2 {
+		pub, priv, err := GenerateMultiPrimeKey(r.bits, r.primes, big.NewInt(65537))
+		if err != nil {
+			return err
+		}
+		r.publicKey, r.privateKey = pub, priv
+		return nil
+	}
+
 	// Генерация p и q
-	p := r.generatePrime(100, 300)
-	q := r.generatePrime(100, 300)
-	for p.Cmp(q) == 0 {
+	var p, q *big.Int
+	if r.bits == 0 {
+		p = r.generatePrime(100, 300)
 		q = r.generatePrime(100, 300)
+		for p.Cmp(q) == 0 {
+			q = r.generatePrime(100, 300)
+		}
+	} else {
+		p, q = generatePrimePair(r.bits)
 	}
 
 	// Вычисляем n и φ(n)
@@ -85,72 +164,204 @@ func (r *RSA) generateKeys() {
 	d := new(big.Int).ModInverse(e, phi)
 
 	r.publicKey = &PublicKey{E: e, N: n}
-	r.privateKey = &PrivateKey{D: d, N: n}
+	r.privateKey = &PrivateKey{D: d, N: n, P: p, Q: q}
+	r.privateKey.Precompute()
+	return nil
 }
 
-func (r *RSA) saveKeys() error {
-	keys := map[string]interface{}{
-		"public_key": map[string]string{
-			"e": r.publicKey.E.String(),
-			"n": r.publicKey.N.String(),
-		},
-		"private_key": map[string]string{
-			"d": r.privateKey.D.String(),
-			"n": r.privateKey.N.String(),
-		},
+// PublicKey возвращает открытый ключ.
+func (r *RSA) PublicKey() *PublicKey {
+	return r.publicKey
+}
+
+// PrivateKey возвращает закрытый ключ.
+func (r *RSA) PrivateKey() *PrivateKey {
+	return r.privateKey
+}
+
+// Формат файла ключей (rsa_keys.json). Числа хранятся десятичными строками,
+// p и q записываются, только если они известны. В retired хранятся ключи,
+// выведенные из обращения при генерации новых (см. Keyring).
+type keysJSON struct {
+	PrivateKey *privateKeyJSON  `json:"private_key,omitempty"`
+	PublicKey  *publicKeyJSON   `json:"public_key"`
+	Retired    []retiredKeyJSON `json:"retired,omitempty"`
+}
+
+type publicKeyJSON struct {
+	E string `json:"e"`
+	N string `json:"n"`
+}
+
+type privateKeyJSON struct {
+	D           string           `json:"d"`
+	N           string           `json:"n"`
+	P           string           `json:"p,omitempty"`
+	Q           string           `json:"q,omitempty"`
+	OtherPrimes []otherPrimeJSON `json:"other_primes,omitempty"`
+}
+
+// otherPrimeJSON запись OtherPrimeInfo многопростого ключа
+type otherPrimeJSON struct {
+	R string `json:"r"`
+	D string `json:"d"`
+	T string `json:"t"`
+}
+
+func marshalPublicKey(pub *PublicKey) *publicKeyJSON {
+	return &publicKeyJSON{
+		E: pub.E.String(),
+		N: pub.N.String(),
 	}
+}
 
-	jsonData, err := json.MarshalIndent(keys, "", "  ")
+func marshalPrivateKey(priv *PrivateKey) *privateKeyJSON {
+	if priv == nil {
+		return nil
+	}
+	j := &privateKeyJSON{
+		D: priv.D.String(),
+		N: priv.N.String(),
+	}
+	if priv.P != nil && priv.Q != nil {
+		j.P = priv.P.String()
+		j.Q = priv.Q.String()
+		for _, other := range priv.otherPrimeInfos() {
+			j.OtherPrimes = append(j.OtherPrimes, otherPrimeJSON{
+				R: other.R.String(),
+				D: other.Exp.String(),
+				T: other.Coeff.String(),
+			})
+		}
+	}
+	return j
+}
+
+func (j *publicKeyJSON) parse() (pub *PublicKey, err error) {
+	pub = &PublicKey{}
+	if pub.E, err = container.ParseInt(j.E); err != nil {
+		return nil, fmt.Errorf("e: %w", err)
+	}
+	if pub.N, err = container.ParseInt(j.N); err != nil {
+		return nil, fmt.Errorf("n: %w", err)
+	}
+	return pub, nil
+}
+
+func (j *privateKeyJSON) parse() (priv *PrivateKey, err error) {
+	priv = &PrivateKey{}
+	if priv.D, err = container.ParseInt(j.D); err != nil {
+		return nil, fmt.Errorf("d: %w", err)
+	}
+	if priv.N, err = container.ParseInt(j.N); err != nil {
+		return nil, fmt.Errorf("n: %w", err)
+	}
+	if j.P != "" && j.Q != "" {
+		if priv.P, err = container.ParseInt(j.P); err != nil {
+			return nil, fmt.Errorf("p: %w", err)
+		}
+		if priv.Q, err = container.ParseInt(j.Q); err != nil {
+			return nil, fmt.Errorf("q: %w", err)
+		}
+		for i, o := range j.OtherPrimes {
+			var other OtherPrime
+			if other.R, err = container.ParseInt(o.R); err != nil {
+				return nil, fmt.Errorf("other_primes[%d].r: %w", i, err)
+			}
+			if other.Exp, err = container.ParseInt(o.D); err != nil {
+				return nil, fmt.Errorf("other_primes[%d].d: %w", i, err)
+			}
+			if other.Coeff, err = container.ParseInt(o.T); err != nil {
+				return nil, fmt.Errorf("other_primes[%d].t: %w", i, err)
+			}
+			priv.Others = append(priv.Others, other)
+		}
+		priv.crt = priv.computeCRT()
+	}
+	return priv, nil
+}
+
+// saveKeys сохраняет новый ключ. Ключ, который был в файле раньше,
+// переносится в список выведенных из обращения.
+func (r *RSA) saveKeys() error {
+	keyring, err := ReadKeyring(r.keysFile)
+	if errors.Is(err, os.ErrNotExist) {
+		keyring, err = &Keyring{}, nil
+	}
 	if err != nil {
 		return fmt.Errorf("saveKeys: %w", err)
 	}
+	if err := keyring.Rotate(r.publicKey, r.privateKey); err != nil {
+		return fmt.Errorf("saveKeys: %w", err)
+	}
+	if err := keyring.Write(r.keysFile); err != nil {
+		return fmt.Errorf("saveKeys: %w", err)
+	}
+	r.retired = keyring.Retired
+	return nil
+}
 
-	err = os.WriteFile(r.keysFile, jsonData, 0644)
+func (r *RSA) loadKeys() error {
+	keyring, err := ReadKeyring(r.keysFile)
 	if err != nil {
-		return fmt.Errorf("saveKeys: %w", err)
+		return fmt.Errorf("loadKeys: %w", err)
+	}
+	if keyring.Current.Private == nil {
+		return fmt.Errorf("loadKeys: в файле %s нет закрытого ключа", r.keysFile)
 	}
+	r.publicKey = keyring.Current.Public
+	r.privateKey = keyring.Current.Private
+	r.retired = keyring.Retired
 	return nil
 }
 
-func (r *RSA) loadKeys() {
-	data, err := os.ReadFile(r.keysFile)
+// WriteKeysFile сохраняет ключи в JSON. priv может быть nil, тогда
+// записывается только открытый ключ.
+func WriteKeysFile(path string, pub *PublicKey, priv *PrivateKey) error {
+	keys := keysJSON{
+		PublicKey:  marshalPublicKey(pub),
+		PrivateKey: marshalPrivateKey(priv),
+	}
+
+	jsonData, err := json.MarshalIndent(keys, "", "  ")
 	if err != nil {
-		panic(err)
+		return err
 	}
+	return os.WriteFile(path, jsonData, 0644)
+}
 
-	var keys map[string]interface{}
-	err = json.Unmarshal(data, &keys)
+// ReadKeysFile читает текущие ключи из JSON. Если в файле только открытый
+// ключ, priv равен nil.
+func ReadKeysFile(path string) (pub *PublicKey, priv *PrivateKey, err error) {
+	keyring, err := ReadKeyring(path)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
+	return keyring.Current.Public, keyring.Current.Private, nil
+}
 
-	// Загрузка публичного ключа
-	publicKey := keys["public_key"].(map[string]interface{})
-	e := new(big.Int)
-	e.SetString(publicKey["e"].(string), 10)
-	n := new(big.Int)
-	n.SetString(publicKey["n"].(string), 10)
-	r.publicKey = &PublicKey{E: e, N: n}
+// Size возвращает длину модуля в байтах.
+func (pub *PublicKey) Size() int {
+	return (pub.N.BitLen() + 7) / 8
+}
 
-	// Загрузка приватного ключа
-	privateKey := keys["private_key"].(map[string]interface{})
-	d := new(big.Int)
-	d.SetString(privateKey["d"].(string), 10)
-	n = new(big.Int)
-	n.SetString(privateKey["n"].(string), 10)
-	r.privateKey = &PrivateKey{D: d, N: n}
+// EncryptInt вычисляет m^e mod n (RSA без дополнения).
+func (pub *PublicKey) EncryptInt(m *big.Int) *big.Int {
+	return new(big.Int).Exp(m, pub.E, pub.N)
 }
 
 func (r *RSA) encryptByte(b byte) *big.Int {
-	byteInt := big.NewInt(int64(b))
-	return new(big.Int).Exp(byteInt, r.publicKey.E, r.publicKey.N)
+	return r.publicKey.EncryptInt(big.NewInt(int64(b)))
 }
 
-func (r *RSA) decryptByte(encrypted *big.Int) byte {
-	decrypted := new(big.Int).Exp(encrypted, r.privateKey.D, r.privateKey.N)
-	return byte(decrypted.Int64())
-}
+// Побайтовый формат файла: заголовок container.Header с сигнатурой "RSAE"
+// (keyID — ключ шифрования, blocks — число байтов открытого текста), далее
+// для каждого байта длина значения (1 байт) и само значение m^e mod n.
+// Файлы, записанные до появления связки ключей, заголовка не имеют; ключ
+// для них подбирается (см. decryptBytesWith).
+const byteMagic = "RSAE"
 
+// EncryptFile шифрует файл побайтово текущим ключом.
 func (r *RSA) EncryptFile(inputPath, outputPath string) error {
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
@@ -164,9 +375,30 @@ func (r *RSA) EncryptFile(inputPath, outputPath string) error {
 	}
 	defer outputFile.Close()
 
-	buffer := make([]byte, 1)
+	return r.encryptBytes(inputFile, outputFile)
+}
+
+// encryptBytes шифрует поток текущим ключом в побайтовом формате. Число
+// байтов заранее неизвестно, поэтому оно дописывается в заголовок по
+// окончании.
+func (r *RSA) encryptBytes(input io.Reader, output io.WriterAt) error {
+	keyID, err := r.publicKey.keyIDBytes()
+	if err != nil {
+		return err
+	}
+	header := &container.Header{
+		Magic:   byteMagic,
+		Version: container.Version,
+		ModLen:  uint16(r.publicKey.Size()),
+		KeyID:   keyID,
+	}
+	reader := bufio.NewReader(input)
+	writer := bufio.NewWriter(io.NewOffsetWriter(output, 0))
+	if _, err := writer.Write(header.Marshal()); err != nil {
+		return err
+	}
 	for {
-		_, err := inputFile.Read(buffer)
+		b, err := reader.ReadByte()
 		if err == io.EOF {
 			break
 		}
@@ -174,19 +406,22 @@ func (r *RSA) EncryptFile(inputPath, outputPath string) error {
 			return err
 		}
 
-		encrypted := r.encryptByte(buffer[0])
-		encryptedBytes := encrypted.Bytes()
-
-		length := byte(len(encryptedBytes))
-		if _, err := outputFile.Write([]byte{length}); err != nil {
+		encryptedBytes := r.encryptByte(b).Bytes()
+		if err := writer.WriteByte(byte(len(encryptedBytes))); err != nil {
 			return err
 		}
-		if _, err := outputFile.Write(encryptedBytes); err != nil {
+		if _, err := writer.Write(encryptedBytes); err != nil {
 			return err
 		}
+		header.Blocks++
+	}
+	if err := writer.Flush(); err != nil {
+		return err
 	}
 
-	return nil
+	// Число байтов записывается в конец заголовка
+	_, err = output.WriteAt(binary.BigEndian.AppendUint64(nil, header.Blocks), int64(header.Size()-8))
+	return err
 }
 
 func (r *RSA) DecryptFile(inputPath, outputPath string) error {
@@ -202,36 +437,173 @@ func (r *RSA) DecryptFile(inputPath, outputPath string) error {
 	}
 	defer outputFile.Close()
 
-	for {
-		// Читаем длину зашифрованного блока
-		lengthBuf := make([]byte, 1)
-		_, err := inputFile.Read(lengthBuf)
+	reader := bufio.NewReader(inputFile)
+	writer := bufio.NewWriter(outputFile)
+
+	// Формат определяется по заголовку: гибридный, блочный, побайтовый или
+	// побайтовый без заголовка
+	switch container.PeekMagic(reader) {
+	case hybridMagic:
+		err = r.decryptHybrid(reader, writer)
+	case blockMagic:
+		err = r.decryptBlocks(reader, writer)
+	case byteMagic:
+		err = r.decryptByteFile(reader, writer)
+	default:
+		err = r.decryptBytes(reader, writer)
+	}
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// ReadByteHeader читает заголовок побайтового файла EncryptFile. Для файла
+// без заголовка возвращает nil, ничего не извлекая из reader.
+func ReadByteHeader(reader *bufio.Reader) (*container.Header, error) {
+	if container.PeekMagic(reader) != byteMagic {
+		return nil, nil
+	}
+	return container.ReadHeader(reader, byteMagic)
+}
+
+// ReadByteCiphertext читает очередное значение побайтового формата
+// EncryptFile после заголовка. В конце потока возвращает io.EOF.
+func ReadByteCiphertext(reader io.Reader) (*big.Int, error) {
+	// Читаем длину зашифрованного блока
+	lengthBuf := make([]byte, 1)
+	if _, err := io.ReadFull(reader, lengthBuf); err != nil {
+		return nil, err
+	}
+
+	// Байт 0 шифруется в 0 и записывается с нулевой длиной
+	encryptedBytes := make([]byte, int(lengthBuf[0]))
+	if _, err := io.ReadFull(reader, encryptedBytes); err != nil {
+		return nil, fmt.Errorf("неполный блок: %w", err)
+	}
+	return new(big.Int).SetBytes(encryptedBytes), nil
+}
+
+// decryptByteFile расшифровывает побайтовый формат ключом, указанным в
+// заголовке.
+func (r *RSA) decryptByteFile(reader io.Reader, writer io.Writer) error {
+	header, err := container.ReadHeader(reader, byteMagic)
+	if err != nil {
+		return err
+	}
+	priv := r.privateKey
+	if header.KeyID != nil {
+		if priv, err = r.privateKeyByID(header.KeyID); err != nil {
+			return fmt.Errorf("decryptBytes: %w", err)
+		}
+	}
+	return decryptByteBody(header, priv, reader, writer)
+}
+
+// decryptByteBody расшифровывает header.Blocks значений, следующих за
+// заголовком. Значение, не меньшее модуля или расшифровывающееся не в
+// байт, означает поврежденный файл.
+func decryptByteBody(header *container.Header, priv *PrivateKey, reader io.Reader, writer io.Writer) error {
+	if k := (priv.N.BitLen() + 7) / 8; int(header.ModLen) != k {
+		return fmt.Errorf("decryptBytes: файл зашифрован ключом с модулем %d байт, текущий ключ %d байт", header.ModLen, k)
+	}
+	for i := uint64(0); i < header.Blocks; i++ {
+		encrypted, err := ReadByteCiphertext(reader)
 		if err == io.EOF {
-			break
+			err = io.ErrUnexpectedEOF
 		}
 		if err != nil {
+			return fmt.Errorf("decryptBytes: байт %d: %w", i, err)
+		}
+		if encrypted.Cmp(priv.N) >= 0 {
+			return fmt.Errorf("decryptBytes: байт %d: %w", i, ErrDecryption)
+		}
+		m := priv.DecryptInt(encrypted)
+		if m.BitLen() > 8 {
+			return fmt.Errorf("decryptBytes: байт %d: %w", i, ErrDecryption)
+		}
+		if _, err := writer.Write([]byte{byte(m.Uint64())}); err != nil {
 			return err
 		}
+	}
+	if n, _ := reader.Read(make([]byte, 1)); n != 0 {
+		return container.ErrTrailingData
+	}
+	return nil
+}
 
-		length := int(lengthBuf[0])
-		if length == 0 {
-			continue
+// decryptBytes расшифровывает побайтовый формат EncryptFile без
+// заголовка: длина (1 байт) и зашифрованное значение для каждого байта.
+// Идентификатора ключа нет, поэтому ключ подбирается среди ключей связки
+// (см. decryptBytesWith).
+func (r *RSA) decryptBytes(reader io.Reader, writer io.Writer) error {
+	keyring, err := r.keyring()
+	if err != nil {
+		return err
+	}
+	_, err = decryptBytesWith(keyring.pairs(), reader, writer)
+	return err
+}
+
+// decryptBytesWith расшифровывает побайтовый формат тем из ключей pairs,
+// который подходит ко всем значениям файла, и возвращает этот ключ. Ключ
+// отбрасывается, как только значение оказывается не меньше его модуля или
+// расшифровывается не в байт; для неверного ключа это происходит почти
+// сразу. Пока подходят несколько ключей, открытый текст каждого
+// накапливается в памяти. Если до конца файла дошли несколько ключей
+// (например, файл из байтов 0 и 1, которые шифрование не меняет),
+// выбирается первый из них.
+func decryptBytesWith(pairs []*KeyPair, reader io.Reader, writer io.Writer) (*KeyPair, error) {
+	type candidate struct {
+		pair  *KeyPair
+		plain []byte
+	}
+	var candidates []*candidate
+	for _, pair := range pairs {
+		if pair.Private != nil {
+			candidates = append(candidates, &candidate{pair: pair})
 		}
+	}
 
-		// Читаем зашифрованные данные
-		encryptedBytes := make([]byte, length)
-		_, err = inputFile.Read(encryptedBytes)
+	for {
+		encrypted, err := ReadByteCiphertext(reader)
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("decryptBytes: %w", err)
 		}
 
-		encrypted := new(big.Int).SetBytes(encryptedBytes)
-		decrypted := r.decryptByte(encrypted)
-
-		if _, err := outputFile.Write([]byte{decrypted}); err != nil {
-			return err
+		kept := candidates[:0]
+		for _, c := range candidates {
+			if encrypted.Cmp(c.pair.Private.N) >= 0 {
+				continue
+			}
+			m := c.pair.Private.DecryptInt(encrypted)
+			if m.BitLen() > 8 {
+				continue
+			}
+			c.plain = append(c.plain, byte(m.Uint64()))
+			kept = append(kept, c)
+		}
+		candidates = kept
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("decryptBytes: %w", ErrUnknownKey)
+		}
+		// Ключ определен: накопленный текст выводится сразу
+		if len(candidates) == 1 {
+			if _, err := writer.Write(candidates[0].plain); err != nil {
+				return nil, err
+			}
+			candidates[0].plain = candidates[0].plain[:0]
 		}
 	}
 
-	return nil
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("decryptBytes: %w", ErrUnknownKey)
+	}
+	if _, err := writer.Write(candidates[0].plain); err != nil {
+		return nil, err
+	}
+	return candidates[0].pair, nil
 }
@@ -0,0 +1,154 @@
+package rsa_alg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRandomFile создает файл из size случайных байт.
+func writeRandomFile(t *testing.T, path string, size int) []byte {
+	t.Helper()
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// checkDecrypted расшифровывает encrypted и сравнивает результат с want.
+func checkDecrypted(t *testing.T, r *RSA, encrypted string, want []byte) {
+	t.Helper()
+	decrypted := encrypted + ".dec"
+	if err := r.DecryptFile(encrypted, decrypted); err != nil {
+		t.Fatalf("%s: %v", encrypted, err)
+	}
+	got, err := os.ReadFile(decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s: расшифрованный текст не совпадает с исходным", encrypted)
+	}
+}
+
+// writeLegacyByteFile шифрует data ключом pub в побайтовом формате без
+// заголовка, как EncryptFile до появления связки ключей.
+func writeLegacyByteFile(t *testing.T, path string, pub *PublicKey, data []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, b := range data {
+		c := pub.EncryptInt(big.NewInt(int64(b))).Bytes()
+		buf.WriteByte(byte(len(c)))
+		buf.Write(c)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestByteFileHeader: побайтовый файл начинается с заголовка RSAE с
+// идентификатором ключа и числом байтов; обрезанный файл и лишние данные
+// отвергаются.
+func TestByteFileHeader(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRSABits(false, filepath.Join(dir, "keys.json"), 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "plain.bin")
+	encrypted := filepath.Join(dir, "plain.enc")
+	want := writeRandomFile(t, plain, 100)
+	if err := r.EncryptFile(plain, encrypted); err != nil {
+		t.Fatal(err)
+	}
+	checkDecrypted(t, r, encrypted, want)
+
+	data, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := ReadByteHeader(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := r.PublicKey().keyIDBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == nil || !bytes.Equal(header.KeyID, id) || header.Blocks != uint64(len(want)) {
+		t.Fatalf("заголовок %+v, ожидается keyID %x и %d байт", header, id, len(want))
+	}
+
+	for name, corrupted := range map[string][]byte{
+		"обрезан":       data[:len(data)-1],
+		"лишние данные": append(bytes.Clone(data), 0),
+	} {
+		path := filepath.Join(dir, "corrupted.enc")
+		if err := os.WriteFile(path, corrupted, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.DecryptFile(path, filepath.Join(dir, "corrupted.dec")); err == nil {
+			t.Errorf("%s: файл расшифрован", name)
+		}
+	}
+}
+
+// TestLegacyByteFile: побайтовый файл без заголовка расшифровывается
+// подбором ключа связки.
+func TestLegacyByteFile(t *testing.T) {
+	dir := t.TempDir()
+	keysFile := filepath.Join(dir, "keys.json")
+	old, err := NewRSABits(false, keysFile, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := filepath.Join(dir, "legacy.enc")
+	want := []byte("файл старого формата")
+	writeLegacyByteFile(t, encrypted, old.PublicKey(), want)
+	current, err := NewRSABits(false, keysFile, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDecrypted(t, current, encrypted, want)
+}
+
+// TestByteFileAfterRotation: побайтовый файл, зашифрованный до смены
+// ключа, расшифровывается выведенным из обращения ключом связки.
+func TestByteFileAfterRotation(t *testing.T) {
+	for _, bits := range []int{0, 512} {
+		dir := t.TempDir()
+		keysFile := filepath.Join(dir, "keys.json")
+		plain := filepath.Join(dir, "plain.bin")
+		encrypted := filepath.Join(dir, "plain.enc")
+		want := writeRandomFile(t, plain, 300)
+
+		old, err := NewRSABits(false, keysFile, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := old.EncryptFile(plain, encrypted); err != nil {
+			t.Fatal(err)
+		}
+		current, err := NewRSABits(false, keysFile, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkDecrypted(t, current, encrypted, want)
+
+		// Без выведенного из обращения ключа файл не читается
+		only := NewRSAFromKeys(current.PublicKey(), current.PrivateKey())
+		err = only.DecryptFile(encrypted, filepath.Join(dir, "plain.dec"))
+		if !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("%d бит: ожидалась ErrUnknownKey, получено %v", bits, err)
+		}
+	}
+}
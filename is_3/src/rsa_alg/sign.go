@@ -0,0 +1,248 @@
+package rsa_alg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// SignatureScheme схема кодирования сообщения для подписи, RFC 8017 раздел 9
+type SignatureScheme byte
+
+const (
+	SchemePKCS1v15 SignatureScheme = 1 // EMSA-PKCS1-v1_5
+	SchemePSS      SignatureScheme = 2 // EMSA-PSS, MGF1 с той же хеш-функцией
+)
+
+// ErrVerification подпись не соответствует сообщению или ключу
+var ErrVerification = errors.New("rsa_alg: подпись недействительна")
+
+func (s SignatureScheme) String() string {
+	switch s {
+	case SchemePKCS1v15:
+		return "PKCS#1 v1.5"
+	case SchemePSS:
+		return "PSS"
+	default:
+		return fmt.Sprintf("SignatureScheme(%d)", byte(s))
+	}
+}
+
+// ParseSignatureScheme разбирает имя схемы из командной строки.
+func ParseSignatureScheme(name string) (SignatureScheme, error) {
+	switch name {
+	case "pkcs1", "pkcs1v15":
+		return SchemePKCS1v15, nil
+	case "pss":
+		return SchemePSS, nil
+	default:
+		return 0, fmt.Errorf("неизвестная схема подписи: %s", name)
+	}
+}
+
+// Префиксы DigestInfo (DER), RFC 8017 9.2 примечание 1
+var digestInfoPrefix = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// Sign подписывает хеш digest закрытым ключом. Для PSS длина соли равна
+// длине хеша.
+func (priv *PrivateKey) Sign(scheme SignatureScheme, hash crypto.Hash, digest []byte) ([]byte, error) {
+	if len(digest) != hash.Size() {
+		return nil, fmt.Errorf("Sign: длина хеша %d, ожидается %d", len(digest), hash.Size())
+	}
+	k := (priv.N.BitLen() + 7) / 8
+
+	var em []byte
+	var err error
+	switch scheme {
+	case SchemePKCS1v15:
+		em, err = emsaPKCS1v15Encode(hash, digest, k)
+	case SchemePSS:
+		em, err = emsaPSSEncode(hash, digest, priv.N.BitLen()-1, hash.Size())
+	default:
+		err = fmt.Errorf("неизвестная схема %d", byte(scheme))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Sign: %w", err)
+	}
+
+	// s = m^d mod n, RSASP1
+	s := priv.DecryptInt(new(big.Int).SetBytes(em))
+	return s.FillBytes(make([]byte, k)), nil
+}
+
+// Verify проверяет подпись sig хеша digest открытым ключом.
+func (pub *PublicKey) Verify(scheme SignatureScheme, hash crypto.Hash, digest, sig []byte) error {
+	k := pub.Size()
+	if len(sig) != k || len(digest) != hash.Size() {
+		return ErrVerification
+	}
+	s := new(big.Int).SetBytes(sig)
+	if s.Cmp(pub.N) >= 0 {
+		return ErrVerification
+	}
+
+	// m = s^e mod n, RSAVP1
+	m := pub.EncryptInt(s)
+
+	switch scheme {
+	case SchemePKCS1v15:
+		expected, err := emsaPKCS1v15Encode(hash, digest, k)
+		if err != nil {
+			return ErrVerification
+		}
+		if subtle.ConstantTimeCompare(m.FillBytes(make([]byte, k)), expected) != 1 {
+			return ErrVerification
+		}
+		return nil
+	case SchemePSS:
+		emBits := pub.N.BitLen() - 1
+		emLen := (emBits + 7) / 8
+		if m.BitLen() > emLen*8 {
+			return ErrVerification
+		}
+		return emsaPSSVerify(hash, digest, m.FillBytes(make([]byte, emLen)), emBits, hash.Size())
+	default:
+		return fmt.Errorf("Verify: неизвестная схема %d", byte(scheme))
+	}
+}
+
+// EM = 0x00 || 0x01 || PS (0xff) || 0x00 || DigestInfo, RFC 8017 9.2
+func emsaPKCS1v15Encode(hash crypto.Hash, digest []byte, k int) ([]byte, error) {
+	prefix, ok := digestInfoPrefix[hash]
+	if !ok {
+		return nil, fmt.Errorf("хеш-функция %v не поддерживается", hash)
+	}
+	tLen := len(prefix) + len(digest)
+	if k < tLen+11 {
+		return nil, fmt.Errorf("модуль %d байт слишком мал для %v", k, hash)
+	}
+
+	em := make([]byte, k)
+	em[1] = 1
+	for i := 2; i < k-tLen-1; i++ {
+		em[i] = 0xff
+	}
+	copy(em[k-tLen:], prefix)
+	copy(em[k-len(digest):], digest)
+	return em, nil
+}
+
+// EMSA-PSS-ENCODE, RFC 8017 9.1.1:
+//
+//	M' = 0x00 ×8 || mHash || salt,  H = Hash(M')
+//	DB = PS (нули) || 0x01 || salt, maskedDB = DB ⊕ MGF1(H)
+//	EM = maskedDB || H || 0xbc
+func emsaPSSEncode(hash crypto.Hash, mHash []byte, emBits, sLen int) ([]byte, error) {
+	hLen := hash.Size()
+	emLen := (emBits + 7) / 8
+	if emLen < hLen+sLen+2 {
+		return nil, errors.New("модуль слишком мал для PSS")
+	}
+
+	salt := make([]byte, sLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	h := hash.New()
+	h.Write(make([]byte, 8))
+	h.Write(mHash)
+	h.Write(salt)
+	hh := h.Sum(nil)
+
+	em := make([]byte, emLen)
+	db := em[:emLen-hLen-1]
+	db[emLen-sLen-hLen-2] = 1
+	copy(db[emLen-sLen-hLen-1:], salt)
+	mgf1XOR(db, hash.New(), hh)
+
+	// Старшие 8·emLen - emBits бит обнуляются
+	db[0] &= 0xff >> (8*emLen - emBits)
+	copy(em[emLen-hLen-1:], hh)
+	em[emLen-1] = 0xbc
+	return em, nil
+}
+
+// EMSA-PSS-VERIFY, RFC 8017 9.1.2
+func emsaPSSVerify(hash crypto.Hash, mHash, em []byte, emBits, sLen int) error {
+	hLen := hash.Size()
+	emLen := (emBits + 7) / 8
+	if emLen != len(em) || emLen < hLen+sLen+2 || em[emLen-1] != 0xbc {
+		return ErrVerification
+	}
+
+	db := bytes.Clone(em[:emLen-hLen-1])
+	hh := em[emLen-hLen-1 : emLen-1]
+	bitMask := byte(0xff >> (8*emLen - emBits))
+	if db[0]&^bitMask != 0 {
+		return ErrVerification
+	}
+	mgf1XOR(db, hash.New(), hh)
+	db[0] &= bitMask
+
+	// DB = нули || 0x01 || salt
+	psLen := emLen - hLen - sLen - 2
+	for _, b := range db[:psLen] {
+		if b != 0 {
+			return ErrVerification
+		}
+	}
+	if db[psLen] != 1 {
+		return ErrVerification
+	}
+	salt := db[len(db)-sLen:]
+
+	h := hash.New()
+	h.Write(make([]byte, 8))
+	h.Write(mHash)
+	h.Write(salt)
+	if subtle.ConstantTimeCompare(h.Sum(nil), hh) != 1 {
+		return ErrVerification
+	}
+	return nil
+}
+
+// SignFile подписывает SHA-256 содержимого файла.
+func (priv *PrivateKey) SignFile(path string, scheme SignatureScheme) ([]byte, error) {
+	digest, err := hashFile(path, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return priv.Sign(scheme, crypto.SHA256, digest)
+}
+
+// VerifyFile проверяет подпись SHA-256 содержимого файла.
+func (pub *PublicKey) VerifyFile(path string, scheme SignatureScheme, sig []byte) error {
+	digest, err := hashFile(path, crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	return pub.Verify(scheme, crypto.SHA256, digest, sig)
+}
+
+func hashFile(path string, hash crypto.Hash) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h := hash.New()
+	if _, err := bufio.NewReader(file).WriteTo(h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,133 @@
+package rsa_alg
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var signHashes = []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512}
+
+// stdKey генерирует ключ и строит по нему ключ crypto/rsa. 2048 бит
+// нужны для PSS с SHA-512: в 1024-битный модуль хеш и соль не помещаются.
+func stdKey(t *testing.T) (*PrivateKey, *PublicKey, *rsa.PrivateKey) {
+	t.Helper()
+	pub, priv, err := GenerateKey(2048, big.NewInt(65537))
+	if err != nil {
+		t.Fatal(err)
+	}
+	std := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: pub.N, E: int(pub.E.Int64())},
+		D:         priv.D,
+		Primes:    []*big.Int{priv.P, priv.Q},
+	}
+	if err := std.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	std.Precompute()
+	return priv, pub, std
+}
+
+func digestOf(hash crypto.Hash, msg string) []byte {
+	h := hash.New()
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
+
+// TestSignPKCS1v15 сравнивает подписи побайтно: схема детерминирована.
+func TestSignPKCS1v15(t *testing.T) {
+	priv, pub, std := stdKey(t)
+	for _, hash := range signHashes {
+		digest := digestOf(hash, "Attack at dawn")
+		sig, err := priv.Sign(SchemePKCS1v15, hash, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := rsa.SignPKCS1v15(nil, std, hash, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(sig, want) {
+			t.Fatalf("%v: подпись отличается от crypto/rsa", hash)
+		}
+		if err := pub.Verify(SchemePKCS1v15, hash, digest, want); err != nil {
+			t.Fatalf("%v: подпись crypto/rsa не принята: %v", hash, err)
+		}
+		checkSignRejects(t, pub, SchemePKCS1v15, hash, digest, sig)
+	}
+}
+
+// TestSignPSS проверяет подписи в обе стороны: соль случайна, поэтому
+// побайтное сравнение невозможно.
+func TestSignPSS(t *testing.T) {
+	priv, pub, std := stdKey(t)
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+	for _, hash := range signHashes {
+		digest := digestOf(hash, "Attack at dawn")
+		sig, err := priv.Sign(SchemePSS, hash, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := rsa.VerifyPSS(&std.PublicKey, hash, digest, sig, opts); err != nil {
+			t.Fatalf("%v: crypto/rsa не принимает подпись: %v", hash, err)
+		}
+		stdSig, err := rsa.SignPSS(rand.Reader, std, hash, digest, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pub.Verify(SchemePSS, hash, digest, stdSig); err != nil {
+			t.Fatalf("%v: подпись crypto/rsa не принята: %v", hash, err)
+		}
+		checkSignRejects(t, pub, SchemePSS, hash, digest, sig)
+	}
+}
+
+// checkSignRejects проверяет, что измененные хеш и подпись отвергаются.
+func checkSignRejects(t *testing.T, pub *PublicKey, scheme SignatureScheme, hash crypto.Hash, digest, sig []byte) {
+	t.Helper()
+	other := digestOf(hash, "Attack at dusk")
+	if err := pub.Verify(scheme, hash, other, sig); !errors.Is(err, ErrVerification) {
+		t.Errorf("%s/%v: принята подпись другого сообщения: %v", scheme, hash, err)
+	}
+	broken := bytes.Clone(sig)
+	broken[len(broken)/2] ^= 1
+	if err := pub.Verify(scheme, hash, digest, broken); !errors.Is(err, ErrVerification) {
+		t.Errorf("%s/%v: принята поврежденная подпись: %v", scheme, hash, err)
+	}
+	if err := pub.Verify(scheme, hash, digest, sig[1:]); !errors.Is(err, ErrVerification) {
+		t.Errorf("%s/%v: принята укороченная подпись: %v", scheme, hash, err)
+	}
+}
+
+func TestSignFile(t *testing.T) {
+	priv, pub, _ := stdKey(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "doc.bin")
+	writeRandomFile(t, path, 10_000)
+	for _, scheme := range []SignatureScheme{SchemePKCS1v15, SchemePSS} {
+		sig, err := priv.SignFile(path, scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pub.VerifyFile(path, scheme, sig); err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+	}
+
+	sig, err := priv.SignFile(path, SchemePSS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("другой документ"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.VerifyFile(path, SchemePSS, sig); !errors.Is(err, ErrVerification) {
+		t.Fatalf("подпись измененного файла: ожидалась ErrVerification, получено %v", err)
+	}
+}
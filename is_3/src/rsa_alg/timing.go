@@ -0,0 +1,134 @@
+package rsa_alg
+
+import (
+	"crypto/rand"
+	"math"
+	"math/big"
+	mrand "math/rand/v2"
+	"slices"
+	"time"
+)
+
+// TimingThreshold порог |t| статистики Уэлча, выше которого разница во
+// времени считается обнаруженной (как в dudect).
+const TimingThreshold = 4.5
+
+// TimingResult результат теста на утечку по времени.
+type TimingResult struct {
+	Samples    int     // измерений в каждом классе
+	MeanFixed  float64 // среднее время, нс, класс фиксированного входа
+	MeanRandom float64 // среднее время, нс, класс случайных входов
+	T          float64 // наибольший |t| по всем порогам отсечения
+}
+
+// Leak сообщает, обнаружена ли зависимость времени от входа.
+func (r TimingResult) Leak() bool {
+	return r.T > TimingThreshold
+}
+
+// SetHardening включает защиту закрытой операции для текущего и
+// выведенных из обращения ключей.
+func (r *RSA) SetHardening(ladder, blinding bool) {
+	pairs := []*KeyPair{{Public: r.publicKey, Private: r.privateKey}}
+	for _, pair := range append(pairs, r.retired...) {
+		if pair.Private == nil {
+			continue
+		}
+		pair.Private.Hardening = Hardening{Ladder: ladder}
+		if blinding {
+			pair.Private.Hardening.Blinding = pair.Public.E
+		}
+	}
+}
+
+// TimingTest измеряет DecryptInt по методике dudect: входы двух классов —
+// фиксированный шифртекст fixed и случайные — перемешиваются в случайном
+// порядке, каждое вычисление замеряется отдельно. Затем классы сравниваются
+// t-тестом Уэлча, в том числе после отсечения медленных выбросов по
+// нескольким процентилям. Возвращается наибольший |t|.
+func TimingTest(priv *PrivateKey, fixed *big.Int, samples int) TimingResult {
+	total := 2 * samples
+	classes := make([]int, total)
+	for i := samples; i < total; i++ {
+		classes[i] = 1
+	}
+	mrand.Shuffle(total, func(i, j int) { classes[i], classes[j] = classes[j], classes[i] })
+
+	// Входы готовятся заранее, чтобы генерация не попала в замер
+	inputs := make([]*big.Int, total)
+	for i, class := range classes {
+		if class == 0 {
+			inputs[i] = fixed
+			continue
+		}
+		c, err := rand.Int(rand.Reader, priv.N)
+		if err != nil {
+			panic(err)
+		}
+		inputs[i] = c
+	}
+
+	times := make([]float64, total)
+	for i, c := range inputs {
+		start := time.Now()
+		priv.DecryptInt(c)
+		times[i] = float64(time.Since(start).Nanoseconds())
+	}
+
+	var byClass [2][]float64
+	for i, class := range classes {
+		byClass[class] = append(byClass[class], times[i])
+	}
+	result := TimingResult{
+		Samples:    samples,
+		MeanFixed:  mean(byClass[0]),
+		MeanRandom: mean(byClass[1]),
+	}
+
+	sorted := slices.Clone(times)
+	slices.Sort(sorted)
+	for _, p := range []float64{1, 0.99, 0.9, 0.75, 0.5} {
+		limit := sorted[int(p*float64(total-1))]
+		t := math.Abs(welchT(below(byClass[0], limit), below(byClass[1], limit)))
+		result.T = max(result.T, t)
+	}
+	return result
+}
+
+func below(xs []float64, limit float64) []float64 {
+	var out []float64
+	for _, x := range xs {
+		if x <= limit {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func mean(xs []float64) float64 {
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// welchT t-статистика Уэлча для выборок с разными дисперсиями.
+func welchT(a, b []float64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+	variance := func(xs []float64, m float64) float64 {
+		sum := 0.0
+		for _, x := range xs {
+			sum += (x - m) * (x - m)
+		}
+		return sum / float64(len(xs)-1)
+	}
+	ma, mb := mean(a), mean(b)
+	se := math.Sqrt(variance(a, ma)/float64(len(a)) + variance(b, mb)/float64(len(b)))
+	if se == 0 {
+		return 0
+	}
+	return (ma - mb) / se
+}
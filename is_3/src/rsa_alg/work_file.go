@@ -1,11 +1,45 @@
 package rsa_alg
 
-import "os"
+import (
+	"os"
+	"path/filepath"
+)
 
-func FileExists(filename string) bool {
-	info, err := os.Stat(filename)
-	if os.IsNotExist(err) {
-		return false
+// WriteFileAtomic записывает данные во временный файл рядом с path и
+// переименовывает его поверх path.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return ReplaceFileAtomic(path, perm, func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+}
+
+// ReplaceFileAtomic создает временный файл в каталоге path, заполняет его
+// функцией write и атомарно переименовывает поверх path. При ошибке path
+// остается нетронутым.
+func ReplaceFileAtomic(path string, perm os.FileMode, write func(f *os.File) error) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = write(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
 	}
-	return !info.IsDir()
+	return os.Rename(tmp.Name(), path)
 }
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// analyzeCommand выполняет "analyze <команда>": weak, complement,
+// differential, feistel или avalanche.
+func analyzeCommand(args []string, flags map[string]string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("не указана команда: weak, complement, differential, feistel или avalanche")
+	}
+	intFlag := func(name string, def int) (int, error) {
+		v, ok := flags[name]
+		if !ok {
+			return def, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("-%s: %v", name, err)
+		}
+		return n, nil
+	}
+
+	switch args[0] {
+	case "weak":
+		return weakKeysDemo(os.Stdout)
+	case "complement":
+		return complementDemo(os.Stdout, 1000)
+	case "differential":
+		rounds, err := intFlag("rounds", 3)
+		if err != nil {
+			return err
+		}
+		pairs, err := intFlag("pairs", 0)
+		if err != nil {
+			return err
+		}
+		return differentialAttack(os.Stdout, rounds, pairs)
+	case "feistel":
+		trials, err := intFlag("trials", 2000)
+		if err != nil {
+			return err
+		}
+		if trials < 1 {
+			return fmt.Errorf("-trials: число испытаний %d меньше 1", trials)
+		}
+		rounds, err := intFlag("rounds", 0)
+		if err != nil {
+			return err
+		}
+		return feistelCommand(os.Stdout, trials, rounds)
+	case "avalanche":
+		samples, err := intFlag("samples", 1000)
+		if err != nil {
+			return err
+		}
+		return avalancheCommand(os.Stdout, samples, flags["csv"], flags["json"])
+	}
+	return fmt.Errorf("неизвестная команда analyze %s", args[0])
+}
+
+// parityMask значащие биты ключа DES, младший бит каждого байта — четность
+const parityMask = 0xFEFEFEFEFEFEFEFE
+
+// weakKeys ключи, для которых все 16 подключей одинаковы: E_K(E_K(P)) = P
+var weakKeys = []uint64{
+	0x0101010101010101,
+	0xFEFEFEFEFEFEFEFE,
+	0xE0E0E0E0F1F1F1F1,
+	0x1F1F1F1F0E0E0E0E,
+}
+
+// semiWeakPairs пары полуслабых ключей: E_K1(E_K2(P)) = P, расписание K2
+// совпадает с расписанием K1 в обратном порядке
+var semiWeakPairs = [][2]uint64{
+	{0x011F011F010E010E, 0x1F011F010E010E01},
+	{0x01E001E001F101F1, 0xE001E001F101F101},
+	{0x01FE01FE01FE01FE, 0xFE01FE01FE01FE01},
+	{0x1FE01FE00EF10EF1, 0xE01FE01FF10EF10E},
+	{0x1FFE1FFE0EFE0EFE, 0xFE1FFE1FFE0EFE0E},
+	{0xE0FEE0FEF1FEF1FE, 0xFEE0FEE0FEF1FEF1},
+}
+
+// weakKeyKind возвращает "слабый" или "полуслабый", если ключ (без учета
+// битов четности) входит в соответствующий список, иначе пустую строку.
+func weakKeyKind(key uint64) string {
+	for _, w := range weakKeys {
+		if key&parityMask == w&parityMask {
+			return "слабый"
+		}
+	}
+	for _, pair := range semiWeakPairs {
+		for _, w := range pair {
+			if key&parityMask == w&parityMask {
+				return "полуслабый"
+			}
+		}
+	}
+	return ""
+}
+
+// weakKeyWarnings проверяет каждый ключ DES, входящий в ключ варианта
+// variant. Для тройного DES также проверяется, что k1 ≠ k2 и k2 ≠ k3:
+// иначе шифрование вырождается в одинарный DES.
+func weakKeyWarnings(variant string, key []byte) []string {
+	var warnings []string
+	parts := make([]uint64, 0, 3)
+	for i := 0; i+8 <= len(key); i += 8 {
+		parts = append(parts, binary.BigEndian.Uint64(key[i:]))
+	}
+	for i, k := range parts {
+		if kind := weakKeyKind(k); kind != "" {
+			name := "ключ"
+			if len(parts) > 1 {
+				name = fmt.Sprintf("ключ k%d", i+1)
+			}
+			warnings = append(warnings, fmt.Sprintf("%s %016X — %s ключ DES", name, k, kind))
+		}
+	}
+	if variant != variantDES && len(parts) >= 2 {
+		k3 := parts[0]
+		if len(parts) == 3 {
+			k3 = parts[2]
+		}
+		if parts[0]&parityMask == parts[1]&parityMask || parts[1]&parityMask == k3&parityMask {
+			warnings = append(warnings, "совпадающие ключи: тройной DES вырождается в одинарный")
+		}
+	}
+	return warnings
+}
+
+// complementDemo проверяет свойство дополнения E(~K, ~P) = ~E(K, P) для
+// count случайных ключей и блоков. Из-за него полный перебор ключей DES
+// при выбранном открытом тексте вдвое короче.
+func complementDemo(w io.Writer, count int) error {
+	buf := make([]byte, 16)
+	for i := 0; i < count; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		key, plain := binary.BigEndian.Uint64(buf), binary.BigEndian.Uint64(buf[8:])
+		c := NewDES(key).encryptBlock(plain)
+		cc := NewDES(^key).encryptBlock(^plain)
+		if i < 3 {
+			fmt.Fprintf(w, "K = %016X  P = %016X  E(K, P)   = %016X\n", key, plain, c)
+			fmt.Fprintf(w, "~K = %016X ~P = %016X E(~K, ~P) = %016X = ~%016X\n\n", ^key, ^plain, cc, ^cc)
+		}
+		if cc != ^c {
+			return fmt.Errorf("свойство нарушено: K = %016X, P = %016X", key, plain)
+		}
+	}
+	fmt.Fprintf(w, "✓ E(~K, ~P) = ~E(K, P) для %d случайных пар\n", count)
+	return nil
+}
+
+// weakKeysDemo показывает, что слабые ключи — инволюции, а полуслабые
+// пары обращают друг друга.
+func weakKeysDemo(w io.Writer) error {
+	plain := uint64(0x0123456789ABCDEF)
+	fmt.Fprintf(w, "Открытый текст P = %016X\n", plain)
+	fmt.Fprintln(w, "Слабые ключи, E_K(E_K(P)):")
+	for _, k := range weakKeys {
+		d := NewDES(k)
+		twice := d.encryptBlock(d.encryptBlock(plain))
+		fmt.Fprintf(w, "  %016X  %016X\n", k, twice)
+		if twice != plain {
+			return fmt.Errorf("ключ %016X не слабый", k)
+		}
+	}
+	fmt.Fprintln(w, "Полуслабые пары, E_K2(E_K1(P)):")
+	for _, pair := range semiWeakPairs {
+		back := NewDES(pair[1]).encryptBlock(NewDES(pair[0]).encryptBlock(plain))
+		fmt.Fprintf(w, "  %016X / %016X  %016X\n", pair[0], pair[1], back)
+		if back != plain {
+			return fmt.Errorf("пара %016X / %016X не полуслабая", pair[0], pair[1])
+		}
+	}
+	fmt.Fprintln(w, "✓ все ключи обращают шифрование")
+	return nil
+}
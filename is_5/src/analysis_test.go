@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// TestWeakKeys: слабые ключи — инволюции, полуслабые пары обращают друг
+// друга.
+func TestWeakKeys(t *testing.T) {
+	if err := weakKeysDemo(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWeakKeyKind(t *testing.T) {
+	cases := []struct {
+		key  uint64
+		want string
+	}{
+		{0x0101010101010101, "слабый"},
+		{0x0000000000000000, "слабый"}, // биты четности не учитываются
+		{0xFEFEFEFEFEFEFEFE, "слабый"},
+		{0x011F011F010E010E, "полуслабый"},
+		{0x1F011F010E010E01, "полуслабый"},
+		{0x133457799BBCDFF1, ""},
+	}
+	for _, c := range cases {
+		if got := weakKeyKind(c.key); got != c.want {
+			t.Errorf("weakKeyKind(%016X) = %q, ожидается %q", c.key, got, c.want)
+		}
+	}
+}
+
+func TestWeakKeyWarnings(t *testing.T) {
+	cases := []struct {
+		variant string
+		key     string
+		warn    bool
+	}{
+		{variantDES, "0101010101010101", true},
+		{variantDES, "0000000000000000", true},
+		{variantDES, "011F011F010E010E", true},
+		{variantDES, "133457799BBCDFF1", false},
+		{variant3DESEDE2, "133457799BBCDFF1133457799BBCDFF1", true},
+		{variant3DESEDE3, "133457799BBCDFF1FEFEFEFEFEFEFEFE0123456789ABCDEF", true},
+		{variant3DESEDE3, "133457799BBCDFF10123456789ABCDEF23456789ABCDEF01", false},
+	}
+	for _, c := range cases {
+		key, _ := hex.DecodeString(c.key)
+		if warnings := weakKeyWarnings(c.variant, key); (len(warnings) > 0) != c.warn {
+			t.Errorf("%s %s: предупреждения %q", c.variant, c.key, warnings)
+		}
+	}
+}
+
+// TestGeneratedKeysNotWeak: генератор ключей не выдает слабых ключей.
+func TestGeneratedKeysNotWeak(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	for i := 0; i < 100; i++ {
+		key, err := generateAndSaveKey(keyFile, variantDES, encodingHex, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := weakKeyWarnings(variantDES, key); len(w) > 0 {
+			t.Fatalf("сгенерирован ключ с предупреждением %q", w)
+		}
+	}
+}
+
+// TestComplement: свойство дополнения E(~K, ~P) = ~E(K, P).
+func TestComplement(t *testing.T) {
+	if err := complementDemo(io.Discard, 1000); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReducedRounds: decryptBlock обращает encryptBlock при любом числе
+// раундов, 16 раундов совпадают с NewDES, недопустимое число отвергается.
+func TestReducedRounds(t *testing.T) {
+	key := binary.BigEndian.Uint64(randomBytes(8))
+	block := binary.BigEndian.Uint64(randomBytes(8))
+	prev := block
+	for rounds := 1; rounds <= fullRounds; rounds++ {
+		d, err := NewDESRounds(key, rounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := d.encryptBlock(block)
+		if got := d.decryptBlock(c); got != block {
+			t.Fatalf("%d раундов: D(E(%016X)) = %016X", rounds, block, got)
+		}
+		if c == prev {
+			t.Fatalf("%d раундов: шифртекст не изменился", rounds)
+		}
+		prev = c
+	}
+	if prev != NewDES(key).encryptBlock(block) {
+		t.Fatal("16 раундов не совпадают с NewDES")
+	}
+	for _, rounds := range []int{0, 17} {
+		if _, err := NewDESRounds(key, rounds); err == nil {
+			t.Errorf("%d раундов приняты", rounds)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+
+	"avalanche"
+)
+
+// desAvalancheCipher DES для анализа лавинного эффекта; анализируются 56
+// значащих бит ключа, биты четности на шифрование не влияют
+type desAvalancheCipher struct{}
+
+func (desAvalancheCipher) Name() string   { return "DES" }
+func (desAvalancheCipher) BlockBits() int { return 64 }
+func (desAvalancheCipher) KeyBits() int   { return 56 }
+func (desAvalancheCipher) KeyBytes() int  { return 8 }
+
+func (desAvalancheCipher) FlipKeyBit(key []byte, i int) {
+	key[i/7] ^= 0x80 >> (i % 7)
+}
+
+func (desAvalancheCipher) EncryptTrace(plain, key []byte) ([]byte, [][]byte) {
+	d := NewDES(binary.BigEndian.Uint64(key))
+	var states [][]byte
+	out := cryptBlockTrace(binary.BigEndian.Uint64(plain), d.subkeys[:d.rounds], func(_ int, state uint64) {
+		states = append(states, binary.BigEndian.AppendUint64(nil, state))
+	})
+	return binary.BigEndian.AppendUint64(nil, out), states
+}
+
+// avalancheCommand выполняет "analyze avalanche"
+func avalancheCommand(w io.Writer, samples int, csvPrefix, jsonPath string) error {
+	return avalanche.Analyze(w, desAvalancheCipher{}, samples, csvPrefix, jsonPath)
+}
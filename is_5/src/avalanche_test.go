@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"avalanche"
+)
+
+// TestDESAvalancheTrace: шифртекст адаптера совпадает с DES, состояний на
+// одно больше числа раундов, первое — открытый текст после IP.
+func TestDESAvalancheTrace(t *testing.T) {
+	c := desAvalancheCipher{}
+	key, plain := randomBytes(8), randomBytes(blockSize)
+	out, states := c.EncryptTrace(plain, key)
+	want := make([]byte, blockSize)
+	NewDES(binary.BigEndian.Uint64(key)).Encrypt(want, plain)
+	if !bytes.Equal(out, want) {
+		t.Fatalf("шифртекст %X, ожидается %X", out, want)
+	}
+	if len(states) != fullRounds+1 {
+		t.Fatalf("%d состояний", len(states))
+	}
+	if got := binary.BigEndian.Uint64(states[0]); got != initialPermutation(binary.BigEndian.Uint64(plain)) {
+		t.Fatalf("состояние 0: %016X", got)
+	}
+
+	// Биты четности не анализируются: каждый инвертируемый бит меняет ключ
+	for i := 0; i < c.KeyBits(); i++ {
+		flipped := bytes.Clone(key)
+		c.FlipKeyBit(flipped, i)
+		if k := binary.BigEndian.Uint64(flipped); k&parityMask == binary.BigEndian.Uint64(key)&parityMask {
+			t.Fatalf("бит %d ключа — бит четности", i)
+		}
+	}
+}
+
+// TestDESAvalanche: после первого раунда меняется немного бит, после
+// последнего — около половины; SAC близок к 0,5.
+func TestDESAvalanche(t *testing.T) {
+	report, err := avalanche.Run(desAvalancheCipher{}, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range []avalanche.Stats{report.Plaintext, report.Key} {
+		if len(st.Rounds) != fullRounds+1 {
+			t.Fatalf("%s: %d раундов в отчете", st.Input, len(st.Rounds))
+		}
+		if first := st.Rounds[1].Mean; first > 8 {
+			t.Fatalf("%s: после раунда 1 меняется %.2f бит", st.Input, first)
+		}
+		if last := st.Rounds[fullRounds].Mean; math.Abs(last-32) > 1 {
+			t.Fatalf("%s: после раунда 16 меняется %.2f бит", st.Input, last)
+		}
+		if math.Abs(st.SACMean-0.5) > 0.01 {
+			t.Fatalf("%s: среднее SAC %.4f", st.Input, st.SACMean)
+		}
+	}
+	if len(report.Key.SAC) != 56 || len(report.Key.SAC[0]) != 64 {
+		t.Fatalf("матрица SAC ключа %dx%d", len(report.Key.SAC), len(report.Key.SAC[0]))
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Перебор ключа DES по известной паре открытый текст/шифртекст в
+// уменьшенном пространстве: неизвестны младшие bits значащих битов ключа,
+// остальные заданы. Пространство делится на фрагменты по 2^chunkBits
+// ключей, которые разбирают рабочие горутины.
+//
+// Внутри фрагмента ключи перебираются в порядке кода Грея: соседние ключи
+// отличаются одним битом, а расписание ключей DES — перестановка битов,
+// поэтому все 16 подключей обновляются шестнадцатью XOR без пересчета
+// PC-1 и PC-2.
+
+// bruteforceCommand выполняет "bruteforce": по паре -plain/-cipher и
+// ключу -known с -bits неизвестными младшими битами, с -resume — по
+// контрольной точке, с -demo — по случайному ключу. Ctrl+C прерывает
+// перебор с сохранением состояния в -checkpoint.
+func bruteforceCommand(flags map[string]string) error {
+	checkpoint := flags["checkpoint"]
+	if checkpoint == "" {
+		checkpoint = "bruteforce.json"
+	}
+	workers := runtime.GOMAXPROCS(0)
+	if v, ok := flags["workers"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("-workers: неправильное число горутин %q", v)
+		}
+		workers = n
+	}
+
+	var t *bruteTask
+	var secret uint64
+	switch {
+	case flags["resume"] == "true":
+		var err error
+		if t, err = readCheckpoint(checkpoint); err != nil {
+			return fmt.Errorf("чтение контрольной точки: %w", err)
+		}
+	default:
+		t = &bruteTask{Plain: flags["plain"], Cipher: flags["cipher"], Known: flags["known"], Bits: 24}
+		if t.Known == "" {
+			t.Known = "0000000000000000"
+		}
+		if v, ok := flags["bits"]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("-bits: %v", err)
+			}
+			t.Bits = n
+		}
+		if flags["demo"] == "true" {
+			buf := make([]byte, 16)
+			if _, err := rand.Read(buf); err != nil {
+				return err
+			}
+			secret = withOddParity(binary.BigEndian.Uint64(buf))
+			plain := binary.BigEndian.Uint64(buf[8:])
+			t.Plain = fmt.Sprintf("%016X", plain)
+			t.Cipher = fmt.Sprintf("%016X", NewDES(secret).encryptBlock(plain))
+			t.Known = fmt.Sprintf("%016X", secret)
+			fmt.Printf("Демонстрация: ключ %016X, P = %s, C = %s, скрыто %d бит\n", secret, t.Plain, t.Cipher, t.Bits)
+		} else if t.Plain == "" || t.Cipher == "" {
+			return fmt.Errorf("нужны -plain и -cipher, либо -demo или -resume")
+		}
+		if _, err := os.Stat(checkpoint); err == nil {
+			return fmt.Errorf("контрольная точка %s уже существует: продолжите с -resume или удалите ее", checkpoint)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	key, ok, err := bruteforce(ctx, os.Stdout, t, workers, checkpoint)
+	if errors.Is(err, context.Canceled) {
+		fmt.Printf("Продолжение: go run . bruteforce -resume -checkpoint %s\n", checkpoint)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("ключ не найден: известная часть ключа или пара текстов неверны")
+	}
+	fmt.Printf("✓ Найден ключ: %016X\n", key)
+	if secret != 0 && key != secret {
+		return fmt.Errorf("найден ключ %016X вместо %016X", key, secret)
+	}
+	return nil
+}
+
+// maxChunkBits размер фрагмента работы одной горутины
+const maxChunkBits = 20
+
+// bruteTask параметры перебора и состояние для продолжения. Хранится в
+// файле контрольной точки в JSON.
+type bruteTask struct {
+	Plain     string `json:"plain"`
+	Cipher    string `json:"cipher"`
+	Known     string `json:"known"` // ключ с известными битами, неизвестные — нули
+	Bits      int    `json:"bits"`
+	ChunkBits int    `json:"chunk_bits"`
+	// Все фрагменты с номером меньше DoneBelow проверены, Done — проверенные
+	// фрагменты с большими номерами
+	DoneBelow uint64   `json:"done_below"`
+	Done      []uint64 `json:"done,omitempty"`
+	Tested    uint64   `json:"tested"`
+	Elapsed   float64  `json:"elapsed_sec"`
+}
+
+// unknownKeyBits позиции младших n значащих битов ключа (без битов четности)
+func unknownKeyBits(n int) []int {
+	var positions []int
+	for p := 0; p < 64 && len(positions) < n; p++ {
+		if p%8 != 0 {
+			positions = append(positions, p)
+		}
+	}
+	return positions
+}
+
+// keyContrib вклад каждого бита ключа в 16 подключей. Расписание ключей
+// линейно: подключи K ⊕ K' равны XOR подключей K и K'.
+var keyContrib = sync.OnceValue(func() (contrib [64][16]uint64) {
+	for p := 0; p < 64; p++ {
+		contrib[p] = NewDES(uint64(1) << uint(p)).subkeys
+	}
+	return contrib
+})
+
+// bruteSearch разбирает фрагменты из chunks, пока они не кончатся или не
+// отменен ctx. Проверенные фрагменты отправляются в done, найденный ключ —
+// в found. tested увеличивается по ходу работы.
+func bruteSearch(ctx context.Context, t *bruteTask, plain, cipher, known uint64, chunks <-chan uint64, done chan<- uint64, found chan<- uint64, tested *atomic.Uint64) {
+	positions := unknownKeyBits(t.Bits)
+	contrib := keyContrib()
+	base := NewDES(known).subkeys
+	chunkSize := uint64(1) << uint(t.ChunkBits)
+
+	for chunk := range chunks {
+		if ctx.Err() != nil {
+			return
+		}
+		// Подключи первого ключа фрагмента
+		first := chunk * chunkSize
+		gray := first ^ first>>1
+		subkeys := base
+		for i, p := range positions {
+			if gray>>uint(i)&1 != 0 {
+				for r := range subkeys {
+					subkeys[r] ^= contrib[p][r]
+				}
+			}
+		}
+
+		reported := first
+		for n := first; ; {
+			if cryptBlock(plain, subkeys[:]) == cipher {
+				key := known
+				g := n ^ n>>1
+				for i, p := range positions {
+					if g>>uint(i)&1 != 0 {
+						key |= uint64(1) << uint(p)
+					}
+				}
+				// После отмены фрагмент не считается проверенным и при
+				// продолжении будет пройден заново
+				select {
+				case found <- withOddParity(key):
+				case <-ctx.Done():
+					return
+				}
+			}
+			n++
+			if n == first+chunkSize {
+				break
+			}
+			// Следующий код Грея отличается битом номер tz(n)
+			flip := contrib[positions[bits.TrailingZeros64(n)]]
+			for r := range subkeys {
+				subkeys[r] ^= flip[r]
+			}
+			if n&0xFFFF == 0 {
+				tested.Add(n - reported)
+				reported = n
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}
+		tested.Add(first + chunkSize - reported)
+		select {
+		case done <- chunk:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// bruteforce выполняет перебор, печатая прогресс в w раз в секунду.
+// checkpoint — путь к файлу контрольной точки: он обновляется во время
+// работы и при отмене ctx, и удаляется после завершения. Возвращает
+// найденный ключ; если перебор прерван, возвращается ошибка context.Canceled.
+func bruteforce(ctx context.Context, w io.Writer, t *bruteTask, workers int, checkpoint string) (uint64, bool, error) {
+	plainBytes, err := hex.DecodeString(t.Plain)
+	if err != nil || len(plainBytes) != blockSize {
+		return 0, false, fmt.Errorf("открытый текст должен быть 16 hex-символами: %q", t.Plain)
+	}
+	cipherBytes, err := hex.DecodeString(t.Cipher)
+	if err != nil || len(cipherBytes) != blockSize {
+		return 0, false, fmt.Errorf("шифртекст должен быть 16 hex-символами: %q", t.Cipher)
+	}
+	knownBytes, err := hex.DecodeString(t.Known)
+	if err != nil || len(knownBytes) != blockSize {
+		return 0, false, fmt.Errorf("известная часть ключа должна быть 16 hex-символами: %q", t.Known)
+	}
+	if t.Bits < 1 || t.Bits > 56 {
+		return 0, false, fmt.Errorf("число неизвестных бит %d вне диапазона 1..56", t.Bits)
+	}
+	t.ChunkBits = min(t.Bits, maxChunkBits)
+
+	plain := binary.BigEndian.Uint64(plainBytes)
+	cipher := binary.BigEndian.Uint64(cipherBytes)
+	known := binary.BigEndian.Uint64(knownBytes)
+	for _, p := range unknownKeyBits(t.Bits) {
+		known &^= uint64(1) << uint(p)
+	}
+
+	total := uint64(1) << uint(t.Bits)
+	numChunks := total >> uint(t.ChunkBits)
+	fmt.Fprintf(w, "Перебор 2^%d ключей, горутин: %d, фрагментов: %d\n", t.Bits, workers, numChunks)
+	if t.DoneBelow > 0 || len(t.Done) > 0 {
+		fmt.Fprintf(w, "Продолжение с контрольной точки: проверено %d ключей\n", t.Tested)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan uint64)
+	doneCh := make(chan uint64, workers)
+	found := make(chan uint64, workers)
+	var tested atomic.Uint64
+	startTested := t.Tested
+
+	// Раздача фрагментов, пропуская уже проверенные
+	skip := make(map[uint64]bool, len(t.Done))
+	for _, c := range t.Done {
+		skip[c] = true
+	}
+	go func() {
+		defer close(chunks)
+		for c := t.DoneBelow; c < numChunks; c++ {
+			if skip[c] {
+				continue
+			}
+			select {
+			case chunks <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bruteSearch(ctx, t, plain, cipher, known, chunks, doneCh, found, &tested)
+		}()
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	start := time.Now()
+	elapsedBefore := t.Elapsed
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// Учет проверенных фрагментов: продвигаем DoneBelow, пока фрагменты
+	// идут подряд
+	markDone := func(c uint64) {
+		skip[c] = true
+		for skip[t.DoneBelow] {
+			delete(skip, t.DoneBelow)
+			t.DoneBelow++
+		}
+		t.Done = t.Done[:0]
+		for k := range skip {
+			if k >= t.DoneBelow {
+				t.Done = append(t.Done, k)
+			}
+		}
+		slices.Sort(t.Done)
+		// Точный счетчик по фрагментам, tested между ними — оценка
+		t.Tested = (t.DoneBelow + uint64(len(t.Done))) << uint(t.ChunkBits)
+	}
+	save := func() error {
+		t.Elapsed = elapsedBefore + time.Since(start).Seconds()
+		if checkpoint == "" {
+			return nil
+		}
+		return writeCheckpoint(checkpoint, t)
+	}
+
+	var key uint64
+	ok := false
+	for running := true; running; {
+		select {
+		case k := <-found:
+			if !ok {
+				key, ok = k, true
+				cancel()
+			}
+		case c := <-doneCh:
+			markDone(c)
+		case <-ticker.C:
+			if ok {
+				continue
+			}
+			progress := startTested + tested.Load()
+			rate := float64(tested.Load()) / time.Since(start).Seconds()
+			fmt.Fprintf(w, "  проверено %d из %d (%.1f%%), %.2f млн ключей/с, осталось ~%s\n",
+				progress, total, 100*float64(progress)/float64(total), rate/1e6,
+				formatSeconds(float64(total-min(progress, total))/rate))
+			if err := save(); err != nil {
+				return 0, false, err
+			}
+		case <-finished:
+			running = false
+		}
+	}
+	// Горутины завершены: учитываем оставшиеся результаты
+	for drained := false; !drained; {
+		select {
+		case c := <-doneCh:
+			markDone(c)
+		case k := <-found:
+			if !ok {
+				key, ok = k, true
+			}
+		default:
+			drained = true
+		}
+	}
+
+	elapsed := time.Since(start).Seconds()
+	rate := float64(tested.Load()) / elapsed
+	fmt.Fprintf(w, "Скорость: %.2f млн ключей/с\n", rate/1e6)
+	if rate > 0 {
+		fmt.Fprintf(w, "Полный перебор 2^56 ключей DES на этой машине: ~%s (2^55 с учетом свойства дополнения)\n",
+			formatSeconds(math.Exp2(56)/rate))
+	}
+
+	if ok {
+		os.Remove(checkpoint)
+		return key, true, nil
+	}
+	if ctx.Err() != nil && t.DoneBelow < numChunks {
+		if err := save(); err != nil {
+			return 0, false, err
+		}
+		fmt.Fprintf(w, "Перебор прерван, состояние сохранено в %s\n", checkpoint)
+		return 0, false, context.Canceled
+	}
+	os.Remove(checkpoint)
+	return 0, false, nil
+}
+
+func writeCheckpoint(path string, t *bruteTask) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Запись через временный файл, чтобы прерывание не оставило половину
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readCheckpoint(path string) (*bruteTask, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var t bruteTask
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if t.Bits < 1 || t.Bits > 56 {
+		return nil, errors.New(path + ": неверное число бит")
+	}
+	return &t, nil
+}
+
+// formatSeconds человекочитаемая длительность
+func formatSeconds(s float64) string {
+	switch {
+	case math.IsInf(s, 0) || math.IsNaN(s):
+		return "неизвестно"
+	case s < 60:
+		return fmt.Sprintf("%.1f с", s)
+	case s < 3600:
+		return fmt.Sprintf("%.1f мин", s/60)
+	case s < 86400:
+		return fmt.Sprintf("%.1f ч", s/3600)
+	case s < 365*86400:
+		return fmt.Sprintf("%.1f дн", s/86400)
+	}
+	return fmt.Sprintf("%.1f лет", s/(365*86400))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// bruteTaskFor задача перебора для случайной пары под ключом key с bits
+// неизвестными битами.
+func bruteTaskFor(key uint64, bits int) *bruteTask {
+	plain := binary.BigEndian.Uint64(randomBytes(8))
+	return &bruteTask{
+		Plain:  fmt.Sprintf("%016X", plain),
+		Cipher: fmt.Sprintf("%016X", NewDES(key).encryptBlock(plain)),
+		Known:  fmt.Sprintf("%016X", key),
+		Bits:   bits,
+	}
+}
+
+func TestUnknownKeyBits(t *testing.T) {
+	positions := unknownKeyBits(56)
+	if len(positions) != 56 {
+		t.Fatalf("%d позиций, ожидается 56", len(positions))
+	}
+	for _, p := range positions {
+		if p%8 == 0 {
+			t.Fatalf("позиция %d — бит четности", p)
+		}
+	}
+	if got := unknownKeyBits(8); got[6] != 7 || got[7] != 9 {
+		t.Fatalf("младшие 8 бит: %v", got)
+	}
+}
+
+// TestBruteforce ищет ключ с 21 неизвестным битом: два фрагмента.
+func TestBruteforce(t *testing.T) {
+	key := withOddParity(binary.BigEndian.Uint64(randomBytes(8)))
+	got, ok, err := bruteforce(context.Background(), io.Discard, bruteTaskFor(key, 21), 2, "")
+	if err != nil || !ok || got != key {
+		t.Fatalf("найден ключ %016X (%v, %v), ожидался %016X", got, ok, err, key)
+	}
+}
+
+// TestBruteforceResume: перебор с отмененным контекстом оставляет
+// контрольную точку, продолжение по ней находит ключ и удаляет ее.
+func TestBruteforceResume(t *testing.T) {
+	key := withOddParity(binary.BigEndian.Uint64(randomBytes(8)))
+	checkpoint := filepath.Join(t.TempDir(), "bruteforce.json")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := bruteforce(ctx, io.Discard, bruteTaskFor(key, 21), 2, checkpoint); !errors.Is(err, context.Canceled) {
+		t.Fatalf("отмененный перебор вернул %v", err)
+	}
+	task, err := readCheckpoint(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := bruteforce(context.Background(), io.Discard, task, 2, checkpoint)
+	if err != nil || !ok || got != key {
+		t.Fatalf("после продолжения найден ключ %016X (%v, %v), ожидался %016X", got, ok, err, key)
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Fatal("контрольная точка не удалена после завершения")
+	}
+}
+
+// TestBruteforceNotFound: если ключ вне перебираемого пространства,
+// перебор завершается без ошибки и без ключа.
+func TestBruteforceNotFound(t *testing.T) {
+	key := withOddParity(binary.BigEndian.Uint64(randomBytes(8)))
+	task := bruteTaskFor(key, 12)
+	// Старший значащий бит ключа не входит в 12 неизвестных
+	task.Known = fmt.Sprintf("%016X", key^0x0200000000000000)
+	if _, ok, err := bruteforce(context.Background(), io.Discard, task, 2, ""); err != nil || ok {
+		t.Fatalf("ожидался ненайденный ключ, получено %v, %v", ok, err)
+	}
+}
+
+func TestBruteforceInvalid(t *testing.T) {
+	valid := func() *bruteTask { return bruteTaskFor(0x133457799BBCDFF1, 8) }
+	bad := map[string]func(*bruteTask){
+		"открытый текст": func(task *bruteTask) { task.Plain = "0123" },
+		"шифртекст":      func(task *bruteTask) { task.Cipher = "zz23456789ABCDEF" },
+		"известный ключ": func(task *bruteTask) { task.Known = "" },
+		"0 бит":          func(task *bruteTask) { task.Bits = 0 },
+		"57 бит":         func(task *bruteTask) { task.Bits = 57 },
+	}
+	for name, change := range bad {
+		task := valid()
+		change(task)
+		if _, _, err := bruteforce(context.Background(), io.Discard, task, 1, ""); err == nil {
+			t.Errorf("%s: неверная задача принята", name)
+		}
+	}
+}
@@ -1,30 +1,57 @@
 package main
 
 import (
+	"bufio"
+	"crypto/cipher"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/binary"
-	"encoding/hex"
 	"fmt"
 	"io"
+	"math/bits"
 	"os"
+	"slices"
 	"strings"
 )
 
 // DES реализация алгоритма DES
+// Подключи для шифрования и расшифровки хранятся отдельно, методы DES не
+// меняют его состояние, и один экземпляр можно использовать из нескольких
+// горутин.
 type DES struct {
-	subkeys [16]uint64
+	subkeys    [16]uint64
+	decSubkeys [16]uint64
+	rounds     int
 }
 
+// fullRounds число раундов стандартного DES
+const fullRounds = 16
+
 // НовыйDES создает новый экземпляр DES с заданным ключом
 func NewDES(key uint64) *DES {
-	des := &DES{}
+	des := &DES{rounds: fullRounds}
 	des.generateSubkeys(key)
 	return des
 }
 
-// Начальная перестановка IP
-func initialPermutation(block uint64) uint64 {
-	ipTable := [64]int{
+// NewDESRounds создает DES с уменьшенным числом раундов (1..16) для
+// криптоанализа. Используются первые rounds подключей стандартного
+// расписания, после последнего раунда половины меняются местами, как в
+// полном DES.
+func NewDESRounds(key uint64, rounds int) (*DES, error) {
+	if rounds < 1 || rounds > fullRounds {
+		return nil, fmt.Errorf("число раундов %d вне диапазона 1..%d", rounds, fullRounds)
+	}
+	des := &DES{rounds: rounds}
+	des.generateSubkeys(key)
+	return des, nil
+}
+
+// Таблицы стандарта FIPS 46-3. Позиции битов нумеруются с 1, начиная со
+// старшего.
+var (
+	// Начальная перестановка IP
+	ipTable = [64]int{
 		58, 50, 42, 34, 26, 18, 10, 2,
 		60, 52, 44, 36, 28, 20, 12, 4,
 		62, 54, 46, 38, 30, 22, 14, 6,
@@ -34,12 +61,9 @@ func initialPermutation(block uint64) uint64 {
 		61, 53, 45, 37, 29, 21, 13, 5,
 		63, 55, 47, 39, 31, 23, 15, 7,
 	}
-	return permute(block, ipTable[:], 64)
-}
 
-// Конечная перестановка IP^-1
-func finalPermutation(block uint64) uint64 {
-	fpTable := [64]int{
+	// Конечная перестановка IP^-1
+	fpTable = [64]int{
 		40, 8, 48, 16, 56, 24, 64, 32,
 		39, 7, 47, 15, 55, 23, 63, 31,
 		38, 6, 46, 14, 54, 22, 62, 30,
@@ -49,12 +73,9 @@ func finalPermutation(block uint64) uint64 {
 		34, 2, 42, 10, 50, 18, 58, 26,
 		33, 1, 41, 9, 49, 17, 57, 25,
 	}
-	return permute(block, fpTable[:], 64)
-}
 
-// Расширяющая перестановка E
-func expansionPermutation(right uint32) uint64 {
-	eTable := [48]int{
+	// Расширяющая перестановка E
+	eTable = [48]int{
 		32, 1, 2, 3, 4, 5,
 		4, 5, 6, 7, 8, 9,
 		8, 9, 10, 11, 12, 13,
@@ -64,23 +85,17 @@ func expansionPermutation(right uint32) uint64 {
 		24, 25, 26, 27, 28, 29,
 		28, 29, 30, 31, 32, 1,
 	}
-	return uint64(permute32(uint64(right), eTable[:], 32))
-}
 
-// Перестановка P
-func pPermutation(data uint32) uint32 {
-	pTable := [32]int{
+	// Перестановка P
+	pTable = [32]int{
 		16, 7, 20, 21, 29, 12, 28, 17,
 		1, 15, 23, 26, 5, 18, 31, 10,
 		2, 8, 24, 14, 32, 27, 3, 9,
 		19, 13, 30, 6, 22, 11, 4, 25,
 	}
-	return uint32(permute32(uint64(data), pTable[:], 32))
-}
 
-// S-блоки (подстановка)
-func sBoxSubstitution(data uint64) uint32 {
-	sBoxes := [8][4][16]uint8{
+	// S-блоки
+	sBoxes = [8][4][16]uint8{
 		// S1
 		{
 			{14, 4, 13, 1, 2, 15, 11, 8, 3, 10, 6, 12, 5, 9, 0, 7},
@@ -139,6 +154,53 @@ func sBoxSubstitution(data uint64) uint32 {
 		},
 	}
 
+	// PC-1 перестановка (удаление битов четности)
+	pc1Table = [56]int{
+		57, 49, 41, 33, 25, 17, 9, 1,
+		58, 50, 42, 34, 26, 18, 10, 2,
+		59, 51, 43, 35, 27, 19, 11, 3,
+		60, 52, 44, 36, 63, 55, 47, 39,
+		31, 23, 15, 7, 62, 54, 46, 38,
+		30, 22, 14, 6, 61, 53, 45, 37,
+		29, 21, 13, 5, 28, 20, 12, 4,
+	}
+
+	// PC-2 перестановка
+	pc2Table = [48]int{
+		14, 17, 11, 24, 1, 5, 3, 28,
+		15, 6, 21, 10, 23, 19, 12, 4,
+		26, 8, 16, 7, 27, 20, 13, 2,
+		41, 52, 31, 37, 47, 55, 30, 40,
+		51, 45, 33, 48, 44, 49, 39, 56,
+		34, 53, 46, 42, 50, 36, 29, 32,
+	}
+
+	// Сдвиги для каждого раунда
+	shifts = [16]int{1, 1, 2, 2, 2, 2, 2, 2, 1, 2, 2, 2, 2, 2, 2, 1}
+)
+
+// Начальная перестановка IP
+func initialPermutation(block uint64) uint64 {
+	return permute(block, ipTable[:], 64)
+}
+
+// Конечная перестановка IP^-1
+func finalPermutation(block uint64) uint64 {
+	return permute(block, fpTable[:], 64)
+}
+
+// Расширяющая перестановка E
+func expansionPermutation(right uint32) uint64 {
+	return uint64(permute32(uint64(right), eTable[:], 32))
+}
+
+// Перестановка P
+func pPermutation(data uint32) uint32 {
+	return uint32(permute32(uint64(data), pTable[:], 32))
+}
+
+// S-блоки (подстановка)
+func sBoxSubstitution(data uint64) uint32 {
 	var result uint32
 	for i := 0; i < 8; i++ {
 		// Берем 6 бит для текущего S-блока
@@ -175,30 +237,6 @@ func feistelFunction(right uint32, subkey uint64) uint32 {
 
 // Генерация подключей
 func (des *DES) generateSubkeys(key uint64) {
-	// PC-1 перестановка (удаление битов четности)
-	pc1Table := [56]int{
-		57, 49, 41, 33, 25, 17, 9, 1,
-		58, 50, 42, 34, 26, 18, 10, 2,
-		59, 51, 43, 35, 27, 19, 11, 3,
-		60, 52, 44, 36, 63, 55, 47, 39,
-		31, 23, 15, 7, 62, 54, 46, 38,
-		30, 22, 14, 6, 61, 53, 45, 37,
-		29, 21, 13, 5, 28, 20, 12, 4,
-	}
-
-	// PC-2 перестановка
-	pc2Table := [48]int{
-		14, 17, 11, 24, 1, 5, 3, 28,
-		15, 6, 21, 10, 23, 19, 12, 4,
-		26, 8, 16, 7, 27, 20, 13, 2,
-		41, 52, 31, 37, 47, 55, 30, 40,
-		51, 45, 33, 48, 44, 49, 39, 56,
-		34, 53, 46, 42, 50, 36, 29, 32,
-	}
-
-	// Сдвиги для каждого раунда
-	shifts := [16]int{1, 1, 2, 2, 2, 2, 2, 2, 1, 2, 2, 2, 2, 2, 2, 1}
-
 	// Начальная перестановка PC-1
 	permutedKey := permute(key, pc1Table[:], 64)
 
@@ -216,10 +254,26 @@ func (des *DES) generateSubkeys(key uint64) {
 		combined := (c << 28) | d
 		des.subkeys[i] = permute(combined, pc2Table[:], 56)
 	}
+
+	// Для расшифровки подключи используемых раундов в обратном порядке
+	for i := 0; i < des.rounds; i++ {
+		des.decSubkeys[i] = des.subkeys[des.rounds-1-i]
+	}
 }
 
-// Шифрование одного блока
+// Шифрование одного блока (быстрая реализация, см. fast.go)
 func (d *DES) encryptBlock(block uint64) uint64 {
+	return cryptBlock(block, d.subkeys[:d.rounds])
+}
+
+// Дешифрование одного блока: те же раунды с подключами в обратном порядке
+func (d *DES) decryptBlock(block uint64) uint64 {
+	return cryptBlock(block, d.decSubkeys[:d.rounds])
+}
+
+// referenceBlock побитовая реализация по определению стандарта. Медленная,
+// используется для сверки и сравнения скорости с cryptBlock.
+func referenceBlock(block uint64, subkeys []uint64) uint64 {
 	// Начальная перестановка
 	block = initialPermutation(block)
 
@@ -227,10 +281,10 @@ func (d *DES) encryptBlock(block uint64) uint64 {
 	left := uint32(block >> 32)
 	right := uint32(block & 0xFFFFFFFF)
 
-	// 16 раундов Фейстеля
-	for i := 0; i < 16; i++ {
+	// Раунды Фейстеля
+	for i := range subkeys {
 		nextLeft := right
-		fResult := feistelFunction(right, d.subkeys[i])
+		fResult := feistelFunction(right, subkeys[i])
 		nextRight := left ^ fResult
 
 		left = nextLeft
@@ -244,20 +298,29 @@ func (d *DES) encryptBlock(block uint64) uint64 {
 	return finalPermutation(combined)
 }
 
-// Дешифрование одного блока
-func (d *DES) decryptBlock(block uint64) uint64 {
-	// Используем подключи в обратном порядке
-	temp := d.subkeys
-	for i := 0; i < 8; i++ {
-		d.subkeys[i], d.subkeys[15-i] = d.subkeys[15-i], d.subkeys[i]
-	}
+// blockSize размер блока DES в байтах
+const blockSize = 8
 
-	result := d.encryptBlock(block)
+// BlockSize, Encrypt и Decrypt реализуют cipher.Block, что позволяет
+// использовать DES со стандартными режимами из crypto/cipher.
+func (d *DES) BlockSize() int {
+	return blockSize
+}
 
-	// Восстанавливаем порядок подключей
-	d.subkeys = temp
+// Encrypt шифрует первый блок src в dst
+func (d *DES) Encrypt(dst, src []byte) {
+	if len(src) < blockSize || len(dst) < blockSize {
+		panic("des: неполный блок")
+	}
+	binary.BigEndian.PutUint64(dst, d.encryptBlock(binary.BigEndian.Uint64(src)))
+}
 
-	return result
+// Decrypt расшифровывает первый блок src в dst
+func (d *DES) Decrypt(dst, src []byte) {
+	if len(src) < blockSize || len(dst) < blockSize {
+		panic("des: неполный блок")
+	}
+	binary.BigEndian.PutUint64(dst, d.decryptBlock(binary.BigEndian.Uint64(src)))
 }
 
 // Вспомогательная функция для перестановки
@@ -280,8 +343,27 @@ func permute32(data uint64, table []int, inputSize int) uint64 {
 	return result
 }
 
-// Шифрование файла
-func (d *DES) encryptFile(inputPath, outputPath string) error {
+// Формат зашифрованного файла:
+//
+//	magic [4]byte       "DESK"
+//	mode  uint8         Mode
+//	kcv   [3]byte       контрольное значение ключа (см. keyCheckValue)
+//	iv    [8]byte       вектор инициализации, отсутствует для ECB
+//
+// Далее идет шифртекст. В режимах ECB и CBC открытый текст дополняется по
+// PKCS#7, потоковые режимы дополнения не используют. Файлы с заголовком
+// "DESM" (без kcv) по-прежнему расшифровываются, но неверный ключ в них
+// не обнаруживается. Файлы без заголовка (старый формат) считаются
+// зашифрованными в режиме ECB с дополнением нулями, которое при
+// расшифровке не снимается.
+const (
+	fileMagic      = "DESK"
+	fileMagicNoKCV = "DESM"
+)
+
+// Шифрование файла блочным шифром b (DES или TripleDES) в режиме mode со
+// случайным IV
+func encryptFile(b cipher.Block, inputPath, outputPath string, mode Mode) error {
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
 		return err
@@ -294,207 +376,287 @@ func (d *DES) encryptFile(inputPath, outputPath string) error {
 	}
 	defer outputFile.Close()
 
-	// Буфер для чтения (8 байт = 64 бита)
-	buffer := make([]byte, 8)
-
-	for {
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
-			return err
-		}
-
-		if n == 0 {
-			break
-		}
-
-		// Дополнение последнего блока если нужно
-		if n < 8 {
-			for i := n; i < 8; i++ {
-				buffer[i] = 0 // Дополнение нулями
-			}
-		}
-
-		// Конвертация байт в uint64
-		block := binary.BigEndian.Uint64(buffer)
-
-		// Шифрование блока
-		encryptedBlock := d.encryptBlock(block)
-
-		// Запись зашифрованного блока
-		encryptedBytes := make([]byte, 8)
-		binary.BigEndian.PutUint64(encryptedBytes, encryptedBlock)
-
-		_, err = outputFile.Write(encryptedBytes)
-		if err != nil {
-			return err
-		}
-
-		if err == io.EOF {
-			break
+	var iv []byte
+	if mode.hasIV() {
+		iv = make([]byte, b.BlockSize())
+		if _, err := rand.Read(iv); err != nil {
+			return fmt.Errorf("ошибка генерации IV: %v", err)
 		}
 	}
+	crypt, err := newModeCrypter(b, mode, iv, true)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	writer := bufio.NewWriter(outputFile)
+	header := append([]byte(fileMagic), byte(mode))
+	header = append(header, keyCheckValue(b)...)
+	if _, err := writer.Write(append(header, iv...)); err != nil {
+		return err
+	}
+	if err := cryptStream(inputFile, writer, crypt, mode, !mode.isStream(), true); err != nil {
+		return err
+	}
+	return writer.Flush()
 }
 
-// Дешифрование файла
-func (d *DES) decryptFile(inputPath, outputPath string) error {
+// Дешифрование файла. Режим и IV берутся из заголовка.
+func decryptFile(b cipher.Block, inputPath, outputPath string) error {
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
 		return err
 	}
 	defer inputFile.Close()
 
+	reader := bufio.NewReader(inputFile)
+	mode := ModeECB
+	var iv []byte
+	magic, err := reader.Peek(len(fileMagic))
+	legacy := err != nil || string(magic) != fileMagic && string(magic) != fileMagicNoKCV
+	if !legacy {
+		header := make([]byte, len(fileMagic)+1)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return fmt.Errorf("ошибка чтения заголовка: %v", err)
+		}
+		mode = Mode(header[len(fileMagic)])
+		if _, ok := modeNames[mode]; !ok {
+			return fmt.Errorf("неизвестный режим в заголовке: %d", header[len(fileMagic)])
+		}
+		if string(header[:len(fileMagic)]) == fileMagic {
+			kcv := make([]byte, kcvSize)
+			if _, err := io.ReadFull(reader, kcv); err != nil {
+				return fmt.Errorf("ошибка чтения KCV: %v", err)
+			}
+			if subtle.ConstantTimeCompare(kcv, keyCheckValue(b)) != 1 {
+				return errWrongKey
+			}
+		}
+		if mode.hasIV() {
+			iv = make([]byte, b.BlockSize())
+			if _, err := io.ReadFull(reader, iv); err != nil {
+				return fmt.Errorf("ошибка чтения IV: %v", err)
+			}
+		}
+	}
+	crypt, err := newModeCrypter(b, mode, iv, false)
+	if err != nil {
+		return err
+	}
+
+	// Выходной файл создается после проверки заголовка, чтобы неверный
+	// ключ не затирал его
 	outputFile, err := os.Create(outputPath)
 	if err != nil {
 		return err
 	}
 	defer outputFile.Close()
 
-	// Буфер для чтения (8 байт = 64 бита)
-	buffer := make([]byte, 8)
+	writer := bufio.NewWriter(outputFile)
+	if err := cryptStream(reader, writer, crypt, mode, !legacy && !mode.isStream(), false); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
 
+// cryptStream обрабатывает поток фрагментами функцией crypt. Если padded,
+// при шифровании к открытому тексту добавляется дополнение PKCS#7, а при
+// расшифровке оно проверяется и снимается. Длина шифртекста блочного
+// режима должна быть кратна блоку и без дополнения (старый формат).
+func cryptStream(input io.Reader, output io.Writer, crypt func(dst, src []byte), mode Mode, padded, encrypt bool) error {
+	buffer := make([]byte, 4096)
+	// Последний расшифрованный фрагмент: дополнение снимается, только когда
+	// известно, что дальше данных нет
+	var pending []byte
 	for {
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
+		n, err := io.ReadFull(input, buffer)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 			return err
 		}
+		last := err != nil
+		chunk := buffer[:n]
 
-		if n == 0 {
-			break
+		if padded && encrypt && last {
+			chunk = pkcs7Pad(chunk, blockSize)
 		}
-
-		// Конвертация байт в uint64
-		block := binary.BigEndian.Uint64(buffer)
-
-		// Дешифрование блока
-		decryptedBlock := d.decryptBlock(block)
-
-		// Запись расшифрованного блока
-		decryptedBytes := make([]byte, 8)
-		binary.BigEndian.PutUint64(decryptedBytes, decryptedBlock)
-
-		// Для последнего блока убираем дополнение
-		if err == io.EOF || n < 8 {
-			decryptedBytes = decryptedBytes[:n]
+		if !encrypt && !mode.isStream() && n%blockSize != 0 {
+			return fmt.Errorf("длина шифртекста не кратна %d байтам", blockSize)
 		}
 
-		_, err = outputFile.Write(decryptedBytes)
-		if err != nil {
+		crypt(chunk, chunk)
+		if padded && !encrypt {
+			// Пустое последнее чтение (шифртекст кратен буферу): дополнение
+			// осталось в pending и выводить его нельзя
+			if n == 0 {
+				break
+			}
+			if _, err := output.Write(pending); err != nil {
+				return err
+			}
+			pending = append(pending[:0], chunk...)
+		} else if _, err := output.Write(chunk); err != nil {
 			return err
 		}
 
-		if err == io.EOF {
+		if last {
 			break
 		}
 	}
 
+	if padded && !encrypt {
+		plain, err := pkcs7Unpad(pending, blockSize)
+		if err != nil {
+			return err
+		}
+		_, err = output.Write(plain)
+		return err
+	}
 	return nil
 }
 
-func readKeyFromFile(keyFilePath string) (uint64, error) {
-	// Чтение файла с ключом
-	keyData, err := os.ReadFile(keyFilePath)
-	if err != nil {
-		return 0, fmt.Errorf("ошибка чтения файла ключа: %v", err)
-	}
-
-	// Очистка ключа от пробелов и переводов строк
-	keyStr := strings.TrimSpace(string(keyData))
-	keyStr = strings.ReplaceAll(keyStr, " ", "")
-	keyStr = strings.ReplaceAll(keyStr, "\n", "")
-	keyStr = strings.ReplaceAll(keyStr, "\r", "")
-	keyStr = strings.ReplaceAll(keyStr, "0x", "")
-	keyStr = strings.ReplaceAll(keyStr, "0X", "")
-
-	// Проверка длины ключа
-	if len(keyStr) != 16 {
-		return 0, fmt.Errorf("неправильная длина ключа: ожидается 16 hex-символов (64 бита), получено %d", len(keyStr))
-	}
-
-	// Парсинг hex-строки
-	keyBytes, err := hex.DecodeString(keyStr)
-	if err != nil {
-		return 0, fmt.Errorf("ошибка парсинга hex-ключа: %v", err)
-	}
-
-	// Конвертация в uint64 (BigEndian)
-	if len(keyBytes) != 8 {
-		return 0, fmt.Errorf("неправильный размер ключа: ожидается 8 байт, получено %d", len(keyBytes))
+// withOddParity устанавливает младший бит каждого байта ключа так, чтобы
+// число единиц в байте было нечетным (биты четности DES).
+func withOddParity(key uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		b := byte(key >> (8 * i))
+		if bits.OnesCount8(b&0xFE)%2 == 0 {
+			b |= 1
+		} else {
+			b &^= 1
+		}
+		key = key&^(0xFF<<(8*i)) | uint64(b)<<(8*i)
 	}
-
-	return binary.BigEndian.Uint64(keyBytes), nil
+	return key
 }
 
-func generateAndSaveKey(keyFilePath string) (uint64, error) {
-	// Генерация случайного ключа
-	keyBytes := make([]byte, 8)
-	_, err := rand.Read(keyBytes)
-	if err != nil {
-		return 0, fmt.Errorf("ошибка генерации ключа: %v", err)
-	}
-
-	key := binary.BigEndian.Uint64(keyBytes)
+func printUsage() {
+	fmt.Println("Использование:")
+	fmt.Println("  Шифрование: go run . encrypt <файл_ключа> <входной_файл> <выходной_файл> [-mode ecb|cbc|cfb|ofb|ctr] [-passphrase фраза]")
+	fmt.Println("  Дешифрование: go run . decrypt <файл_ключа> <входной_файл> <выходной_файл> [-passphrase фраза]")
+	fmt.Println("  Генерация ключа: go run . genkey <файл_ключа> [--3des [-keylen 16|24]] [-encoding hex|base64 | -passphrase фраза]")
+	fmt.Println("  Анализ: go run . analyze weak|complement|differential [-rounds 3..6] [-pairs N]")
+	fmt.Println("          go run . analyze feistel [-trials N] [-rounds N]")
+	fmt.Println("          go run . analyze avalanche [-samples N] [-csv префикс] [-json файл]")
+	fmt.Println("  Перебор ключа: go run . bruteforce -plain HEX -cipher HEX [-known HEX] [-bits N] [-workers N] [-checkpoint файл]")
+	fmt.Println("                 go run . bruteforce -demo [-bits N] | -resume [-checkpoint файл]")
+	fmt.Println("")
+	fmt.Println("Режим по умолчанию — cbc. Режим и IV записываются в заголовок файла.")
+	fmt.Println("Файл ключа хранит алгоритм, версию, ключ и KCV; с -passphrase ключ выводится из")
+	fmt.Println("парольной фразы (PBKDF2), которая не сохраняется и указывается при шифровании.")
+	fmt.Println("С --3des генерируется ключ тройного DES (EDE3, 24 байта; EDE2 — -keylen 16).")
+}
 
-	// Сохранение ключа в файл в hex-формате
-	keyHex := hex.EncodeToString(keyBytes)
-	err = os.WriteFile(keyFilePath, []byte(keyHex), 0600) // права только для владельца
-	if err != nil {
-		return 0, fmt.Errorf("ошибка сохранения ключа: %v", err)
+// splitFlags отделяет флаги вида "-name value" от позиционных аргументов.
+// Флаги из boolFlags значения не принимают, для них записывается "true".
+func splitFlags(args []string, boolFlags ...string) (positional []string, flags map[string]string, err error) {
+	flags = make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			positional = append(positional, args[i])
+			continue
+		}
+		if name := strings.TrimLeft(args[i], "-"); slices.Contains(boolFlags, name) {
+			flags[name] = "true"
+			continue
+		}
+		if i+1 == len(args) {
+			return nil, nil, fmt.Errorf("флаг %s требует значения", args[i])
+		}
+		flags[strings.TrimLeft(args[i], "-")] = args[i+1]
+		i++
 	}
-
-	return key, nil
+	return positional, flags, nil
 }
 
 func main() {
-	// fmt.Printf("len(os.Args) = %d \n", len(os.Args))
-	if len(os.Args) < 3 {
-		fmt.Println("Использование:")
-		fmt.Println("  Шифрование: go run des.go encrypt <файл_ключа> <входной_файл> <выходной_файл>")
-		fmt.Println("  Дешифрование: go run des.go decrypt <файл_ключа> <входной_файл> <выходной_файл>")
-		fmt.Println("  Генерация ключа: go run des.go genkey <файл_ключа>")
-		fmt.Println("")
+	if len(os.Args) < 2 {
+		printUsage()
 		return
 	}
 
-	mode := os.Args[1]
+	command := os.Args[1]
+	args, flags, err := splitFlags(os.Args[2:], "3des", "demo", "resume")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-	switch mode {
+	switch command {
 	case "genkey":
-		if len(os.Args) < 3 {
-			fmt.Println("Использование: go run des.go genkey <файл_ключа>")
+		if len(args) < 1 {
+			fmt.Println("Использование: go run . genkey <файл_ключа>")
 			return
 		}
-		keyFilePath := os.Args[2]
+		keyFilePath := args[0]
+
+		variant := variantDES
+		if flags["3des"] == "true" {
+			switch flags["keylen"] {
+			case "", "24":
+				variant = variant3DESEDE3
+			case "16":
+				variant = variant3DESEDE2
+			default:
+				fmt.Printf("Неправильная длина ключа 3DES: %s, ожидается 16 или 24\n", flags["keylen"])
+				return
+			}
+		}
 
-		key, err := generateAndSaveKey(keyFilePath)
+		encoding := encodingHex
+		if v, ok := flags["encoding"]; ok {
+			encoding = v
+		}
+		if _, ok := flags["passphrase"]; ok {
+			encoding = encodingPassphrase
+		}
+
+		key, err := generateAndSaveKey(keyFilePath, variant, encoding, flags["passphrase"])
+		if err != nil {
+			fmt.Printf("Ошибка генерации ключа: %v\n", err)
+			return
+		}
+		block, err := newBlockCipher(variant, key)
 		if err != nil {
 			fmt.Printf("Ошибка генерации ключа: %v\n", err)
 			return
 		}
-		fmt.Printf("Ключ успешно сгенерирован и сохранен в: %s\n", keyFilePath)
-		fmt.Printf("Hex-представление: %016X\n", key)
+		fmt.Printf("Ключ %s успешно сгенерирован и сохранен в: %s\n", variant, keyFilePath)
+		fmt.Printf("Hex-представление: %X, KCV: %X\n", key, keyCheckValue(block))
 
 	case "encrypt", "decrypt":
-		if len(os.Args) < 5 {
-			fmt.Printf("Использование: go run des.go %s <файл_ключа> <входной_файл> <выходной_файл>\n", mode)
+		if len(args) < 3 {
+			fmt.Printf("Использование: go run . %s <файл_ключа> <входной_файл> <выходной_файл>\n", command)
 			return
 		}
 
-		keyFilePath := os.Args[2]
-		inputFile := os.Args[3]
-		outputFile := os.Args[4]
+		keyFilePath := args[0]
+		inputFile := args[1]
+		outputFile := args[2]
+
+		cipherMode := ModeCBC
+		if name, ok := flags["mode"]; ok {
+			if cipherMode, err = parseMode(name); err != nil {
+				fmt.Println(err)
+				return
+			}
+		}
 
 		// Чтение ключа из файла
-		key, err := readKeyFromFile(keyFilePath)
+		variant, key, err := readKeyFromFile(keyFilePath, flags["passphrase"])
 		if err != nil {
 			fmt.Printf("Ошибка чтения ключа: %v\n", err)
 			return
 		}
 
-		fmt.Printf("Загружен ключ: %016X\n", key)
+		fmt.Printf("Загружен ключ %s: %X\n", variant, key)
+		for _, w := range weakKeyWarnings(variant, key) {
+			fmt.Printf("Предупреждение: %s\n", w)
+		}
 
-		des := NewDES(key)
+		block, err := newBlockCipher(variant, key)
+		if err != nil {
+			fmt.Printf("Ошибка чтения ключа: %v\n", err)
+			return
+		}
 
 		// Проверка существования входного файла
 		if _, err := os.Stat(inputFile); os.IsNotExist(err) {
@@ -502,17 +664,17 @@ func main() {
 			return
 		}
 
-		switch mode {
+		switch command {
 		case "encrypt":
-			err = des.encryptFile(inputFile, outputFile)
+			err = encryptFile(block, inputFile, outputFile, cipherMode)
 			if err != nil {
 				fmt.Printf("Ошибка шифрования: %v\n", err)
 				return
 			}
-			fmt.Printf("Файл успешно зашифрован: %s -> %s\n", inputFile, outputFile)
+			fmt.Printf("Файл успешно зашифрован (%s): %s -> %s\n", strings.ToUpper(cipherMode.String()), inputFile, outputFile)
 
 		case "decrypt":
-			err = des.decryptFile(inputFile, outputFile)
+			err = decryptFile(block, inputFile, outputFile)
 			if err != nil {
 				fmt.Printf("Ошибка дешифрования: %v\n", err)
 				return
@@ -520,11 +682,20 @@ func main() {
 			fmt.Printf("Файл успешно расшифрован: %s -> %s\n", inputFile, outputFile)
 		}
 
+	case "analyze":
+		if err := analyzeCommand(args, flags); err != nil {
+			fmt.Printf("Ошибка анализа: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "bruteforce":
+		if err := bruteforceCommand(flags); err != nil {
+			fmt.Printf("Ошибка перебора: %v\n", err)
+			os.Exit(1)
+		}
+
 	default:
 		fmt.Println("Неизвестный режим. Используйте 'encrypt', 'decrypt' или 'genkey'")
-		fmt.Println("Использование:")
-		fmt.Println("  Шифрование: go run des.go encrypt <файл_ключа> <входной_файл> <выходной_файл>")
-		fmt.Println("  Дешифрование: go run des.go decrypt <файл_ключа> <входной_файл> <выходной_файл>")
-		fmt.Println("  Генерация ключа: go run des.go genkey <файл_ключа>")
+		printUsage()
 	}
 }
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"crypto/des"
+	"encoding/binary"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Известные ответы (KAT) из NIST SP 800-17, приложение B. Ключи
+// содержат биты четности, алгоритм их игнорирует.
+
+// katVarPlaintext шифртексты для ключа 0101010101010101 и открытого текста
+// с единственным установленным битом i (i = 0 — старший бит).
+// Проверяет IP, FP и прохождение каждого бита через раунды.
+var katVarPlaintext = [64]uint64{
+	0x95F8A5E5DD31D900, 0xDD7F121CA5015619, 0x2E8653104F3834EA, 0x4BD388FF6CD81D4F,
+	0x20B9E767B2FB1456, 0x55579380D77138EF, 0x6CC5DEFAAF04512F, 0x0D9F279BA5D87260,
+	0xD9031B0271BD5A0A, 0x424250B37C3DD951, 0xB8061B7ECD9A21E5, 0xF15D0F286B65BD28,
+	0xADD0CC8D6E5DEBA1, 0xE6D5F82752AD63D1, 0xECBFE3BD3F591A5E, 0xF356834379D165CD,
+	0x2B9F982F20037FA9, 0x889DE068A16F0BE6, 0xE19E275D846A1298, 0x329A8ED523D71AEC,
+	0xE7FCE22557D23C97, 0x12A9F5817FF2D65D, 0xA484C3AD38DC9C19, 0xFBE00A8A1EF8AD72,
+	0x750D079407521363, 0x64FEED9C724C2FAF, 0xF02B263B328E2B60, 0x9D64555A9A10B852,
+	0xD106FF0BED5255D7, 0xE1652C6B138C64A5, 0xE428581186EC8F46, 0xAEB5F5EDE22D1A36,
+	0xE943D7568AEC0C5C, 0xDF98C8276F54B04B, 0xB160E4680F6C696F, 0xFA0752B07D9C4AB8,
+	0xCA3A2B036DBC8502, 0x5E0905517BB59BCF, 0x814EEB3B91D90726, 0x4D49DB1532919C9F,
+	0x25EB5FC3F8CF0621, 0xAB6A20C0620D1C6F, 0x79E90DBC98F92CCA, 0x866ECEDD8072BB0E,
+	0x8B54536F2F3E64A8, 0xEA51D3975595B86B, 0xCAFFC6AC4542DE31, 0x8DD45A2DDF90796C,
+	0x1029D55E880EC2D0, 0x5D86CB23639DBEA9, 0x1D1CA853AE7C0C5F, 0xCE332329248F3228,
+	0x8405D1ABE24FB942, 0xE643D78090CA4207, 0x48221B9937748A23, 0xDD7C0BBD61FAFD54,
+	0x2FBC291A570DB5C4, 0xE07C30D7E4E26E12, 0x0953E2258E8E90A1, 0x5B711BC4CEEBF2EE,
+	0xCC083F1E6D9E85F6, 0xD2FD8867D50D2DFE, 0x06E7EA22CE92708F, 0x166B40B44ABA4BD6,
+}
+
+// katVarKey шифртексты нулевого блока для ключей, в которых установлен
+// единственный значащий бит (биты четности не перебираются). Проверяет
+// PC-1, PC-2 и сдвиги расписания ключей.
+var katVarKey = [56]uint64{
+	0x95A8D72813DAA94D, 0x0EEC1487DD8C26D5, 0x7AD16FFB79C45926, 0xD3746294CA6A6CF3,
+	0x809F5F873C1FD761, 0xC02FAFFEC989D1FC, 0x4615AA1D33E72F10, 0x2055123350C00858,
+	0xDF3B99D6577397C8, 0x31FE17369B5288C9, 0xDFDD3CC64DAE1642, 0x178C83CE2B399D94,
+	0x50F636324A9B7F80, 0xA8468EE3BC18F06D, 0xA2DC9E92FD3CDE92, 0xCAC09F797D031287,
+	0x90BA680B22AEB525, 0xCE7A24F350E280B6, 0x882BFF0AA01A0B87, 0x25610288924511C2,
+	0xC71516C29C75D170, 0x5199C29A52C9F059, 0xC22F0A294A71F29F, 0xEE371483714C02EA,
+	0xA81FBD448F9E522F, 0x4F644C92E192DFED, 0x1AFA9A66A6DF92AE, 0xB3C1CC715CB879D8,
+	0x19D032E64AB0BD8B, 0x3CFAA7A7DC8720DC, 0xB7265F7F447AC6F3, 0x9DB73B3C0D163F54,
+	0x8181B65BABF4A975, 0x93C9B64042EAA240, 0x5570530829705592, 0x8638809E878787A0,
+	0x41B9A79AF79AC208, 0x7A9BE42F2009A892, 0x29038D56BA6D2745, 0x5495C6ABF1E5DF51,
+	0xAE13DBD561488933, 0x024D1FFA8904E389, 0xD1399712F99BF02E, 0x14C1D7C1CFFEC79E,
+	0x1DE5279DAE3BED6F, 0xE941A33F85501303, 0xDA99DBBC9A03F379, 0xB7FC92F91D8E92E9,
+	0xAE8E5CAA3CA04E85, 0x9CC62DF43B6EED74, 0xD863DBB5C59A91A0, 0xA1AB2190545B91D7,
+	0x0875041E64C570F7, 0x5A594528BEBEF1CC, 0xFCDB3291DE21F0C0, 0x869EFD7F9F265A09,
+}
+
+// katVector ключ, открытый текст и ожидаемый шифртекст
+type katVector struct {
+	key, plain, cipher uint64
+}
+
+// katPermutation нулевой блок под ключами, подобранными так, что через
+// S-блоки проходят биты каждой позиции перестановки P.
+var katPermutation = []katVector{
+	{0x1046913489980131, 0, 0x88D55E54F54C97B4},
+	{0x1007103489988020, 0, 0x0C0CC00C83EA48FD},
+	{0x10071034C8980120, 0, 0x83BC8EF3A6570183},
+	{0x1046103489988020, 0, 0xDF725DCAD94EA2E9},
+	{0x1086911519190101, 0, 0xE652B53B550BE8B0},
+	{0x1086911519580101, 0, 0xAF527120C485CBB0},
+	{0x5107B01519580101, 0, 0x0F04CE393DB926D5},
+	{0x1007B01519190101, 0, 0xC9F00FFC74079067},
+	{0x3107915498080101, 0, 0x7CFD82A593252B4E},
+	{0x3107919498080101, 0, 0xCB49A2F9E91363E3},
+	{0x10079115B9080140, 0, 0x00B588BE70D23F56},
+	{0x3107911598080140, 0, 0x406A9A6AB43399AE},
+	{0x1007D01589980101, 0, 0x6CB773611DCA9ADA},
+	{0x9107911589980101, 0, 0x67FD21C17DBB5D70},
+	{0x9107D01589190101, 0, 0x9592CB4110430787},
+	{0x1007D01598980120, 0, 0xA6B7FF68A318DDD3},
+	{0x1007940498190101, 0, 0x4D102196C914CA16},
+	{0x0107910491190401, 0, 0x2DFA9F4573594965},
+	{0x0107910491190101, 0, 0xB46604816C0E0774},
+	{0x0107940491190401, 0, 0x6E7E6221A4F34E87},
+	{0x19079210981A0101, 0, 0xAA85E74643233199},
+	{0x1007911998190801, 0, 0x2E5A19DB4D1962D6},
+	{0x10079119981A0801, 0, 0x23A866A809D30894},
+	{0x1007921098190101, 0, 0xD812D961F017D320},
+	{0x100791159819010B, 0, 0x055605816E58608F},
+	{0x1004801598190101, 0, 0xABD88E8B1B7716F1},
+	{0x1004801598190102, 0, 0x537AC95BE69DA1E1},
+	{0x1004801598190108, 0, 0xAED0F6AE3C25CDD8},
+	{0x1002911598100104, 0, 0xB3E35A5EE53E7B8D},
+	{0x1002911598190104, 0, 0x61C79C71921A2EF8},
+	{0x1002911598100201, 0, 0xE2F5728F0995013C},
+	{0x1002911698100101, 0, 0x1AEAC39A61F0A464},
+}
+
+// katSBox пары ключ/текст, задающие все 64 входа каждого S-блока.
+var katSBox = []katVector{
+	{0x7CA110454A1A6E57, 0x01A1D6D039776742, 0x690F5B0D9A26939B},
+	{0x0131D9619DC1376E, 0x5CD54CA83DEF57DA, 0x7A389D10354BD271},
+	{0x07A1133E4A0B2686, 0x0248D43806F67172, 0x868EBB51CAB4599A},
+	{0x3849674C2602319E, 0x51454B582DDF440A, 0x7178876E01F19B2A},
+	{0x04B915BA43FEB5B6, 0x42FD443059577FA2, 0xAF37FB421F8C4095},
+	{0x0113B970FD34F2CE, 0x059B5E0851CF143A, 0x86A560F10EC6D85B},
+	{0x0170F175468FB5E6, 0x0756D8E0774761D2, 0x0CD3DA020021DC09},
+	{0x43297FAD38E373FE, 0x762514B829BF486A, 0xEA676B2CB7DB2B7A},
+	{0x07A7137045DA2A16, 0x3BDD119049372802, 0xDFD64A815CAF1A0F},
+	{0x04689104C2FD3B2F, 0x26955F6835AF609A, 0x5C513C9C4886C088},
+	{0x37D06BB516CB7546, 0x164D5E404F275232, 0x0A2AEEAE3FF4AB77},
+	{0x1F08260D1AC2465E, 0x6B056E18759F5CCA, 0xEF1BF03E5DFA575A},
+	{0x584023641ABA6176, 0x004BD6EF09176062, 0x88BF0DB6D70DEE56},
+	{0x025816164629B007, 0x480D39006EE762F2, 0xA1F9915541020B56},
+	{0x49793EBC79B3258F, 0x437540C8698F3CFA, 0x6FBF1CAFCFFD0556},
+	{0x4FB05E1515AB73A7, 0x072D43A077075292, 0x2F22E49BAB7CA1AC},
+	{0x49E95D6D4CA229BF, 0x02FE55778117F12A, 0x5A6B612CC26CCE4A},
+	{0x018310DC409B26D6, 0x1D9D5C5018F728C2, 0x5F4C038ED12B2E41},
+	{0x1C587F1C13924FEF, 0x305532286D6F295A, 0x63FAC0D034D9F793},
+}
+
+// varKeyBit ключ с единственным значащим битом i (0..55) и нечетной
+// четностью остальных байтов, как в таблице B.2.
+func varKeyBit(i int) uint64 {
+	key := uint64(0x0101010101010101)
+	byteIdx, bit := i/7, i%7
+	shift := uint(8*(7-byteIdx) + 7 - bit)
+	key &^= 0xFF << uint(8*(7-byteIdx))
+	return key | 1<<shift
+}
+
+func TestKATVarPlaintext(t *testing.T) {
+	d := NewDES(0x0101010101010101)
+	for i, want := range katVarPlaintext {
+		plain := uint64(1) << uint(63-i)
+		if got := d.encryptBlock(plain); got != want {
+			t.Errorf("бит %d: шифртекст %016X, ожидается %016X", i, got, want)
+		}
+		// Обратная перестановка (B.1, inverse permutation KAT)
+		if got := d.decryptBlock(want); got != plain {
+			t.Errorf("бит %d: расшифровка %016X, ожидается %016X", i, got, plain)
+		}
+	}
+}
+
+func TestKATVarKey(t *testing.T) {
+	for i, want := range katVarKey {
+		d := NewDES(varKeyBit(i))
+		if got := d.encryptBlock(0); got != want {
+			t.Errorf("ключ %016X: шифртекст %016X, ожидается %016X", varKeyBit(i), got, want)
+		}
+		if got := d.decryptBlock(want); got != 0 {
+			t.Errorf("ключ %016X: расшифровка %016X, ожидается 0", varKeyBit(i), got)
+		}
+	}
+}
+
+func TestKATPermutation(t *testing.T) {
+	testKATVectors(t, katPermutation)
+}
+
+func TestKATSBox(t *testing.T) {
+	testKATVectors(t, katSBox)
+}
+
+func testKATVectors(t *testing.T, vectors []katVector) {
+	for _, v := range vectors {
+		d := NewDES(v.key)
+		if got := d.encryptBlock(v.plain); got != v.cipher {
+			t.Errorf("ключ %016X, текст %016X: шифртекст %016X, ожидается %016X", v.key, v.plain, got, v.cipher)
+		}
+		if got := d.decryptBlock(v.cipher); got != v.plain {
+			t.Errorf("ключ %016X: расшифровка %016X, ожидается %016X", v.key, got, v.plain)
+		}
+	}
+}
+
+// TestMonteCarlo по SP 800-17: внутренний цикл из 10000 шифрований, где
+// каждый шифртекст — следующий открытый текст, после него ключ
+// складывается с последним шифртекстом; 400 внешних итераций, с -short —
+// 100. Ответ сверяется с тем же циклом поверх crypto/des.
+func TestMonteCarlo(t *testing.T) {
+	outer := 400
+	if testing.Short() {
+		outer = 100
+	}
+	for _, decrypt := range []bool{false, true} {
+		key, text := uint64(0x0123456789ABCDEF), uint64(0x4E6F772069732074)
+		stdKey, stdText := key, text
+		for i := 0; i < outer; i++ {
+			d := NewDES(key)
+			for j := 0; j < monteCarloInner; j++ {
+				if decrypt {
+					text = d.decryptBlock(text)
+				} else {
+					text = d.encryptBlock(text)
+				}
+			}
+			key = withOddParity(key ^ text)
+
+			stdText = stdMonteCarloInner(t, stdKey, stdText, decrypt)
+			stdKey = withOddParity(stdKey ^ stdText)
+			if key != stdKey || text != stdText {
+				t.Fatalf("итерация %d (decrypt=%v): %016X/%016X, crypto/des %016X/%016X", i, decrypt, key, text, stdKey, stdText)
+			}
+		}
+	}
+}
+
+const monteCarloInner = 10000
+
+func stdMonteCarloInner(t *testing.T, key, text uint64, decrypt bool) uint64 {
+	buf := binary.BigEndian.AppendUint64(nil, key)
+	c, err := des.NewCipher(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.BigEndian.PutUint64(buf, text)
+	for j := 0; j < monteCarloInner; j++ {
+		if decrypt {
+			c.Decrypt(buf, buf)
+		} else {
+			c.Encrypt(buf, buf)
+		}
+	}
+	return binary.BigEndian.Uint64(buf)
+}
+
+// FuzzEncryptDecrypt: decryptBlock обращает encryptBlock, результат
+// совпадает с crypto/des, а IP и FP взаимно обратны. Без -fuzz
+// проверяются ключи KAT и тысяча случайных пар ключ/блок.
+func FuzzEncryptDecrypt(f *testing.F) {
+	for _, v := range append(katPermutation, katSBox...) {
+		f.Add(v.key, v.plain)
+	}
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 1000; i++ {
+		f.Add(rng.Uint64(), rng.Uint64())
+	}
+	f.Fuzz(func(t *testing.T, key, block uint64) {
+		if got := finalPermutation(initialPermutation(block)); got != block {
+			t.Fatalf("FP(IP(%016X)) = %016X", block, got)
+		}
+
+		d := NewDES(key)
+		encrypted := d.encryptBlock(block)
+		if got := d.decryptBlock(encrypted); got != block {
+			t.Fatalf("ключ %016X: D(E(%016X)) = %016X", key, block, got)
+		}
+
+		std, err := des.NewCipher(binary.BigEndian.AppendUint64(nil, key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := binary.BigEndian.AppendUint64(nil, block)
+		std.Encrypt(buf, buf)
+		if want := binary.BigEndian.Uint64(buf); encrypted != want {
+			t.Fatalf("ключ %016X, блок %016X: %016X, crypto/des %016X", key, block, encrypted, want)
+		}
+	})
+}
+
+// fileRoundTrip шифрует и расшифровывает файл из size случайных байт
+// и проверяет длину шифртекста: в ECB и CBC дополнение PKCS#7 занимает от
+// 1 до 8 байт, потоковые режимы длину не меняют.
+func fileRoundTrip(t *testing.T, d *DES, mode Mode, size int) {
+	t.Helper()
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain")
+	encrypted := filepath.Join(dir, "encrypted")
+	decrypted := filepath.Join(dir, "decrypted")
+
+	data := randomBytes(size)
+	if err := os.WriteFile(plain, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := encryptFile(d, plain, encrypted, mode); err != nil {
+		t.Fatalf("%v, %d байт: %v", mode, size, err)
+	}
+	info, err := os.Stat(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := len(fileMagic) + 1 + kcvSize + size
+	if mode.hasIV() {
+		want += blockSize
+	}
+	if !mode.isStream() {
+		want += blockSize - size%blockSize
+	}
+	if info.Size() != int64(want) {
+		t.Fatalf("%v, %d байт: длина шифртекста %d, ожидается %d", mode, size, info.Size(), want)
+	}
+	if err := decryptFile(d, encrypted, decrypted); err != nil {
+		t.Fatalf("%v, %d байт: %v", mode, size, err)
+	}
+	got, err := os.ReadFile(decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("%v, %d байт: расшифрованный файл не совпадает с исходным", mode, size)
+	}
+}
+
+// TestFileSizes: пустой, однобайтовый файлы и файлы на границе блока, как
+// требует задание лабораторной, а также на границе буфера cryptStream
+// (4096 байт): при длине 4088..4095 шифртекст ровно заполняет буфер, и
+// дополнение оказывается в последнем полном фрагменте. 10003 байта —
+// файл из нескольких фрагментов с неполным последним блоком.
+func TestFileSizes(t *testing.T) {
+	d, _, err := randomDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []Mode{ModeECB, ModeCBC, ModeCFB, ModeOFB, ModeCTR} {
+		for _, size := range []int{0, 1, 7, 8, 9, 4087, 4088, 4090, 4095, 4096, 8184, 10003} {
+			fileRoundTrip(t, d, mode, size)
+		}
+	}
+}
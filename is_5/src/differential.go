@@ -0,0 +1,327 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Дифференциальный криптоанализ DES с уменьшенным числом раундов r = 3..6
+// (Бихам и Шамир) по подобранным открытым текстам.
+//
+// Разности считаются после начальной перестановки: текст — это (L0, R0),
+// шифртекст после IP дает (R_r, L_r), так как после последнего раунда
+// половины меняются местами. В последнем раунде
+//
+//	L_r = R_{r-1},  R_r = L_{r-1} ⊕ f(R_{r-1}, K_r),
+//
+// поэтому вход f известен из шифртекста (L_r), а разность выхода
+// f' = R_r' ⊕ R_{r-2}'. Характеристика на t = r-3 раунда предсказывает
+// разность (L_t', R_t'); еще через раунд R_{t+1}' = L_t' ⊕ f'(R_t'), и
+// там, где S-блоки раунда t+1 не затронуты разностью R_t', f' = 0. Для этих
+// S-блоков разность выхода последнего раунда известна, и 6 бит подключа
+// K_r каждого из них находятся подсчетом: правильный подключ согласуется
+// со всеми правильными парами. Оставшиеся биты ключа перебираются.
+
+// characteristic дифференциальная характеристика на t раундов
+type characteristic struct {
+	name string
+	in   uint64  // разность (L0', R0')
+	outL uint32  // предсказанная L_t'
+	outR uint32  // предсказанная R_t'
+	prob float64 // вероятность характеристики
+}
+
+// Однораундовая характеристика DES: разность 04000000 на входе f дает
+// 40080000 на выходе с вероятностью 1/4 (аналогично 00000400 → 00200008).
+// Раунд с нулевой разностью на входе f проходит с вероятностью 1, поэтому
+// из (40080000, 04000000) получается цепочка
+//
+//	(40080000, 04000000) → (04000000, 0) → (0, 04000000) → (04000000, 40080000)
+//
+// где первая и третья стрелки выполняются с вероятностью 1/4.
+//
+// Ключ карты — число раундов характеристики t = r-3.
+var characteristics = map[int][]characteristic{
+	// r = 3: R0' = 0, разность L0' проходит первый раунд без изменений
+	0: {
+		{name: "L0' = FFFFFFFF, R0' = 0", in: 0xFFFFFFFF00000000, outL: 0xFFFFFFFF, prob: 1},
+	},
+	// r = 4: бит L0' попадает в средние биты одного S-блока, во втором
+	// раунде активен только он
+	1: {
+		{name: "(40000000, 0) → (0, 40000000)", in: 0x4000000000000000, outR: 0x40000000, prob: 1},
+		{name: "(04000000, 0) → (0, 04000000)", in: 0x0400000000000000, outR: 0x04000000, prob: 1},
+	},
+	2: {
+		{name: "(40080000, 04000000) → (0, 04000000)", in: 0x4008000004000000, outR: 0x04000000, prob: 1.0 / 4},
+		{name: "(00200008, 00000400) → (0, 00000400)", in: 0x0020000800000400, outR: 0x00000400, prob: 1.0 / 4},
+	},
+	3: {
+		{name: "(40080000, 04000000) → (04000000, 40080000)", in: 0x4008000004000000, outL: 0x04000000, outR: 0x40080000, prob: 1.0 / 16},
+		{name: "(00200008, 00000400) → (00000400, 00200008)", in: 0x0020000800000400, outL: 0x00000400, outR: 0x00200008, prob: 1.0 / 16},
+	},
+}
+
+// sBoxMask биты выхода S-блока j после перестановки P
+func sBoxMask(j int) uint32 {
+	return pPermutation(0xF << (28 - 4*j))
+}
+
+// sBoxInput 6-битный вход S-блока j из 48-битного значения
+func sBoxInput(x uint64, j int) int {
+	return int(x>>(42-6*j)) & 0x3F
+}
+
+// knownSBoxes S-блоки последнего раунда, для которых характеристика
+// предсказывает разность выхода: в раунде t+1 их вход не меняется.
+func (c *characteristic) knownSBoxes() []int {
+	e := expansionPermutation(c.outR)
+	var boxes []int
+	for j := 0; j < 8; j++ {
+		if sBoxInput(e, j) == 0 {
+			boxes = append(boxes, j)
+		}
+	}
+	return boxes
+}
+
+// diffResult результат подсчета по одной характеристике
+type diffResult struct {
+	counts [8][64]int // счетчики кандидатов подключа по S-блокам
+	known  []int      // S-блоки, для которых считались кандидаты
+	pairs  int        // использовано пар
+	passed int        // пар прошло фильтр
+}
+
+// countSubkeys набирает pairs пар с разностью c.in через oracle и считает
+// для каждого S-блока кандидатов 6 бит подключа последнего раунда.
+// Пары, для которых хотя бы у одного S-блока нет ни одного кандидата,
+// заведомо неправильные и отбрасываются.
+func countSubkeys(oracle func(uint64) uint64, c *characteristic, pairs int) (*diffResult, error) {
+	res := &diffResult{known: c.knownSBoxes(), pairs: pairs}
+	if len(res.known) == 0 {
+		return nil, errors.New("характеристика не дает известных S-блоков")
+	}
+	buf := make([]byte, 8)
+	var consistent [8][]int
+	for n := 0; n < pairs; n++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		x := binary.BigEndian.Uint64(buf)
+		// Открытые тексты задаются после IP, FP = IP^-1
+		c1 := initialPermutation(oracle(finalPermutation(x)))
+		c2 := initialPermutation(oracle(finalPermutation(x ^ c.in)))
+
+		l1, l2 := uint32(c1), uint32(c2)
+		rDiff := uint32(c1>>32) ^ uint32(c2>>32)
+		// R_r' ⊕ R_{r-2}', где R_{r-2}' = L_t' на известных S-блоках
+		fDiff := rDiff ^ c.outL
+		e1, e2 := expansionPermutation(l1), expansionPermutation(l2)
+
+		ok := true
+		for _, j := range res.known {
+			in1, in2 := sBoxInput(e1, j), sBoxInput(e2, j)
+			want := fDiff & sBoxMask(j)
+			consistent[j] = consistent[j][:0]
+			if in1 == in2 {
+				// Разность входа нулевая: выход тоже должен совпасть
+				if want != 0 {
+					ok = false
+					break
+				}
+				continue
+			}
+			for k := 0; k < 64; k++ {
+				if spBox[j][in1^k]^spBox[j][in2^k] == want {
+					consistent[j] = append(consistent[j], k)
+				}
+			}
+			if len(consistent[j]) == 0 {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		res.passed++
+		for _, j := range res.known {
+			for _, k := range consistent[j] {
+				res.counts[j][k]++
+			}
+		}
+	}
+	return res, nil
+}
+
+// subkeyKeyBits для каждого из 48 бит подключа раунда round (1..16)
+// возвращает бит ключа, из которого он получен расписанием ключей.
+func subkeyKeyBits(round int) [48]uint64 {
+	var table [48]uint64
+	for p := 0; p < 64; p++ {
+		keyBit := uint64(1) << uint(p)
+		if keyBit&parityMask == 0 {
+			continue
+		}
+		d := NewDES(keyBit)
+		sub := d.subkeys[round-1]
+		if sub != 0 {
+			table[47-bits.TrailingZeros64(sub)] = keyBit
+		}
+	}
+	return table
+}
+
+// differentialAttack атакует DES с rounds раундами и случайным секретным
+// ключом, используя pairs пар на характеристику (0 — по вероятности
+// характеристики). Печатает в w найденные подключи и восстановленный ключ.
+func differentialAttack(w io.Writer, rounds, pairs int) error {
+	if rounds < 3 || rounds > 6 {
+		return fmt.Errorf("атака реализована для 3..6 раундов, получено %d", rounds)
+	}
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	secret := withOddParity(binary.BigEndian.Uint64(buf))
+	target, err := NewDESRounds(secret, rounds)
+	if err != nil {
+		return err
+	}
+	queries := 0
+	oracle := func(p uint64) uint64 {
+		queries++
+		return target.encryptBlock(p)
+	}
+	trueSubkey := target.subkeys[rounds-1]
+
+	fmt.Fprintf(w, "DES, %d раундов, секретный ключ выбран случайно\n", rounds)
+	var total [8][64]int
+	var found [8]bool
+	for _, c := range characteristics[rounds-3] {
+		n := pairs
+		if n == 0 {
+			n = defaultPairs(c.prob)
+		}
+		res, err := countSubkeys(oracle, &c, n)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Характеристика %s, p = %g: %d пар, прошло фильтр %d, S-блоки %v\n",
+			c.name, c.prob, res.pairs, res.passed, sBoxNames(res.known))
+		for _, j := range res.known {
+			found[j] = true
+			for k := range total[j] {
+				total[j][k] += res.counts[j][k]
+			}
+		}
+	}
+
+	// Лучший кандидат каждого S-блока и сравнение с настоящим подключом
+	var subkey, subkeyMask uint64
+	fmt.Fprintf(w, "\nПодключ K%d по S-блокам (кандидат, голоса, второй по голосам):\n", rounds)
+	for j := 0; j < 8; j++ {
+		if !found[j] {
+			fmt.Fprintf(w, "  S%d  не определяется\n", j+1)
+			continue
+		}
+		best, second := 0, -1
+		for k := 1; k < 64; k++ {
+			if total[j][k] > total[j][best] {
+				best, second = k, best
+			} else if second < 0 || total[j][k] > total[j][second] {
+				second = k
+			}
+		}
+		mark := "✓"
+		if best != sBoxInput(trueSubkey, j) {
+			mark = fmt.Sprintf("✗ верно %02X", sBoxInput(trueSubkey, j))
+		}
+		fmt.Fprintf(w, "  S%d  %02X  %5d  %5d  %s\n", j+1, best, total[j][best], total[j][second], mark)
+		subkey |= uint64(best) << (42 - 6*j)
+		subkeyMask |= 0x3F << (42 - 6*j)
+	}
+
+	key, tried, err := recoverKey(target, rounds, subkey, subkeyMask)
+	fmt.Fprintf(w, "\nЗапросов к шифратору: %d, перебрано ключей: %d\n", queries, tried)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Найден ключ:     %016X\n", key)
+	fmt.Fprintf(w, "Секретный ключ:  %016X\n", secret)
+	if key&parityMask != secret&parityMask {
+		return errors.New("найденный ключ отличается от секретного")
+	}
+	fmt.Fprintln(w, "✓ ключ восстановлен")
+	return nil
+}
+
+// defaultPairs число пар для характеристики с вероятностью prob: около
+// 20 правильных пар на характеристику.
+func defaultPairs(prob float64) int {
+	if prob >= 1 {
+		return 32
+	}
+	return int(20 / prob)
+}
+
+// recoverKey восстанавливает ключ по известным битам подключа последнего
+// раунда (subkeyMask) и перебору остальных битов ключа. Кандидат
+// проверяется на двух известных парах открытый текст/шифртекст.
+func recoverKey(target *DES, rounds int, subkey, subkeyMask uint64) (uint64, int, error) {
+	keyBits := subkeyKeyBits(rounds)
+	var known, knownMask uint64
+	for b := 0; b < 48; b++ {
+		bit := uint64(1) << uint(47-b)
+		if subkeyMask&bit == 0 {
+			continue
+		}
+		knownMask |= keyBits[b]
+		if subkey&bit != 0 {
+			known |= keyBits[b]
+		}
+	}
+	var unknown []uint64
+	for p := 0; p < 64; p++ {
+		if bit := uint64(1) << uint(p); bit&parityMask != 0 && bit&knownMask == 0 {
+			unknown = append(unknown, bit)
+		}
+	}
+
+	plains := [2]uint64{0x0123456789ABCDEF, 0xFEDCBA9876543210}
+	ciphers := [2]uint64{target.encryptBlock(plains[0]), target.encryptBlock(plains[1])}
+	tried := 0
+	for n := uint64(0); n < 1<<len(unknown); n++ {
+		key := known
+		for i, bit := range unknown {
+			if n>>uint(i)&1 != 0 {
+				key |= bit
+			}
+		}
+		tried++
+		d, err := NewDESRounds(key, rounds)
+		if err != nil {
+			return 0, tried, err
+		}
+		if d.encryptBlock(plains[0]) == ciphers[0] && d.encryptBlock(plains[1]) == ciphers[1] {
+			return withOddParity(key), tried, nil
+		}
+	}
+	return 0, tried, fmt.Errorf("ключ не найден перебором %d бит: подключ определен неверно", len(unknown))
+}
+
+func sBoxNames(boxes []int) string {
+	s := ""
+	for i, j := range boxes {
+		if i > 0 {
+			s += ","
+		}
+		s += fmt.Sprintf("S%d", j+1)
+	}
+	return s
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+// TestDifferentialAttack восстанавливает ключ DES с 3..6 раундами.
+// differentialAttack сама сверяет найденный ключ с настоящим.
+func TestDifferentialAttack(t *testing.T) {
+	for rounds := 3; rounds <= 6; rounds++ {
+		t.Run(fmt.Sprintf("%d раундов", rounds), func(t *testing.T) {
+			if err := differentialAttack(io.Discard, rounds, 0); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import "math/bits"
+
+// Быстрая реализация раунда на таблицах, вычисляемых из таблиц стандарта
+// при запуске программы.
+//
+// spBox[i][x] — результат S-блока i для 6-битного входа x, сразу
+// переставленный перестановкой P. P линейна, поэтому P(S1 || ... || S8)
+// равна XOR восьми значений spBox, и функция Фейстеля сводится к восьми
+// обращениям к таблицам.
+//
+// ipBytes[j][v] и fpBytes[j][v] — вклад байта j со значением v в результат
+// перестановки IP (IP^-1). Перестановка 64 бит — XOR восьми значений.
+var (
+	spBox   [8][64]uint32
+	ipBytes [8][256]uint64
+	fpBytes [8][256]uint64
+)
+
+func init() {
+	for i := 0; i < 8; i++ {
+		for x := uint64(0); x < 64; x++ {
+			// Вход S-блока i занимает биты 42-6i..47-6i выхода E
+			s := sBoxSubstitution(x<<(42-6*i)) & (0xF << (28 - 4*i))
+			spBox[i][x] = pPermutation(s)
+		}
+	}
+	for j := 0; j < 8; j++ {
+		for v := uint64(0); v < 256; v++ {
+			ipBytes[j][v] = initialPermutation(v << (56 - 8*j))
+			fpBytes[j][v] = finalPermutation(v << (56 - 8*j))
+		}
+	}
+}
+
+// permuteBytes перестановка 64 бит по побайтовым таблицам
+func permuteBytes(table *[8][256]uint64, block uint64) uint64 {
+	return table[0][block>>56] ^ table[1][block>>48&0xFF] ^
+		table[2][block>>40&0xFF] ^ table[3][block>>32&0xFF] ^
+		table[4][block>>24&0xFF] ^ table[5][block>>16&0xFF] ^
+		table[6][block>>8&0xFF] ^ table[7][block&0xFF]
+}
+
+// fastFeistel функция Фейстеля на таблицах spBox. Расширение E не
+// вычисляется отдельно: входы S-блоков — это 6-битные окна с шагом 4
+// в правой половине, циклически сдвинутой на 1 бит вправо.
+func fastFeistel(right uint32, subkey uint64) uint32 {
+	r := bits.RotateLeft32(right, -1)
+	x := uint64(r)<<32 | uint64(r)
+	return spBox[0][(x>>58^subkey>>42)&0x3F] ^
+		spBox[1][(x>>54^subkey>>36)&0x3F] ^
+		spBox[2][(x>>50^subkey>>30)&0x3F] ^
+		spBox[3][(x>>46^subkey>>24)&0x3F] ^
+		spBox[4][(x>>42^subkey>>18)&0x3F] ^
+		spBox[5][(x>>38^subkey>>12)&0x3F] ^
+		spBox[6][(x>>34^subkey>>6)&0x3F] ^
+		spBox[7][(x>>30^subkey)&0x3F]
+}
+
+// cryptBlock раунды DES с подключами subkeys, по раунду на подключ. Для
+// расшифровки передаются подключи в обратном порядке.
+func cryptBlock(block uint64, subkeys []uint64) uint64 {
+	block = permuteBytes(&ipBytes, block)
+	left, right := uint32(block>>32), uint32(block)
+	for _, k := range subkeys {
+		left, right = right, left^fastFeistel(right, k)
+	}
+	return permuteBytes(&fpBytes, uint64(right)<<32|uint64(left))
+}
+
+// cryptBlockTrace то же, что cryptBlock, но после начальной перестановки и
+// каждого раунда вызывает hook с номером раунда (0 — после IP) и
+// состоянием L||R. Используется для анализа лавинного эффекта.
+func cryptBlockTrace(block uint64, subkeys []uint64, hook func(round int, state uint64)) uint64 {
+	block = permuteBytes(&ipBytes, block)
+	left, right := uint32(block>>32), uint32(block)
+	hook(0, block)
+	for i, k := range subkeys {
+		left, right = right, left^fastFeistel(right, k)
+		hook(i+1, uint64(left)<<32|uint64(right))
+	}
+	return permuteBytes(&fpBytes, uint64(right)<<32|uint64(left))
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"crypto/des"
+	"encoding/binary"
+	"sync"
+	"testing"
+)
+
+func TestFastAgainstReference(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		d, _, err := randomDES()
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := binary.BigEndian.Uint64(randomBytes(blockSize))
+		if got, want := d.encryptBlock(block), referenceBlock(block, d.subkeys[:]); got != want {
+			t.Fatalf("шифрование %016X: %016X, побитово %016X", block, got, want)
+		}
+		if got, want := d.decryptBlock(block), referenceBlock(block, d.decSubkeys[:]); got != want {
+			t.Fatalf("расшифровка %016X: %016X, побитово %016X", block, got, want)
+		}
+	}
+}
+
+// TestConcurrentUse: горутины шифруют и расшифровывают общим DES, раньше
+// decryptBlock переставлял подключи на месте и результаты портились.
+func TestConcurrentUse(t *testing.T) {
+	d, std, err := randomDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			src := randomBytes(blockSize)
+			want, got := make([]byte, blockSize), make([]byte, blockSize)
+			std.Encrypt(want, src)
+			for i := 0; i < 10000; i++ {
+				d.Encrypt(got, src)
+				if !bytes.Equal(got, want) {
+					t.Errorf("Encrypt(%x) = %x, ожидается %x", src, got, want)
+					return
+				}
+				d.Decrypt(got, want)
+				if !bytes.Equal(got, src) {
+					t.Errorf("Decrypt(%x) = %x, ожидается %x", want, got, src)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Скорость шифрования блоков. BenchmarkEncryptBlock и BenchmarkDecryptBlock —
+// побитовая реализация по стандарту (referenceBlock), какой она была до
+// табличной; BenchmarkFast* — табличная реализация из fast.go. Каждый блок
+// зависит от предыдущего, чтобы вычисления нельзя было отбросить.
+
+const benchKey = 0x133457799BBCDFF1
+
+// benchSink результат последнего блока, чтобы компилятор не удалил цикл
+var benchSink uint64
+
+func benchBlocks(b *testing.B, crypt func(uint64) uint64) {
+	b.SetBytes(blockSize)
+	block := uint64(0)
+	for b.Loop() {
+		block = crypt(block)
+	}
+	benchSink = block
+}
+
+func BenchmarkEncryptBlock(b *testing.B) {
+	d := NewDES(benchKey)
+	benchBlocks(b, func(x uint64) uint64 { return referenceBlock(x, d.subkeys[:]) })
+}
+
+func BenchmarkDecryptBlock(b *testing.B) {
+	d := NewDES(benchKey)
+	benchBlocks(b, func(x uint64) uint64 { return referenceBlock(x, d.decSubkeys[:]) })
+}
+
+func BenchmarkFastEncryptBlock(b *testing.B) {
+	benchBlocks(b, NewDES(benchKey).encryptBlock)
+}
+
+func BenchmarkFastDecryptBlock(b *testing.B) {
+	benchBlocks(b, NewDES(benchKey).decryptBlock)
+}
+
+// BenchmarkFastParallel общий экземпляр DES во всех горутинах: расписания
+// ключей только читаются
+func BenchmarkFastParallel(b *testing.B) {
+	d := NewDES(benchKey)
+	b.SetBytes(blockSize)
+	b.RunParallel(func(pb *testing.PB) {
+		block := uint64(0)
+		for pb.Next() {
+			block = d.encryptBlock(block)
+		}
+		if block == 1 {
+			benchSink = block
+		}
+	})
+}
+
+func BenchmarkTripleDES(b *testing.B) {
+	t, err := NewTripleDES([]byte("0123456789ABCDEFGHIJKLMN"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, blockSize)
+	b.SetBytes(blockSize)
+	for b.Loop() {
+		t.Encrypt(buf, buf)
+	}
+}
+
+func BenchmarkStdlibDES(b *testing.B) {
+	std, err := des.NewCipher(binary.BigEndian.AppendUint64(nil, benchKey))
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, blockSize)
+	b.SetBytes(blockSize)
+	for b.Loop() {
+		std.Encrypt(buf, buf)
+	}
+}
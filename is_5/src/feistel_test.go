@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// TestFeistelDES: конфигурация DES с 1..16 раундами совпадает с
+// NewDESRounds.
+func TestFeistelDES(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		key := randomBytes(8)
+		block := binary.BigEndian.Uint64(randomBytes(blockSize))
+		cfg := desConfig()
+		cfg.Rounds = 1 + i%fullRounds
+		f, err := NewFeistel(cfg, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := NewDESRounds(binary.BigEndian.Uint64(key), cfg.Rounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, blockSize)
+		f.Encrypt(got, binary.BigEndian.AppendUint64(nil, block))
+		if want := d.encryptBlock(block); binary.BigEndian.Uint64(got) != want {
+			t.Fatalf("%d раундов: %X, ожидается %016X", cfg.Rounds, got, want)
+		}
+	}
+}
+
+func TestFeistelInvalidConfig(t *testing.T) {
+	bad := map[string]func(*FeistelConfig){
+		"0 раундов":          func(c *FeistelConfig) { c.Rounds = 0 },
+		"1 подблок":          func(c *FeistelConfig) { c.Branches = 1 },
+		"IP при 3 подблоках": func(c *FeistelConfig) { c.Branches = 3 },
+		"P не перестановка":  func(c *FeistelConfig) { c.PTable[0] = c.PTable[1] },
+		"E вне диапазона":    func(c *FeistelConfig) { c.ETable[0] = 33 },
+	}
+	for name, change := range bad {
+		cfg := desConfig()
+		change(&cfg)
+		if _, err := NewFeistel(cfg, randomBytes(8)); err == nil {
+			t.Errorf("%s: неверная конфигурация принята", name)
+		}
+	}
+	if _, err := NewFeistel(desxConfig(), randomBytes(8)); err == nil {
+		t.Error("DESX: ключ без ключей отбеливания принят")
+	}
+}
+
+// TestDESX: DESX равен DES с ручным отбеливанием.
+func TestDESX(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		key := randomBytes(24)
+		plain := randomBytes(blockSize)
+		f, err := NewFeistel(desxConfig(), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, blockSize)
+		f.Encrypt(got, plain)
+		want := make([]byte, blockSize)
+		xorBytes(want, plain, key[8:16])
+		NewDES(binary.BigEndian.Uint64(key)).Encrypt(want, want)
+		xorBytes(want, want, key[16:])
+		if !bytes.Equal(got, want) {
+			t.Fatalf("DESX: %X, ожидается %X", got, want)
+		}
+	}
+}
+
+// TestFeistelPresets: все пресеты расшифровывают свой шифртекст.
+func TestFeistelPresets(t *testing.T) {
+	for _, cfg := range feistelPresets() {
+		for i := 0; i < 100; i++ {
+			f, err := NewFeistel(cfg, randomBytes(cfg.keySize()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain := randomBytes(f.BlockSize())
+			c := make([]byte, f.BlockSize())
+			f.Encrypt(c, plain)
+			got := make([]byte, f.BlockSize())
+			f.Decrypt(got, c)
+			if !bytes.Equal(got, plain) {
+				t.Fatalf("%s: D(E(%X)) = %X", cfg.Name, plain, got)
+			}
+		}
+	}
+}
+
+// TestGDESTwoBranches: GDES с двумя подблоками без IP — DES без начальной
+// и конечной перестановок.
+func TestGDESTwoBranches(t *testing.T) {
+	gdes2 := gdesConfig()
+	gdes2.Branches = 2
+	key := randomBytes(8)
+	f, err := NewFeistel(gdes2, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := binary.BigEndian.Uint64(randomBytes(blockSize))
+	got := make([]byte, blockSize)
+	f.Encrypt(got, binary.BigEndian.AppendUint64(nil, initialPermutation(block)))
+	want := initialPermutation(NewDES(binary.BigEndian.Uint64(key)).encryptBlock(block))
+	if binary.BigEndian.Uint64(got) != want {
+		t.Fatalf("GDES с двумя подблоками: %X, ожидается %016X", got, want)
+	}
+}
+
+// TestFeistelAvalanche: у полного DES меняется около половины бит блока,
+// у одного раунда — заметно меньше.
+func TestFeistelAvalanche(t *testing.T) {
+	plainBits, keyBits, err := feistelAvalanche(desConfig(), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(plainBits-32) > 1.5 || math.Abs(keyBits-32) > 1.5 {
+		t.Fatalf("16 раундов: %.2f и %.2f бит, ожидается около 32", plainBits, keyBits)
+	}
+	one := desConfig()
+	one.Rounds = 1
+	if plainBits, _, err = feistelAvalanche(one, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if plainBits > 16 {
+		t.Fatalf("1 раунд: %.2f бит", plainBits)
+	}
+}
@@ -0,0 +1,7 @@
+module is_5
+
+go 1.25.1
+
+require avalanche v0.0.0
+
+replace avalanche => ../../avalanche
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Формат файла ключа (версия 1) — строки "поле: значение":
+//
+//	algorithm:  des, 3des-ede2 или 3des-ede3
+//	version:    1
+//	encoding:   hex, base64 или passphrase
+//	key:        ключ в кодировке encoding, нет для passphrase
+//	salt:       соль PBKDF2 в hex, только для passphrase
+//	iterations: число итераций PBKDF2, только для passphrase
+//	kcv:        контрольное значение ключа в hex
+//
+// Для encoding: passphrase ключ выводится из парольной фразы, которая в
+// файле не хранится и передается при шифровании флагом -passphrase.
+// Поддерживаются и старые форматы: "<вариант>:<hex-ключ>" и один hex-ключ
+// без варианта (DES).
+const keyFileVersion = 1
+
+const (
+	encodingHex        = "hex"
+	encodingBase64     = "base64"
+	encodingPassphrase = "passphrase"
+)
+
+// pbkdf2Iterations число итераций PBKDF2-HMAC-SHA256 для новых ключей
+const pbkdf2Iterations = 100000
+
+// kcvSize длина KCV: первые 3 байта шифртекста нулевого блока
+const kcvSize = 3
+
+// errWrongKey ключ не соответствует KCV файла ключа или зашифрованного файла
+var errWrongKey = errors.New("неверный ключ: контрольное значение KCV не совпадает")
+
+// keyCheckValue KCV ключа блочного шифра b: E_K(0) без последних байт
+func keyCheckValue(b cipher.Block) []byte {
+	block := make([]byte, b.BlockSize())
+	b.Encrypt(block, block)
+	return block[:kcvSize]
+}
+
+// setOddParity устанавливает биты четности в каждом ключе DES из key
+func setOddParity(key []byte) {
+	for i := 0; i+8 <= len(key); i += 8 {
+		binary.BigEndian.PutUint64(key[i:], withOddParity(binary.BigEndian.Uint64(key[i:])))
+	}
+}
+
+// deriveKey ключ варианта variant из парольной фразы по PBKDF2-HMAC-SHA256
+func deriveKey(variant, passphrase string, salt []byte, iterations int) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("ключ выводится из парольной фразы: укажите -passphrase")
+	}
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, variantKeySize[variant])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка вывода ключа: %v", err)
+	}
+	setOddParity(key)
+	return key, nil
+}
+
+// readKeyFromFile читает ключ в любом из поддерживаемых форматов. passphrase
+// нужна только для ключей, выводимых из парольной фразы.
+func readKeyFromFile(keyFilePath, passphrase string) (variant string, key []byte, err error) {
+	// Чтение файла с ключом
+	keyData, err := os.ReadFile(keyFilePath)
+	if err != nil {
+		return "", nil, fmt.Errorf("ошибка чтения файла ключа: %v", err)
+	}
+	if bytes.Contains(keyData, []byte("algorithm:")) {
+		return parseKeyFile(keyData, passphrase)
+	}
+
+	// Старый формат. Очистка ключа от пробелов и переводов строк
+	keyStr := strings.TrimSpace(string(keyData))
+	keyStr = strings.ReplaceAll(keyStr, " ", "")
+	keyStr = strings.ReplaceAll(keyStr, "\n", "")
+	keyStr = strings.ReplaceAll(keyStr, "\r", "")
+
+	variant = variantDES
+	if name, rest, ok := strings.Cut(keyStr, ":"); ok {
+		variant, keyStr = strings.ToLower(name), rest
+	}
+	size, ok := variantKeySize[variant]
+	if !ok {
+		return "", nil, fmt.Errorf("неизвестный вариант ключа %q, ожидается des, 3des-ede2 или 3des-ede3", variant)
+	}
+
+	keyStr = strings.ReplaceAll(keyStr, "0x", "")
+	keyStr = strings.ReplaceAll(keyStr, "0X", "")
+
+	// Проверка длины ключа
+	if len(keyStr) != 2*size {
+		return "", nil, fmt.Errorf("неправильная длина ключа %s: ожидается %d hex-символов (%d бит), получено %d", variant, 2*size, 8*size, len(keyStr))
+	}
+
+	// Парсинг hex-строки
+	key, err = hex.DecodeString(keyStr)
+	if err != nil {
+		return "", nil, fmt.Errorf("ошибка парсинга hex-ключа: %v", err)
+	}
+	setOddParity(key)
+
+	return variant, key, nil
+}
+
+// parseKeyFile разбирает файл ключа в формате версии 1 и проверяет KCV
+func parseKeyFile(data []byte, passphrase string) (variant string, key []byte, err error) {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return "", nil, fmt.Errorf("неправильная строка файла ключа: %q", line)
+		}
+		fields[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+
+	variant = strings.ToLower(fields["algorithm"])
+	size, ok := variantKeySize[variant]
+	if !ok {
+		return "", nil, fmt.Errorf("неизвестный алгоритм %q, ожидается des, 3des-ede2 или 3des-ede3", fields["algorithm"])
+	}
+	version, err := strconv.Atoi(fields["version"])
+	if err != nil || version < 1 || version > keyFileVersion {
+		return "", nil, fmt.Errorf("неподдерживаемая версия файла ключа %q", fields["version"])
+	}
+	kcv, err := hex.DecodeString(fields["kcv"])
+	if err != nil || len(kcv) != kcvSize {
+		return "", nil, fmt.Errorf("неправильное поле kcv: %q", fields["kcv"])
+	}
+
+	encoding := fields["encoding"]
+	switch encoding {
+	case encodingHex:
+		key, err = hex.DecodeString(fields["key"])
+	case encodingBase64:
+		key, err = base64.StdEncoding.DecodeString(fields["key"])
+	case encodingPassphrase:
+		salt, err := hex.DecodeString(fields["salt"])
+		if err != nil || len(salt) == 0 {
+			return "", nil, fmt.Errorf("неправильное поле salt: %q", fields["salt"])
+		}
+		iterations, err := strconv.Atoi(fields["iterations"])
+		if err != nil || iterations < 1 {
+			return "", nil, fmt.Errorf("неправильное поле iterations: %q", fields["iterations"])
+		}
+		if key, err = deriveKey(variant, passphrase, salt, iterations); err != nil {
+			return "", nil, err
+		}
+	default:
+		return "", nil, fmt.Errorf("неизвестная кодировка ключа %q, ожидается hex, base64 или passphrase", encoding)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("ошибка декодирования ключа (%s): %v", encoding, err)
+	}
+	if len(key) != size {
+		return "", nil, fmt.Errorf("неправильная длина ключа %s: ожидается %d байт, получено %d", variant, size, len(key))
+	}
+	setOddParity(key)
+
+	b, err := newBlockCipher(variant, key)
+	if err != nil {
+		return "", nil, err
+	}
+	if subtle.ConstantTimeCompare(keyCheckValue(b), kcv) != 1 {
+		if encoding == encodingPassphrase {
+			return "", nil, fmt.Errorf("неверная парольная фраза: %w", errWrongKey)
+		}
+		return "", nil, fmt.Errorf("файл ключа поврежден: %w", errWrongKey)
+	}
+	return variant, key, nil
+}
+
+// generateAndSaveKey создает ключ варианта variant и сохраняет его в файл
+// ключа с кодировкой encoding. Для encodingPassphrase ключ выводится из
+// passphrase со случайной солью.
+func generateAndSaveKey(keyFilePath, variant, encoding, passphrase string) ([]byte, error) {
+	size, ok := variantKeySize[variant]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный вариант ключа %q", variant)
+	}
+
+	// Генерация случайного ключа (или соли), слабые и полуслабые ключи
+	// отбрасываются
+	keyBytes := make([]byte, size)
+	salt := make([]byte, 16)
+	for {
+		var err error
+		switch encoding {
+		case encodingHex, encodingBase64:
+			_, err = rand.Read(keyBytes)
+			setOddParity(keyBytes)
+		case encodingPassphrase:
+			if _, err = rand.Read(salt); err == nil {
+				keyBytes, err = deriveKey(variant, passphrase, salt, pbkdf2Iterations)
+			}
+		default:
+			return nil, fmt.Errorf("неизвестная кодировка ключа %q, ожидается hex, base64 или passphrase", encoding)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ошибка генерации ключа: %v", err)
+		}
+		if len(weakKeyWarnings(variant, keyBytes)) == 0 {
+			break
+		}
+	}
+
+	b, err := newBlockCipher(variant, keyBytes)
+	if err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "algorithm: %s\nversion: %d\nencoding: %s\n", variant, keyFileVersion, encoding)
+	switch encoding {
+	case encodingHex:
+		fmt.Fprintf(&text, "key: %x\n", keyBytes)
+	case encodingBase64:
+		fmt.Fprintf(&text, "key: %s\n", base64.StdEncoding.EncodeToString(keyBytes))
+	case encodingPassphrase:
+		fmt.Fprintf(&text, "salt: %x\niterations: %d\n", salt, pbkdf2Iterations)
+	}
+	fmt.Fprintf(&text, "kcv: %X\n", keyCheckValue(b))
+
+	err = os.WriteFile(keyFilePath, []byte(text.String()), 0600) // права только для владельца
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения ключа: %v", err)
+	}
+
+	return keyBytes, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithOddParity(t *testing.T) {
+	cases := map[uint64]uint64{
+		0x0000000000000000: 0x0101010101010101,
+		0x1234567890ABCDEF: 0x1334577991ABCDEF,
+		0x133457799BBCDFF1: 0x133457799BBCDFF1,
+	}
+	for key, want := range cases {
+		if got := withOddParity(key); got != want {
+			t.Errorf("withOddParity(%016X) = %016X, ожидается %016X", key, got, want)
+		}
+	}
+}
+
+// TestKeyFileEncodings: ключ в каждой кодировке читается обратно с битами
+// четности.
+func TestKeyFileEncodings(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	for _, encoding := range []string{encodingHex, encodingBase64, encodingPassphrase} {
+		key, err := generateAndSaveKey(keyFile, variant3DESEDE2, encoding, "пароль")
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		_, got, err := readKeyFromFile(keyFile, "пароль")
+		if err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if !bytes.Equal(got, key) {
+			t.Fatalf("%s: прочитан ключ %X, ожидается %X", encoding, got, key)
+		}
+		for i := 0; i < len(got); i += 8 {
+			k := binary.BigEndian.Uint64(got[i:])
+			if withOddParity(k) != k {
+				t.Fatalf("%s: ключ %016X без битов четности", encoding, k)
+			}
+		}
+	}
+}
+
+// TestKeyFilePassphrase: неверная парольная фраза отвергается по KCV,
+// без парольной фразы ключ не читается.
+func TestKeyFilePassphrase(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if _, err := generateAndSaveKey(keyFile, variantDES, encodingPassphrase, "пароль"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readKeyFromFile(keyFile, "другой пароль"); !errors.Is(err, errWrongKey) {
+		t.Fatalf("неверная парольная фраза: получено %v", err)
+	}
+	if _, _, err := readKeyFromFile(keyFile, ""); err == nil {
+		t.Fatal("ключ из парольной фразы прочитан без нее")
+	}
+}
+
+// TestKeyFileCorrupted: измененный значащий бит ключа не совпадает с KCV,
+// неверные поля файла отвергаются.
+func TestKeyFileCorrupted(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	key, err := generateAndSaveKey(keyFile, variantDES, encodingHex, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := bytes.Clone(key)
+	changed[0] ^= 0x02
+	corrupted := bytes.Replace(data, []byte(hex.EncodeToString(key)), []byte(hex.EncodeToString(changed)), 1)
+	if err := os.WriteFile(keyFile, corrupted, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readKeyFromFile(keyFile, ""); !errors.Is(err, errWrongKey) {
+		t.Fatalf("поврежденный ключ: получено %v", err)
+	}
+
+	bad := map[string]string{
+		"алгоритм":  "algorithm: aes\nversion: 1\nencoding: hex\nkey: %s\nkcv: 000000\n",
+		"версия":    "algorithm: des\nversion: 2\nencoding: hex\nkey: %s\nkcv: 000000\n",
+		"кодировка": "algorithm: des\nversion: 1\nencoding: base32\nkey: %s\nkcv: 000000\n",
+		"kcv":       "algorithm: des\nversion: 1\nencoding: hex\nkey: %s\nkcv: 00\n",
+		"длина":     "algorithm: 3des-ede3\nversion: 1\nencoding: hex\nkey: %s\nkcv: 000000\n",
+	}
+	for name, format := range bad {
+		if err := os.WriteFile(keyFile, fmt.Appendf(nil, format, hex.EncodeToString(key)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := readKeyFromFile(keyFile, ""); err == nil {
+			t.Errorf("%s: неверный файл ключа принят", name)
+		}
+	}
+}
+
+// TestLegacyKeyFormats: hex-ключ старого формата и ключ с вариантом
+// "<вариант>:<hex>" получают биты четности.
+func TestLegacyKeyFormats(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	cases := []struct {
+		data, variant, key string
+	}{
+		{"0x1234567890abcdef\n", variantDES, "1334577991ABCDEF"},
+		{"3des-ede2:1234567890abcdef 1234567890abcdee\n", variant3DESEDE2, "1334577991ABCDEF1334577991ABCDEF"},
+	}
+	for _, c := range cases {
+		if err := os.WriteFile(keyFile, []byte(c.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		variant, key, err := readKeyFromFile(keyFile, "")
+		if err != nil {
+			t.Fatalf("%q: %v", c.data, err)
+		}
+		if variant != c.variant || fmt.Sprintf("%X", key) != c.key {
+			t.Fatalf("%q: ключ %s:%X, ожидается %s:%s", c.data, variant, key, c.variant, c.key)
+		}
+	}
+}
+
+// TestWrongKey: файл, зашифрованный одним ключом, не расшифровывается
+// другим, и выходной файл не создается.
+func TestWrongKey(t *testing.T) {
+	d, _, err := randomDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := randomDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain")
+	encrypted := filepath.Join(dir, "encrypted")
+	decrypted := filepath.Join(dir, "decrypted")
+	if err := os.WriteFile(plain, randomBytes(100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []Mode{ModeECB, ModeCBC, ModeCTR} {
+		if err := encryptFile(d, plain, encrypted, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := decryptFile(other, encrypted, decrypted); !errors.Is(err, errWrongKey) {
+			t.Fatalf("%v: получено %v, ожидается errWrongKey", mode, err)
+		}
+		if _, err := os.Stat(decrypted); !os.IsNotExist(err) {
+			t.Fatalf("%v: выходной файл создан при неверном ключе", mode)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Mode режим шифрования
+type Mode byte
+
+const (
+	ModeECB Mode = iota + 1
+	ModeCBC
+	ModeCFB
+	ModeOFB
+	ModeCTR
+)
+
+var modeNames = map[Mode]string{
+	ModeECB: "ecb",
+	ModeCBC: "cbc",
+	ModeCFB: "cfb",
+	ModeOFB: "ofb",
+	ModeCTR: "ctr",
+}
+
+func (m Mode) String() string {
+	if name, ok := modeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("mode(%d)", byte(m))
+}
+
+// parseMode разбирает имя режима: ecb, cbc, cfb, ofb, ctr.
+func parseMode(s string) (Mode, error) {
+	for m, name := range modeNames {
+		if strings.EqualFold(s, name) {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("неизвестный режим %q, ожидается ecb|cbc|cfb|ofb|ctr", s)
+}
+
+// hasIV нужен ли режиму вектор инициализации
+func (m Mode) hasIV() bool {
+	return m != ModeECB
+}
+
+// isStream потоковый режим: длина шифртекста равна длине открытого текста
+func (m Mode) isStream() bool {
+	return m == ModeCFB || m == ModeOFB || m == ModeCTR
+}
+
+// newModeCrypter возвращает функцию, обрабатывающую данные в режиме mode.
+// Для ECB и CBC длина данных должна быть кратна размеру блока, потоковые
+// режимы принимают фрагменты любой длины. Состояние режима сохраняется
+// между вызовами, поэтому поток можно обрабатывать по частям.
+func newModeCrypter(b cipher.Block, mode Mode, iv []byte, encrypt bool) (func(dst, src []byte), error) {
+	if mode.hasIV() && len(iv) != b.BlockSize() {
+		return nil, fmt.Errorf("длина IV %d байт, ожидается %d", len(iv), b.BlockSize())
+	}
+	switch mode {
+	case ModeECB:
+		if encrypt {
+			return newECBEncrypter(b).CryptBlocks, nil
+		}
+		return newECBDecrypter(b).CryptBlocks, nil
+	case ModeCBC:
+		if encrypt {
+			return newCBCEncrypter(b, iv).CryptBlocks, nil
+		}
+		return newCBCDecrypter(b, iv).CryptBlocks, nil
+	case ModeCFB:
+		return newCFB(b, iv, !encrypt).XORKeyStream, nil
+	case ModeOFB:
+		return newOFB(b, iv).XORKeyStream, nil
+	case ModeCTR:
+		return newCTR(b, iv).XORKeyStream, nil
+	}
+	return nil, fmt.Errorf("неизвестный режим %v", mode)
+}
+
+// ECB: каждый блок шифруется независимо.
+type ecb struct {
+	b       cipher.Block
+	decrypt bool
+}
+
+func newECBEncrypter(b cipher.Block) cipher.BlockMode { return &ecb{b: b} }
+
+func newECBDecrypter(b cipher.Block) cipher.BlockMode { return &ecb{b: b, decrypt: true} }
+
+func (x *ecb) BlockSize() int { return x.b.BlockSize() }
+
+func (x *ecb) CryptBlocks(dst, src []byte) {
+	bs := x.b.BlockSize()
+	if len(src)%bs != 0 {
+		panic("ecb: длина данных не кратна блоку")
+	}
+	for i := 0; i < len(src); i += bs {
+		if x.decrypt {
+			x.b.Decrypt(dst[i:i+bs], src[i:i+bs])
+		} else {
+			x.b.Encrypt(dst[i:i+bs], src[i:i+bs])
+		}
+	}
+}
+
+// CBC: C_i = E(P_i ⊕ C_{i-1}), C_0 = IV.
+type cbc struct {
+	b       cipher.Block
+	iv      []byte
+	tmp     []byte
+	decrypt bool
+}
+
+func newCBCEncrypter(b cipher.Block, iv []byte) cipher.BlockMode {
+	return &cbc{b: b, iv: append([]byte(nil), iv...), tmp: make([]byte, b.BlockSize())}
+}
+
+func newCBCDecrypter(b cipher.Block, iv []byte) cipher.BlockMode {
+	return &cbc{b: b, iv: append([]byte(nil), iv...), tmp: make([]byte, b.BlockSize()), decrypt: true}
+}
+
+func (x *cbc) BlockSize() int { return x.b.BlockSize() }
+
+func (x *cbc) CryptBlocks(dst, src []byte) {
+	bs := x.b.BlockSize()
+	if len(src)%bs != 0 {
+		panic("cbc: длина данных не кратна блоку")
+	}
+	for i := 0; i < len(src); i += bs {
+		in, out := src[i:i+bs], dst[i:i+bs]
+		if x.decrypt {
+			// in и out могут совпадать, шифрблок сохраняется заранее
+			copy(x.tmp, in)
+			x.b.Decrypt(out, in)
+			xorBytes(out, out, x.iv)
+			x.iv, x.tmp = x.tmp, x.iv
+		} else {
+			xorBytes(x.tmp, in, x.iv)
+			x.b.Encrypt(out, x.tmp)
+			copy(x.iv, out)
+		}
+	}
+}
+
+// CFB с сегментом в один блок: C_i = P_i ⊕ E(C_{i-1}), C_0 = IV.
+type cfb struct {
+	b       cipher.Block
+	next    []byte // вход шифра для следующего блока гаммы
+	out     []byte // текущий блок гаммы
+	used    int
+	decrypt bool
+}
+
+func newCFB(b cipher.Block, iv []byte, decrypt bool) cipher.Stream {
+	bs := b.BlockSize()
+	return &cfb{
+		b:       b,
+		next:    append([]byte(nil), iv...),
+		out:     make([]byte, bs),
+		used:    bs,
+		decrypt: decrypt,
+	}
+}
+
+func (x *cfb) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if x.used == len(x.out) {
+			x.b.Encrypt(x.out, x.next)
+			x.used = 0
+		}
+		c := src[i]
+		dst[i] = c ^ x.out[x.used]
+		if !x.decrypt {
+			c = dst[i]
+		}
+		// Шифртекст становится входом для следующего блока гаммы
+		x.next[x.used] = c
+		x.used++
+	}
+}
+
+// OFB: гамма O_i = E(O_{i-1}), O_0 = IV, не зависит от текста.
+type ofb struct {
+	b    cipher.Block
+	out  []byte
+	used int
+}
+
+func newOFB(b cipher.Block, iv []byte) cipher.Stream {
+	return &ofb{b: b, out: append([]byte(nil), iv...), used: b.BlockSize()}
+}
+
+func (x *ofb) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if x.used == len(x.out) {
+			x.b.Encrypt(x.out, x.out)
+			x.used = 0
+		}
+		dst[i] = src[i] ^ x.out[x.used]
+		x.used++
+	}
+}
+
+// CTR: гамма E(IV + i), счетчик — IV как 64-битное число big-endian.
+type ctr struct {
+	b       cipher.Block
+	counter []byte
+	out     []byte
+	used    int
+}
+
+func newCTR(b cipher.Block, iv []byte) cipher.Stream {
+	return &ctr{
+		b:       b,
+		counter: append([]byte(nil), iv...),
+		out:     make([]byte, b.BlockSize()),
+		used:    b.BlockSize(),
+	}
+}
+
+func (x *ctr) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if x.used == len(x.out) {
+			x.b.Encrypt(x.out, x.counter)
+			binary.BigEndian.PutUint64(x.counter, binary.BigEndian.Uint64(x.counter)+1)
+			x.used = 0
+		}
+		dst[i] = src[i] ^ x.out[x.used]
+		x.used++
+	}
+}
+
+func xorBytes(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
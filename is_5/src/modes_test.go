@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rand"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// randomBytes возвращает size случайных байт.
+func randomBytes(size int) []byte {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// randomDES создает DES и crypto/des со случайным общим ключом.
+func randomDES() (*DES, cipher.Block, error) {
+	key := randomBytes(8)
+	std, err := des.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewDES(binary.BigEndian.Uint64(key)), std, nil
+}
+
+func TestBlockAgainstStdlib(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d, std, err := randomDES()
+		if err != nil {
+			t.Fatal(err)
+		}
+		src := randomBytes(blockSize)
+		got, want := make([]byte, blockSize), make([]byte, blockSize)
+		d.Encrypt(got, src)
+		std.Encrypt(want, src)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Encrypt(%x) = %x, ожидается %x", src, got, want)
+		}
+		d.Decrypt(got, want)
+		if !bytes.Equal(got, src) {
+			t.Fatalf("Decrypt(%x) = %x, ожидается %x", want, got, src)
+		}
+	}
+}
+
+func TestECB(t *testing.T) {
+	d, std, err := randomDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := randomBytes(64 * blockSize)
+	want := make([]byte, len(src))
+	for i := 0; i < len(src); i += blockSize {
+		std.Encrypt(want[i:], src[i:])
+	}
+	got := make([]byte, len(src))
+	newECBEncrypter(d).CryptBlocks(got, src)
+	if !bytes.Equal(got, want) {
+		t.Fatal("шифртекст не совпадает")
+	}
+	newECBDecrypter(d).CryptBlocks(got, got)
+	if !bytes.Equal(got, src) {
+		t.Fatal("расшифровка не совпадает с исходным текстом")
+	}
+}
+
+// stdlibCrypter возвращает реализацию режима из crypto/cipher.
+func stdlibCrypter(b cipher.Block, mode Mode, iv []byte, encrypt bool) func(dst, src []byte) {
+	switch {
+	case mode == ModeCBC && encrypt:
+		return cipher.NewCBCEncrypter(b, iv).CryptBlocks
+	case mode == ModeCBC:
+		return cipher.NewCBCDecrypter(b, iv).CryptBlocks
+	case mode == ModeCFB && encrypt:
+		return cipher.NewCFBEncrypter(b, iv).XORKeyStream
+	case mode == ModeCFB:
+		return cipher.NewCFBDecrypter(b, iv).XORKeyStream
+	case mode == ModeOFB:
+		return cipher.NewOFB(b, iv).XORKeyStream
+	default:
+		return cipher.NewCTR(b, iv).XORKeyStream
+	}
+}
+
+// TestModesAgainstStdlib сравнивает режимы с crypto/cipher поверх нашего
+// DES. Данные подаются фрагментами разной длины, чтобы проверить перенос
+// состояния между вызовами.
+func TestModesAgainstStdlib(t *testing.T) {
+	for _, mode := range []Mode{ModeCBC, ModeCFB, ModeOFB, ModeCTR} {
+		d, _, err := randomDES()
+		if err != nil {
+			t.Fatal(err)
+		}
+		iv := randomBytes(blockSize)
+		src := randomBytes(100 * blockSize)
+		want := make([]byte, len(src))
+		stdlibCrypter(d, mode, iv, true)(want, src)
+
+		chunks := []int{1, 7, 8, 13, 64, 3}
+		if !mode.isStream() {
+			chunks = []int{8, 16, 64, 24}
+		}
+		for _, encrypt := range []bool{true, false} {
+			crypt, err := newModeCrypter(d, mode, iv, encrypt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			in, expect := src, want
+			if !encrypt {
+				in, expect = want, src
+			}
+			got := make([]byte, len(in))
+			for off, i := 0, 0; off < len(in); i++ {
+				n := min(chunks[i%len(chunks)], len(in)-off)
+				crypt(got[off:off+n], in[off:off+n])
+				off += n
+			}
+			if !bytes.Equal(got, expect) {
+				t.Fatalf("%v: результат не совпадает с crypto/cipher (encrypt=%v)", mode, encrypt)
+			}
+		}
+	}
+}
+
+// TestCBCHidesRepeats: в ECB одинаковые блоки открытого текста дают
+// одинаковые блоки шифртекста, в CBC — нет.
+func TestCBCHidesRepeats(t *testing.T) {
+	d, _, err := randomDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := bytes.Repeat([]byte("ABCDEFGH"), 4)
+	for _, mode := range []Mode{ModeECB, ModeCBC} {
+		crypt, err := newModeCrypter(d, mode, randomBytes(blockSize), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := make([]byte, len(src))
+		crypt(dst, src)
+		repeats := bytes.Equal(dst[:blockSize], dst[blockSize:2*blockSize])
+		if repeats != (mode == ModeECB) {
+			t.Fatalf("%v: повтор блоков шифртекста = %v", mode, repeats)
+		}
+	}
+}
+
+// TestLegacyFile: файл без заголовка расшифровывается как ECB без снятия
+// дополнения.
+func TestLegacyFile(t *testing.T) {
+	d, std, err := randomDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := randomBytes(64 * blockSize)
+	legacy := make([]byte, len(data))
+	for i := 0; i < len(data); i += blockSize {
+		std.Encrypt(legacy[i:], data[i:])
+	}
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "legacy")
+	decrypted := filepath.Join(dir, "decrypted")
+	if err := os.WriteFile(encrypted, legacy, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := decryptFile(d, encrypted, decrypted); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("расшифрованный файл не совпадает с исходным")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+)
+
+// errInvalidPadding дополнение PKCS#7 повреждено: неверный ключ или файл
+var errInvalidPadding = errors.New("неверное дополнение PKCS#7: неверный ключ или поврежденный файл")
+
+// pkcs7Pad дополняет data до длины, кратной size, байтами со значением
+// длины дополнения. Если длина уже кратна size, добавляется целый блок,
+// иначе расшифровка не отличит дополнение от данных.
+func pkcs7Pad(data []byte, size int) []byte {
+	pad := size - len(data)%size
+	return append(data, bytes.Repeat([]byte{byte(pad)}, pad)...)
+}
+
+// pkcs7Unpad проверяет и снимает дополнение PKCS#7.
+func pkcs7Unpad(data []byte, size int) ([]byte, error) {
+	if len(data) == 0 || len(data)%size != 0 {
+		return nil, errInvalidPadding
+	}
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > size {
+		return nil, errInvalidPadding
+	}
+	for _, b := range data[len(data)-pad:] {
+		if int(b) != pad {
+			return nil, errInvalidPadding
+		}
+	}
+	return data[:len(data)-pad], nil
+}
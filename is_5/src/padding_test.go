@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPKCS7(t *testing.T) {
+	for size := 0; size <= 2*blockSize; size++ {
+		data := randomBytes(size)
+		padded := pkcs7Pad(bytes.Clone(data), blockSize)
+		pad := blockSize - size%blockSize
+		if len(padded) != size+pad || padded[len(padded)-1] != byte(pad) {
+			t.Fatalf("%d байт: неверное дополнение %x", size, padded[size:])
+		}
+		got, err := pkcs7Unpad(padded, blockSize)
+		if err != nil {
+			t.Fatalf("%d байт: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%d байт: после снятия дополнения данные не совпадают", size)
+		}
+	}
+}
+
+func TestPKCS7Invalid(t *testing.T) {
+	for _, bad := range [][]byte{
+		nil,
+		{1, 2, 3},
+		{1, 2, 3, 4, 5, 6, 7, 0},
+		{1, 2, 3, 4, 5, 6, 7, 9},
+		{1, 2, 3, 4, 5, 6, 2, 3},
+		{8, 8, 8, 8, 8, 8, 8, 7},
+	} {
+		if _, err := pkcs7Unpad(bad, blockSize); err != errInvalidPadding {
+			t.Errorf("pkcs7Unpad(%x): %v, ожидается errInvalidPadding", bad, err)
+		}
+	}
+}
+
+// TestDecryptBadPadding: файл, последний блок которого расшифровывается в
+// неверное дополнение, отвергается с errInvalidPadding.
+func TestDecryptBadPadding(t *testing.T) {
+	d, _, err := randomDES()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := []byte{'A', 'B', 'C', 'D', 'E', 'F', 'G', 0}
+	d.Encrypt(block, block)
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "encrypted")
+	header := append([]byte(fileMagic+"\x01"), keyCheckValue(d)...)
+	if err := os.WriteFile(encrypted, append(header, block...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = decryptFile(d, encrypted, filepath.Join(dir, "decrypted"))
+	if !errors.Is(err, errInvalidPadding) {
+		t.Fatalf("получено %v, ожидается errInvalidPadding", err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+)
+
+// Варианты ключа, записываемые в файл ключа
+const (
+	variantDES      = "des"
+	variant3DESEDE2 = "3des-ede2"
+	variant3DESEDE3 = "3des-ede3"
+)
+
+// variantKeySize длина ключа варианта в байтах
+var variantKeySize = map[string]int{
+	variantDES:      8,
+	variant3DESEDE2: 16,
+	variant3DESEDE3: 24,
+}
+
+// TripleDES тройной DES (TDEA, NIST SP 800-67) по схеме EDE:
+// C = E_k3(D_k2(E_k1(P))). Ключ из 16 байт (EDE2) задает k1, k2 и k3 = k1,
+// ключ из 24 байт (EDE3) — три независимых ключа. При k1 = k2 = k3 схема
+// совпадает с одинарным DES.
+type TripleDES struct {
+	k1, k2, k3 *DES
+}
+
+// NewTripleDES создает TripleDES с ключом длиной 16 или 24 байта.
+func NewTripleDES(key []byte) (*TripleDES, error) {
+	var k1, k2, k3 uint64
+	switch len(key) {
+	case 16:
+		k1 = binary.BigEndian.Uint64(key[0:])
+		k2 = binary.BigEndian.Uint64(key[8:])
+		k3 = k1
+	case 24:
+		k1 = binary.BigEndian.Uint64(key[0:])
+		k2 = binary.BigEndian.Uint64(key[8:])
+		k3 = binary.BigEndian.Uint64(key[16:])
+	default:
+		return nil, fmt.Errorf("неправильная длина ключа 3DES: %d байт, ожидается 16 или 24", len(key))
+	}
+	return &TripleDES{k1: NewDES(k1), k2: NewDES(k2), k3: NewDES(k3)}, nil
+}
+
+func (t *TripleDES) BlockSize() int {
+	return blockSize
+}
+
+// Encrypt шифрует первый блок src в dst: E_k3(D_k2(E_k1(P)))
+func (t *TripleDES) Encrypt(dst, src []byte) {
+	if len(src) < blockSize || len(dst) < blockSize {
+		panic("des: неполный блок")
+	}
+	block := binary.BigEndian.Uint64(src)
+	block = t.k1.encryptBlock(block)
+	block = t.k2.decryptBlock(block)
+	block = t.k3.encryptBlock(block)
+	binary.BigEndian.PutUint64(dst, block)
+}
+
+// Decrypt расшифровывает первый блок src в dst: D_k1(E_k2(D_k3(C)))
+func (t *TripleDES) Decrypt(dst, src []byte) {
+	if len(src) < blockSize || len(dst) < blockSize {
+		panic("des: неполный блок")
+	}
+	block := binary.BigEndian.Uint64(src)
+	block = t.k3.decryptBlock(block)
+	block = t.k2.encryptBlock(block)
+	block = t.k1.decryptBlock(block)
+	binary.BigEndian.PutUint64(dst, block)
+}
+
+// newBlockCipher создает шифр варианта variant с ключом key.
+func newBlockCipher(variant string, key []byte) (cipher.Block, error) {
+	if size, ok := variantKeySize[variant]; !ok {
+		return nil, fmt.Errorf("неизвестный вариант ключа %q", variant)
+	} else if len(key) != size {
+		return nil, fmt.Errorf("неправильный размер ключа %s: ожидается %d байт, получено %d", variant, size, len(key))
+	}
+	if variant == variantDES {
+		return NewDES(binary.BigEndian.Uint64(key)), nil
+	}
+	return NewTripleDES(key)
+}
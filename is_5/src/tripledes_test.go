@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"crypto/des"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestTripleDESVector: пример из приложения B NIST SP 800-67 (ECB, три
+// независимых ключа, опечатка "qufck" присутствует в стандарте).
+func TestTripleDESVector(t *testing.T) {
+	key, _ := hex.DecodeString("0123456789ABCDEF" + "23456789ABCDEF01" + "456789ABCDEF0123")
+	plain := []byte("The qufck brown fox jump")
+	want, _ := hex.DecodeString("A826FD8CE53B855F" + "CCE21C8112256FE6" + "68D5C05DD9B6B900")
+
+	tdes, err := NewTripleDES(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(plain))
+	newECBEncrypter(tdes).CryptBlocks(got, plain)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("шифртекст %X, ожидается %X", got, want)
+	}
+	newECBDecrypter(tdes).CryptBlocks(got, got)
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("расшифровка %q, ожидается %q", got, plain)
+	}
+}
+
+func TestTripleDESAgainstStdlib(t *testing.T) {
+	for _, size := range []int{16, 24} {
+		for i := 0; i < 50; i++ {
+			key := randomBytes(size)
+			tdes, err := NewTripleDES(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// crypto/des принимает только 24 байта: EDE2 — это k1 || k2 || k1
+			stdKey := key
+			if size == 16 {
+				stdKey = append(bytes.Clone(key), key[:8]...)
+			}
+			std, err := des.NewTripleDESCipher(stdKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			src := randomBytes(blockSize)
+			got, want := make([]byte, blockSize), make([]byte, blockSize)
+			tdes.Encrypt(got, src)
+			std.Encrypt(want, src)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("ключ %d байт: Encrypt(%x) = %x, ожидается %x", size, src, got, want)
+			}
+			tdes.Decrypt(got, want)
+			if !bytes.Equal(got, src) {
+				t.Fatalf("ключ %d байт: Decrypt(%x) = %x, ожидается %x", size, want, got, src)
+			}
+		}
+	}
+}
+
+func TestNewTripleDESKeySize(t *testing.T) {
+	for _, size := range []int{0, 8, 15, 17, 23, 25, 32} {
+		if _, err := NewTripleDES(make([]byte, size)); err == nil {
+			t.Errorf("ключ %d байт принят", size)
+		}
+	}
+}
+
+// TestTripleDESDegenerate: EDE с одинаковыми ключами — обратная
+// совместимость с одинарным DES.
+func TestTripleDESDegenerate(t *testing.T) {
+	key := randomBytes(8)
+	d := NewDES(binary.BigEndian.Uint64(key))
+	tdes, err := NewTripleDES(bytes.Repeat(key, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := randomBytes(blockSize)
+	got, want := make([]byte, blockSize), make([]byte, blockSize)
+	tdes.Encrypt(got, src)
+	d.Encrypt(want, src)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("3DES %x, DES %x", got, want)
+	}
+}
+
+// TestTripleDESFile: ключ каждого варианта сохраняется в файл, читается
+// обратно с тем же вариантом и шифрует файл.
+func TestTripleDESFile(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	plain := filepath.Join(dir, "plain")
+	encrypted := filepath.Join(dir, "encrypted")
+	decrypted := filepath.Join(dir, "decrypted")
+	data := randomBytes(1000)
+	if err := os.WriteFile(plain, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, variant := range []string{variantDES, variant3DESEDE2, variant3DESEDE3} {
+		key, err := generateAndSaveKey(keyFile, variant, encodingHex, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotVariant, gotKey, err := readKeyFromFile(keyFile, "")
+		if err != nil {
+			t.Fatalf("%s: %v", variant, err)
+		}
+		if gotVariant != variant || !bytes.Equal(gotKey, key) {
+			t.Fatalf("%s: прочитан ключ %s:%x", variant, gotVariant, gotKey)
+		}
+		b, err := newBlockCipher(gotVariant, gotKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := encryptFile(b, plain, encrypted, ModeCBC); err != nil {
+			t.Fatalf("%s: %v", variant, err)
+		}
+		if err := decryptFile(b, encrypted, decrypted); err != nil {
+			t.Fatalf("%s: %v", variant, err)
+		}
+		got, err := os.ReadFile(decrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%s: расшифрованный файл не совпадает с исходным", variant)
+		}
+	}
+}
+
+// TestOldKeyFile: hex-ключ старого формата без варианта читается как DES.
+func TestOldKeyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("a4aac0663d5d7249\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	variant, _, err := readKeyFromFile(keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if variant != variantDES {
+		t.Fatalf("старый файл ключа прочитан как %s", variant)
+	}
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"crypto/cipher"
 	"crypto/rand"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 // AES константы
@@ -297,56 +302,158 @@ func addRoundKey(state *AESState, roundKey []byte) {
 	}
 }
 
-// Основные функции шифрования/дешифрования
-func aesEncryptBlock(plaintext []byte, key []byte, keySize AESKeySize) ([]byte, error) {
-	if len(plaintext) != AESBlockSize {
-		return nil, errors.New("plaintext must be 16 bytes")
-	}
+// AES экземпляр шифра с ключом, расширенным один раз при создании.
+// Реализует crypto/cipher.Block. Encrypt и Decrypt не меняют состояние,
+// один экземпляр можно использовать из нескольких горутин. После работы
+// расписание ключей нужно стереть вызовом Destroy.
+type AES struct {
+	expandedKey []byte
+	numRounds   int
+}
 
-	var numRounds int
+var _ cipher.Block = (*AES)(nil)
+
+// aesNumRounds число раундов для размера ключа
+func aesNumRounds(keySize AESKeySize) (int, error) {
 	switch keySize {
 	case AESKeySize128:
-		numRounds = AESRounds128
+		return AESRounds128, nil
 	case AESKeySize192:
-		numRounds = AESRounds192
+		return AESRounds192, nil
 	case AESKeySize256:
-		numRounds = AESRounds256
-	default:
-		return nil, errors.New("unsupported key size")
+		return AESRounds256, nil
 	}
+	return 0, errors.New("unsupported key size")
+}
 
-	expandedKey, err := aesExpandKey(key, keySize)
+// NewAES создает шифр с ключом 16, 24 или 32 байта
+func NewAES(key []byte) (*AES, error) {
+	numRounds, err := aesNumRounds(AESKeySize(len(key)))
+	if err != nil {
+		return nil, err
+	}
+	expandedKey, err := aesExpandKey(key, AESKeySize(len(key)))
 	if err != nil {
 		return nil, err
 	}
-	defer secureZeroMemory(expandedKey)
+	return &AES{expandedKey: expandedKey, numRounds: numRounds}, nil
+}
 
-	var state AESState
-	for r := 0; r < AESStateDim; r++ {
-		for c := 0; c < AESStateDim; c++ {
-			state[r][c] = plaintext[r+AESStateDim*c]
-		}
+func (a *AES) BlockSize() int {
+	return AESBlockSize
+}
+
+// Destroy обнуляет расписание ключей. После вызова шифр использовать нельзя.
+func (a *AES) Destroy() {
+	secureZeroMemory(a.expandedKey)
+	a.expandedKey = nil
+}
+
+func (a *AES) checkBlock(dst, src []byte) {
+	if a.expandedKey == nil {
+		panic("aes: ключ уничтожен")
+	}
+	if len(src) < AESBlockSize || len(dst) < AESBlockSize {
+		panic("aes: неполный блок")
 	}
+}
+
+// Encrypt шифрует первый блок src в dst
+func (a *AES) Encrypt(dst, src []byte) {
+	a.encryptTrace(dst, src, nil)
+}
+
+// encryptTrace шифрует блок и, если hook не nil, вызывает его после
+// каждого раунда с номером раунда (0 — начальное AddRoundKey) и
+// состоянием. Используется для анализа лавинного эффекта.
+func (a *AES) encryptTrace(dst, src []byte, hook func(round int, state *AESState)) {
+	a.checkBlock(dst, src)
+	state := loadState(src)
 
 	// Шифрование
-	addRoundKey(&state, expandedKey)
-	for round := 1; round < numRounds; round++ {
+	addRoundKey(&state, a.expandedKey)
+	if hook != nil {
+		hook(0, &state)
+	}
+	for round := 1; round < a.numRounds; round++ {
 		subBytes(&state)
 		shiftRows(&state)
 		mixColumns(&state)
-		addRoundKey(&state, expandedKey[AESBlockSize*round:])
+		addRoundKey(&state, a.expandedKey[AESBlockSize*round:])
+		if hook != nil {
+			hook(round, &state)
+		}
 	}
 	subBytes(&state)
 	shiftRows(&state)
-	addRoundKey(&state, expandedKey[AESBlockSize*numRounds:])
+	addRoundKey(&state, a.expandedKey[AESBlockSize*a.numRounds:])
+	if hook != nil {
+		hook(a.numRounds, &state)
+	}
 
-	ciphertext := make([]byte, AESBlockSize)
+	storeState(dst, &state)
+}
+
+// Decrypt расшифровывает первый блок src в dst
+func (a *AES) Decrypt(dst, src []byte) {
+	a.checkBlock(dst, src)
+	state := loadState(src)
+
+	// Дешифрование
+	addRoundKey(&state, a.expandedKey[AESBlockSize*a.numRounds:])
+	for round := a.numRounds; round > 1; round-- {
+		invShiftRows(&state)
+		invSubBytes(&state)
+		addRoundKey(&state, a.expandedKey[AESBlockSize*(round-1):])
+		invMixColumns(&state)
+	}
+	invShiftRows(&state)
+	invSubBytes(&state)
+	addRoundKey(&state, a.expandedKey)
+
+	storeState(dst, &state)
+}
+
+// loadState раскладывает блок в состояние по столбцам
+func loadState(block []byte) AESState {
+	var state AESState
 	for r := 0; r < AESStateDim; r++ {
 		for c := 0; c < AESStateDim; c++ {
-			ciphertext[r+AESStateDim*c] = state[r][c]
+			state[r][c] = block[r+AESStateDim*c]
 		}
 	}
+	return state
+}
+
+// storeState записывает состояние в блок по столбцам
+func storeState(block []byte, state *AESState) {
+	for r := 0; r < AESStateDim; r++ {
+		for c := 0; c < AESStateDim; c++ {
+			block[r+AESStateDim*c] = state[r][c]
+		}
+	}
+}
+
+// Основные функции шифрования/дешифрования одного блока. Ключ
+// расширяется при каждом вызове; для потока блоков используйте NewAES.
+func aesEncryptBlock(plaintext []byte, key []byte, keySize AESKeySize) ([]byte, error) {
+	return aesEncryptBlockTrace(plaintext, key, keySize, nil)
+}
+
+// aesEncryptBlockTrace то же, что aesEncryptBlock, с вызовом hook после
+// каждого раунда (см. AES.encryptTrace)
+func aesEncryptBlockTrace(plaintext []byte, key []byte, keySize AESKeySize, hook func(round int, state *AESState)) ([]byte, error) {
+	if len(plaintext) != AESBlockSize {
+		return nil, errors.New("plaintext must be 16 bytes")
+	}
+	a, err := newAESWithSize(key, keySize)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Destroy()
 
+	ciphertext := make([]byte, AESBlockSize)
+	a.encryptTrace(ciphertext, plaintext, hook)
 	return ciphertext, nil
 }
 
@@ -354,56 +461,102 @@ func aesDecryptBlock(ciphertext []byte, key []byte, keySize AESKeySize) ([]byte,
 	if len(ciphertext) != AESBlockSize {
 		return nil, errors.New("ciphertext must be 16 bytes")
 	}
-
-	var numRounds int
-	switch keySize {
-	case AESKeySize128:
-		numRounds = AESRounds128
-	case AESKeySize192:
-		numRounds = AESRounds192
-	case AESKeySize256:
-		numRounds = AESRounds256
-	default:
-		return nil, errors.New("unsupported key size")
-	}
-
-	expandedKey, err := aesExpandKey(key, keySize)
+	a, err := newAESWithSize(key, keySize)
 	if err != nil {
 		return nil, err
 	}
-	defer secureZeroMemory(expandedKey)
+	defer a.Destroy()
 
-	var state AESState
-	for r := 0; r < AESStateDim; r++ {
-		for c := 0; c < AESStateDim; c++ {
-			state[r][c] = ciphertext[r+AESStateDim*c]
-		}
-	}
+	plaintext := make([]byte, AESBlockSize)
+	a.Decrypt(plaintext, ciphertext)
+	return plaintext, nil
+}
 
-	// Дешифрование
-	addRoundKey(&state, expandedKey[AESBlockSize*numRounds:])
-	for round := numRounds; round > 1; round-- {
-		invShiftRows(&state)
-		invSubBytes(&state)
-		addRoundKey(&state, expandedKey[AESBlockSize*(round-1):])
-		invMixColumns(&state)
+// newAESWithSize NewAES с проверкой, что длина ключа равна keySize
+func newAESWithSize(key []byte, keySize AESKeySize) (*AES, error) {
+	if _, err := aesNumRounds(keySize); err != nil {
+		return nil, err
 	}
-	invShiftRows(&state)
-	invSubBytes(&state)
-	addRoundKey(&state, expandedKey)
+	if len(key) != int(keySize) {
+		return nil, errors.New("key length does not match key size")
+	}
+	return NewAES(key)
+}
 
-	plaintext := make([]byte, AESBlockSize)
-	for r := 0; r < AESStateDim; r++ {
-		for c := 0; c < AESStateDim; c++ {
-			plaintext[r+AESStateDim*c] = state[r][c]
-		}
+// Функции для работы с файлами
+//
+// Режимы шифрования файлов. GCM — по умолчанию, единственный с
+// аутентификацией данных; остальные не обнаруживают их изменения.
+const (
+	ModeGCM = "gcm"
+	ModeCBC = "cbc"
+	ModeECB = "ecb"
+	ModeCTR = "ctr"
+	ModeCFB = "cfb"
+	ModeOFB = "ofb"
+	ModeXTS = "xts"
+)
+
+// Формат файла описан в container.go. Файлы старого формата (IV и
+// шифртекст CBC без заголовка) расшифровываются только явно:
+// decryptLegacyFile, флаг -legacy команды decrypt.
+
+// encryptFile шифрует файл в режиме mode (ModeGCM, ModeCBC, ...) ключом
+// из файла ключа
+func encryptFile(inputPath, outputPath string, key []byte, keySize AESKeySize, mode string) error {
+	m, err := modeByName(mode)
+	if err != nil {
+		return err
+	}
+	if len(key) != int(keySize) {
+		return errors.New("invalid key size")
 	}
+	if err := checkKeySize(m, len(key)); err != nil {
+		return err
+	}
+	return encryptContainer(inputPath, outputPath, key, m, kdfParams{ID: kdfNone})
+}
 
-	return plaintext, nil
+// encryptFileWithPassword шифрует файл ключом длины keyBits, полученным
+// из пароля; соль и параметры KDF записываются в заголовок
+func encryptFileWithPassword(inputPath, outputPath string, password []byte, kdf kdfParams, keyBits int, mode string) error {
+	m, err := modeByName(mode)
+	if err != nil {
+		return err
+	}
+	if keyBits%BitsPerByte != 0 {
+		return fmt.Errorf("неверная длина ключа %d бит", keyBits)
+	}
+	if err := checkKeySize(m, keyBits/BitsPerByte); err != nil {
+		return err
+	}
+	if err := newKDFSalt(&kdf); err != nil {
+		return err
+	}
+	key, err := deriveKey(kdf, password, keyBits/BitsPerByte)
+	if err != nil {
+		return err
+	}
+	defer secureZeroMemory(key)
+	return encryptContainer(inputPath, outputPath, key, m, kdf)
 }
 
-// Функции для работы с файлами
-func encryptFile(inputPath, outputPath string, key []byte, keySize AESKeySize) error {
+// encryptContainer записывает заголовок и шифртекст в режиме m
+func encryptContainer(inputPath, outputPath string, key []byte, m Mode, kdf kdfParams) error {
+	h := &fileHeader{
+		Version:   containerVersion,
+		Cipher:    cipherAES,
+		Mode:      m.ID(),
+		KeyBits:   len(key) * BitsPerByte,
+		KDF:       kdf,
+		ChunkSize: uint32(m.ChunkSize()),
+		IV:        make([]byte, m.IVSize()),
+	}
+	if _, err := rand.Read(h.IV); err != nil {
+		return err
+	}
+	header := h.seal(key)
+
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
 		return err
@@ -415,132 +568,156 @@ func encryptFile(inputPath, outputPath string, key []byte, keySize AESKeySize) e
 		return err
 	}
 	defer outputFile.Close()
-
-	// Генерируем случайный IV (Initialization Vector)
-	iv := make([]byte, AESBlockSize)
-	if _, err := rand.Read(iv); err != nil {
+	writer := bufio.NewWriter(outputFile)
+	if _, err := writer.Write(header); err != nil {
 		return err
 	}
-
-	// Записываем IV в начало файла
-	if _, err := outputFile.Write(iv); err != nil {
+	if err := m.Encrypt(writer, inputFile, key, h.IV, header); err != nil {
 		return err
 	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return outputFile.Close()
+}
 
-	buffer := make([]byte, AESBlockSize)
-	previousBlock := make([]byte, AESBlockSize)
-	copy(previousBlock, iv)
-
-	for {
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
-			return err
+// decryptFile расшифровывает файл ключом из файла ключа
+func decryptFile(inputPath, outputPath string, key []byte, keySize AESKeySize) error {
+	return decryptContainer(inputPath, outputPath, func(h *fileHeader) ([]byte, error) {
+		if h.KDF.ID != kdfNone {
+			return nil, errors.New("файл зашифрован паролем: укажите -password, -password-file или -password-env")
 		}
+		if h.KeyBits != len(key)*BitsPerByte {
+			return nil, fmt.Errorf("файл зашифрован ключом %d бит, а загружен ключ %d бит", h.KeyBits, len(key)*BitsPerByte)
+		}
+		if len(key) != int(keySize) {
+			return nil, errors.New("invalid key size")
+		}
+		m, err := modeByID(h.Mode)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkKeySize(m, len(key)); err != nil {
+			return nil, err
+		}
+		return key, nil
+	})
+}
 
-		if n == 0 {
-			break
+// decryptFileWithPassword расшифровывает файл ключом, полученным из пароля
+// по параметрам KDF из заголовка. Параметры, требующие больше maxMemory
+// байт, отвергаются до запуска KDF.
+func decryptFileWithPassword(inputPath, outputPath string, password []byte, maxMemory uint64) error {
+	var key []byte
+	defer func() { secureZeroMemory(key) }()
+	return decryptContainer(inputPath, outputPath, func(h *fileHeader) ([]byte, error) {
+		if h.KDF.ID == kdfNone {
+			return nil, errors.New("файл зашифрован файлом ключа, а не паролем")
 		}
+		m, err := modeByID(h.Mode)
+		if err != nil {
+			return nil, err
+		}
+		if h.KeyBits%BitsPerByte != 0 || checkKeySize(m, h.KeyBits/BitsPerByte) != nil {
+			return nil, fmt.Errorf("неверная длина ключа %d бит в заголовке", h.KeyBits)
+		}
+		if err := checkKDFMemory(h.KDF, maxMemory); err != nil {
+			return nil, fmt.Errorf("%w; увеличьте -max-kdf-memory, если файлу можно доверять", err)
+		}
+		key, err = deriveKey(h.KDF, password, h.KeyBits/BitsPerByte)
+		return key, err
+	})
+}
 
-		// Дополнение PKCS7 для последнего блока
-		if n < AESBlockSize {
-			padding := byte(AESBlockSize - n)
-			for i := n; i < AESBlockSize; i++ {
-				buffer[i] = padding
+// decryptContainer расшифровывает файл; режим и IV берутся из заголовка,
+// ключ — из keyFor. До расшифровки проверяются шифр, режим и MAC заголовка.
+func decryptContainer(inputPath, outputPath string, keyFor func(h *fileHeader) ([]byte, error)) error {
+	return decryptTo(inputPath, outputPath, func(w io.Writer, r *bufio.Reader, size int64) error {
+		h, header, err := readFileHeader(r)
+		if err != nil {
+			return err
+		}
+		if h.Cipher != cipherAES {
+			name, ok := cipherNames[h.Cipher]
+			if !ok {
+				name = fmt.Sprintf("с кодом %d", h.Cipher)
 			}
+			return fmt.Errorf("файл зашифрован шифром %s, а не AES", name)
 		}
-
-		// Режим CBC: XOR с предыдущим зашифрованным блоком
-		for i := 0; i < AESBlockSize; i++ {
-			buffer[i] ^= previousBlock[i]
+		m, err := modeByID(h.Mode)
+		if err != nil {
+			return err
 		}
-
-		encryptedBlock, err := aesEncryptBlock(buffer, key, keySize)
+		if int(h.ChunkSize) != m.ChunkSize() {
+			return fmt.Errorf("%s: неподдерживаемый размер части %d байт", m.Name(), h.ChunkSize)
+		}
+		if len(h.IV) != m.IVSize() {
+			return fmt.Errorf("%s: неверная длина IV %d байт", m.Name(), len(h.IV))
+		}
+		key, err := keyFor(h)
 		if err != nil {
 			return err
 		}
-
-		if _, err := outputFile.Write(encryptedBlock); err != nil {
+		if err := h.verify(key); err != nil {
 			return err
 		}
+		return m.Decrypt(w, r, size-int64(len(header)), key, h.IV, header)
+	})
+}
 
-		copy(previousBlock, encryptedBlock)
+// decryptLegacyFile расшифровывает файл старого формата: IV и шифртекст
+// CBC без заголовка. Формат не опознается, поэтому выбирается явно.
+func decryptLegacyFile(inputPath, outputPath string, key []byte, keySize AESKeySize) error {
+	if len(key) != int(keySize) {
+		return errors.New("invalid key size")
 	}
-
-	return nil
+	return decryptTo(inputPath, outputPath, func(w io.Writer, r *bufio.Reader, size int64) error {
+		iv := make([]byte, AESBlockSize)
+		if _, err := io.ReadFull(r, iv); err != nil {
+			return errors.New("invalid encrypted file size")
+		}
+		return legacyCBC.Decrypt(w, r, size-AESBlockSize, key, iv, nil)
+	})
 }
 
-func decryptFile(inputPath, outputPath string, key []byte, keySize AESKeySize) error {
+// decryptTo выполняет decrypt над входным файлом размера size. Результат
+// пишется во временный файл рядом с outputPath, который переименовывается
+// только при успехе: при ошибке, в том числе неверном теге GCM или
+// дополнении, выходной файл не создается.
+func decryptTo(inputPath, outputPath string, decrypt func(w io.Writer, r *bufio.Reader, size int64) error) error {
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
 		return err
 	}
 	defer inputFile.Close()
-
-	outputFile, err := os.Create(outputPath)
+	info, err := inputFile.Stat()
 	if err != nil {
 		return err
 	}
-	defer outputFile.Close()
 
-	// Читаем IV из начала файла
-	iv := make([]byte, AESBlockSize)
-	if _, err := inputFile.Read(iv); err != nil {
+	tmp, err := os.CreateTemp(filepath.Dir(outputPath), ".aes-decrypt-*")
+	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name()) // после переименования ничего не удаляет
+	defer tmp.Close()
+	writer := bufio.NewWriter(tmp)
 
-	fileInfo, err := inputFile.Stat()
-	if err != nil {
+	if err := decrypt(writer, bufio.NewReader(inputFile), info.Size()); err != nil {
 		return err
 	}
-
-	fileSize := fileInfo.Size()
-	if fileSize%AESBlockSize != 0 || fileSize < int64(AESBlockSize) {
-		return errors.New("invalid encrypted file size")
+	if err := writer.Flush(); err != nil {
+		return err
 	}
-
-	previousBlock := make([]byte, AESBlockSize)
-	copy(previousBlock, iv)
-	buffer := make([]byte, AESBlockSize)
-
-	for {
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
-			return err
-		}
-
-		if n == 0 {
-			break
-		}
-
-		decryptedBlock, err := aesDecryptBlock(buffer, key, keySize)
-		if err != nil {
-			return err
-		}
-
-		// Режим CBC: XOR с предыдущим зашифрованным блоком
-		for i := 0; i < AESBlockSize; i++ {
-			decryptedBlock[i] ^= previousBlock[i]
-		}
-
-		copy(previousBlock, buffer)
-
-		// Обработка дополнения для последнего блока
-		bytesToWrite := AESBlockSize
-		if inputFile, err := inputFile.Seek(0, io.SeekCurrent); err == nil {
-			if inputFile == fileSize {
-				padding := decryptedBlock[AESBlockSize-1]
-				if padding > 0 && padding <= AESBlockSize {
-					bytesToWrite = AESBlockSize - int(padding)
-				}
-			}
-		}
-
-		if _, err := outputFile.Write(decryptedBlock[:bytesToWrite]); err != nil {
-			return err
-		}
+	// CreateTemp создает файл с правами 0600; расшифрованный файл, как и
+	// раньше при os.Create, получает 0644
+	if err := tmp.Chmod(0644); err != nil {
+		return err
 	}
-
-	return nil
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), outputPath)
 }
 
 // ---------------------------------------------------------------------------------------
@@ -562,20 +739,32 @@ func loadKeyFromFile(filename string) ([]byte, error) {
 		return nil, err
 	}
 
-	// Проверяем размер ключа
+	// Проверяем размер ключа; 64 байта — только для xts
 	switch len(key) {
-	case 16, 24, 32:
+	case 16, 24, 32, 64:
 		return key, nil
 	default:
-		return nil, errors.New("invalid key size. Must be 16, 24, or 32 bytes")
+		return nil, errors.New("invalid key size. Must be 16, 24, 32 or 64 (xts) bytes")
 	}
 }
 
 func printUsage() {
 	fmt.Println("Использование:")
-	fmt.Println("  Шифрование: go run aes.go encrypt <файл_ключа> <входной_файл> <выходной_файл>")
-	fmt.Println("  Дешифрование: go run aes.go decrypt <файл_ключа> <входной_файл> <выходной_файл>")
-	fmt.Println("  Генерация ключа: go run aes.go genkey <файл_ключа> [128|192|256]")
+	fmt.Println("  Шифрование: go run . encrypt <файл_ключа> <входной_файл> <выходной_файл> [-mode режим]")
+	fmt.Println("  Дешифрование: go run . decrypt <файл_ключа> <входной_файл> <выходной_файл> [-legacy]")
+	fmt.Println("  По паролю: go run . encrypt|decrypt <входной_файл> <выходной_файл> -password-env ИМЯ|-password-file ФАЙЛ|-password ПАРОЛЬ")
+	fmt.Println("             при шифровании [-kdf argon2id|scrypt|pbkdf2] [-kdf-params ...] [-keysize 128|192|256|512]")
+	fmt.Println("             при расшифровке [-max-kdf-memory МиБ] (по умолчанию 256)")
+	fmt.Println("  Подбор параметров KDF: go run . kdfbench [-target мс] [-max-memory МиБ]")
+	fmt.Println("  Заголовок файла: go run . info <зашифрованный_файл>")
+	fmt.Println("  Генерация ключа: go run . genkey <файл_ключа> [128|192|256|512]")
+	fmt.Println("  Лавинный эффект: go run . analyze avalanche [-samples N] [-keysize 128|192|256] [-csv префикс] [-json файл]")
+	fmt.Println("")
+	fmt.Println("Режимы: gcm (по умолчанию, с аутентификацией), cbc, ctr, cfb, ofb, xts (секторы")
+	fmt.Println("как на диске, ключ 256 или 512 бит), ecb (только для обучения). Ключ 512 бит —")
+	fmt.Println("только для xts (XTS-AES-256).")
+	fmt.Println("При расшифровке режим и IV определяются по заголовку файла; -legacy —")
+	fmt.Println("файл старого формата без заголовка (IV и шифртекст CBC).")
 }
 
 func main() {
@@ -588,25 +777,74 @@ func main() {
 
 	switch command {
 	case "encrypt":
-		if len(os.Args) != 5 {
+		positional, flags := splitArgs(os.Args[2:])
+		fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+		mode := fs.String("mode", ModeGCM, "режим: "+modeNames())
+		pf := addPasswordFlags(fs)
+		kdf := fs.String("kdf", "argon2id", "KDF для пароля: argon2id, scrypt или pbkdf2")
+		params := fs.String("kdf-params", "", "параметры KDF, например t=3,m=65536,p=4 (подбираются kdfbench)")
+		keyBits := fs.Int("keysize", 256, "длина ключа из пароля: 128, 192, 256 или 512 (только xts)")
+		fs.Parse(flags)
+		if len(positional) != 3 && !(len(positional) == 2 && pf.set()) || len(positional) == 3 && pf.set() {
 			fmt.Println("Ошибка: Неверное количество аргументов для шифрования")
-			fmt.Println("Использование: go run aes.go encrypt <файл_ключа> <входной_файл> <выходной_файл>")
+			fmt.Println("Использование: go run . encrypt <файл_ключа> <входной_файл> <выходной_файл> [-mode режим]")
+			fmt.Println("               go run . encrypt <входной_файл> <выходной_файл> -password-env ИМЯ [-kdf argon2id|scrypt|pbkdf2] [-kdf-params ...]")
+			os.Exit(1)
+		}
+		if !pf.set() {
+			encryptCommand(positional[0], positional[1], positional[2], *mode)
+			break
+		}
+		password := readPassword(pf)
+		id, err := kdfByName(*kdf)
+		if err != nil {
+			fmt.Printf("Ошибка: %v\n", err)
+			os.Exit(1)
+		}
+		kdfParams, err := parseKDFParams(id, *params)
+		if err != nil {
+			fmt.Printf("Ошибка: %v\n", err)
 			os.Exit(1)
 		}
-		encryptCommand(os.Args[2], os.Args[3], os.Args[4])
+		encryptPasswordCommand(password, kdfParams, *keyBits, positional[0], positional[1], *mode)
 
 	case "decrypt":
-		if len(os.Args) != 5 {
+		positional, flags := splitArgs(os.Args[2:])
+		fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+		legacy := fs.Bool("legacy", false, "файл старого формата без заголовка")
+		pf := addPasswordFlags(fs)
+		maxMemoryMiB := fs.Uint64("max-kdf-memory", defaultMaxKDFMemory>>20, "наибольшая память KDF при расшифровке, МиБ")
+		fs.Parse(flags)
+		if len(positional) != 3 && !(len(positional) == 2 && pf.set()) || len(positional) == 3 && pf.set() {
 			fmt.Println("Ошибка: Неверное количество аргументов для дешифрования")
-			fmt.Println("Использование: go run aes.go decrypt <файл_ключа> <входной_файл> <выходной_файл>")
+			fmt.Println("Использование: go run . decrypt <файл_ключа> <входной_файл> <выходной_файл> [-legacy]")
+			fmt.Println("               go run . decrypt <входной_файл> <выходной_файл> -password-env ИМЯ [-max-kdf-memory МиБ]")
+			os.Exit(1)
+		}
+		if !pf.set() {
+			decryptCommand(positional[0], positional[1], positional[2], *legacy)
+			break
+		}
+		if *maxMemoryMiB < 1 || *maxMemoryMiB > maxKDFMemory>>20 {
+			fmt.Printf("Ошибка: -max-kdf-memory должно быть от 1 до %d МиБ\n", maxKDFMemory>>20)
+			os.Exit(1)
+		}
+		decryptPasswordCommand(readPassword(pf), *maxMemoryMiB<<20, positional[0], positional[1])
+
+	case "info":
+		if len(os.Args) != 3 {
+			fmt.Println("Использование: go run . info <зашифрованный_файл>")
+			os.Exit(1)
+		}
+		if err := infoCommand(os.Stdout, os.Args[2]); err != nil {
+			fmt.Printf("Ошибка: %v\n", err)
 			os.Exit(1)
 		}
-		decryptCommand(os.Args[2], os.Args[3], os.Args[4])
 
 	case "genkey":
 		if len(os.Args) < 3 || len(os.Args) > 4 {
 			fmt.Println("Ошибка: Неверное количество аргументов для генерации ключа")
-			fmt.Println("Использование: go run aes.go genkey <файл_ключа> [128|192|256]")
+			fmt.Println("Использование: go run . genkey <файл_ключа> [128|192|256|512]")
 			os.Exit(1)
 		}
 		keySize := AESKeySize256 // По умолчанию 256 бит
@@ -614,7 +852,7 @@ func main() {
 			size, err := strconv.Atoi(os.Args[3])
 			if err != nil {
 				fmt.Printf("Ошибка: Неверный размер ключа: %s\n", os.Args[3])
-				fmt.Println("Допустимые значения: 128, 192, 256")
+				fmt.Println("Допустимые значения: 128, 192, 256, 512 (только xts)")
 				os.Exit(1)
 			}
 			switch size {
@@ -624,14 +862,28 @@ func main() {
 				keySize = AESKeySize192
 			case 256:
 				keySize = AESKeySize256
+			case 512:
+				keySize = XTSKeySize512
 			default:
 				fmt.Printf("Ошибка: Неподдерживаемый размер ключа: %d\n", size)
-				fmt.Println("Допустимые значения: 128, 192, 256")
+				fmt.Println("Допустимые значения: 128, 192, 256, 512 (только xts)")
 				os.Exit(1)
 			}
 		}
 		genkeyCommand(os.Args[2], keySize)
 
+	case "analyze":
+		if err := analyzeCommand(os.Args[2:]); err != nil {
+			fmt.Printf("Ошибка анализа: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "kdfbench":
+		if err := kdfBenchCommand(os.Stdout, os.Args[2:]); err != nil {
+			fmt.Printf("Ошибка замера: %v\n", err)
+			os.Exit(1)
+		}
+
 	case "help", "-h", "--help":
 		printUsage()
 
@@ -642,31 +894,75 @@ func main() {
 	}
 }
 
-func encryptCommand(keyFile, inputFile, outputFile string) {
-	fmt.Printf("Шифрование файла: %s -> %s\n", inputFile, outputFile)
+// splitArgs делит аргументы на позиционные (до первого флага) и флаги
+func splitArgs(args []string) (positional, flags []string) {
+	for i, a := range args {
+		if strings.HasPrefix(a, "-") {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
 
-	// Загрузка ключа
+// readPassword читает пароль из источника, указанного флагами
+func readPassword(pf *passwordFlags) []byte {
+	if pf.value != "" {
+		fmt.Println("Внимание: пароль в командной строке виден в списке процессов и истории, используйте -password-file или -password-env")
+	}
+	password, err := pf.read()
+	if err != nil {
+		fmt.Printf("Ошибка чтения пароля: %v\n", err)
+		os.Exit(1)
+	}
+	return password
+}
+
+// loadKey загружает ключ из файла и определяет его размер
+func loadKey(keyFile string) ([]byte, AESKeySize) {
 	key, err := loadKeyFromFile(keyFile)
 	if err != nil {
 		fmt.Printf("Ошибка загрузки ключа: %v\n", err)
 		os.Exit(1)
 	}
+	// loadKeyFromFile пропускает только 16, 24, 32 и 64 байта; подходит ли
+	// длина режиму, проверяют encryptFile и decryptFile
+	keySize := AESKeySize(len(key))
+	fmt.Printf("Используется ключ: %s (%d бит)\n", keyFile, keySize*8)
+	return key, keySize
+}
 
-	// Определение размера ключа
-	var keySize AESKeySize
-	switch len(key) {
-	case 16:
-		keySize = AESKeySize128
-	case 24:
-		keySize = AESKeySize192
-	case 32:
-		keySize = AESKeySize256
-	default:
-		fmt.Printf("Ошибка: Неподдерживаемый размер ключа: %d байт\n", len(key))
+func encryptCommand(keyFile, inputFile, outputFile, mode string) {
+	runEncrypt(inputFile, outputFile, mode, func() error {
+		key, keySize := loadKey(keyFile)
+		return encryptFile(inputFile, outputFile, key, keySize, mode)
+	})
+}
+
+func encryptPasswordCommand(password []byte, kdf kdfParams, keyBits int, inputFile, outputFile, mode string) {
+	runEncrypt(inputFile, outputFile, mode, func() error {
+		fmt.Printf("Ключ %d бит из пароля: %s (%s)\n", keyBits, kdfNames[kdf.ID], formatKDFParams(kdf))
+		if m := kdfMemory(kdf); m > defaultMaxKDFMemory {
+			fmt.Printf("Внимание: KDF требует %d МиБ, для расшифровки понадобится -max-kdf-memory %d\n", m>>20, m>>20)
+		}
+		return encryptFileWithPassword(inputFile, outputFile, password, kdf, keyBits, mode)
+	})
+}
+
+// runEncrypt общая часть команд шифрования: предупреждение о режиме,
+// проверка входного файла и статистика
+func runEncrypt(inputFile, outputFile, mode string, encrypt func() error) {
+	if _, err := modeByName(mode); err != nil {
+		fmt.Printf("Ошибка: %v\n", err)
 		os.Exit(1)
 	}
-
-	fmt.Printf("Используется ключ: %s (%d бит)\n", keyFile, keySize*8)
+	fmt.Printf("Шифрование файла (%s): %s -> %s\n", strings.ToUpper(mode), inputFile, outputFile)
+	switch mode {
+	case ModeGCM:
+	case ModeECB:
+		fmt.Println("Внимание: ECB шифрует одинаковые блоки одинаково и раскрывает структуру данных, режим только для обучения")
+	default:
+		fmt.Printf("Внимание: %s не защищает от изменения файла, для этого используйте gcm\n", strings.ToUpper(mode))
+	}
 
 	// Проверка существования входного файла
 	if _, err := os.Stat(inputFile); os.IsNotExist(err) {
@@ -675,7 +971,7 @@ func encryptCommand(keyFile, inputFile, outputFile string) {
 	}
 
 	// Шифрование
-	if err := encryptFile(inputFile, outputFile, key, keySize); err != nil {
+	if err := encrypt(); err != nil {
 		fmt.Printf("Ошибка шифрования: %v\n", err)
 		os.Exit(1)
 	}
@@ -689,31 +985,26 @@ func encryptCommand(keyFile, inputFile, outputFile string) {
 	fmt.Printf("Размер зашифрованного файла: %d байт\n", outputInfo.Size())
 }
 
-func decryptCommand(keyFile, inputFile, outputFile string) {
-	fmt.Printf("Дешифрование файла: %s -> %s\n", inputFile, outputFile)
-
-	// Загрузка ключа
-	key, err := loadKeyFromFile(keyFile)
-	if err != nil {
-		fmt.Printf("Ошибка загрузки ключа: %v\n", err)
-		os.Exit(1)
-	}
+func decryptCommand(keyFile, inputFile, outputFile string, legacy bool) {
+	runDecrypt(inputFile, outputFile, func() error {
+		key, keySize := loadKey(keyFile)
+		if legacy {
+			return decryptLegacyFile(inputFile, outputFile, key, keySize)
+		}
+		return decryptFile(inputFile, outputFile, key, keySize)
+	})
+}
 
-	// Определение размера ключа
-	var keySize AESKeySize
-	switch len(key) {
-	case 16:
-		keySize = AESKeySize128
-	case 24:
-		keySize = AESKeySize192
-	case 32:
-		keySize = AESKeySize256
-	default:
-		fmt.Printf("Ошибка: Неподдерживаемый размер ключа: %d байт\n", len(key))
-		os.Exit(1)
-	}
+func decryptPasswordCommand(password []byte, maxMemory uint64, inputFile, outputFile string) {
+	runDecrypt(inputFile, outputFile, func() error {
+		fmt.Println("Ключ из пароля по параметрам KDF из заголовка файла")
+		return decryptFileWithPassword(inputFile, outputFile, password, maxMemory)
+	})
+}
 
-	fmt.Printf("Используется ключ: %s (%d бит)\n", keyFile, keySize*8)
+// runDecrypt общая часть команд дешифрования
+func runDecrypt(inputFile, outputFile string, decrypt func() error) {
+	fmt.Printf("Дешифрование файла: %s -> %s\n", inputFile, outputFile)
 
 	// Проверка существования входного файла
 	if _, err := os.Stat(inputFile); os.IsNotExist(err) {
@@ -722,7 +1013,7 @@ func decryptCommand(keyFile, inputFile, outputFile string) {
 	}
 
 	// Дешифрование
-	if err := decryptFile(inputFile, outputFile, key, keySize); err != nil {
+	if err := decrypt(); err != nil {
 		fmt.Printf("Ошибка дешифрования: %v\n", err)
 		os.Exit(1)
 	}
@@ -820,7 +1111,7 @@ func main2() {
 
 	// Шифруем файл
 	fmt.Println("\nEncrypting file...")
-	if err := encryptFile(inputFile, encryptedFile, key, AESKeySize256); err != nil {
+	if err := encryptFile(inputFile, encryptedFile, key, AESKeySize256, ModeGCM); err != nil {
 		fmt.Printf("File encryption error: %v\n", err)
 		return
 	}
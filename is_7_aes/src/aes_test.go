@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+)
+
+func randomBytes(size int) []byte {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func unhex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// TestFIPS197 примеры из приложения C FIPS-197
+func TestFIPS197(t *testing.T) {
+	plain := unhex("00112233445566778899aabbccddeeff")
+	vectors := []struct{ key, cipher string }{
+		{"000102030405060708090a0b0c0d0e0f", "69c4e0d86a7b0430d8cdb78070b4c55a"},
+		{"000102030405060708090a0b0c0d0e0f1011121314151617", "dda97ca4864cdfe06eaf70a0ec0d7191"},
+		{"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f", "8ea2b7ca516745bfeafc49904b496089"},
+	}
+	for _, v := range vectors {
+		a, err := NewAES(unhex(v.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, AESBlockSize)
+		a.Encrypt(got, plain)
+		if !bytes.Equal(got, unhex(v.cipher)) {
+			t.Errorf("ключ %s: получено %x, ожидается %s", v.key, got, v.cipher)
+		}
+		a.Decrypt(got, got)
+		if !bytes.Equal(got, plain) {
+			t.Errorf("ключ %s: расшифровано %x", v.key, got)
+		}
+	}
+}
+
+func TestAgainstStdlib(t *testing.T) {
+	for i := 0; i < 300; i++ {
+		key := randomBytes(16 + 8*(i%3))
+		a, err := NewAES(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		std, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := randomBytes(AESBlockSize)
+		got, want := make([]byte, AESBlockSize), make([]byte, AESBlockSize)
+		a.Encrypt(got, block)
+		std.Encrypt(want, block)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("ключ %x: получено %x, ожидается %x", key, got, want)
+		}
+	}
+}
+
+// TestDestroy: после Destroy расписание обнулено, шифрование паникует
+func TestDestroy(t *testing.T) {
+	a, err := NewAES(randomBytes(32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule := a.expandedKey
+	a.Destroy()
+	for _, b := range schedule {
+		if b != 0 {
+			t.Fatal("расписание ключей не обнулено")
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("шифрование после Destroy не вызвало панику")
+		}
+	}()
+	block := make([]byte, AESBlockSize)
+	a.Encrypt(block, block)
+}
+
+// Скорость шифрования блоков: с расширением ключа на каждый блок
+// (aesEncryptBlock, как было до NewAES), с расширенным один раз ключом и
+// crypto/aes. Каждый блок шифруется на месте и зависит от предыдущего.
+
+var benchKey = unhex("000102030405060708090a0b0c0d0e0f")
+
+func benchBlocks(b *testing.B, encrypt func(dst, src []byte)) {
+	block := make([]byte, AESBlockSize)
+	b.SetBytes(AESBlockSize)
+	for b.Loop() {
+		encrypt(block, block)
+	}
+}
+
+func BenchmarkEncryptBlockExpandKey(b *testing.B) {
+	benchBlocks(b, func(dst, src []byte) {
+		out, err := aesEncryptBlock(src, benchKey, AESKeySize128)
+		if err != nil {
+			b.Fatal(err)
+		}
+		copy(dst, out)
+	})
+}
+
+func BenchmarkEncrypt(b *testing.B) {
+	for _, size := range []int{16, 24, 32} {
+		b.Run(map[int]string{16: "128", 24: "192", 32: "256"}[size], func(b *testing.B) {
+			a, err := NewAES(randomBytes(size))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer a.Destroy()
+			benchBlocks(b, a.Encrypt)
+		})
+	}
+}
+
+func BenchmarkDecrypt(b *testing.B) {
+	a, err := NewAES(benchKey)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer a.Destroy()
+	benchBlocks(b, a.Decrypt)
+}
+
+func BenchmarkStdlibAES(b *testing.B) {
+	std, err := aes.NewCipher(benchKey)
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchBlocks(b, std.Encrypt)
+}
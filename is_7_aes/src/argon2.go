@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"sync"
+)
+
+// Argon2id (RFC 9106, версия 0x13): функция получения ключа из пароля,
+// требующая много памяти. Память — матрица блоков по 1 КиБ: threads
+// дорожек по q столбцов, каждая дорожка делится на 4 сегмента. В первой
+// половине первого прохода индексы опорных блоков не зависят от пароля
+// (как в Argon2i, защита от атак по времени), дальше — зависят от данных
+// (как в Argon2d, защита от перебора на специализированном оборудовании).
+
+const (
+	argon2BlockWords = 128 // слов uint64 в блоке 1 КиБ
+	argon2SyncPoints = 4   // сегментов в дорожке
+	argon2Version    = 0x13
+	argon2TypeID     = 2
+)
+
+type argon2Block [argon2BlockWords]uint64
+
+// argon2id вычисляет ключ длины keyLen; secret и ad — необязательные
+// секрет и ассоциированные данные (K и X в RFC 9106). memory в КиБ,
+// не меньше 8*threads.
+func argon2id(password, salt, secret, ad []byte, time, memory uint32, threads uint8, keyLen uint32) []byte {
+	le := binary.LittleEndian.AppendUint32
+	var h0in []byte
+	for _, v := range []uint32{uint32(threads), keyLen, memory, time, argon2Version, argon2TypeID} {
+		h0in = le(h0in, v)
+	}
+	for _, p := range [][]byte{password, salt, secret, ad} {
+		h0in = le(h0in, uint32(len(p)))
+		h0in = append(h0in, p...)
+	}
+	h0 := blake2bSum(blake2bSize, h0in)
+
+	lanes := uint32(threads)
+	// m' — наибольшее кратное 4*threads, не больше memory
+	total := memory / (argon2SyncPoints * lanes) * (argon2SyncPoints * lanes)
+	q := total / lanes
+	segment := q / argon2SyncPoints
+	mem := make([]argon2Block, total)
+
+	var buf [1024]byte
+	for l := uint32(0); l < lanes; l++ {
+		for j := uint32(0); j < 2; j++ {
+			argon2Hash(buf[:], h0, le(le(nil, j), l))
+			for i := range mem[l*q+j] {
+				mem[l*q+j][i] = binary.LittleEndian.Uint64(buf[8*i:])
+			}
+		}
+	}
+
+	for pass := uint32(0); pass < time; pass++ {
+		for slice := uint32(0); slice < argon2SyncPoints; slice++ {
+			var wg sync.WaitGroup
+			for l := uint32(0); l < lanes; l++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					argon2Segment(mem, pass, slice, l, lanes, q, segment, total, time)
+				}()
+			}
+			wg.Wait()
+		}
+	}
+
+	// Итог — XOR последних блоков всех дорожек
+	final := mem[q-1]
+	for l := uint32(1); l < lanes; l++ {
+		for i, v := range mem[l*q+q-1] {
+			final[i] ^= v
+		}
+	}
+	for i, v := range final {
+		binary.LittleEndian.PutUint64(buf[8*i:], v)
+	}
+	out := make([]byte, keyLen)
+	argon2Hash(out, buf[:])
+	clear(buf[:])
+	for i := range mem {
+		clear(mem[i][:])
+	}
+	return out
+}
+
+// argon2Segment заполняет сегмент slice дорожки lane на проходе pass
+func argon2Segment(mem []argon2Block, pass, slice, lane, lanes, q, segment, total, time uint32) {
+	independent := pass == 0 && slice < argon2SyncPoints/2
+	var addresses, input, zero argon2Block
+	if independent {
+		input[0] = uint64(pass)
+		input[1] = uint64(lane)
+		input[2] = uint64(slice)
+		input[3] = uint64(total)
+		input[4] = uint64(time)
+		input[5] = argon2TypeID
+	}
+	nextAddresses := func() {
+		input[6]++
+		argon2Compress(&addresses, &zero, &input, false)
+		argon2Compress(&addresses, &zero, &addresses, false)
+	}
+
+	index := uint32(0)
+	if pass == 0 && slice == 0 {
+		index = 2 // первые два блока дорожки получены из H0
+		if independent {
+			nextAddresses()
+		}
+	}
+	offset := lane*q + slice*segment + index
+	for ; index < segment; index, offset = index+1, offset+1 {
+		prev := offset - 1
+		if index == 0 && slice == 0 {
+			prev += q // последний блок дорожки
+		}
+		var random uint64
+		if independent {
+			if index%argon2BlockWords == 0 {
+				nextAddresses()
+			}
+			random = addresses[index%argon2BlockWords]
+		} else {
+			random = mem[prev][0]
+		}
+		ref := argon2RefIndex(random, pass, slice, lane, index, lanes, q, segment)
+		// Версия 0x13: на повторных проходах результат складывается со
+		// старым значением блока; на первом проходе блок нулевой
+		argon2Compress(&mem[offset], &mem[prev], &mem[ref], true)
+	}
+}
+
+// argon2RefIndex номер опорного блока по псевдослучайному значению:
+// старшие 32 бита выбирают дорожку, младшие — блок среди доступных
+func argon2RefIndex(random uint64, pass, slice, lane, index, lanes, q, segment uint32) uint32 {
+	refLane := uint32(random>>32) % lanes
+	if pass == 0 && slice == 0 {
+		refLane = lane
+	}
+	// Доступны завершенные сегменты и, в своей дорожке, блоки текущего
+	var area, start uint32
+	if pass == 0 {
+		area = slice * segment
+		if slice == 0 || refLane == lane {
+			area += index
+		}
+	} else {
+		area = 3 * segment
+		start = (slice + 1) % argon2SyncPoints * segment
+		if refLane == lane {
+			area += index
+		}
+	}
+	if index == 0 || refLane == lane {
+		area-- // кроме предыдущего блока
+	}
+	x := random & 0xFFFFFFFF
+	x = x * x >> 32
+	x = uint64(area) * x >> 32
+	rel := uint64(area) - 1 - x
+	return refLane*q + uint32((uint64(start)+rel)%uint64(q))
+}
+
+// argon2Compress функция сжатия G: out = P(x ⊕ y) ⊕ x ⊕ y, при xor
+// результат дополнительно складывается со старым out
+func argon2Compress(out, x, y *argon2Block, xor bool) {
+	var r, t argon2Block
+	for i := range r {
+		r[i] = x[i] ^ y[i]
+	}
+	t = r
+	for i := 0; i < argon2BlockWords; i += 16 {
+		blamka(&t, i, i+1, i+2, i+3, i+4, i+5, i+6, i+7, i+8, i+9, i+10, i+11, i+12, i+13, i+14, i+15)
+	}
+	for i := 0; i < 16; i += 2 {
+		blamka(&t, i, i+1, i+16, i+17, i+32, i+33, i+48, i+49, i+64, i+65, i+80, i+81, i+96, i+97, i+112, i+113)
+	}
+	for i := range out {
+		if xor {
+			out[i] ^= t[i] ^ r[i]
+		} else {
+			out[i] = t[i] ^ r[i]
+		}
+	}
+}
+
+// blamka раунд BLAKE2b с умножением младших половин слов вместо
+// сложения, над 16 словами блока с указанными номерами
+func blamka(b *argon2Block, i0, i1, i2, i3, i4, i5, i6, i7, i8, i9, i10, i11, i12, i13, i14, i15 int) {
+	g := func(a, c, d, e int) {
+		mul := func(x, y uint64) uint64 { return x + y + 2*uint64(uint32(x))*uint64(uint32(y)) }
+		b[a] = mul(b[a], b[c])
+		b[e] = bits.RotateLeft64(b[e]^b[a], -32)
+		b[d] = mul(b[d], b[e])
+		b[c] = bits.RotateLeft64(b[c]^b[d], -24)
+		b[a] = mul(b[a], b[c])
+		b[e] = bits.RotateLeft64(b[e]^b[a], -16)
+		b[d] = mul(b[d], b[e])
+		b[c] = bits.RotateLeft64(b[c]^b[d], -63)
+	}
+	g(i0, i4, i8, i12)
+	g(i1, i5, i9, i13)
+	g(i2, i6, i10, i14)
+	g(i3, i7, i11, i15)
+	g(i0, i5, i10, i15)
+	g(i1, i6, i11, i12)
+	g(i2, i7, i8, i13)
+	g(i3, i4, i9, i14)
+}
+
+// argon2Hash хеш переменной длины H' от конкатенации parts в out: при
+// длине больше 64 байт — цепочка BLAKE2b-512, от каждого звена берутся
+// первые 32 байта
+func argon2Hash(out []byte, parts ...[]byte) {
+	prefix := binary.LittleEndian.AppendUint32(nil, uint32(len(out)))
+	if len(out) <= blake2bSize {
+		copy(out, blake2bSum(len(out), append([][]byte{prefix}, parts...)...))
+		return
+	}
+	v := blake2bSum(blake2bSize, append([][]byte{prefix}, parts...)...)
+	for len(out) > blake2bSize {
+		n := copy(out, v[:blake2bSize/2])
+		out = out[n:]
+		v = blake2bSum(min(len(out), blake2bSize), v)
+	}
+	copy(out, v)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestArgon2id пример из раздела 5.3 RFC 9106: пароль, соль, секрет и
+// ассоциированные данные, t=3, m=32 КиБ, p=4
+func TestArgon2id(t *testing.T) {
+	got := argon2id(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16),
+		bytes.Repeat([]byte{3}, 8), bytes.Repeat([]byte{4}, 12), 3, 32, 4, 32)
+	want := unhex("0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659")
+	if !bytes.Equal(got, want) {
+		t.Fatalf("получено %x, ожидается %x", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"avalanche"
+)
+
+// aesAvalancheCipher AES с ключом размера keySize для анализа лавинного
+// эффекта; состояния раундов берутся из aesEncryptBlockTrace
+type aesAvalancheCipher struct {
+	keySize AESKeySize
+}
+
+func (c aesAvalancheCipher) Name() string {
+	return fmt.Sprintf("AES-%d", int(c.keySize)*BitsPerByte)
+}
+
+func (c aesAvalancheCipher) BlockBits() int { return AESBlockSize * BitsPerByte }
+func (c aesAvalancheCipher) KeyBits() int   { return int(c.keySize) * BitsPerByte }
+func (c aesAvalancheCipher) KeyBytes() int  { return int(c.keySize) }
+
+func (c aesAvalancheCipher) FlipKeyBit(key []byte, i int) {
+	key[i/BitsPerByte] ^= 0x80 >> (i % BitsPerByte)
+}
+
+func (c aesAvalancheCipher) EncryptTrace(plain, key []byte) ([]byte, [][]byte) {
+	var states [][]byte
+	out, err := aesEncryptBlockTrace(plain, key, c.keySize, func(_ int, state *AESState) {
+		block := make([]byte, AESBlockSize)
+		storeState(block, state)
+		states = append(states, block)
+	})
+	if err != nil {
+		panic(err) // размеры блока и ключа заданы выше
+	}
+	return out, states
+}
+
+// analyzeCommand выполняет "analyze avalanche [-samples N] [-keysize
+// 128|192|256] [-csv префикс] [-json файл]"
+func analyzeCommand(args []string) error {
+	if len(args) == 0 || args[0] != "avalanche" {
+		return errors.New("неизвестная команда analyze, ожидается avalanche")
+	}
+	fs := flag.NewFlagSet("analyze avalanche", flag.ContinueOnError)
+	samples := fs.Int("samples", 500, "число случайных ключей и блоков")
+	keyBits := fs.Int("keysize", 128, "размер ключа: 128, 192 или 256")
+	csvPrefix := fs.String("csv", "", "префикс файлов CSV")
+	jsonPath := fs.String("json", "", "файл JSON")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	var keySize AESKeySize
+	switch *keyBits {
+	case 128:
+		keySize = AESKeySize128
+	case 192:
+		keySize = AESKeySize192
+	case 256:
+		keySize = AESKeySize256
+	default:
+		return fmt.Errorf("неподдерживаемый размер ключа: %d", *keyBits)
+	}
+	return avalanche.Analyze(os.Stdout, aesAvalancheCipher{keySize}, *samples, *csvPrefix, *jsonPath)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"math"
+	"testing"
+
+	"avalanche"
+)
+
+// TestAESAvalancheTrace: шифртекст адаптера совпадает с crypto/aes,
+// состояний на одно больше числа раундов, первое — открытый текст после
+// начального AddRoundKey.
+func TestAESAvalancheTrace(t *testing.T) {
+	for _, keySize := range []AESKeySize{AESKeySize128, AESKeySize192, AESKeySize256} {
+		c := aesAvalancheCipher{keySize}
+		key, plain := randomBytes(c.KeyBytes()), randomBytes(AESBlockSize)
+		out, states := c.EncryptTrace(plain, key)
+		std, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]byte, AESBlockSize)
+		std.Encrypt(want, plain)
+		if !bytes.Equal(out, want) {
+			t.Fatalf("%s: шифртекст %x, ожидается %x", c.Name(), out, want)
+		}
+		rounds := int(keySize)/4 + 6
+		if len(states) != rounds+1 {
+			t.Fatalf("%s: %d состояний, ожидается %d", c.Name(), len(states), rounds+1)
+		}
+		if !bytes.Equal(states[rounds], out) {
+			t.Fatalf("%s: последнее состояние %x не равно шифртексту", c.Name(), states[rounds])
+		}
+		first := make([]byte, AESBlockSize)
+		for i := range first {
+			first[i] = plain[i] ^ key[i]
+		}
+		if !bytes.Equal(states[0], first) {
+			t.Fatalf("%s: состояние 0 %x, ожидается %x", c.Name(), states[0], first)
+		}
+	}
+}
+
+// TestAESAvalanche: после первого раунда меняется меньше половины бит,
+// начиная со второго — около половины; SAC близок к 0,5.
+func TestAESAvalanche(t *testing.T) {
+	report, err := avalanche.Run(aesAvalancheCipher{AESKeySize128}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Cipher != "AES-128" || report.BlockBits != 128 {
+		t.Fatalf("заголовок отчета: %s, %d бит", report.Cipher, report.BlockBits)
+	}
+	for _, st := range []avalanche.Stats{report.Plaintext, report.Key} {
+		if len(st.Rounds) != 11 {
+			t.Fatalf("%s: %d раундов в отчете", st.Input, len(st.Rounds))
+		}
+		if last := st.Rounds[10].Mean; math.Abs(last-64) > 1.5 {
+			t.Fatalf("%s: после раунда 10 меняется %.2f бит", st.Input, last)
+		}
+		if math.Abs(st.SACMean-0.5) > 0.01 {
+			t.Fatalf("%s: среднее SAC %.4f", st.Input, st.SACMean)
+		}
+	}
+	if first := report.Plaintext.Rounds[1].Mean; first > 32 {
+		t.Fatalf("после раунда 1 меняется %.2f бит", first)
+	}
+}
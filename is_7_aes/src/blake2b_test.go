@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestBlake2b тестовые векторы RFC 7693 (приложение A и "abc")
+func TestBlake2b(t *testing.T) {
+	vectors := []struct{ in, want string }{
+		{"", "786a02f742015903c6c6fd852552d272912f4740e15847618a86e217f71f5419" +
+			"d25e1031afee585313896444934eb04b903a685b1448b755d56f701afe9be2ce"},
+		{"abc", "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d1" +
+			"7d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+	}
+	for _, v := range vectors {
+		if got := blake2bSum(blake2bSize, []byte(v.in)); !bytes.Equal(got, unhex(v.want)) {
+			t.Errorf("%q: получено %x, ожидается %s", v.in, got, v.want)
+		}
+	}
+}
+
+// TestBlake2bWrite: запись частями, в том числе ровно по границе блока,
+// дает тот же хеш
+func TestBlake2bWrite(t *testing.T) {
+	data := randomBytes(3 * blake2bBlockSize)
+	d := newBlake2b(32)
+	d.Write(data[:blake2bBlockSize])
+	d.Write(data[blake2bBlockSize : 2*blake2bBlockSize+1])
+	d.Write(data[2*blake2bBlockSize+1:])
+	if !bytes.Equal(d.Sum(), blake2bSum(32, data)) {
+		t.Fatal("хеш при записи частями отличается")
+	}
+	if got := blake2bSum(32, data[:10], data[10:]); !bytes.Equal(got, blake2bSum(32, data)) {
+		t.Fatal("хеш нескольких частей отличается")
+	}
+}
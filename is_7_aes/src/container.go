@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Формат зашифрованного файла, версия 1. Формат не привязан к AES: шифр
+// указан в заголовке, чтобы им могли пользоваться и другие программы
+// репозитория. Числа — big-endian.
+//
+//	magic       [4]byte  "ENCF"
+//	version     uint8    1
+//	header_len  uint16   длина заголовка вместе с MAC
+//	cipher      uint8    cipherAES, cipherDES, cipher3DES
+//	mode        uint8    код режима (modeIDGCM, modeIDCBC, ...)
+//	key_bits    uint16   длина ключа в битах
+//	kdf         uint8    kdfNone — ключ из файла ключа, иначе ключ из пароля
+//	kdf_time    uint32   параметры KDF (см. kdf.go), для kdfNone — 0
+//	kdf_memory  uint32
+//	kdf_threads uint8
+//	salt_len    uint8,   salt [salt_len]byte
+//	chunk_size  uint32   размер независимо шифруемой части (сектор XTS), 0 — файл целиком
+//	iv_len      uint8,   iv [iv_len]byte  IV или nonce режима
+//	mac         [32]byte HMAC-SHA256 всех предыдущих байт заголовка
+//
+// За заголовком — шифртекст, для GCM за ним тег; весь заголовок вместе с
+// MAC — ассоциированные данные GCM. MAC заголовка вычисляется на ключе,
+// выведенном из ключа шифрования (headerMAC): неверный ключ или измененный
+// заголовок обнаруживаются до расшифровки. Данные MAC заголовка не
+// защищает — их целостность проверяет только GCM.
+const (
+	containerMagic   = "ENCF"
+	containerVersion = 1
+	headerMACSize    = sha256.Size
+	// maxHeaderSize ограничение на header_len при чтении
+	maxHeaderSize = 1024
+)
+
+// Коды шифров в заголовке
+const (
+	cipherAES byte = 1 + iota
+	cipherDES
+	cipher3DES
+)
+
+// Коды KDF в заголовке, параметры — в kdf.go
+const (
+	kdfNone byte = iota
+	kdfPBKDF2
+	kdfScrypt
+	kdfArgon2id
+)
+
+var cipherNames = map[byte]string{cipherAES: "AES", cipherDES: "DES", cipher3DES: "3DES"}
+
+var kdfNames = map[byte]string{kdfNone: "нет (файл ключа)", kdfPBKDF2: "pbkdf2", kdfScrypt: "scrypt", kdfArgon2id: "argon2id"}
+
+// Сигнатуры файлов программы DES (is_5): такие файлы распознаются, чтобы
+// сообщить, чем их расшифровывать
+var desFileMagics = []string{"DESK", "DESM"}
+
+// errHeaderAuth MAC заголовка не совпал
+var errHeaderAuth = errors.New("заголовок не прошел проверку: ключ неверный или заголовок изменен")
+
+// errNotContainer файл без сигнатуры ENCF
+var errNotContainer = errors.New("неизвестный формат файла: нет сигнатуры " + containerMagic)
+
+// kdfParams параметры получения ключа из пароля
+type kdfParams struct {
+	ID      byte
+	Time    uint32
+	Memory  uint32 // КиБ
+	Threads byte
+	Salt    []byte
+}
+
+// fileHeader разобранный заголовок контейнера
+type fileHeader struct {
+	Version   byte
+	Cipher    byte
+	Mode      byte
+	KeyBits   int
+	KDF       kdfParams
+	ChunkSize uint32
+	IV        []byte
+	MAC       []byte
+}
+
+// marshal заголовок без MAC; header_len учитывает MAC
+func (h *fileHeader) marshal() []byte {
+	b := []byte(containerMagic)
+	b = append(b, h.Version, 0, 0, h.Cipher, h.Mode)
+	b = binary.BigEndian.AppendUint16(b, uint16(h.KeyBits))
+	b = append(b, h.KDF.ID)
+	b = binary.BigEndian.AppendUint32(b, h.KDF.Time)
+	b = binary.BigEndian.AppendUint32(b, h.KDF.Memory)
+	b = append(b, h.KDF.Threads, byte(len(h.KDF.Salt)))
+	b = append(b, h.KDF.Salt...)
+	b = binary.BigEndian.AppendUint32(b, h.ChunkSize)
+	b = append(b, byte(len(h.IV)))
+	b = append(b, h.IV...)
+	binary.BigEndian.PutUint16(b[len(containerMagic)+1:], uint16(len(b)+headerMACSize))
+	return b
+}
+
+// seal вычисляет MAC заголовка на ключе key и возвращает заголовок целиком
+func (h *fileHeader) seal(key []byte) []byte {
+	b := h.marshal()
+	h.MAC = headerMAC(key, b)
+	return append(b, h.MAC...)
+}
+
+// verify проверяет MAC заголовка на ключе key
+func (h *fileHeader) verify(key []byte) error {
+	if !hmac.Equal(headerMAC(key, h.marshal()), h.MAC) {
+		return errHeaderAuth
+	}
+	return nil
+}
+
+// headerMAC HMAC-SHA256 заголовка на ключе, выведенном из ключа шифрования:
+// один и тот же ключ не используется и в AES, и в HMAC
+func headerMAC(key, header []byte) []byte {
+	kdf := hmac.New(sha256.New, key)
+	kdf.Write([]byte(containerMagic + " header MAC"))
+	mac := hmac.New(sha256.New, kdf.Sum(nil))
+	mac.Write(header)
+	return mac.Sum(nil)
+}
+
+// readFileHeader читает и разбирает заголовок; возвращает его и байты
+// заголовка целиком. MAC не проверяется — для этого нужен ключ.
+func readFileHeader(r *bufio.Reader) (*fileHeader, []byte, error) {
+	prefix, err := r.Peek(len(containerMagic) + 3)
+	if err != nil || string(prefix[:len(containerMagic)]) != containerMagic {
+		for _, magic := range desFileMagics {
+			if len(prefix) >= len(magic) && string(prefix[:len(magic)]) == magic {
+				return nil, nil, errors.New("файл зашифрован программой DES (is_5), а не AES")
+			}
+		}
+		return nil, nil, errNotContainer
+	}
+	if v := prefix[len(containerMagic)]; v != containerVersion {
+		return nil, nil, fmt.Errorf("неподдерживаемая версия формата %d, ожидается %d", v, containerVersion)
+	}
+	size := int(binary.BigEndian.Uint16(prefix[len(containerMagic)+1:]))
+	if size < len(prefix)+headerMACSize || size > maxHeaderSize {
+		return nil, nil, fmt.Errorf("неверная длина заголовка %d", size)
+	}
+	raw := make([]byte, size)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, nil, errors.New("файл короче заголовка")
+	}
+
+	h := &fileHeader{Version: raw[len(containerMagic)]}
+	p := headerParser{data: raw[len(prefix) : size-headerMACSize]}
+	h.Cipher = p.byte()
+	h.Mode = p.byte()
+	h.KeyBits = int(p.uint16())
+	h.KDF.ID = p.byte()
+	h.KDF.Time = p.uint32()
+	h.KDF.Memory = p.uint32()
+	h.KDF.Threads = p.byte()
+	h.KDF.Salt = p.bytes(int(p.byte()))
+	h.ChunkSize = p.uint32()
+	h.IV = p.bytes(int(p.byte()))
+	if p.err != nil || len(p.data) != 0 {
+		return nil, nil, errors.New("поврежденный заголовок файла")
+	}
+	h.MAC = raw[size-headerMACSize:]
+	return h, raw, nil
+}
+
+// headerParser последовательное чтение полей; при нехватке данных
+// запоминает ошибку и возвращает нули
+type headerParser struct {
+	data []byte
+	err  error
+}
+
+func (p *headerParser) bytes(n int) []byte {
+	if p.err != nil || len(p.data) < n {
+		p.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := bytes.Clone(p.data[:n])
+	p.data = p.data[n:]
+	return b
+}
+
+func (p *headerParser) byte() byte {
+	if b := p.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (p *headerParser) uint16() uint16 {
+	if b := p.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (p *headerParser) uint32() uint32 {
+	if b := p.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// infoCommand выполняет "info": печатает заголовок без ключа
+func infoCommand(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	h, raw, err := readFileHeader(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+
+	name := func(names map[byte]string, id byte) string {
+		if n, ok := names[id]; ok {
+			return n
+		}
+		return fmt.Sprintf("неизвестный (%d)", id)
+	}
+	mode := fmt.Sprintf("неизвестный (%d)", h.Mode)
+	if m, err := modeByID(h.Mode); err == nil {
+		mode = m.Name()
+	}
+
+	fmt.Fprintf(w, "Файл:          %s (%d байт)\n", path, st.Size())
+	fmt.Fprintf(w, "Формат:        %s, версия %d, заголовок %d байт\n", containerMagic, h.Version, len(raw))
+	fmt.Fprintf(w, "Шифр:          %s-%d\n", name(cipherNames, h.Cipher), h.KeyBits)
+	fmt.Fprintf(w, "Режим:         %s\n", mode)
+	fmt.Fprintf(w, "KDF:           %s\n", name(kdfNames, h.KDF.ID))
+	if h.KDF.ID != kdfNone {
+		fmt.Fprintf(w, "  параметры:   %s\n", formatKDFParams(h.KDF))
+		fmt.Fprintf(w, "  соль:        %x\n", h.KDF.Salt)
+	}
+	if h.ChunkSize > 0 {
+		fmt.Fprintf(w, "Размер части:  %d байт\n", h.ChunkSize)
+	} else {
+		fmt.Fprintf(w, "Размер части:  файл целиком\n")
+	}
+	fmt.Fprintf(w, "IV/nonce:      %x\n", h.IV)
+	fmt.Fprintf(w, "MAC заголовка: %x (проверяется при расшифровке)\n", h.MAC)
+	fmt.Fprintf(w, "Данные:        %d байт\n", st.Size()-int64(len(raw)))
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// headerSize длина заголовка контейнера для режима m с ключом из файла
+func headerSize(m Mode) int {
+	h := fileHeader{IV: make([]byte, m.IVSize())}
+	return len(h.marshal()) + headerMACSize
+}
+
+// encryptedXTS шифрует 1000 случайных байт в XTS и возвращает путь к
+// файлу, его содержимое и ключ
+func encryptedXTS(t *testing.T) (string, []byte, []byte) {
+	t.Helper()
+	dir := t.TempDir()
+	key := randomBytes(32)
+	plain := filepath.Join(dir, "plain")
+	encrypted := filepath.Join(dir, "encrypted")
+	if err := os.WriteFile(plain, randomBytes(1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := encryptFile(plain, encrypted, key, AESKeySize256, ModeXTS); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encrypted, data, key
+}
+
+// TestContainerHeader: заголовок читается без ключа, info показывает его
+// поля
+func TestContainerHeader(t *testing.T) {
+	encrypted, data, _ := encryptedXTS(t)
+	h, raw, err := readFileHeader(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Cipher != cipherAES || h.Mode != modeIDXTS || h.KeyBits != 256 || h.ChunkSize != xtsSectorSize || len(raw) != headerSize(xtsMode{}) {
+		t.Fatalf("заголовок прочитан неверно: %+v", h)
+	}
+	var out bytes.Buffer
+	if err := infoCommand(&out, encrypted); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"AES-256", "xts", "512 байт"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("info не содержит %q:\n%s", want, out.String())
+		}
+	}
+}
+
+// TestContainerHeaderKDF: параметры KDF и IV переживают запись и чтение
+// заголовка
+func TestContainerHeaderKDF(t *testing.T) {
+	want := &fileHeader{Version: containerVersion, Cipher: cipherAES, Mode: modeIDGCM, KeyBits: 192,
+		KDF: kdfParams{ID: kdfArgon2id, Time: 2, Memory: 1024, Threads: 3, Salt: randomBytes(kdfSaltSize)},
+		IV:  randomBytes(GCMNonceSize)}
+	sealed := want.seal(randomBytes(24))
+	got, raw, err := readFileHeader(bufio.NewReader(bytes.NewReader(sealed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, sealed) {
+		t.Fatalf("прочитано %d байт заголовка из %d", len(raw), len(sealed))
+	}
+	if got.Mode != want.Mode || got.KeyBits != want.KeyBits || got.KDF.ID != want.KDF.ID ||
+		got.KDF.Time != want.KDF.Time || got.KDF.Memory != want.KDF.Memory || got.KDF.Threads != want.KDF.Threads ||
+		!bytes.Equal(got.KDF.Salt, want.KDF.Salt) || !bytes.Equal(got.IV, want.IV) {
+		t.Fatalf("заголовок %+v, ожидается %+v", got, want)
+	}
+}
+
+// TestContainerTamper: изменение любого байта заголовка после его длины —
+// поля, IV или MAC — обнаруживается до расшифровки
+func TestContainerTamper(t *testing.T) {
+	encrypted, data, key := encryptedXTS(t)
+	decrypted := filepath.Join(t.TempDir(), "decrypted")
+	for pos := len(containerMagic) + 3; pos < headerSize(xtsMode{}); pos++ {
+		changed := bytes.Clone(data)
+		changed[pos] ^= 0x80
+		if err := os.WriteFile(encrypted, changed, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := decryptFile(encrypted, decrypted, key, AESKeySize256); err == nil {
+			t.Fatalf("изменен байт заголовка %d: файл расшифрован", pos)
+		}
+	}
+}
+
+// TestForeignFiles: случайные данные, файл DES, AES-файл с другим шифром
+// в заголовке и обрезанный заголовок отвергаются без создания выходного
+// файла
+func TestForeignFiles(t *testing.T) {
+	encrypted, data, key := encryptedXTS(t)
+	decrypted := filepath.Join(t.TempDir(), "decrypted")
+	other := &fileHeader{Version: containerVersion, Cipher: cipherDES, Mode: modeIDCBC, KeyBits: 64, IV: randomBytes(8)}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"случайные данные", randomBytes(100)},
+		{"файл DES", append([]byte("DESK"), randomBytes(100)...)},
+		{"шифр DES", append(other.seal(randomBytes(8)), randomBytes(16)...)},
+		{"обрезанный заголовок", data[:headerSize(xtsMode{})-1]},
+		{"пустой файл", nil},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(encrypted, f.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := decryptFile(encrypted, decrypted, key, AESKeySize256); err == nil {
+			t.Fatalf("%s: файл расшифрован", f.name)
+		}
+		if _, err := os.Stat(decrypted); !os.IsNotExist(err) {
+			t.Fatalf("%s: создан выходной файл", f.name)
+		}
+	}
+}
+
+// TestLegacyFile: файл старого формата (IV и шифртекст CBC без заголовка)
+// расшифровывается decryptLegacyFile, но не decryptFile. Дополнение там
+// добавлялось только к неполному блоку.
+func TestLegacyFile(t *testing.T) {
+	key := randomBytes(32)
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "encrypted")
+	decrypted := filepath.Join(dir, "decrypted")
+	a, err := NewAES(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 1, 100, 32} {
+		data := randomBytes(size)
+		if size == 32 {
+			data[size-1] = 0xAA // не похоже на дополнение
+		}
+		iv := randomBytes(AESBlockSize)
+		body := bytes.Clone(data)
+		if rem := size % AESBlockSize; rem != 0 {
+			body = append(body, bytes.Repeat([]byte{byte(AESBlockSize - rem)}, AESBlockSize-rem)...)
+		}
+		newCBC(a, iv, true).CryptBlocks(body, body)
+		if err := os.WriteFile(encrypted, append(iv, body...), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := decryptFile(encrypted, decrypted, key, AESKeySize256); !errors.Is(err, errNotContainer) {
+			t.Fatalf("%d байт: без -legacy получено %v", size, err)
+		}
+		if err := decryptLegacyFile(encrypted, decrypted, key, AESKeySize256); err != nil {
+			t.Fatalf("%d байт: %v", size, err)
+		}
+		got, err := os.ReadFile(decrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%d байт: расшифрованный файл не совпадает с исходным", size)
+		}
+	}
+}
+
+// TestDecryptedFileMode: расшифрованный файл создается с правами 0644, а
+// не 0600 временного файла
+func TestDecryptedFileMode(t *testing.T) {
+	encrypted, _, key := encryptedXTS(t)
+	decrypted := filepath.Join(t.TempDir(), "decrypted")
+	if err := decryptFile(encrypted, decrypted, key, AESKeySize256); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Fatalf("права расшифрованного файла %v, ожидается 0644", info.Mode().Perm())
+	}
+}
@@ -1,3 +1,7 @@
+//go:build ignore
+
+// Копия ранней версии, оставлена для сравнения и в сборку не входит.
+
 package main
 
 import (
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// Режим GCM (NIST SP 800-38D) поверх собственного блочного шифра:
+// шифрование в режиме счетчика и аутентификация GHASH — умножением в
+// GF(2^128) на H = E_K(0^128).
+
+const (
+	GCMNonceSize = 12
+	GCMTagSize   = 16
+	// gcmMaxPlaintext наибольшая длина данных в байтах (2^39-256 бит):
+	// 32-битный счетчик после 2^32-2 блоков вернулся бы к J0 и повторил
+	// keystream
+	gcmMaxPlaintext = (1<<32 - 2) * AESBlockSize
+)
+
+// errAuthFailed тег не совпал: шифртекст, заголовок или ключ изменены
+var errAuthFailed = errors.New("ошибка аутентификации: файл поврежден или ключ неверный")
+
+// errGCMTooLong данные длиннее gcmMaxPlaintext
+var errGCMTooLong = errors.New("GCM: данные длиннее 2^39-256 бит (64 ГиБ)")
+
+// gfElement элемент GF(2^128); бит 0 — старший бит hi, как в SP 800-38D
+type gfElement struct {
+	hi, lo uint64
+}
+
+func gfLoad(b []byte) gfElement {
+	return gfElement{binary.BigEndian.Uint64(b), binary.BigEndian.Uint64(b[8:])}
+}
+
+// gfMul умножение в GF(2^128) по модулю x^128 + x^7 + x^2 + x + 1
+// (алгоритм 1 из SP 800-38D)
+func gfMul(x, y gfElement) gfElement {
+	var z gfElement
+	v := y
+	for i := 0; i < 128; i++ {
+		word := x.hi
+		if i >= 64 {
+			word = x.lo
+		}
+		// Без ветвлений по секретным данным: маска из бита x
+		mask := -(word >> (63 - uint(i%64)) & 1)
+		z.hi ^= v.hi & mask
+		z.lo ^= v.lo & mask
+
+		carry := -(v.lo & 1)
+		v.lo = v.lo>>1 | v.hi<<63
+		v.hi = v.hi>>1 ^ 0xE100000000000000&carry
+	}
+	return z
+}
+
+// ghash потоковое вычисление GHASH_H. Данные дополняются нулями до целого
+// блока отдельно для ассоциированных данных и шифртекста (см. pad).
+type ghash struct {
+	h, y gfElement
+	buf  [AESBlockSize]byte
+	n    int
+}
+
+func (g *ghash) write(data []byte) {
+	for len(data) > 0 {
+		k := copy(g.buf[g.n:], data)
+		g.n += k
+		data = data[k:]
+		if g.n == AESBlockSize {
+			g.block()
+		}
+	}
+}
+
+func (g *ghash) block() {
+	x := gfLoad(g.buf[:])
+	g.y = gfMul(gfElement{g.y.hi ^ x.hi, g.y.lo ^ x.lo}, g.h)
+	g.n = 0
+}
+
+// pad дополняет последний неполный блок нулями
+func (g *ghash) pad() {
+	if g.n > 0 {
+		clear(g.buf[g.n:])
+		g.block()
+	}
+}
+
+// sum завершает вычисление блоком длин (в битах) и возвращает S
+func (g *ghash) sum(adLen, ctLen uint64) [AESBlockSize]byte {
+	g.pad()
+	binary.BigEndian.PutUint64(g.buf[:], adLen*8)
+	binary.BigEndian.PutUint64(g.buf[8:], ctLen*8)
+	g.n = AESBlockSize
+	g.block()
+	var s [AESBlockSize]byte
+	binary.BigEndian.PutUint64(s[:], g.y.hi)
+	binary.BigEndian.PutUint64(s[8:], g.y.lo)
+	return s
+}
+
+// gcmStream шифрование или расшифровка GCM по частям произвольной длины:
+// сначала ассоциированные данные, затем данные через crypt, в конце tag.
+type gcmStream struct {
+	b         cipher.Block
+	encrypt   bool
+	j0        [AESBlockSize]byte
+	counter   [AESBlockSize]byte
+	keystream [AESBlockSize]byte
+	used      int // использованные байты keystream
+	g         ghash
+	adLen     uint64
+	ctLen     uint64
+}
+
+// newGCMStream начинает шифрование (encrypt) или расшифровку с 96-битным
+// nonce и ассоциированными данными ad
+func newGCMStream(b cipher.Block, nonce, ad []byte, encrypt bool) (*gcmStream, error) {
+	if b.BlockSize() != AESBlockSize {
+		return nil, errors.New("GCM требует шифр со 128-битным блоком")
+	}
+	if len(nonce) != GCMNonceSize {
+		return nil, errors.New("GCM: nonce должен быть 12 байт")
+	}
+	s := &gcmStream{b: b, encrypt: encrypt, used: AESBlockSize}
+	var h [AESBlockSize]byte
+	b.Encrypt(h[:], h[:])
+	s.g.h = gfLoad(h[:])
+
+	// J0 = nonce || 0^31 || 1, данные шифруются начиная с inc32(J0)
+	copy(s.j0[:], nonce)
+	s.j0[AESBlockSize-1] = 1
+	s.counter = s.j0
+
+	s.g.write(ad)
+	s.g.pad()
+	s.adLen = uint64(len(ad))
+	return s, nil
+}
+
+// crypt шифрует или расшифровывает src в dst (dst и src могут совпадать).
+// Если вместе с уже обработанными данные длиннее gcmMaxPlaintext,
+// возвращает errGCMTooLong, не обрабатывая src.
+func (s *gcmStream) crypt(dst, src []byte) error {
+	if uint64(len(src)) > gcmMaxPlaintext-s.ctLen {
+		return errGCMTooLong
+	}
+	if !s.encrypt {
+		s.g.write(src)
+	}
+	for i := range src {
+		if s.used == AESBlockSize {
+			ctr := binary.BigEndian.Uint32(s.counter[12:])
+			binary.BigEndian.PutUint32(s.counter[12:], ctr+1)
+			s.b.Encrypt(s.keystream[:], s.counter[:])
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.keystream[s.used]
+		s.used++
+	}
+	if s.encrypt {
+		s.g.write(dst[:len(src)])
+	}
+	s.ctLen += uint64(len(src))
+	return nil
+}
+
+// tag возвращает тег T = E_K(J0) ⊕ GHASH
+func (s *gcmStream) tag() []byte {
+	sum := s.g.sum(s.adLen, s.ctLen)
+	t := make([]byte, AESBlockSize)
+	s.b.Encrypt(t, s.j0[:])
+	for i := range t {
+		t[i] ^= sum[i]
+	}
+	return t[:GCMTagSize]
+}
+
+// gcm реализует cipher.AEAD для сообщений в памяти
+type gcm struct {
+	b cipher.Block
+}
+
+func newGCM(b cipher.Block) (cipher.AEAD, error) {
+	if b.BlockSize() != AESBlockSize {
+		return nil, errors.New("GCM требует шифр со 128-битным блоком")
+	}
+	return &gcm{b: b}, nil
+}
+
+func (g *gcm) NonceSize() int { return GCMNonceSize }
+func (g *gcm) Overhead() int  { return GCMTagSize }
+
+func (g *gcm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	s, err := newGCMStream(g.b, nonce, additionalData, true)
+	if err != nil {
+		panic(err)
+	}
+	out := make([]byte, len(plaintext), len(plaintext)+GCMTagSize)
+	if err := s.crypt(out, plaintext); err != nil {
+		panic(err)
+	}
+	return append(dst, append(out, s.tag()...)...)
+}
+
+func (g *gcm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < GCMTagSize {
+		return nil, errAuthFailed
+	}
+	s, err := newGCMStream(g.b, nonce, additionalData, false)
+	if err != nil {
+		return nil, err
+	}
+	body, tag := ciphertext[:len(ciphertext)-GCMTagSize], ciphertext[len(ciphertext)-GCMTagSize:]
+	out := make([]byte, len(body))
+	if err := s.crypt(out, body); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(s.tag(), tag) != 1 {
+		clear(out)
+		return nil, errAuthFailed
+	}
+	return append(dst, out...), nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGCMVectors тестовые векторы из описания GCM (McGrew, Viega),
+// использованные в NIST SP 800-38D: случаи 1-4 (AES-128) и 13-16 (AES-256)
+func TestGCMVectors(t *testing.T) {
+	const (
+		key3   = "feffe9928665731c6d6a8f9467308308"
+		nonce3 = "cafebabefacedbaddecaf888"
+		plain3 = "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72" +
+			"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b391aafd255"
+		ad4 = "feedfacedeadbeeffeedfacedeadbeefabaddad2"
+	)
+	vectors := []struct{ name, key, nonce, plain, ad, cipher, tag string }{
+		{"1", "00000000000000000000000000000000", "000000000000000000000000", "", "", "",
+			"58e2fccefa7e3061367f1d57a4e7455a"},
+		{"2", "00000000000000000000000000000000", "000000000000000000000000",
+			"00000000000000000000000000000000", "", "0388dace60b6a392f328c2b971b2fe78",
+			"ab6e47d42cec13bdf53a67b21257bddf"},
+		{"3", key3, nonce3, plain3, "",
+			"42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+				"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091473f5985",
+			"4d5c2af327cd64a62cf35abd2ba6fab4"},
+		{"4", key3, nonce3, plain3[:120], ad4,
+			"42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+				"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091",
+			"5bc94fbc3221a5db94fae95ae7121a47"},
+		{"13", "0000000000000000000000000000000000000000000000000000000000000000",
+			"000000000000000000000000", "", "", "", "530f8afbc74536b9a963b4f1c4cb738b"},
+		{"14", "0000000000000000000000000000000000000000000000000000000000000000",
+			"000000000000000000000000", "00000000000000000000000000000000", "",
+			"cea7403d4d606b6e074ec5d3baf39d18", "d0d1c8a799996bf0265b98b5d48ab919"},
+		{"16", key3 + key3, nonce3, plain3[:120], ad4,
+			"522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa" +
+				"8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662",
+			"76fc6ece0f4e1768cddf8853bb2d551b"},
+	}
+	for _, v := range vectors {
+		g, err := newGCM(ownBlock(t, unhex(v.key)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := unhex(v.cipher + v.tag)
+		got := g.Seal(nil, unhex(v.nonce), unhex(v.plain), unhex(v.ad))
+		if !bytes.Equal(got, want) {
+			t.Fatalf("случай %s: получено %x, ожидается %x", v.name, got, want)
+		}
+		plain, err := g.Open(nil, unhex(v.nonce), want, unhex(v.ad))
+		if err != nil || !bytes.Equal(plain, unhex(v.plain)) {
+			t.Fatalf("случай %s: расшифровка: %v", v.name, err)
+		}
+	}
+}
+
+// TestGCMAgainstStdlib: случайные длины данных и ассоциированных данных
+func TestGCMAgainstStdlib(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		key := randomBytes(16 + 8*(i%3))
+		a, err := NewAES(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := newGCM(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		std, err := cipher.NewGCM(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce := randomBytes(GCMNonceSize)
+		plain := randomBytes(i)
+		ad := randomBytes(i % 37)
+		got := g.Seal(nil, nonce, plain, ad)
+		if want := std.Seal(nil, nonce, plain, ad); !bytes.Equal(got, want) {
+			t.Fatalf("%d байт: получено %x, ожидается %x", i, got, want)
+		}
+		if _, err := std.Open(nil, nonce, got, ad); err != nil {
+			t.Fatalf("%d байт: crypto/cipher не принял шифртекст: %v", i, err)
+		}
+	}
+}
+
+// TestGCMFiles: длина файла — заголовок, данные и тег
+func TestGCMFiles(t *testing.T) {
+	for _, size := range []int{0, 1, 15, 16, 17, 100000} {
+		info, err := os.Stat(fileRoundTrip(t, size, ModeGCM))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := int64(headerSize(gcmMode{}) + size + GCMTagSize); info.Size() != want {
+			t.Fatalf("%d байт: длина шифртекста %d, ожидается %d", size, info.Size(), want)
+		}
+	}
+}
+
+// TestGCMTamper: изменение одного бита в шифртексте или теге дает
+// errAuthFailed, в nonce (заголовке) и неверный ключ — errHeaderAuth;
+// выходной файл не создается
+func TestGCMTamper(t *testing.T) {
+	key := randomBytes(16)
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain")
+	encrypted := filepath.Join(dir, "encrypted")
+	decrypted := filepath.Join(dir, "decrypted")
+	if err := os.WriteFile(plain, randomBytes(100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := encryptFile(plain, encrypted, key, AESKeySize128, ModeGCM); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := headerSize(gcmMode{})
+	for _, c := range []struct {
+		pos  int
+		want error
+	}{
+		{header - headerMACSize - 1, errHeaderAuth},
+		{header + 50, errAuthFailed},
+		{len(data) - 1, errAuthFailed},
+	} {
+		changed := bytes.Clone(data)
+		changed[c.pos] ^= 1
+		if err := os.WriteFile(encrypted, changed, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := decryptFile(encrypted, decrypted, key, AESKeySize128); !errors.Is(err, c.want) {
+			t.Fatalf("изменен байт %d: получено %v", c.pos, err)
+		}
+		if _, err := os.Stat(decrypted); !os.IsNotExist(err) {
+			t.Fatalf("изменен байт %d: создан выходной файл", c.pos)
+		}
+	}
+
+	if err := os.WriteFile(encrypted, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := decryptFile(encrypted, decrypted, randomBytes(16), AESKeySize128); !errors.Is(err, errHeaderAuth) {
+		t.Fatalf("неверный ключ: получено %v", err)
+	}
+}
+
+// TestGCMMaxLength: после 2^39-256 бит данных шифрование прекращается, а
+// не повторяет keystream с J0
+func TestGCMMaxLength(t *testing.T) {
+	s, err := newGCMStream(ownBlock(t, randomBytes(16)), randomBytes(GCMNonceSize), nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Как будто обработаны все блоки, кроме последнего
+	s.ctLen = gcmMaxPlaintext - AESBlockSize
+	binary.BigEndian.PutUint32(s.counter[12:], 1<<32-2)
+	block := make([]byte, AESBlockSize)
+	if err := s.crypt(block, block); err != nil {
+		t.Fatalf("последний блок: %v", err)
+	}
+	if err := s.crypt(block[:1], block[:1]); !errors.Is(err, errGCMTooLong) {
+		t.Fatalf("байт сверх предела: получено %v", err)
+	}
+	if got := binary.BigEndian.Uint32(s.counter[12:]); got != 1<<32-1 {
+		t.Fatalf("счетчик %d", got)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Получение ключа AES из пароля. Параметры KDF и соль записываются в
+// заголовок файла (container.go), поэтому при расшифровке нужен только
+// пароль. Поля заголовка kdf_time, kdf_memory, kdf_threads:
+//
+//	argon2id  t (проходы), m (КиБ), p (потоки)
+//	scrypt    N, r, p
+//	pbkdf2    i (итерации HMAC-SHA256), 0, 0
+//
+// Argon2id — по умолчанию: он требует много памяти, что замедляет
+// перебор паролей на GPU и специализированном оборудовании.
+
+// Параметры KDF читаются из заголовка до проверки его MAC: ключ MAC сам
+// получается из KDF. Поэтому стоимость KDF ограничена сверху, иначе
+// подделанный файл заставит расшифровщик выделить гигабайты памяти или
+// считать часами. Число проходов ограничено всегда; память при
+// расшифровке — флагом -max-kdf-memory (по умолчанию defaultMaxKDFMemory).
+const (
+	kdfSaltSize = 16
+	// maxKDFMemory наибольшая память KDF в байтах, в том числе с
+	// -max-kdf-memory
+	maxKDFMemory = 4 << 30
+	// defaultMaxKDFMemory предел памяти KDF при расшифровке по умолчанию
+	defaultMaxKDFMemory = 256 << 20
+	// maxArgon2Time наибольшее число проходов Argon2id
+	maxArgon2Time = 32
+	// maxScryptParallel наибольшее p scrypt: время растет линейно по p
+	// без роста памяти
+	maxScryptParallel = 16
+	// maxPBKDF2Iterations наибольшее число итераций PBKDF2
+	maxPBKDF2Iterations = 10_000_000
+)
+
+var kdfIDs = map[string]byte{"argon2id": kdfArgon2id, "scrypt": kdfScrypt, "pbkdf2": kdfPBKDF2}
+
+// kdfByName код KDF по имени из флага -kdf
+func kdfByName(name string) (byte, error) {
+	if id, ok := kdfIDs[name]; ok {
+		return id, nil
+	}
+	return 0, fmt.Errorf("неизвестная KDF %q, ожидается argon2id, scrypt или pbkdf2", name)
+}
+
+// defaultKDFParams параметры по умолчанию: Argon2id — второй
+// рекомендуемый вариант RFC 9106, scrypt — N=2^15, PBKDF2 — 600000
+// итераций (рекомендация OWASP для HMAC-SHA256)
+func defaultKDFParams(id byte) kdfParams {
+	switch id {
+	case kdfArgon2id:
+		return kdfParams{ID: id, Time: 3, Memory: 64 * 1024, Threads: 4}
+	case kdfScrypt:
+		return kdfParams{ID: id, Time: 1 << 15, Memory: 8, Threads: 1}
+	case kdfPBKDF2:
+		return kdfParams{ID: id, Time: 600000}
+	}
+	return kdfParams{ID: id}
+}
+
+// kdfParamNames имена параметров в -kdf-params в порядке полей Time, Memory, Threads
+func kdfParamNames(id byte) []string {
+	switch id {
+	case kdfArgon2id:
+		return []string{"t", "m", "p"}
+	case kdfScrypt:
+		return []string{"N", "r", "p"}
+	case kdfPBKDF2:
+		return []string{"i"}
+	}
+	return nil
+}
+
+// parseKDFParams разбирает строку вида "t=3,m=65536,p=4"; не указанные
+// параметры берутся по умолчанию
+func parseKDFParams(id byte, s string) (kdfParams, error) {
+	p := defaultKDFParams(id)
+	if s == "" {
+		return p, nil
+	}
+	names := kdfParamNames(id)
+	values := []uint32{p.Time, p.Memory, uint32(p.Threads)}
+	for _, item := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(item, "=")
+		i := -1
+		for j, n := range names {
+			if n == strings.TrimSpace(name) {
+				i = j
+			}
+		}
+		if !ok || i < 0 {
+			return p, fmt.Errorf("неверный параметр KDF %q, ожидается %s", item, strings.Join(names, "=..., ")+"=...")
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+		if err != nil {
+			return p, fmt.Errorf("неверное значение параметра %s: %w", name, err)
+		}
+		values[i] = uint32(v)
+	}
+	if values[2] > 255 {
+		return p, fmt.Errorf("число потоков %d больше 255", values[2])
+	}
+	p.Time, p.Memory, p.Threads = values[0], values[1], byte(values[2])
+	return p, checkKDFParams(p)
+}
+
+// formatKDFParams параметры в виде для -kdf-params
+func formatKDFParams(p kdfParams) string {
+	values := []uint32{p.Time, p.Memory, uint32(p.Threads)}
+	var parts []string
+	for i, name := range kdfParamNames(p.ID) {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, values[i]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// checkKDFParams проверяет параметры, в том числе прочитанные из заголовка
+func checkKDFParams(p kdfParams) error {
+	switch p.ID {
+	case kdfArgon2id:
+		if p.Time < 1 || p.Threads < 1 {
+			return errors.New("argon2id: t и p должны быть не меньше 1")
+		}
+		if p.Time > maxArgon2Time {
+			return fmt.Errorf("argon2id: t=%d больше ограничения %d", p.Time, maxArgon2Time)
+		}
+		if p.Memory < 8*uint32(p.Threads) {
+			return fmt.Errorf("argon2id: m=%d КиБ меньше 8*p", p.Memory)
+		}
+	case kdfScrypt:
+		if p.Time < 2 || p.Time&(p.Time-1) != 0 {
+			return fmt.Errorf("scrypt: N=%d должно быть степенью двойки больше 1", p.Time)
+		}
+		if p.Memory < 1 || p.Threads < 1 || uint64(p.Memory)*uint64(p.Threads) >= 1<<30 {
+			return errors.New("scrypt: r и p должны быть не меньше 1, r*p меньше 2^30")
+		}
+		if p.Threads > maxScryptParallel {
+			return fmt.Errorf("scrypt: p=%d больше ограничения %d", p.Threads, maxScryptParallel)
+		}
+	case kdfPBKDF2:
+		if p.Time < 1 {
+			return errors.New("pbkdf2: число итераций должно быть не меньше 1")
+		}
+		if p.Time > maxPBKDF2Iterations {
+			return fmt.Errorf("pbkdf2: i=%d больше ограничения %d", p.Time, maxPBKDF2Iterations)
+		}
+	default:
+		return fmt.Errorf("неизвестный код KDF %d", p.ID)
+	}
+	if err := checkKDFMemory(p, maxKDFMemory); err != nil {
+		return err
+	}
+	if len(p.Salt) > 0 && len(p.Salt) < 8 {
+		return fmt.Errorf("соль %d байт короче 8", len(p.Salt))
+	}
+	return nil
+}
+
+// kdfMemory память в байтах, которую выделит KDF с параметрами p
+func kdfMemory(p kdfParams) uint64 {
+	switch p.ID {
+	case kdfArgon2id:
+		return uint64(p.Memory) * 1024
+	case kdfScrypt:
+		return 128 * uint64(p.Memory) * uint64(p.Time)
+	}
+	return 0
+}
+
+// checkKDFMemory проверяет, что KDF выделит не больше limit байт
+func checkKDFMemory(p kdfParams, limit uint64) error {
+	if m := kdfMemory(p); m > limit {
+		return fmt.Errorf("KDF требует %d МиБ памяти, ограничение %d МиБ", m>>20, limit>>20)
+	}
+	return nil
+}
+
+// newKDFSalt заполняет соль случайными байтами
+func newKDFSalt(p *kdfParams) error {
+	p.Salt = make([]byte, kdfSaltSize)
+	_, err := rand.Read(p.Salt)
+	return err
+}
+
+// deriveKey ключ длины keyLen из пароля по параметрам p
+func deriveKey(p kdfParams, password []byte, keyLen int) ([]byte, error) {
+	if err := checkKDFParams(p); err != nil {
+		return nil, err
+	}
+	switch p.ID {
+	case kdfArgon2id:
+		return argon2id(password, p.Salt, nil, nil, p.Time, p.Memory, p.Threads, uint32(keyLen)), nil
+	case kdfScrypt:
+		return scryptKey(password, p.Salt, int(p.Time), int(p.Memory), int(p.Threads), keyLen)
+	default:
+		return pbkdf2.Key(sha256.New, string(password), p.Salt, int(p.Time), keyLen)
+	}
+}
+
+// passwordFlags источники пароля: -password, -password-file, -password-env
+type passwordFlags struct {
+	value, file, env string
+}
+
+func addPasswordFlags(fs *flag.FlagSet) *passwordFlags {
+	pf := &passwordFlags{}
+	fs.StringVar(&pf.value, "password", "", "пароль (виден в списке процессов и истории команд)")
+	fs.StringVar(&pf.file, "password-file", "", "файл, первая строка которого — пароль")
+	fs.StringVar(&pf.env, "password-env", "", "переменная окружения с паролем")
+	return pf
+}
+
+// set указан ли какой-либо источник пароля
+func (pf *passwordFlags) set() bool {
+	return pf.value != "" || pf.file != "" || pf.env != ""
+}
+
+// read возвращает пароль из единственного указанного источника
+func (pf *passwordFlags) read() ([]byte, error) {
+	count := 0
+	for _, s := range []string{pf.value, pf.file, pf.env} {
+		if s != "" {
+			count++
+		}
+	}
+	if count != 1 {
+		return nil, errors.New("укажите ровно один из флагов -password, -password-file, -password-env")
+	}
+	var password string
+	switch {
+	case pf.value != "":
+		password = pf.value
+	case pf.file != "":
+		data, err := os.ReadFile(pf.file)
+		if err != nil {
+			return nil, err
+		}
+		password, _, _ = strings.Cut(string(data), "\n")
+		password = strings.TrimSuffix(password, "\r")
+	default:
+		password = os.Getenv(pf.env)
+		if password == "" {
+			return nil, fmt.Errorf("переменная окружения %s пуста или не задана", pf.env)
+		}
+	}
+	if password == "" {
+		return nil, errors.New("пустой пароль")
+	}
+	return []byte(password), nil
+}
+
+// kdfBenchCommand выполняет "kdfbench": подбирает для каждой KDF
+// параметры, при которых получение ключа занимает около target на этой
+// машине, не превышая maxMemory
+func kdfBenchCommand(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("kdfbench", flag.ContinueOnError)
+	targetMs := fs.Int("target", 1000, "желаемое время получения ключа, мс")
+	maxMemoryMiB := fs.Int("max-memory", defaultMaxKDFMemory>>20, "наибольшая память KDF, МиБ (больше предела расшифровки по умолчанию — нужен -max-kdf-memory)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *targetMs < 10 {
+		return fmt.Errorf("время %d мс слишком мало", *targetMs)
+	}
+	if *maxMemoryMiB < 1 || uint64(*maxMemoryMiB)<<20 > maxKDFMemory {
+		return fmt.Errorf("память должна быть от 1 до %d МиБ", maxKDFMemory>>20)
+	}
+	target := time.Duration(*targetMs) * time.Millisecond
+	maxMemory := uint64(*maxMemoryMiB) << 20
+	password := []byte("kdfbench")
+
+	measure := func(p kdfParams) (time.Duration, error) {
+		p.Salt = make([]byte, kdfSaltSize)
+		start := time.Now()
+		_, err := deriveKey(p, password, 32)
+		return time.Since(start), err
+	}
+	report := func(name string, p kdfParams, elapsed time.Duration, memory uint64) {
+		fmt.Fprintf(w, "  %-9s %6d мс %7d МиБ   -kdf %s -kdf-params %s\n",
+			name, elapsed.Milliseconds(), memory>>20, name, formatKDFParams(p))
+	}
+	fmt.Fprintf(w, "Подбор параметров KDF: цель %v, память до %d МиБ, процессоров %d\n", target, *maxMemoryMiB, runtime.NumCPU())
+	fmt.Fprintf(w, "  %-9s %9s %11s   параметры\n", "KDF", "время", "память")
+
+	// Argon2id: t=3, p — по числу процессоров (до 4), память удваивается
+	// до цели; если цель не достигнута при наибольшей памяти, растет t
+	p := kdfParams{ID: kdfArgon2id, Time: 3, Memory: 16 * 1024, Threads: byte(min(4, runtime.NumCPU()))}
+	elapsed, err := measure(p)
+	if err != nil {
+		return err
+	}
+	for elapsed < target/2 && uint64(p.Memory)*2048 <= maxMemory {
+		p.Memory *= 2
+		if elapsed, err = measure(p); err != nil {
+			return err
+		}
+	}
+	if elapsed < target/2 {
+		p.Time = uint32(min(maxArgon2Time, max(1, int64(p.Time)*int64(target)/int64(elapsed))))
+		if elapsed, err = measure(p); err != nil {
+			return err
+		}
+	}
+	report("argon2id", p, elapsed, kdfMemory(p))
+
+	// scrypt: r=8, p=1, N удваивается до цели или предела памяти
+	p = kdfParams{ID: kdfScrypt, Time: 1 << 14, Memory: 8, Threads: 1}
+	if elapsed, err = measure(p); err != nil {
+		return err
+	}
+	for elapsed < target/2 && 128*8*uint64(p.Time)*2 <= maxMemory {
+		p.Time *= 2
+		if elapsed, err = measure(p); err != nil {
+			return err
+		}
+	}
+	report("scrypt", p, elapsed, kdfMemory(p))
+
+	// PBKDF2: время линейно по числу итераций
+	p = kdfParams{ID: kdfPBKDF2, Time: 100000}
+	if elapsed, err = measure(p); err != nil {
+		return err
+	}
+	iterations := int64(p.Time) * int64(target) / int64(max(elapsed, 1))
+	p.Time = uint32(max(10000, min(iterations, maxPBKDF2Iterations)/10000*10000))
+	if elapsed, err = measure(p); err != nil {
+		return err
+	}
+	report("pbkdf2", p, elapsed, 0)
+
+	fmt.Fprintln(w, "\nArgon2id и scrypt требуют много памяти, что замедляет перебор паролей на GPU;")
+	fmt.Fprintln(w, "PBKDF2 — только для совместимости. Параметры должны подходить и машине, на")
+	fmt.Fprintln(w, "которой файл будут расшифровывать: она выделит столько же памяти.")
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestKDFParams: разбор -kdf-params, значения по умолчанию и отказ от
+// неверных параметров
+func TestKDFParams(t *testing.T) {
+	for _, s := range []string{"t=1,m=64,p=2", "N=16,r=1,p=1", "i=1000"} {
+		id := map[byte]byte{'t': kdfArgon2id, 'N': kdfScrypt, 'i': kdfPBKDF2}[s[0]]
+		p, err := parseKDFParams(id, s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if got := formatKDFParams(p); got != s {
+			t.Fatalf("параметры %q записаны как %q", s, got)
+		}
+	}
+	for name, id := range kdfIDs {
+		p, err := parseKDFParams(id, "")
+		if err != nil || formatKDFParams(p) != formatKDFParams(defaultKDFParams(id)) {
+			t.Fatalf("%s: параметры по умолчанию %+v, %v", name, p, err)
+		}
+	}
+
+	bad := []struct {
+		id byte
+		s  string
+	}{
+		{kdfArgon2id, "t=0"},
+		{kdfArgon2id, "t=33"},
+		{kdfArgon2id, "m=4194305"},
+		{kdfArgon2id, "m=7,p=1"},
+		{kdfArgon2id, "p=256"},
+		{kdfArgon2id, "x=1"},
+		{kdfArgon2id, "t"},
+		{kdfScrypt, "N=1000"},
+		{kdfScrypt, "N=1"},
+		{kdfScrypt, "r=0"},
+		{kdfScrypt, "p=17"},
+		{kdfPBKDF2, "i=0"},
+		{kdfPBKDF2, "i=-1"},
+		{kdfPBKDF2, "i=10000001"},
+	}
+	for _, b := range bad {
+		if _, err := parseKDFParams(b.id, b.s); err == nil {
+			t.Errorf("KDF %d: параметры %q приняты", b.id, b.s)
+		}
+	}
+	if _, err := kdfByName("bcrypt"); err == nil {
+		t.Error("неизвестная KDF принята")
+	}
+}
+
+// TestPasswordFiles: файлы, зашифрованные по паролю каждой KDF (с малыми
+// параметрами для скорости), расшифровываются тем же паролем; неверный
+// пароль и файл ключа вместо пароля отвергаются без создания выходного
+// файла
+func TestPasswordFiles(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain")
+	encrypted := filepath.Join(dir, "encrypted")
+	decrypted := filepath.Join(dir, "decrypted")
+	data := randomBytes(1000)
+	if err := os.WriteFile(plain, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	password := []byte("correct horse battery staple")
+	cases := []struct{ kdf, params, mode string }{
+		{"argon2id", "t=1,m=64,p=2", ModeGCM},
+		{"scrypt", "N=16,r=1,p=1", ModeCBC},
+		{"pbkdf2", "i=1000", ModeXTS},
+	}
+	for _, c := range cases {
+		id, err := kdfByName(c.kdf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params, err := parseKDFParams(id, c.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := encryptFileWithPassword(plain, encrypted, password, params, 256, c.mode); err != nil {
+			t.Fatalf("%s: %v", c.kdf, err)
+		}
+		if err := decryptFileWithPassword(encrypted, decrypted, password, defaultMaxKDFMemory); err != nil {
+			t.Fatalf("%s: %v", c.kdf, err)
+		}
+		got, err := os.ReadFile(decrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%s: расшифрованный файл не совпадает с исходным", c.kdf)
+		}
+		os.Remove(decrypted)
+		if err := decryptFileWithPassword(encrypted, decrypted, []byte("wrong"), defaultMaxKDFMemory); !errors.Is(err, errHeaderAuth) {
+			t.Fatalf("%s: неверный пароль: получено %v", c.kdf, err)
+		}
+		if err := decryptFile(encrypted, decrypted, randomBytes(32), AESKeySize256); err == nil {
+			t.Fatalf("%s: файл по паролю расшифрован файлом ключа", c.kdf)
+		}
+		if _, err := os.Stat(decrypted); !os.IsNotExist(err) {
+			t.Fatalf("%s: создан выходной файл", c.kdf)
+		}
+	}
+}
+
+// passwordHeaderFile пишет файл с заголовком по паролю с параметрами kdf,
+// запечатанным случайным ключом, и возвращает путь к нему
+func passwordHeaderFile(t *testing.T, kdf kdfParams) string {
+	t.Helper()
+	encrypted := filepath.Join(t.TempDir(), "encrypted")
+	kdf.Salt = randomBytes(kdfSaltSize)
+	h := &fileHeader{Version: containerVersion, Cipher: cipherAES, Mode: modeIDGCM, KeyBits: 256,
+		KDF: kdf, IV: randomBytes(GCMNonceSize)}
+	if err := os.WriteFile(encrypted, append(h.seal(randomBytes(32)), randomBytes(GCMTagSize)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return encrypted
+}
+
+// TestExcessiveKDFParams: заголовок с дорогими параметрами KDF отвергается
+// до запуска KDF и до проверки MAC. Память Argon2id 512 МиБ допустима, но
+// больше предела расшифровки по умолчанию.
+func TestExcessiveKDFParams(t *testing.T) {
+	for _, kdf := range []kdfParams{
+		{ID: kdfArgon2id, Time: 1, Memory: 1 << 31, Threads: 1},
+		{ID: kdfArgon2id, Time: 1, Memory: 512 << 10, Threads: 1},
+		{ID: kdfArgon2id, Time: 1 << 20, Memory: 64, Threads: 1},
+		{ID: kdfScrypt, Time: 1 << 20, Memory: 8, Threads: 1},
+		{ID: kdfScrypt, Time: 16, Memory: 1, Threads: 255},
+		{ID: kdfPBKDF2, Time: 1 << 31},
+	} {
+		encrypted := passwordHeaderFile(t, kdf)
+		decrypted := filepath.Join(filepath.Dir(encrypted), "decrypted")
+		start := time.Now()
+		err := decryptFileWithPassword(encrypted, decrypted, []byte("password"), defaultMaxKDFMemory)
+		if err == nil || errors.Is(err, errHeaderAuth) {
+			t.Fatalf("%+v: получено %v", kdf, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("%+v: отказ через %v — KDF запущена", kdf, elapsed)
+		}
+	}
+}
+
+// TestKDFMemoryLimit: с большим пределом памяти тот же заголовок доходит до
+// проверки MAC
+func TestKDFMemoryLimit(t *testing.T) {
+	encrypted := passwordHeaderFile(t, kdfParams{ID: kdfArgon2id, Time: 1, Memory: 1024, Threads: 1})
+	decrypted := filepath.Join(filepath.Dir(encrypted), "decrypted")
+	if err := decryptFileWithPassword(encrypted, decrypted, []byte("password"), 512<<10); err == nil || errors.Is(err, errHeaderAuth) {
+		t.Fatalf("предел 512 КиБ: получено %v", err)
+	}
+	if err := decryptFileWithPassword(encrypted, decrypted, []byte("password"), 2<<20); !errors.Is(err, errHeaderAuth) {
+		t.Fatalf("предел 2 МиБ: получено %v", err)
+	}
+}
+
+// TestPasswordSources: пароль из файла (первая строка без перевода строки),
+// переменной окружения и флага; два источника сразу не принимаются
+func TestPasswordSources(t *testing.T) {
+	password := []byte("correct horse battery staple")
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(passwordFile, append(password, "\r\nвторая строка\n"...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	const env = "AES_TEST_PASSWORD"
+	t.Setenv(env, string(password))
+	for _, pf := range []passwordFlags{{file: passwordFile}, {env: env}, {value: string(password)}} {
+		got, err := pf.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, password) {
+			t.Fatalf("пароль прочитан как %q", got)
+		}
+	}
+	for _, pf := range []passwordFlags{{}, {file: passwordFile, env: env}, {env: "AES_TEST_PASSWORD_UNSET"}} {
+		if _, err := pf.read(); err == nil {
+			t.Errorf("%+v: пароль прочитан", pf)
+		}
+	}
+}
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Mode режим шифрования файла. Данные читаются из r и пишутся в w
+// потоком; iv — IV или nonce из заголовка файла (IVSize байт), header —
+// весь заголовок, режимы с аутентификацией защищают его тегом.
+type Mode interface {
+	Name() string
+	// ID код режима в заголовке файла
+	ID() byte
+	IVSize() int
+	// ChunkSize размер независимо шифруемой части, 0 — файл целиком
+	ChunkSize() int
+	Encrypt(w io.Writer, r io.Reader, key, iv, header []byte) error
+	// Decrypt расшифровывает size байт шифртекста из r
+	Decrypt(w io.Writer, r io.Reader, size int64, key, iv, header []byte) error
+}
+
+// checkKeySize проверяет длину ключа n байт для режима m: XTS требует два
+// ключа AES-128 или AES-256 (32 или 64 байта), остальные режимы — ключ
+// AES 16, 24 или 32 байта
+func checkKeySize(m Mode, n int) error {
+	if m.ID() == modeIDXTS {
+		if n != int(AESKeySize256) && n != int(XTSKeySize512) {
+			return fmt.Errorf("xts: ключ %d бит, нужен ключ 256 или 512 бит", n*BitsPerByte)
+		}
+		return nil
+	}
+	switch AESKeySize(n) {
+	case AESKeySize128, AESKeySize192, AESKeySize256:
+		return nil
+	}
+	return fmt.Errorf("%s: ключ %d бит, нужен ключ 128, 192 или 256 бит", m.Name(), n*BitsPerByte)
+}
+
+// Коды режимов в заголовке файла
+const (
+	modeIDGCM byte = 1 + iota
+	modeIDCBC
+	modeIDECB
+	modeIDCTR
+	modeIDCFB
+	modeIDOFB
+	modeIDXTS
+)
+
+// fileBufferSize размер порции данных при шифровании файла, кратен блоку
+// и сектору XTS
+const fileBufferSize = 64 * 1024
+
+var modes = []Mode{
+	gcmMode{},
+	&blockMode{id: modeIDCBC, name: ModeCBC, ivSize: AESBlockSize,
+		encrypter: func(b cipher.Block, iv []byte) cipher.BlockMode { return newCBC(b, iv, true) },
+		decrypter: func(b cipher.Block, iv []byte) cipher.BlockMode { return newCBC(b, iv, false) }},
+	&blockMode{id: modeIDECB, name: ModeECB,
+		encrypter: func(b cipher.Block, _ []byte) cipher.BlockMode { return ecb{b, true} },
+		decrypter: func(b cipher.Block, _ []byte) cipher.BlockMode { return ecb{b, false} }},
+	&streamMode{id: modeIDCTR, name: ModeCTR, newStream: newCTR},
+	&streamMode{id: modeIDCFB, name: ModeCFB, newStream: newCFB},
+	&streamMode{id: modeIDOFB, name: ModeOFB, newStream: func(b cipher.Block, iv []byte, _ bool) cipher.Stream {
+		return newOFB(b, iv)
+	}},
+	xtsMode{},
+}
+
+// legacyCBC режим файлов старого формата: IV и шифртекст CBC без заголовка
+var legacyCBC = &blockMode{name: ModeCBC, ivSize: AESBlockSize, legacyPadding: true,
+	decrypter: func(b cipher.Block, iv []byte) cipher.BlockMode { return newCBC(b, iv, false) }}
+
+// modeByName режим по имени из флага -mode
+func modeByName(name string) (Mode, error) {
+	for _, m := range modes {
+		if m.Name() == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("неизвестный режим %q, ожидается один из: %s", name, modeNames())
+}
+
+// modeByID режим по коду из заголовка файла
+func modeByID(id byte) (Mode, error) {
+	for _, m := range modes {
+		if m.ID() == id {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("неизвестный код режима %d в заголовке файла", id)
+}
+
+func modeNames() string {
+	names := ""
+	for i, m := range modes {
+		if i > 0 {
+			names += ", "
+		}
+		names += m.Name()
+	}
+	return names
+}
+
+// readChunk читает в buffer до заполнения или конца данных; last — данные
+// закончились
+func readChunk(r io.Reader, buffer []byte) (n int, last bool, err error) {
+	n, err = io.ReadFull(r, buffer)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return n, true, nil
+	}
+	return n, false, err
+}
+
+// ---------------------------------------------------------------------------------------
+// Блочные режимы ECB и CBC: данные дополняются по PKCS#7 до целого блока
+
+type blockMode struct {
+	id                   byte
+	name                 string
+	ivSize               int
+	encrypter, decrypter func(b cipher.Block, iv []byte) cipher.BlockMode
+	// legacyPadding старые файлы CBC без заголовка, см. legacyUnpad
+	legacyPadding bool
+}
+
+func (m *blockMode) Name() string   { return m.name }
+func (m *blockMode) ID() byte       { return m.id }
+func (m *blockMode) ChunkSize() int { return 0 }
+func (m *blockMode) IVSize() int    { return m.ivSize }
+
+func (m *blockMode) Encrypt(w io.Writer, r io.Reader, key, iv, _ []byte) error {
+	block, err := NewAES(key)
+	if err != nil {
+		return err
+	}
+	defer block.Destroy()
+	bm := m.encrypter(block, iv)
+
+	buffer := make([]byte, fileBufferSize+AESBlockSize)
+	for {
+		n, last, err := readChunk(r, buffer[:fileBufferSize])
+		if err != nil {
+			return err
+		}
+		if last {
+			n = pkcs7Pad(buffer, n)
+		}
+		bm.CryptBlocks(buffer[:n], buffer[:n])
+		if _, err := w.Write(buffer[:n]); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+func (m *blockMode) Decrypt(w io.Writer, r io.Reader, size int64, key, iv, _ []byte) error {
+	if size%AESBlockSize != 0 || size == 0 && !m.legacyPadding {
+		return errors.New("invalid encrypted file size")
+	}
+	block, err := NewAES(key)
+	if err != nil {
+		return err
+	}
+	defer block.Destroy()
+	bm := m.decrypter(block, iv)
+
+	buffer := make([]byte, fileBufferSize)
+	for remaining := size; remaining > 0; {
+		chunk := buffer[:min(int64(len(buffer)), remaining)]
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return err
+		}
+		bm.CryptBlocks(chunk, chunk)
+		remaining -= int64(len(chunk))
+
+		if remaining == 0 {
+			n := len(chunk) - AESBlockSize
+			if m.legacyPadding {
+				chunk = chunk[:n+legacyUnpad(chunk[n:])]
+			} else {
+				last, err := pkcs7Unpad(chunk[n:])
+				if err != nil {
+					return err
+				}
+				chunk = chunk[:n+len(last)]
+			}
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PaddingError неверное дополнение PKCS#7 после расшифровки: файл
+// поврежден или ключ неверный. Подробностей о том, какой байт неверен, нет
+// намеренно: иначе ошибка стала бы оракулом дополнения.
+type PaddingError struct{}
+
+func (PaddingError) Error() string {
+	return "неверное дополнение PKCS#7: файл поврежден или ключ неверный"
+}
+
+// pkcs7Pad дополняет n байт buffer до целого числа блоков. Дополнение
+// добавляется всегда, при n кратном блоку — целый блок из байт 16, иначе
+// при расшифровке нельзя отличить дополнение от данных. В buffer должно
+// быть место для блока после n.
+func pkcs7Pad(buffer []byte, n int) int {
+	padding := byte(AESBlockSize - n%AESBlockSize)
+	for i := 0; i < int(padding); i++ {
+		buffer[n+i] = padding
+	}
+	return n + int(padding)
+}
+
+// pkcs7Unpad снимает дополнение с последнего блока. Проверяются все байты
+// дополнения за время, не зависящее от их значений.
+func pkcs7Unpad(last []byte) ([]byte, error) {
+	if len(last) != AESBlockSize {
+		return nil, PaddingError{}
+	}
+	padding := int(last[AESBlockSize-1])
+	good := subtle.ConstantTimeLessOrEq(1, padding) & subtle.ConstantTimeLessOrEq(padding, AESBlockSize)
+	for i := 0; i < AESBlockSize; i++ {
+		// Байт i входит в дополнение, если AESBlockSize-i <= padding
+		inPadding := subtle.ConstantTimeLessOrEq(AESBlockSize-i, padding)
+		equal := subtle.ConstantTimeByteEq(last[i], byte(padding))
+		good &= subtle.ConstantTimeSelect(inPadding, equal, 1)
+	}
+	if good != 1 {
+		return nil, PaddingError{}
+	}
+	return last[:AESBlockSize-padding], nil
+}
+
+// legacyUnpad длина данных последнего блока в файлах старого формата: там
+// дополнялся только неполный блок, поэтому байт 1..16 в конце считается
+// дополнением без проверки. Для файла кратной длины это может отрезать
+// данные, но отличить такие файлы нельзя.
+func legacyUnpad(last []byte) int {
+	padding := last[AESBlockSize-1]
+	if padding > 0 && padding <= AESBlockSize {
+		return AESBlockSize - int(padding)
+	}
+	return AESBlockSize
+}
+
+// ecb каждый блок шифруется независимо: одинаковые блоки открытого текста
+// дают одинаковые блоки шифртекста
+type ecb struct {
+	b       cipher.Block
+	encrypt bool
+}
+
+func (e ecb) BlockSize() int { return AESBlockSize }
+
+func (e ecb) CryptBlocks(dst, src []byte) {
+	if len(src)%AESBlockSize != 0 {
+		panic("ecb: длина данных не кратна блоку")
+	}
+	for i := 0; i < len(src); i += AESBlockSize {
+		if e.encrypt {
+			e.b.Encrypt(dst[i:], src[i:])
+		} else {
+			e.b.Decrypt(dst[i:], src[i:])
+		}
+	}
+}
+
+// cbc C_i = E(P_i ⊕ C_{i-1}), C_0 = IV
+type cbc struct {
+	b       cipher.Block
+	prev    [AESBlockSize]byte
+	encrypt bool
+}
+
+func newCBC(b cipher.Block, iv []byte, encrypt bool) *cbc {
+	c := &cbc{b: b, encrypt: encrypt}
+	copy(c.prev[:], iv)
+	return c
+}
+
+func (c *cbc) BlockSize() int { return AESBlockSize }
+
+func (c *cbc) CryptBlocks(dst, src []byte) {
+	if len(src)%AESBlockSize != 0 {
+		panic("cbc: длина данных не кратна блоку")
+	}
+	var saved [AESBlockSize]byte
+	for i := 0; i < len(src); i += AESBlockSize {
+		in, out := src[i:i+AESBlockSize], dst[i:i+AESBlockSize]
+		if c.encrypt {
+			xorBytes(out, in, c.prev[:])
+			c.b.Encrypt(out, out)
+			copy(c.prev[:], out)
+			continue
+		}
+		copy(saved[:], in) // dst и src могут совпадать
+		c.b.Decrypt(out, in)
+		xorBytes(out, out, c.prev[:])
+		c.prev = saved
+	}
+}
+
+// xorBytes dst = a ⊕ b для len(b) байт
+func xorBytes(dst, a, b []byte) {
+	for i := range b {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
+// ---------------------------------------------------------------------------------------
+// Потоковые режимы CTR, CFB и OFB: без дополнения, шифртекст той же длины
+
+type streamMode struct {
+	id        byte
+	name      string
+	newStream func(b cipher.Block, iv []byte, encrypt bool) cipher.Stream
+}
+
+func (m *streamMode) Name() string   { return m.name }
+func (m *streamMode) ID() byte       { return m.id }
+func (m *streamMode) ChunkSize() int { return 0 }
+func (m *streamMode) IVSize() int    { return AESBlockSize }
+
+func (m *streamMode) Encrypt(w io.Writer, r io.Reader, key, iv, _ []byte) error {
+	return m.crypt(w, r, -1, key, iv, true)
+}
+
+func (m *streamMode) Decrypt(w io.Writer, r io.Reader, size int64, key, iv, _ []byte) error {
+	return m.crypt(w, r, size, key, iv, false)
+}
+
+// crypt обрабатывает size байт из r, при size < 0 — до конца данных
+func (m *streamMode) crypt(w io.Writer, r io.Reader, size int64, key, iv []byte, encrypt bool) error {
+	block, err := NewAES(key)
+	if err != nil {
+		return err
+	}
+	defer block.Destroy()
+	stream := m.newStream(block, iv, encrypt)
+
+	if size >= 0 {
+		r = io.LimitReader(r, size)
+	}
+	buffer := make([]byte, fileBufferSize)
+	for {
+		n, last, err := readChunk(r, buffer)
+		if err != nil {
+			return err
+		}
+		stream.XORKeyStream(buffer[:n], buffer[:n])
+		if _, err := w.Write(buffer[:n]); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// keystream общая часть потоковых режимов: next вычисляет очередной блок
+// гаммы, для CFB — с учетом предыдущего блока шифртекста
+type keystream struct {
+	b    cipher.Block
+	reg  [AESBlockSize]byte // счетчик или регистр сдвига
+	out  [AESBlockSize]byte // текущий блок гаммы
+	used int
+}
+
+// ctr гамма E(IV), E(IV+1), ...; счетчик — все 128 бит, big-endian
+type ctr struct{ keystream }
+
+func newCTR(b cipher.Block, iv []byte, _ bool) cipher.Stream {
+	c := &ctr{keystream{b: b, used: AESBlockSize}}
+	copy(c.reg[:], iv)
+	return c
+}
+
+func (c *ctr) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.used == AESBlockSize {
+			c.b.Encrypt(c.out[:], c.reg[:])
+			for j := AESBlockSize - 1; j >= 0; j-- {
+				c.reg[j]++
+				if c.reg[j] != 0 {
+					break
+				}
+			}
+			c.used = 0
+		}
+		dst[i] = src[i] ^ c.out[c.used]
+		c.used++
+	}
+}
+
+// ofb гамма E(IV), E(E(IV)), ... не зависит от данных
+type ofb struct{ keystream }
+
+func newOFB(b cipher.Block, iv []byte) cipher.Stream {
+	o := &ofb{keystream{b: b, used: AESBlockSize}}
+	copy(o.out[:], iv)
+	return o
+}
+
+func (o *ofb) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if o.used == AESBlockSize {
+			o.b.Encrypt(o.out[:], o.out[:])
+			o.used = 0
+		}
+		dst[i] = src[i] ^ o.out[o.used]
+		o.used++
+	}
+}
+
+// cfb полноблочный CFB: C_i = P_i ⊕ E(C_{i-1}), C_0 = IV. В reg
+// накапливается текущий блок шифртекста.
+type cfb struct {
+	keystream
+	encrypt bool
+}
+
+func newCFB(b cipher.Block, iv []byte, encrypt bool) cipher.Stream {
+	c := &cfb{keystream{b: b, used: AESBlockSize}, encrypt}
+	copy(c.reg[:], iv)
+	return c
+}
+
+func (c *cfb) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.used == AESBlockSize {
+			c.b.Encrypt(c.out[:], c.reg[:])
+			c.used = 0
+		}
+		in := src[i]
+		dst[i] = in ^ c.out[c.used]
+		if c.encrypt {
+			c.reg[c.used] = dst[i]
+		} else {
+			c.reg[c.used] = in
+		}
+		c.used++
+	}
+}
+
+// ---------------------------------------------------------------------------------------
+// GCM: шифртекст той же длины и тег; заголовок файла — ассоциированные данные
+
+type gcmMode struct{}
+
+func (gcmMode) Name() string   { return ModeGCM }
+func (gcmMode) ID() byte       { return modeIDGCM }
+func (gcmMode) ChunkSize() int { return 0 }
+func (gcmMode) IVSize() int    { return GCMNonceSize }
+
+func (gcmMode) Encrypt(w io.Writer, r io.Reader, key, iv, header []byte) error {
+	block, err := NewAES(key)
+	if err != nil {
+		return err
+	}
+	defer block.Destroy()
+	stream, err := newGCMStream(block, iv, header, true)
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, fileBufferSize)
+	for {
+		n, last, err := readChunk(r, buffer)
+		if err != nil {
+			return err
+		}
+		if err := stream.crypt(buffer[:n], buffer[:n]); err != nil {
+			return err
+		}
+		if _, err := w.Write(buffer[:n]); err != nil {
+			return err
+		}
+		if last {
+			break
+		}
+	}
+	_, err = w.Write(stream.tag())
+	return err
+}
+
+// Decrypt пишет в w непроверенный открытый текст: вызывающий должен
+// отбросить его, если возвращена ошибка
+func (gcmMode) Decrypt(w io.Writer, r io.Reader, size int64, key, iv, header []byte) error {
+	bodySize := size - GCMTagSize
+	if bodySize < 0 {
+		return errors.New("invalid encrypted file size")
+	}
+	if bodySize > gcmMaxPlaintext {
+		return errGCMTooLong
+	}
+	block, err := NewAES(key)
+	if err != nil {
+		return err
+	}
+	defer block.Destroy()
+	stream, err := newGCMStream(block, iv, header, false)
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, fileBufferSize)
+	for remaining := bodySize; remaining > 0; {
+		chunk := buffer[:min(int64(len(buffer)), remaining)]
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return err
+		}
+		if err := stream.crypt(chunk, chunk); err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		remaining -= int64(len(chunk))
+	}
+	tag := make([]byte, GCMTagSize)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(stream.tag(), tag) != 1 {
+		return errAuthFailed
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// blockModeStream cipher.BlockMode как cipher.Stream для данных целыми блоками
+type blockModeStream struct{ cipher.BlockMode }
+
+func (s blockModeStream) XORKeyStream(dst, src []byte) { s.CryptBlocks(dst, src) }
+
+func stdBlock(t *testing.T, key []byte) cipher.Block {
+	t.Helper()
+	b, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func ownBlock(t *testing.T, key []byte) cipher.Block {
+	t.Helper()
+	a, err := NewAES(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// TestModesAgainstStdlib сравнивает шифртекст собственных режимов с
+// crypto/cipher поверх crypto/aes на случайных данных, поданных частями
+// случайной длины
+func TestModesAgainstStdlib(t *testing.T) {
+	// Блочные режимы приводятся к cipher.Stream для общего цикла проверки
+	blockStream := func(mode cipher.BlockMode) cipher.Stream { return blockModeStream{mode} }
+	pairs := []struct {
+		name     string
+		own, std func(key, iv []byte) cipher.Stream
+	}{
+		{"ECB",
+			func(key, _ []byte) cipher.Stream { return blockStream(ecb{ownBlock(t, key), true}) },
+			func(key, _ []byte) cipher.Stream { return blockStream(ecb{stdBlock(t, key), true}) }},
+		{"CBC",
+			func(key, iv []byte) cipher.Stream { return blockStream(newCBC(ownBlock(t, key), iv, true)) },
+			func(key, iv []byte) cipher.Stream { return blockStream(cipher.NewCBCEncrypter(stdBlock(t, key), iv)) }},
+		{"CTR",
+			func(key, iv []byte) cipher.Stream { return newCTR(ownBlock(t, key), iv, true) },
+			func(key, iv []byte) cipher.Stream { return cipher.NewCTR(stdBlock(t, key), iv) }},
+		{"CFB",
+			func(key, iv []byte) cipher.Stream { return newCFB(ownBlock(t, key), iv, true) },
+			func(key, iv []byte) cipher.Stream { return cipher.NewCFBEncrypter(stdBlock(t, key), iv) }},
+		{"OFB",
+			func(key, iv []byte) cipher.Stream { return newOFB(ownBlock(t, key), iv) },
+			func(key, iv []byte) cipher.Stream { return cipher.NewOFB(stdBlock(t, key), iv) }},
+	}
+	for _, p := range pairs {
+		for i := 0; i < 50; i++ {
+			key := randomBytes(16 + 8*(i%3))
+			iv := randomBytes(AESBlockSize)
+			if i == 0 {
+				iv = bytes.Repeat([]byte{0xff}, AESBlockSize) // перенос счетчика CTR
+			}
+			size := i * 7
+			if p.name == "ECB" || p.name == "CBC" {
+				size = i * AESBlockSize
+			}
+			plain := randomBytes(size)
+			own, std := p.own(key, iv), p.std(key, iv)
+			got, want := make([]byte, size), make([]byte, size)
+			// Части кратны блоку для блочных режимов, иначе произвольны
+			for off := 0; off < size; {
+				n := min(size-off, AESBlockSize*(1+i%3)+(size%AESBlockSize)*(i%2))
+				own.XORKeyStream(got[off:off+n], plain[off:off+n])
+				off += n
+			}
+			std.XORKeyStream(want, plain)
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s, %d байт: получено %x, ожидается %x", p.name, size, got, want)
+			}
+		}
+	}
+}
+
+// TestModesDecrypt: расшифровка — обратное преобразование
+func TestModesDecrypt(t *testing.T) {
+	blockStream := func(mode cipher.BlockMode) cipher.Stream { return blockModeStream{mode} }
+	key, iv := randomBytes(32), randomBytes(AESBlockSize)
+	plain := randomBytes(10 * AESBlockSize)
+	for _, m := range []struct {
+		name     string
+		enc, dec cipher.Stream
+	}{
+		{"ECB", blockStream(ecb{ownBlock(t, key), true}), blockStream(ecb{ownBlock(t, key), false})},
+		{"CBC", blockStream(newCBC(ownBlock(t, key), iv, true)), blockStream(newCBC(ownBlock(t, key), iv, false))},
+		{"CFB", newCFB(ownBlock(t, key), iv, true), newCFB(ownBlock(t, key), iv, false)},
+	} {
+		data := bytes.Clone(plain)
+		m.enc.XORKeyStream(data, data)
+		m.dec.XORKeyStream(data, data)
+		if !bytes.Equal(data, plain) {
+			t.Fatalf("%s: расшифровка не совпадает с открытым текстом", m.name)
+		}
+	}
+}
+
+func TestModeByName(t *testing.T) {
+	for _, m := range modes {
+		byName, err := modeByName(m.Name())
+		if err != nil || byName != m {
+			t.Fatalf("%s: modeByName вернул %v, %v", m.Name(), byName, err)
+		}
+		byID, err := modeByID(m.ID())
+		if err != nil || byID != m {
+			t.Fatalf("%s: modeByID вернул %v, %v", m.Name(), byID, err)
+		}
+	}
+	if _, err := modeByName("pcbc"); err == nil {
+		t.Error("неизвестный режим принят")
+	}
+}
+
+// fileRoundTrip шифрует и расшифровывает файл из size случайных байт и
+// возвращает путь к зашифрованному файлу
+func fileRoundTrip(t *testing.T, size int, mode string) string {
+	t.Helper()
+	dir := t.TempDir()
+	key := randomBytes(32)
+	plain := filepath.Join(dir, "plain")
+	encrypted := filepath.Join(dir, "encrypted")
+	decrypted := filepath.Join(dir, "decrypted")
+	data := randomBytes(size)
+	if err := os.WriteFile(plain, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := encryptFile(plain, encrypted, key, AESKeySize256, mode); err != nil {
+		t.Fatalf("%s, %d байт: %v", mode, size, err)
+	}
+	if err := decryptFile(encrypted, decrypted, key, AESKeySize256); err != nil {
+		t.Fatalf("%s, %d байт: %v", mode, size, err)
+	}
+	got, err := os.ReadFile(decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("%s, %d байт: расшифрованный файл не совпадает с исходным", mode, size)
+	}
+	return encrypted
+}
+
+// TestModeFiles шифрует и расшифровывает файлы во всех режимах. Для XTS —
+// границы секторов и хвост меньше блока.
+func TestModeFiles(t *testing.T) {
+	sizes := map[string][]int{
+		ModeGCM: {0, 1, 17, 100000},
+		ModeECB: {0, 1, 16, 100000},
+		ModeCBC: {0, 1, 16, 100000},
+		ModeCTR: {0, 1, 15, 16, 17, 100000},
+		ModeCFB: {0, 1, 15, 16, 17, 100000},
+		ModeOFB: {0, 1, 15, 16, 17, 100000},
+		ModeXTS: {0, 16, 17, 511, 512, 513, 527, 528, 529, 100000},
+	}
+	for _, m := range modes {
+		for _, size := range sizes[m.Name()] {
+			fileRoundTrip(t, size, m.Name())
+		}
+	}
+}
@@ -1,3 +1,7 @@
+//go:build ignore
+
+// Ранняя нерабочая версия, оставлена для сравнения и в сборку не входит.
+
 package main
 
 // Константы AES
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPKCS7(t *testing.T) {
+	for n := 0; n <= 2*AESBlockSize; n++ {
+		data := randomBytes(n)
+		buffer := append(bytes.Clone(data), make([]byte, AESBlockSize)...)
+		padded := pkcs7Pad(buffer, n)
+		if padded%AESBlockSize != 0 || padded <= n || padded > n+AESBlockSize {
+			t.Fatalf("%d байт: длина с дополнением %d", n, padded)
+		}
+		last, err := pkcs7Unpad(buffer[padded-AESBlockSize : padded])
+		if err != nil {
+			t.Fatalf("%d байт: %v", n, err)
+		}
+		if got := append(bytes.Clone(buffer[:padded-AESBlockSize]), last...); !bytes.Equal(got, data) {
+			t.Fatalf("%d байт: после снятия дополнения данные не совпадают", n)
+		}
+	}
+	if last, err := pkcs7Unpad(unhex("01020304050607080910111213030303")); err != nil || len(last) != 13 {
+		t.Fatalf("верное дополнение 3: %v", err)
+	}
+}
+
+// badPadding последние открытые блоки с неверным дополнением
+var badPadding = []string{
+	"00000000000000000000000000000000", // дополнение 0
+	"00000000000000000000000000000011", // больше блока
+	"00000000000000000000000000030203", // байт внутри дополнения неверен
+	"0f101010101010101010101010101010", // 16 с неверным первым байтом
+}
+
+func TestPKCS7Invalid(t *testing.T) {
+	for _, b := range append(badPadding, "0202") {
+		if _, err := pkcs7Unpad(unhex(b)); !errors.As(err, &PaddingError{}) {
+			t.Errorf("блок %s: дополнение принято", b)
+		}
+	}
+}
+
+// TestPaddingFiles: дополнение добавляется всегда, в том числе к файлам
+// кратной блоку длины (раньше они теряли конец при расшифровке)
+func TestPaddingFiles(t *testing.T) {
+	for _, mode := range []string{ModeECB, ModeCBC} {
+		m, err := modeByName(mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, size := range []int{0, 1, 15, 16, 17, 32} {
+			// Последний байт 1 — то, что раньше отрезалось как дополнение
+			data := bytes.Repeat([]byte{1}, size)
+			dir := t.TempDir()
+			plain := filepath.Join(dir, "plain")
+			encrypted := filepath.Join(dir, "encrypted")
+			decrypted := filepath.Join(dir, "decrypted")
+			key := randomBytes(16)
+			if err := os.WriteFile(plain, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := encryptFile(plain, encrypted, key, AESKeySize128, mode); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := int64(headerSize(m) + (size/AESBlockSize+1)*AESBlockSize); info.Size() != want {
+				t.Fatalf("%s, %d байт: длина шифртекста %d, ожидается %d", mode, size, info.Size(), want)
+			}
+			if err := decryptFile(encrypted, decrypted, key, AESKeySize128); err != nil {
+				t.Fatalf("%s, %d байт: %v", mode, size, err)
+			}
+			got, err := os.ReadFile(decrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("%s, %d байт: расшифровано %d байт", mode, size, len(got))
+			}
+		}
+	}
+}
+
+// TestBadPaddingFile: неверное дополнение дает PaddingError и выходной
+// файл не создается
+func TestBadPaddingFile(t *testing.T) {
+	key := randomBytes(16)
+	a, err := NewAES(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	encrypted := filepath.Join(dir, "encrypted")
+	decrypted := filepath.Join(dir, "decrypted")
+	for _, b := range badPadding {
+		block := unhex(b)
+		a.Encrypt(block, block)
+		h := &fileHeader{Version: containerVersion, Cipher: cipherAES, Mode: modeIDECB, KeyBits: 128}
+		if err := os.WriteFile(encrypted, append(h.seal(key), block...), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := decryptFile(encrypted, decrypted, key, AESKeySize128); !errors.As(err, &PaddingError{}) {
+			t.Fatalf("блок %s: получено %v", b, err)
+		}
+		if _, err := os.Stat(decrypted); !os.IsNotExist(err) {
+			t.Fatalf("блок %s: создан выходной файл", b)
+		}
+	}
+}
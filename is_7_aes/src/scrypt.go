@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/bits"
+)
+
+// scrypt (RFC 7914): PBKDF2-HMAC-SHA256 растягивает пароль в p блоков по
+// 128*r байт, каждый перемешивается ROMix — N раз записывается в таблицу
+// и N раз читается по зависящим от данных индексам, поэтому вычисление
+// требует 128*r*N байт памяти. Перемешивание — BlockMix на основе Salsa20/8.
+
+// scryptKey ключ длины keyLen; N — степень двойки больше 1
+func scryptKey(password, salt []byte, n, r, p, keyLen int) ([]byte, error) {
+	blockWords := 32 * r // слов uint32 в блоке 128*r байт
+	b, err := pbkdf2.Key(sha256.New, string(password), salt, 1, p*4*blockWords)
+	if err != nil {
+		return nil, err
+	}
+	x := make([]uint32, blockWords)
+	y := make([]uint32, blockWords)
+	v := make([]uint32, n*blockWords)
+	for i := 0; i < p; i++ {
+		chunk := b[i*4*blockWords : (i+1)*4*blockWords]
+		for j := range x {
+			x[j] = binary.LittleEndian.Uint32(chunk[4*j:])
+		}
+		scryptROMix(x, y, v, n, r)
+		for j, w := range x {
+			binary.LittleEndian.PutUint32(chunk[4*j:], w)
+		}
+	}
+	clear(v)
+	key, err := pbkdf2.Key(sha256.New, string(password), b, 1, keyLen)
+	clear(b)
+	return key, err
+}
+
+// scryptROMix перемешивает x на месте; y — рабочий буфер того же размера,
+// v — таблица из n блоков
+func scryptROMix(x, y, v []uint32, n, r int) {
+	size := len(x)
+	for i := 0; i < n; i++ {
+		copy(v[i*size:], x)
+		scryptBlockMix(x, y, r)
+	}
+	for i := 0; i < n; i++ {
+		// Integerify: первое слово последнего 64-байтного подблока
+		j := int(x[size-16] & uint32(n-1))
+		for k, w := range v[j*size : (j+1)*size] {
+			x[k] ^= w
+		}
+		scryptBlockMix(x, y, r)
+	}
+}
+
+// scryptBlockMix: X = B[2r-1], Y_i = Salsa20/8(X ⊕ B_i); результат — сначала
+// Y с четными номерами, затем с нечетными
+func scryptBlockMix(b, y []uint32, r int) {
+	var t [16]uint32
+	copy(t[:], b[(2*r-1)*16:])
+	for i := 0; i < 2*r; i++ {
+		for k := range t {
+			t[k] ^= b[i*16+k]
+		}
+		salsa208(&t)
+		dst := (i/2 + i%2*r) * 16
+		copy(y[dst:], t[:])
+	}
+	copy(b, y)
+}
+
+// salsa208 ядро Salsa20 с 8 раундами
+func salsa208(b *[16]uint32) {
+	x := *b
+	quarter := func(a, c, d, e int) {
+		x[c] ^= bits.RotateLeft32(x[a]+x[e], 7)
+		x[d] ^= bits.RotateLeft32(x[c]+x[a], 9)
+		x[e] ^= bits.RotateLeft32(x[d]+x[c], 13)
+		x[a] ^= bits.RotateLeft32(x[e]+x[d], 18)
+	}
+	for i := 0; i < 8; i += 2 {
+		// Столбцы
+		quarter(0, 4, 8, 12)
+		quarter(5, 9, 13, 1)
+		quarter(10, 14, 2, 6)
+		quarter(15, 3, 7, 11)
+		// Строки
+		quarter(0, 1, 2, 3)
+		quarter(5, 6, 7, 4)
+		quarter(10, 11, 8, 9)
+		quarter(15, 12, 13, 14)
+	}
+	for i := range b {
+		b[i] += x[i]
+	}
+}
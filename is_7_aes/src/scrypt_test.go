@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestScrypt примеры 1 и 2 из раздела 12 RFC 7914
+func TestScrypt(t *testing.T) {
+	vectors := []struct {
+		password, salt string
+		n, r, p        int
+		want           string
+	}{
+		{"", "", 16, 1, 1, "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442" +
+			"fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906"},
+		{"password", "NaCl", 1024, 8, 16, "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b373162" +
+			"2eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640"},
+	}
+	for _, v := range vectors {
+		got, err := scryptKey([]byte(v.password), []byte(v.salt), v.n, v.r, v.p, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, unhex(v.want)) {
+			t.Errorf("N=%d: получено %x, ожидается %s", v.n, got, v.want)
+		}
+	}
+}
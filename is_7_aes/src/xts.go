@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Режим XTS (IEEE 1619, NIST SP 800-38E) для шифрования секторов диска.
+// Ключ делится пополам: K1 шифрует данные, K2 — номер сектора, из которого
+// получается tweak. Каждый сектор шифруется независимо, поэтому сектор
+// можно расшифровать или перезаписать отдельно; шифртекст той же длины,
+// неполный последний блок обрабатывается заимствованием шифртекста.
+//
+// Файл делится на секторы по xtsSectorSize байт, номера с 0. Последний
+// сектор может быть короче, но не короче блока: хвост меньше 16 байт
+// присоединяется к предыдущему сектору. Файл короче блока XTS не шифрует.
+// IV нет: одинаковые данные в секторе с тем же номером дают одинаковый
+// шифртекст, как на диске.
+
+const xtsSectorSize = 512
+
+// XTSKeySize512 ключ XTS-AES-256: два ключа AES-256
+const XTSKeySize512 AESKeySize = 64
+
+type xts struct {
+	k1, k2 *AES
+}
+
+// newXTS ключ из двух ключей AES одной длины: 32 байта (XTS-AES-128) или
+// 64 байта (XTS-AES-256)
+func newXTS(key []byte) (*xts, error) {
+	if len(key) != 32 && len(key) != 64 {
+		return nil, fmt.Errorf("XTS: ключ %d байт, нужен ключ 256 бит (два ключа AES-128) или 512 бит", len(key))
+	}
+	k1, err := NewAES(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+	k2, err := NewAES(key[len(key)/2:])
+	if err != nil {
+		k1.Destroy()
+		return nil, err
+	}
+	return &xts{k1, k2}, nil
+}
+
+func (x *xts) Destroy() {
+	x.k1.Destroy()
+	x.k2.Destroy()
+}
+
+// tweak T = E_K2(номер сектора), номер — 128 бит little-endian
+func (x *xts) tweak(sector uint64) [AESBlockSize]byte {
+	var t [AESBlockSize]byte
+	binary.LittleEndian.PutUint64(t[:], sector)
+	x.k2.Encrypt(t[:], t[:])
+	return t
+}
+
+// mulAlpha умножение tweak на α (x) в GF(2^128) с младшим битом в первом байте
+func mulAlpha(t *[AESBlockSize]byte) {
+	carry := t[AESBlockSize-1] >> 7
+	for i := AESBlockSize - 1; i > 0; i-- {
+		t[i] = t[i]<<1 | t[i-1]>>7
+	}
+	t[0] = t[0]<<1 ^ 0x87&-carry
+}
+
+// cryptBlock C = E_K1(P ⊕ T) ⊕ T или обратное преобразование
+func (x *xts) cryptBlock(dst, src []byte, t *[AESBlockSize]byte, encrypt bool) {
+	var buf [AESBlockSize]byte
+	xorBytes(buf[:], src, t[:])
+	if encrypt {
+		x.k1.Encrypt(buf[:], buf[:])
+	} else {
+		x.k1.Decrypt(buf[:], buf[:])
+	}
+	xorBytes(dst, buf[:], t[:])
+}
+
+// cryptSector шифрует или расшифровывает сектор произвольной длины не
+// меньше блока; dst и src могут совпадать
+func (x *xts) cryptSector(dst, src []byte, sector uint64, encrypt bool) {
+	if len(src) < AESBlockSize {
+		panic("xts: сектор короче блока")
+	}
+	t := x.tweak(sector)
+	full := len(src) / AESBlockSize
+	tail := len(src) % AESBlockSize
+	if tail != 0 {
+		full-- // последний полный блок участвует в заимствовании
+	}
+	for i := 0; i < full; i++ {
+		x.cryptBlock(dst[i*AESBlockSize:], src[i*AESBlockSize:], &t, encrypt)
+		mulAlpha(&t)
+	}
+	if tail == 0 {
+		return
+	}
+
+	// Заимствование шифртекста: последний полный блок обрабатывается, его
+	// начало становится хвостом, а хвост с остатком — последним полным
+	// блоком. При расшифровке tweak этих двух блоков идут в обратном порядке.
+	off := full * AESBlockSize
+	first, second := t, t
+	mulAlpha(&second)
+	if !encrypt {
+		first, second = second, first
+	}
+	var cc, pp [AESBlockSize]byte
+	x.cryptBlock(cc[:], src[off:], &first, encrypt)
+	copy(pp[:], src[off+AESBlockSize:])
+	copy(pp[tail:], cc[tail:])
+	copy(dst[off+AESBlockSize:], cc[:tail])
+	x.cryptBlock(dst[off:], pp[:], &second, encrypt)
+}
+
+type xtsMode struct{}
+
+func (xtsMode) Name() string   { return ModeXTS }
+func (xtsMode) ID() byte       { return modeIDXTS }
+func (xtsMode) IVSize() int    { return 0 }
+func (xtsMode) ChunkSize() int { return xtsSectorSize }
+
+// Encrypt читает по сектору с запасом в блок, чтобы хвост меньше блока
+// присоединить к последнему сектору
+func (xtsMode) Encrypt(w io.Writer, r io.Reader, key, _, _ []byte) error {
+	x, err := newXTS(key)
+	if err != nil {
+		return err
+	}
+	defer x.Destroy()
+
+	buffer := make([]byte, xtsSectorSize+AESBlockSize)
+	filled := 0
+	for sector := uint64(0); ; sector++ {
+		n, last, err := readChunk(r, buffer[filled:])
+		if err != nil {
+			return err
+		}
+		filled += n
+		size := xtsSectorSize
+		if last {
+			size = filled
+		}
+		if size == 0 {
+			return nil
+		}
+		if size < AESBlockSize {
+			return errors.New("XTS: файл короче 16 байт")
+		}
+		x.cryptSector(buffer[:size], buffer[:size], sector, true)
+		if _, err := w.Write(buffer[:size]); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		filled = copy(buffer, buffer[size:filled])
+	}
+}
+
+func (xtsMode) Decrypt(w io.Writer, r io.Reader, size int64, key, _, _ []byte) error {
+	if size > 0 && size < AESBlockSize {
+		return errors.New("invalid encrypted file size")
+	}
+	x, err := newXTS(key)
+	if err != nil {
+		return err
+	}
+	defer x.Destroy()
+
+	buffer := make([]byte, xtsSectorSize+AESBlockSize)
+	for sector, remaining := uint64(0), size; remaining > 0; sector++ {
+		n := int64(xtsSectorSize)
+		if remaining < xtsSectorSize+AESBlockSize {
+			n = remaining
+		}
+		chunk := buffer[:n]
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return err
+		}
+		x.cryptSector(chunk, chunk, sector, false)
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestXTSVectors векторы 1-3 и 15-18 (с заимствованием шифртекста) из
+// приложения B IEEE 1619-2007
+func TestXTSVectors(t *testing.T) {
+	const (
+		key3   = "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0"
+		key15  = key3 + "bfbebdbcbbbab9b8b7b6b5b4b3b2b1b0"
+		plain2 = "4444444444444444444444444444444444444444444444444444444444444444"
+		steal  = "000102030405060708090a0b0c0d0e0f10111213"
+	)
+	vectors := []struct {
+		name   string
+		key    string
+		sector uint64
+		plain  string
+		cipher string
+	}{
+		{"1", strings.Repeat("00", 32), 0, strings.Repeat("00", 32),
+			"917cf69ebd68b2ec9b9fe9a3eadda692cd43d2f59598ed858c02c2652fbf922e"},
+		{"2", strings.Repeat("11", 16) + strings.Repeat("22", 16), 0x3333333333, plain2,
+			"c454185e6a16936e39334038acef838bfb186fff7480adc4289382ecd6d394f0"},
+		{"3", key3 + strings.Repeat("22", 16), 0x3333333333, plain2,
+			"af85336b597afc1a900b2eb21ec949d292df4c047e0b21532186a5971a227a89"},
+		{"15", key15, 0x123456789a, steal[:34], "6c1625db4671522d3d7599601de7ca09ed"},
+		{"16", key15, 0x123456789a, steal[:36], "d069444b7a7e0cab09e24447d24deb1fedbf"},
+		{"17", key15, 0x123456789a, steal[:38], "e5df1351c0544ba1350b3363cd8ef4beedbf9d"},
+		{"18", key15, 0x123456789a, steal[:40], "9d84c813f719aa2c7be3f66171c7c5c2edbf9dac"},
+	}
+	for _, v := range vectors {
+		x, err := newXTS(unhex(v.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain := unhex(v.plain)
+		got := make([]byte, len(plain))
+		x.cryptSector(got, plain, v.sector, true)
+		if !bytes.Equal(got, unhex(v.cipher)) {
+			t.Fatalf("вектор %s: получено %x, ожидается %s", v.name, got, v.cipher)
+		}
+		x.cryptSector(got, got, v.sector, false)
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("вектор %s: расшифровано %x", v.name, got)
+		}
+	}
+}
+
+// TestXTSShortFile: XTS не шифрует файлы короче блока
+func TestXTSShortFile(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain")
+	if err := os.WriteFile(plain, randomBytes(15), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := encryptFile(plain, filepath.Join(dir, "encrypted"), randomBytes(32), AESKeySize256, ModeXTS); err == nil {
+		t.Fatal("файл из 15 байт зашифрован")
+	}
+}
+
+// TestXTSKeySizes: XTS-AES-256 с ключом 512 бит из файла ключа и из
+// пароля; длина ключа проверяется для XTS так же, как для других режимов
+func TestXTSKeySizes(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain")
+	encrypted := filepath.Join(dir, "encrypted")
+	decrypted := filepath.Join(dir, "decrypted")
+	data := randomBytes(1000)
+	if err := os.WriteFile(plain, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(dir, "key")
+	if err := saveKeyToFile(randomBytes(64), keyFile); err != nil {
+		t.Fatal(err)
+	}
+	key, err := loadKeyFromFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := encryptFile(plain, encrypted, key, XTSKeySize512, ModeXTS); err != nil {
+		t.Fatal(err)
+	}
+	if err := decryptFile(encrypted, decrypted, key, XTSKeySize512); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(decrypted); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("XTS-AES-256: расшифрованный файл не совпадает с исходным (%v)", err)
+	}
+
+	bad := []struct {
+		name string
+		key  []byte
+		size AESKeySize
+		mode string
+	}{
+		{"xts, 128 бит", randomBytes(16), AESKeySize128, ModeXTS},
+		{"xts, -keysize не совпадает с ключом", randomBytes(64), AESKeySize256, ModeXTS},
+		{"gcm, 512 бит", randomBytes(64), XTSKeySize512, ModeGCM},
+	}
+	for _, b := range bad {
+		if err := encryptFile(plain, filepath.Join(dir, "bad"), b.key, b.size, b.mode); err == nil {
+			t.Errorf("%s: файл зашифрован", b.name)
+		}
+	}
+	if err := decryptFile(encrypted, decrypted, key, AESKeySize256); err == nil {
+		t.Error("расшифровка с несовпадающей длиной ключа")
+	}
+
+	params, err := parseKDFParams(kdfPBKDF2, "i=1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := []byte("password")
+	if err := encryptFileWithPassword(plain, encrypted, password, params, 512, ModeXTS); err != nil {
+		t.Fatal(err)
+	}
+	if err := decryptFileWithPassword(encrypted, decrypted, password, defaultMaxKDFMemory); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(decrypted); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("XTS-AES-256 по паролю: расшифрованный файл не совпадает с исходным (%v)", err)
+	}
+	if err := encryptFileWithPassword(plain, encrypted, password, params, 512, ModeGCM); err == nil {
+		t.Error("gcm с ключом 512 бит из пароля")
+	}
+}